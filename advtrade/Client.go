@@ -0,0 +1,695 @@
+package advtrade
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"golang.org/x/time/rate"
+)
+
+//BaseURL is the production Advanced Trade REST api
+const BaseURL = "https://api.coinbase.com"
+
+const brokerage = "/api/v3/brokerage"
+
+//maxCandles is the most candles one candles request returns
+const maxCandles = 350
+
+//Client is an exchange.Exchange over the Coinbase Advanced Trade REST api, the successor of Coinbase Pro.
+//Requests are signed by Signer, without one they are sent unauthenticated, e.g. to a local mock.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	signer     *Signer
+	limiter    *rate.Limiter
+}
+
+func NewClient(baseURL string, signer *Signer) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		signer:     signer,
+		limiter:    rate.NewLimiter(rate.Inf, 0),
+	}
+}
+
+//SetRateLimit waits on a token bucket before every request, a rate of 0 turns it off
+func (c *Client) SetRateLimit(perSecond float64, burst int) {
+	if perSecond <= 0 {
+		c.limiter = rate.NewLimiter(rate.Inf, 0)
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+func (c *Client) Name() string {
+	return "advanced-trade"
+}
+
+//apiError is the body of a non 2xx answer
+type apiError struct {
+	Error        string `json:"error"`
+	Message      string `json:"message"`
+	ErrorDetails string `json:"error_details"`
+}
+
+//do sends a signed request to path under /api/v3/brokerage and decodes the json answer into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	u, err := url.Parse(c.BaseURL + brokerage + path)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.signer != nil {
+		token, err := c.signer.Token(method, u.Host, u.Path)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e apiError
+		json.Unmarshal(b, &e)
+		message := e.Message
+		if message == "" {
+			message = e.Error
+		}
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &exchange.HTTPError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: exchange.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:        errors.New(message),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+type product struct {
+	ProductID       string `json:"product_id"`
+	BaseCurrencyID  string `json:"base_currency_id"`
+	QuoteCurrencyID string `json:"quote_currency_id"`
+	BaseMinSize     string `json:"base_min_size"`
+	BaseMaxSize     string `json:"base_max_size"`
+	BaseIncrement   string `json:"base_increment"`
+	QuoteIncrement  string `json:"quote_increment"`
+	QuoteMinSize    string `json:"quote_min_size"`
+	Status          string `json:"status"`
+	TradingDisabled bool   `json:"trading_disabled"`
+	IsDisabled      bool   `json:"is_disabled"`
+	CancelOnly      bool   `json:"cancel_only"`
+	LimitOnly       bool   `json:"limit_only"`
+	PostOnly        bool   `json:"post_only"`
+}
+
+//productStatus the flags are checked first, the status stays online while they are set
+func productStatus(p product) exchange.ProductStatus {
+	switch {
+	case p.CancelOnly:
+		return exchange.ProductCancelOnly
+	case p.LimitOnly:
+		return exchange.ProductLimitOnly
+	case p.PostOnly:
+		return exchange.ProductPostOnly
+	case p.Status != "" && p.Status != "online":
+		return exchange.ProductOffline
+	}
+	return exchange.ProductOnline
+}
+
+func (c *Client) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	var resp struct {
+		Products []product `json:"products"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/products", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Product, 0, len(resp.Products))
+	for _, p := range resp.Products {
+		out = append(out, exchange.Product{
+			ID:             p.ProductID,
+			BaseCurrency:   p.BaseCurrencyID,
+			QuoteCurrency:  p.QuoteCurrencyID,
+			BaseMinSize:    parseOptional(p.BaseMinSize),
+			BaseMaxSize:    parseOptional(p.BaseMaxSize),
+			BaseIncrement:  parseOptional(p.BaseIncrement),
+			QuoteIncrement: parseOptional(p.QuoteIncrement),
+			MinFunds:       parseOptional(p.QuoteMinSize),
+			Status:         productStatus(p),
+			Disabled:       p.TradingDisabled || p.IsDisabled,
+		})
+	}
+	return out, nil
+}
+
+type level struct {
+	Price string `json:"price"`
+	Size  string `json:"size"`
+}
+
+func (c *Client) GetBook(ctx context.Context, productID string) (exchange.Book, error) {
+	var resp struct {
+		Pricebook struct {
+			Bids []level `json:"bids"`
+			Asks []level `json:"asks"`
+		} `json:"pricebook"`
+	}
+	query := url.Values{"product_id": {productID}, "limit": {"1"}}
+	if err := c.do(ctx, http.MethodGet, "/product_book", query, nil, &resp); err != nil {
+		return exchange.Book{}, err
+	}
+	bids, err := levels(resp.Pricebook.Bids)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	asks, err := levels(resp.Pricebook.Asks)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	return exchange.Book{Bids: bids, Asks: asks}, nil
+}
+
+func levels(entries []level) ([]exchange.BookLevel, error) {
+	var out []exchange.BookLevel
+	for _, entry := range entries {
+		price, err := decimal.Parse(entry.Price)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.BookLevel{Price: price, Size: parseOptional(entry.Size)})
+	}
+	return out, nil
+}
+
+//granularities the candles endpoint takes, smallest first
+var granularities = []struct {
+	d    time.Duration
+	name string
+}{
+	{time.Minute, "ONE_MINUTE"},
+	{time.Minute * 5, "FIVE_MINUTE"},
+	{time.Minute * 15, "FIFTEEN_MINUTE"},
+	{time.Minute * 30, "THIRTY_MINUTE"},
+	{time.Hour, "ONE_HOUR"},
+	{time.Hour * 2, "TWO_HOUR"},
+	{time.Hour * 6, "SIX_HOUR"},
+	{time.Hour * 24, "ONE_DAY"},
+}
+
+//granularity names d, a zero d is the smallest granularity that covers start to end in one request
+func granularity(d time.Duration, start, end time.Time) (string, error) {
+	for _, g := range granularities {
+		if d == g.d || (d == 0 && end.Sub(start) <= g.d*maxCandles) {
+			return g.name, nil
+		}
+	}
+	if d == 0 {
+		return granularities[len(granularities)-1].name, nil
+	}
+	return "", fmt.Errorf("granularity %s is not supported by advanced trade", d)
+}
+
+func (c *Client) GetCandles(ctx context.Context, productID string, start, end time.Time, d time.Duration) ([]exchange.Candle, error) {
+	g, err := granularity(d, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Candles []struct {
+			Start  string `json:"start"`
+			Low    string `json:"low"`
+			High   string `json:"high"`
+			Open   string `json:"open"`
+			Close  string `json:"close"`
+			Volume string `json:"volume"`
+		} `json:"candles"`
+	}
+	query := url.Values{
+		"start":       {strconv.FormatInt(start.Unix(), 10)},
+		"end":         {strconv.FormatInt(end.Unix(), 10)},
+		"granularity": {g},
+	}
+	if err := c.do(ctx, http.MethodGet, "/products/"+url.PathEscape(productID)+"/candles", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Candle, 0, len(resp.Candles))
+	for _, candle := range resp.Candles {
+		seconds, err := strconv.ParseInt(candle.Start, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.Candle{
+			Time:   time.Unix(seconds, 0).UTC(),
+			Open:   parseFloat(candle.Open),
+			High:   parseFloat(candle.High),
+			Low:    parseFloat(candle.Low),
+			Close:  parseFloat(candle.Close),
+			Volume: parseFloat(candle.Volume),
+		})
+	}
+	//the api answers newest first
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+type amount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+func (c *Client) GetBalances(ctx context.Context) ([]exchange.Balance, error) {
+	var out []exchange.Balance
+	query := url.Values{"limit": {"250"}}
+	for {
+		var resp struct {
+			Accounts []struct {
+				Currency         string `json:"currency"`
+				AvailableBalance amount `json:"available_balance"`
+				Hold             amount `json:"hold"`
+			} `json:"accounts"`
+			HasNext bool   `json:"has_next"`
+			Cursor  string `json:"cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/accounts", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, a := range resp.Accounts {
+			if err := checkRange(a.AvailableBalance.Value, a.Hold.Value); err != nil {
+				return nil, err
+			}
+			available, hold := parseOptional(a.AvailableBalance.Value), parseOptional(a.Hold.Value)
+			total, err := available.Add(hold)
+			if err != nil {
+				return nil, fmt.Errorf("%s balance %s and hold %s: %w", a.Currency, available, hold, err)
+			}
+			out = append(out, exchange.Balance{
+				Currency:  a.Currency,
+				Total:     total,
+				Available: available,
+				Hold:      hold,
+			})
+		}
+		if !resp.HasNext || resp.Cursor == "" {
+			return out, nil
+		}
+		query.Set("cursor", resp.Cursor)
+	}
+}
+
+type marketIOC struct {
+	QuoteSize string `json:"quote_size,omitempty"`
+	BaseSize  string `json:"base_size,omitempty"`
+}
+
+type limitGTC struct {
+	BaseSize   string `json:"base_size"`
+	LimitPrice string `json:"limit_price"`
+	PostOnly   bool   `json:"post_only"`
+}
+
+type orderConfiguration struct {
+	MarketMarketIOC *marketIOC `json:"market_market_ioc,omitempty"`
+	LimitLimitGTC   *limitGTC  `json:"limit_limit_gtc,omitempty"`
+}
+
+type createOrder struct {
+	ClientOrderID      string             `json:"client_order_id"`
+	ProductID          string             `json:"product_id"`
+	Side               string             `json:"side"`
+	OrderConfiguration orderConfiguration `json:"order_configuration"`
+}
+
+//failureReasons are reworded so svc classifies them like the Coinbase Pro messages
+var failureReasons = map[string]string{
+	"INSUFFICIENT_FUND":         "insufficient funds",
+	"PREVIEW_INSUFFICIENT_FUND": "insufficient funds",
+	"INVALID_PRODUCT_ID":        "product not found",
+	"UNKNOWN_PRODUCT_ID":        "product not found",
+	"TRADING_DISABLED":          "trading disabled",
+	"CANCEL_ONLY":               "cancel only",
+	"INVALID_SIGNATURE":         "invalid signature",
+}
+
+func failure(reasons ...string) error {
+	for _, reason := range reasons {
+		if message, ok := failureReasons[reason]; ok {
+			return errors.New(message)
+		}
+	}
+	for _, reason := range reasons {
+		if reason != "" && !strings.HasPrefix(reason, "UNKNOWN_FAILURE") {
+			return errors.New(strings.ToLower(strings.ReplaceAll(reason, "_", " ")))
+		}
+	}
+	return errors.New("order rejected")
+}
+
+//PlaceOrder market orders are immediate or cancel, limit orders good til cancelled.
+//Amounts are sent exactly, a missing ClientID is generated.
+func (c *Client) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Order, error) {
+	create := createOrder{
+		ClientOrderID: req.ClientID,
+		ProductID:     req.Product,
+		Side:          strings.ToUpper(req.Side),
+	}
+	if create.ClientOrderID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return exchange.Order{}, err
+		}
+		create.ClientOrderID = hex.EncodeToString(id)
+	}
+	switch req.Type {
+	case exchange.TypeMarket:
+		create.OrderConfiguration.MarketMarketIOC = &marketIOC{}
+		if !req.Funds.IsZero() {
+			create.OrderConfiguration.MarketMarketIOC.QuoteSize = req.Funds.String()
+		} else {
+			create.OrderConfiguration.MarketMarketIOC.BaseSize = req.Size.String()
+		}
+	case exchange.TypeLimit:
+		create.OrderConfiguration.LimitLimitGTC = &limitGTC{
+			BaseSize:   req.Size.String(),
+			LimitPrice: req.Price.String(),
+		}
+	default:
+		return exchange.Order{}, fmt.Errorf("order type %q is not supported by advanced trade", req.Type)
+	}
+
+	var resp struct {
+		Success         bool   `json:"success"`
+		FailureReason   string `json:"failure_reason"`
+		OrderID         string `json:"order_id"`
+		SuccessResponse struct {
+			OrderID string `json:"order_id"`
+		} `json:"success_response"`
+		ErrorResponse struct {
+			Error                 string `json:"error"`
+			Message               string `json:"message"`
+			PreviewFailureReason  string `json:"preview_failure_reason"`
+			NewOrderFailureReason string `json:"new_order_failure_reason"`
+		} `json:"error_response"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/orders", nil, create, &resp); err != nil {
+		return exchange.Order{}, err
+	}
+	if !resp.Success {
+		e := resp.ErrorResponse
+		return exchange.Order{}, &exchange.HTTPError{
+			StatusCode: http.StatusBadRequest,
+			Err:        failure(e.NewOrderFailureReason, e.PreviewFailureReason, e.Error, resp.FailureReason),
+		}
+	}
+	id := resp.SuccessResponse.OrderID
+	if id == "" {
+		id = resp.OrderID
+	}
+	return exchange.Order{
+		ID:      id,
+		Product: req.Product,
+		Side:    req.Side,
+		Type:    req.Type,
+		Size:    req.Size,
+		Funds:   req.Funds,
+		Price:   req.Price,
+		Status:  exchange.StatusPending,
+		Reason:  "created",
+	}, nil
+}
+
+type order struct {
+	OrderID            string `json:"order_id"`
+	ProductID          string `json:"product_id"`
+	Side               string `json:"side"`
+	OrderType          string `json:"order_type"`
+	Status             string `json:"status"`
+	FilledSize         string `json:"filled_size"`
+	FilledValue        string `json:"filled_value"`
+	TotalFees          string `json:"total_fees"`
+	CreatedTime        string `json:"created_time"`
+	RejectReason       string `json:"reject_reason"`
+	OrderConfiguration struct {
+		MarketMarketIOC *marketIOC `json:"market_market_ioc"`
+		LimitLimitGTC   *limitGTC  `json:"limit_limit_gtc"`
+	} `json:"order_configuration"`
+}
+
+//toOrder amounts the api leaves out are 0, e.g. the base size of a market buy, amounts out of range are an error
+func toOrder(o order) (exchange.Order, error) {
+	if err := checkRange(o.FilledSize, o.FilledValue, o.TotalFees); err != nil {
+		return exchange.Order{}, err
+	}
+	created, _ := time.Parse(time.RFC3339Nano, o.CreatedTime)
+	out := exchange.Order{
+		ID:            o.OrderID,
+		Product:       o.ProductID,
+		Side:          strings.ToLower(o.Side),
+		Type:          strings.ToLower(o.OrderType),
+		Status:        status(o.Status),
+		Reason:        o.Status,
+		FilledSize:    parseOptional(o.FilledSize),
+		ExecutedValue: parseOptional(o.FilledValue),
+		Fees:          parseOptional(o.TotalFees),
+		Created:       created,
+	}
+	if o.RejectReason != "" && o.RejectReason != "REJECT_REASON_UNSPECIFIED" {
+		out.Reason = o.Status + " " + o.RejectReason
+	}
+	if m := o.OrderConfiguration.MarketMarketIOC; m != nil {
+		if err := checkRange(m.QuoteSize, m.BaseSize); err != nil {
+			return exchange.Order{}, err
+		}
+		out.Funds = parseOptional(m.QuoteSize)
+		out.Size = parseOptional(m.BaseSize)
+	}
+	if l := o.OrderConfiguration.LimitLimitGTC; l != nil {
+		if err := checkRange(l.BaseSize, l.LimitPrice); err != nil {
+			return exchange.Order{}, err
+		}
+		out.Size = parseOptional(l.BaseSize)
+		out.Price = parseOptional(l.LimitPrice)
+	}
+	return out, nil
+}
+
+//status orders are OPEN, PENDING or QUEUED until they are FILLED, CANCELLED, EXPIRED or FAILED
+func status(s string) exchange.OrderStatus {
+	switch s {
+	case "FILLED":
+		return exchange.StatusFilled
+	case "CANCELLED":
+		return exchange.StatusCancelled
+	case "EXPIRED", "FAILED":
+		return exchange.StatusRejected
+	}
+	return exchange.StatusPending
+}
+
+func (c *Client) GetOrder(ctx context.Context, id string) (exchange.Order, error) {
+	var resp struct {
+		Order order `json:"order"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/orders/historical/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return exchange.Order{}, err
+	}
+	return toOrder(resp.Order)
+}
+
+//cancel cancels ids in one request and returns the ones that were cancelled
+func (c *Client) cancel(ctx context.Context, ids []string) ([]string, error) {
+	var resp struct {
+		Results []struct {
+			Success       bool   `json:"success"`
+			FailureReason string `json:"failure_reason"`
+			OrderID       string `json:"order_id"`
+		} `json:"results"`
+	}
+	body := map[string][]string{"order_ids": ids}
+	if err := c.do(ctx, http.MethodPost, "/orders/batch_cancel", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	var cancelled []string
+	var err error
+	for _, r := range resp.Results {
+		if !r.Success {
+			err = &exchange.HTTPError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("failed to cancel order %s %s", r.OrderID, r.FailureReason)}
+			continue
+		}
+		cancelled = append(cancelled, r.OrderID)
+	}
+	return cancelled, err
+}
+
+func (c *Client) CancelOrder(ctx context.Context, id string) error {
+	_, err := c.cancel(ctx, []string{id})
+	return err
+}
+
+//CancelAllOrders the api has no cancel all, the open orders are listed and cancelled in one batch
+func (c *Client) CancelAllOrders(ctx context.Context, productID string) ([]string, error) {
+	orders, err := c.ListOpenOrders(ctx, productID)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	return c.cancel(ctx, ids)
+}
+
+func (c *Client) ListOpenOrders(ctx context.Context, productID string) ([]exchange.Order, error) {
+	var out []exchange.Order
+	query := url.Values{"order_status": {"OPEN"}}
+	if productID != "" {
+		query.Set("product_id", productID)
+	}
+	for {
+		var resp struct {
+			Orders  []order `json:"orders"`
+			HasNext bool    `json:"has_next"`
+			Cursor  string  `json:"cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/orders/historical/batch", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, o := range resp.Orders {
+			order, err := toOrder(o)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, order)
+		}
+		if !resp.HasNext || resp.Cursor == "" {
+			return out, nil
+		}
+		query.Set("cursor", resp.Cursor)
+	}
+}
+
+func (c *Client) ListFills(ctx context.Context, orderID string) ([]exchange.Fill, error) {
+	var out []exchange.Fill
+	query := url.Values{"order_id": {orderID}}
+	for {
+		var resp struct {
+			Fills []struct {
+				TradeID    string `json:"trade_id"`
+				OrderID    string `json:"order_id"`
+				ProductID  string `json:"product_id"`
+				Side       string `json:"side"`
+				Price      string `json:"price"`
+				Size       string `json:"size"`
+				Commission string `json:"commission"`
+				TradeTime  string `json:"trade_time"`
+			} `json:"fills"`
+			Cursor string `json:"cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/orders/historical/fills", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, f := range resp.Fills {
+			price, err := decimal.Parse(f.Price)
+			if err != nil {
+				return nil, err
+			}
+			size, err := decimal.Parse(f.Size)
+			if err != nil {
+				return nil, err
+			}
+			t, _ := time.Parse(time.RFC3339Nano, f.TradeTime)
+			out = append(out, exchange.Fill{
+				TradeID: f.TradeID,
+				OrderID: f.OrderID,
+				Product: f.ProductID,
+				Side:    strings.ToLower(f.Side),
+				Price:   price,
+				Size:    size,
+				Fee:     parseOptional(f.Commission),
+				Time:    t,
+			})
+		}
+		if len(resp.Fills) == 0 || resp.Cursor == "" {
+			return out, nil
+		}
+		query.Set("cursor", resp.Cursor)
+	}
+}
+
+//parseOptional amounts that are left out of an answer are 0
+func parseOptional(v string) decimal.Decimal {
+	d, err := decimal.Parse(v)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+//checkRange amounts past the range of a decimal are a decimal.ErrOverflow, not a 0 that reads as nothing held or filled
+func checkRange(amounts ...string) error {
+	for _, v := range amounts {
+		if _, err := decimal.Parse(v); errors.Is(err, decimal.ErrOverflow) {
+			return err
+		}
+	}
+	return nil
+}
+
+//parseFloat candle values that are left out of an answer are 0
+func parseFloat(v string) float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
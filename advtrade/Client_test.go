@@ -0,0 +1,317 @@
+package advtrade
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/svc"
+	"github.com/stretchr/testify/assert"
+)
+
+//newKey is a fresh api key in the PEM format the CDP portal hands out
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+//fixtureServer answers "METHOD path?query" with the recorded response in testdata, requests must carry a valid token.
+//Bodies of POST requests are passed to check.
+func fixtureServer(t *testing.T, pub *ecdsa.PublicKey, fixtures map[string]string, check func(path string, body map[string]interface{})) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := verify(r.Header.Get("Authorization")[len("Bearer "):], pub)
+		if err != nil || claims.URI != r.Method+" "+r.Host+r.URL.Path {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(readFixture(t, "error_unauthorized.json"))
+			return
+		}
+		if r.Method == http.MethodPost && check != nil {
+			var body map[string]interface{}
+			b, _ := io.ReadAll(r.Body)
+			json.Unmarshal(b, &body)
+			check(r.URL.Path, body)
+		}
+		name, ok := fixtures[r.Method+" "+r.URL.RequestURI()]
+		if !ok {
+			name, ok = fixtures[r.Method+" "+r.URL.Path]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(readFixture(t, name))
+	}))
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newTestClient(t *testing.T, fixtures map[string]string, check func(path string, body map[string]interface{})) (*Client, func()) {
+	key, secret := newKey(t)
+	signer, err := NewSigner("organizations/org/apiKeys/key", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := fixtureServer(t, &key.PublicKey, fixtures, check)
+	return NewClient(server.URL, signer), server.Close
+}
+
+func TestClient_MarketData(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	start := time.Unix(1627776000, 0).UTC()
+	client, done := newTestClient(t, map[string]string{
+		"GET /api/v3/brokerage/products":                                                                      "products.json",
+		"GET /api/v3/brokerage/product_book?limit=1&product_id=BTC-USD":                                       "product_book.json",
+		"GET /api/v3/brokerage/products/BTC-USD/candles?end=1627783200&granularity=ONE_HOUR&start=1627776000": "candles.json",
+		"GET /api/v3/brokerage/accounts?limit=250":                                                            "accounts.json",
+		"GET /api/v3/brokerage/accounts?cursor=789100&limit=250":                                              "accounts_page2.json",
+	}, nil)
+	defer done()
+	assert.Equal("advanced-trade", client.Name())
+
+	products, err := client.GetProducts(ctx)
+	assert.Nil(err)
+	assert.Equal(exchange.Product{
+		ID:             "BTC-USD",
+		BaseCurrency:   "BTC",
+		QuoteCurrency:  "USD",
+		BaseMinSize:    d("0.000016"),
+		BaseMaxSize:    d("2600"),
+		BaseIncrement:  d("0.00000001"),
+		QuoteIncrement: d("0.01"),
+		MinFunds:       d("1"),
+		Status:         exchange.ProductOnline,
+	}, products[0])
+	assert.True(products[1].Disabled, "trading disabled")
+	assert.Equal(exchange.ProductOffline, products[1].Status, "delisted")
+
+	book, err := client.GetBook(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(exchange.Book{
+		Bids: []exchange.BookLevel{{Price: d("29270.01"), Size: d("0.25")}},
+		Asks: []exchange.BookLevel{{Price: d("29270.02"), Size: d("0.5")}},
+	}, book)
+
+	candles, err := client.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour*2), time.Hour)
+	assert.Nil(err)
+	assert.Equal([]exchange.Candle{
+		{Time: start, Open: 41100, High: 41600, Low: 41000, Close: 41500, Volume: 98.25},
+		{Time: start.Add(time.Hour), Open: 41500, High: 41900, Low: 41200, Close: 41800, Volume: 120.5},
+	}, candles, "oldest first")
+
+	_, err = client.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour*2), time.Hour*3)
+	assert.NotNil(err, "three hours is not a granularity of the api")
+
+	balances, err := client.GetBalances(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Balance{
+		{Currency: "USD", Total: d("1050.25"), Available: d("1000.25"), Hold: d("50")},
+		{Currency: "BTC", Total: d("0.5"), Available: d("0.5")},
+	}, balances, "every page")
+}
+
+func TestClient_granularity(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		d       time.Duration
+		end     time.Time
+		want    string
+		wantErr bool
+	}{
+		{name: "Happy Path. Exact.", d: time.Minute * 15, end: start.Add(time.Hour), want: "FIFTEEN_MINUTE"},
+		{name: "Happy Path. Smallest that fits a day.", end: start.Add(time.Hour * 24), want: "FIVE_MINUTE"},
+		{name: "Happy Path. Smallest that fits an hour.", end: start.Add(time.Hour), want: "ONE_MINUTE"},
+		{name: "Happy Path. A year is in days.", end: start.Add(time.Hour * 24 * 365), want: "ONE_DAY"},
+		{name: "Sad Path. Not supported.", d: time.Minute * 10, end: start.Add(time.Hour), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := granularity(tt.d, start, tt.end)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_Orders(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	var bodies []map[string]interface{}
+	client, done := newTestClient(t, map[string]string{
+		"POST /api/v3/brokerage/orders":                                                      "create_order.json",
+		"GET /api/v3/brokerage/orders/historical/11111-00000-000000":                         "order_filled.json",
+		"GET /api/v3/brokerage/orders/historical/batch?order_status=OPEN&product_id=BTC-USD": "orders_open.json",
+		"POST /api/v3/brokerage/orders/batch_cancel":                                         "batch_cancel.json",
+		"GET /api/v3/brokerage/orders/historical/fills?order_id=11111-00000-000000":          "fills.json",
+	}, func(path string, body map[string]interface{}) {
+		bodies = append(bodies, body)
+	})
+	defer done()
+
+	placed, err := client.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("100")})
+	assert.Nil(err)
+	assert.Equal("11111-00000-000000", placed.ID)
+	assert.Equal(exchange.StatusPending, placed.Status)
+	assert.Equal("BUY", bodies[0]["side"])
+	assert.NotEmpty(bodies[0]["client_order_id"], "the api requires a client order id")
+	assert.Equal(map[string]interface{}{"market_market_ioc": map[string]interface{}{"quote_size": "100"}}, bodies[0]["order_configuration"])
+
+	_, err = client.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideSell, Type: exchange.TypeLimit, Size: d("0.001"), Price: d("30000")})
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"limit_limit_gtc": map[string]interface{}{"base_size": "0.001", "limit_price": "30000", "post_only": false}}, bodies[1]["order_configuration"])
+
+	order, err := client.GetOrder(ctx, placed.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.Order{
+		ID:            "11111-00000-000000",
+		Product:       "BTC-USD",
+		Side:          "buy",
+		Type:          "market",
+		Funds:         d("100"),
+		Status:        exchange.StatusFilled,
+		Reason:        "FILLED",
+		FilledSize:    d("0.002"),
+		ExecutedValue: d("100"),
+		Fees:          d("0.6"),
+		Created:       time.Date(2021, time.August, 1, 12, 0, 0, 123000000, time.UTC),
+	}, order)
+	avg, err := order.AveragePrice()
+	assert.Nil(err)
+	assert.Equal(d("50000"), avg)
+
+	orders, err := client.ListOpenOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Len(orders, 1)
+	assert.Equal(d("25000"), orders[0].Price)
+	assert.Equal(d("0.001"), orders[0].Size)
+	assert.Equal(exchange.StatusPending, orders[0].Status)
+
+	cancelled, err := client.CancelAllOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal([]string{"33333-00000-000000"}, cancelled)
+	assert.Equal([]interface{}{"33333-00000-000000"}, bodies[2]["order_ids"])
+
+	fills, err := client.ListFills(ctx, placed.ID)
+	assert.Nil(err)
+	assert.Equal([]exchange.Fill{{
+		TradeID: "1111-11111-111111",
+		OrderID: "11111-00000-000000",
+		Product: "BTC-USD",
+		Side:    "buy",
+		Price:   d("50000"),
+		Size:    d("0.002"),
+		Fee:     d("0.6"),
+		Time:    time.Date(2021, time.August, 1, 12, 0, 0, 500000000, time.UTC),
+	}}, fills)
+}
+
+func TestClient_status(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   exchange.OrderStatus
+	}{
+		{name: "Happy Path. Filled.", status: "FILLED", want: exchange.StatusFilled},
+		{name: "Happy Path. Cancelled.", status: "CANCELLED", want: exchange.StatusCancelled},
+		{name: "Happy Path. Expired.", status: "EXPIRED", want: exchange.StatusRejected},
+		{name: "Happy Path. Failed.", status: "FAILED", want: exchange.StatusRejected},
+		{name: "Happy Path. Open.", status: "OPEN", want: exchange.StatusPending},
+		{name: "Happy Path. Queued.", status: "QUEUED", want: exchange.StatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status(tt.status))
+		})
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, done := newTestClient(t, map[string]string{
+		"GET /api/v3/brokerage/products": "products.json",
+		"POST /api/v3/brokerage/orders":  "create_order_insufficient.json",
+	}, nil)
+	defer done()
+
+	_, err := client.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: decimal.RequireFromString("100000")})
+	var httpErr *exchange.HTTPError
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusBadRequest, httpErr.StatusCode)
+	assert.Equal("insufficient funds", httpErr.Message())
+
+	//a key the server does not know
+	_, other := newKey(t)
+	client.signer, _ = NewSigner("organizations/org/apiKeys/other", other)
+	_, err = client.GetBalances(ctx)
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusUnauthorized, httpErr.StatusCode)
+	assert.Equal("Unauthorized", httpErr.Message())
+
+	//a filled size out of range is refused, not read as nothing filled
+	_, err = toOrder(order{OrderID: "1", Status: "FILLED", FilledSize: "100000000000", FilledValue: "1000000"})
+	assert.ErrorIs(err, decimal.ErrOverflow)
+}
+
+//TestClient_CoinbaseSvc the bot trades over advanced trade without changes to CoinbaseSvc
+func TestClient_CoinbaseSvc(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	client, done := newTestClient(t, map[string]string{
+		"GET /api/v3/brokerage/products":                             "products.json",
+		"POST /api/v3/brokerage/orders":                              "create_order.json",
+		"GET /api/v3/brokerage/orders/historical/11111-00000-000000": "order_filled.json",
+		"GET /api/v3/brokerage/product_book":                         "product_book.json",
+	}, nil)
+	defer done()
+	cbSvc := svc.NewCoinbaseSvc(client, time.Second*5)
+
+	price, err := cbSvc.GetLastPrice(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(d("29270.01"), price)
+
+	numberOwn, buyPrice, err := cbSvc.Buy(ctx, "BTC-USD", price, d("100"))
+	assert.Nil(err)
+	assert.Equal(d("0.002"), numberOwn)
+	assert.Equal(d("50000"), buyPrice)
+
+	client, done = newTestClient(t, map[string]string{
+		"GET /api/v3/brokerage/products": "products.json",
+		"POST /api/v3/brokerage/orders":  "create_order_insufficient.json",
+	}, nil)
+	defer done()
+	_, _, err = svc.NewCoinbaseSvc(client, time.Second).Buy(ctx, "BTC-USD", price, d("100000"))
+	assert.True(errors.Is(err, svc.ErrInsufficientFunds))
+}
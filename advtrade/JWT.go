@@ -0,0 +1,108 @@
+package advtrade
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Signer signs every request with a short lived ES256 JWT, as the Advanced Trade API requires.
+//KeyName is the CDP api key name, organizations/{org_id}/apiKeys/{key_id}.
+type Signer struct {
+	KeyName string
+	key     *ecdsa.PrivateKey
+	now     func() time.Time
+}
+
+//tokenTTL is the lifetime the api accepts for a token
+const tokenTTL = time.Minute * 2
+
+//NewSigner secret is the EC private key of the api key in PEM, escaped newlines as stored in json or env values are accepted
+func NewSigner(keyName, secret string) (*Signer, error) {
+	if keyName == "" {
+		return nil, fmt.Errorf("advanced trade api key name is empty")
+	}
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(secret, `\n`, "\n")))
+	if block == nil {
+		return nil, fmt.Errorf("advanced trade api secret is not a PEM private key")
+	}
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		k, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ec, ok := k.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("advanced trade api secret is not an EC private key")
+		}
+		key = ec
+	default:
+		return nil, fmt.Errorf("advanced trade api secret has an unknown PEM type %s", block.Type)
+	}
+	return &Signer{KeyName: keyName, key: key, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg   string `json:"alg"`
+	Kid   string `json:"kid"`
+	Nonce string `json:"nonce"`
+	Typ   string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Sub string `json:"sub"`
+	Iss string `json:"iss"`
+	Nbf int64  `json:"nbf"`
+	Exp int64  `json:"exp"`
+	URI string `json:"uri"`
+}
+
+//Token is the bearer token for one request, host is api.coinbase.com and path starts with /api/v3
+func (s *Signer) Token(method, host, path string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	now := s.now()
+	header, err := json.Marshal(jwtHeader{Alg: "ES256", Kid: s.KeyName, Nonce: hex.EncodeToString(nonce), Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(jwtClaims{
+		Sub: s.KeyName,
+		Iss: "cdp",
+		Nbf: now.Unix(),
+		Exp: now.Add(tokenTTL).Unix(),
+		URI: method + " " + host + path,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	//ES256 signatures are r and s as fixed 32 byte big endian numbers
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,111 @@
+package advtrade
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//verify checks the ES256 signature of token with pub and returns its claims
+func verify(token string, pub *ecdsa.PublicKey) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, errors.New("token is not three parts")
+	}
+	var header jwtHeader
+	var claims jwtClaims
+	for i, v := range []interface{}{&header, &claims} {
+		b, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return jwtClaims{}, err
+		}
+		if err := json.Unmarshal(b, v); err != nil {
+			return jwtClaims{}, err
+		}
+	}
+	if header.Alg != "ES256" || header.Kid != claims.Sub {
+		return jwtClaims{}, errors.New("unexpected header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return jwtClaims{}, errors.New("signature is not 64 bytes")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		return jwtClaims{}, errors.New("bad signature")
+	}
+	return claims, nil
+}
+
+func TestSigner_Token(t *testing.T) {
+	assert := assert.New(t)
+	key, secret := newKey(t)
+	signer, err := NewSigner("organizations/org/apiKeys/key", secret)
+	assert.Nil(err)
+	now := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+	signer.now = func() time.Time { return now }
+
+	token, err := signer.Token("GET", "api.coinbase.com", "/api/v3/brokerage/accounts")
+	assert.Nil(err)
+	claims, err := verify(token, &key.PublicKey)
+	assert.Nil(err)
+	assert.Equal(jwtClaims{
+		Sub: "organizations/org/apiKeys/key",
+		Iss: "cdp",
+		Nbf: now.Unix(),
+		Exp: now.Add(time.Minute * 2).Unix(),
+		URI: "GET api.coinbase.com/api/v3/brokerage/accounts",
+	}, claims)
+
+	other, err := signer.Token("GET", "api.coinbase.com", "/api/v3/brokerage/accounts")
+	assert.Nil(err)
+	assert.NotEqual(strings.Split(token, ".")[0], strings.Split(other, ".")[0], "every token has its own nonce")
+}
+
+func TestNewSigner(t *testing.T) {
+	key, secret := newKey(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		keyName string
+		secret  string
+		wantErr bool
+	}{
+		{name: "Happy Path. EC private key.", keyName: "key", secret: secret},
+		{name: "Happy Path. Escaped newlines.", keyName: "key", secret: strings.ReplaceAll(secret, "\n", `\n`)},
+		{name: "Happy Path. PKCS8.", keyName: "key", secret: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))},
+		{name: "Sad Path. No key name.", secret: secret, wantErr: true},
+		{name: "Sad Path. Not PEM.", keyName: "key", secret: "c2VjcmV0", wantErr: true},
+		{name: "Sad Path. RSA key.", keyName: "key", secret: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rsaPKCS8})), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSigner(tt.keyName, tt.secret)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
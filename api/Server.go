@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/svc"
+)
+
+//staleAfter is how long a product can go without a loop heartbeat before health reports it
+const staleAfter = time.Minute * 5
+
+//Server is the status and control api for the running bot.
+//	GET  /state            all states
+//	GET  /state/{product}  one state
+//	GET  /trades/{product} recent trades
+//	GET  /health           loop heartbeat per product
+//	POST /pause/{product}  stop buying
+//	POST /resume/{product} start buying
+//	POST /sell/{product}   sell the position at the last price
+//	POST /strategy/{product} update strategy params, body is a partial strategy
+//
+//Orders placed through the api run on OrderCtx rather than the request context,
+//a client hanging up must not leave an order pending.
+type Server struct {
+	Token    string
+	CbSvc    svc.CoinbaseSvcInterface
+	States   map[string]*svc.State
+	OrderCtx context.Context
+	mux      *http.ServeMux
+}
+
+func NewServer(token string, cbSvc svc.CoinbaseSvcInterface, states ...*svc.State) *Server {
+	s := &Server{
+		Token:    token,
+		CbSvc:    cbSvc,
+		States:   map[string]*svc.State{},
+		OrderCtx: context.Background(),
+		mux:      http.NewServeMux(),
+	}
+	for _, st := range states {
+		s.States[st.Product] = st
+	}
+
+	s.mux.HandleFunc("/state", get(s.handleStates))
+	s.mux.HandleFunc("/state/", get(s.product(s.handleState)))
+	s.mux.HandleFunc("/trades/", get(s.product(s.handleTrades)))
+	s.mux.HandleFunc("/health", get(s.handleHealth))
+	s.mux.HandleFunc("/pause/", post(s.product(s.handlePause(true))))
+	s.mux.HandleFunc("/resume/", post(s.product(s.handlePause(false))))
+	s.mux.HandleFunc("/sell/", post(s.product(s.handleSell)))
+	s.mux.HandleFunc("/strategy/", post(s.product(s.handleStrategy)))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+//ListenAndServe blocks until ctx is done, requests in flight get a few seconds to finish
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if s.Token == "" {
+		return fmt.Errorf("api token is required")
+	}
+	server := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	log.Printf("serving api on %s", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func get(h http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodGet, h)
+}
+
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodPost, h)
+}
+
+func method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		h(w, r)
+	}
+}
+
+type productHandler func(w http.ResponseWriter, r *http.Request, st *svc.State)
+
+//product resolves the last path segment to a State
+func (s *Server) product(h productHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		st, ok := s.States[p]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown product %q", p))
+			return
+		}
+		h(w, r, st)
+	}
+}
+
+func (s *Server) handleStates(w http.ResponseWriter, r *http.Request) {
+	states := make([]json.RawMessage, 0, len(s.States))
+	for _, p := range s.products() {
+		b, err := snapshot(s.States[p])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		states = append(states, b)
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request, st *svc.State) {
+	writeState(w, http.StatusOK, st)
+}
+
+func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request, st *svc.State) {
+	trades := []svc.Trade{}
+	st.Guard(func() {
+		trades = append(trades, st.Trades...)
+	})
+	writeJSON(w, http.StatusOK, trades)
+}
+
+type productHealth struct {
+	Product   string    `json:"product"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+	Paused    bool      `json:"paused"`
+}
+
+type health struct {
+	Healthy  bool            `json:"healthy"`
+	Products []productHealth `json:"products"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	h := health{Healthy: true, Products: []productHealth{}}
+	for _, p := range s.products() {
+		st := s.States[p]
+		st.Guard(func() {
+			ph := productHealth{
+				Product:   st.Product,
+				LastCheck: st.LastCheck,
+				LastError: st.LastError,
+				Paused:    st.Paused,
+			}
+			ph.Healthy = ph.LastError == "" && time.Since(ph.LastCheck) < staleAfter
+			h.Healthy = h.Healthy && ph.Healthy
+			h.Products = append(h.Products, ph)
+		})
+	}
+
+	status := http.StatusOK
+	if !h.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, h)
+}
+
+func (s *Server) handlePause(paused bool) productHandler {
+	return func(w http.ResponseWriter, r *http.Request, st *svc.State) {
+		st.Guard(func() {
+			st.Pause(paused)
+			st.PrintStateChange(fmt.Sprintf("api paused %t", paused))
+		})
+		writeState(w, http.StatusOK, st)
+	}
+}
+
+func (s *Server) handleSell(w http.ResponseWriter, r *http.Request, st *svc.State) {
+	if err := st.ForceSell(s.OrderCtx, s.CbSvc); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeState(w, http.StatusOK, st)
+}
+
+func (s *Server) handleStrategy(w http.ResponseWriter, r *http.Request, st *svc.State) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	st.Guard(func() {
+		strategy := st.Strategy
+		if strategy == (svc.Strategy{}) {
+			strategy = svc.DefaultStrategy()
+		}
+		if err = json.Unmarshal(body, &strategy); err != nil {
+			return
+		}
+		if err = strategy.Validate(); err != nil {
+			return
+		}
+		st.Strategy = strategy
+		st.PrintStateChange("api strategy update")
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeState(w, http.StatusOK, st)
+}
+
+func (s *Server) products() []string {
+	products := make([]string, 0, len(s.States))
+	for p := range s.States {
+		products = append(products, p)
+	}
+	sort.Strings(products)
+	return products
+}
+
+//snapshot is st marshalled under its lock
+func snapshot(st *svc.State) ([]byte, error) {
+	var b []byte
+	var err error
+	st.Guard(func() {
+		b, err = json.Marshal(st)
+	})
+	return b, err
+}
+
+//writeState writes a snapshot of st, the lock is not held while a slow client reads it
+func writeState(w http.ResponseWriter, status int, st *svc.State) {
+	b, err := snapshot(st)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, status, json.RawMessage(b))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write api response %s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
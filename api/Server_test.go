@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/svc"
+	"github.com/stretchr/testify/assert"
+)
+
+type cbSvcFake struct {
+	sold      decimal.Decimal
+	lastPrice decimal.Decimal
+	err       error
+}
+
+func (f *cbSvcFake) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if f.err != nil {
+		return numberOwn, decimal.Zero, f.err
+	}
+	f.sold = numberOwn
+	funds, err := numberOwn.Mul(sellPrice)
+	return decimal.Zero, funds, err
+}
+
+func (f *cbSvcFake) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+func (f *cbSvcFake) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+func (f *cbSvcFake) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	return f.lastPrice, f.err
+}
+
+func (f *cbSvcFake) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+func newTestServer(cbSvc svc.CoinbaseSvcInterface, states ...*svc.State) *httptest.Server {
+	return httptest.NewServer(NewServer("secret", cbSvc, states...))
+}
+
+func do(t *testing.T, method, url, token, body string) (*http.Response, map[string]interface{}) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp, out
+}
+
+func TestServer_Auth(t *testing.T) {
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	server := newTestServer(&cbSvcFake{}, state)
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "Happy Path. Valid token.", token: "secret", wantStatus: http.StatusOK},
+		{name: "Sad Path. Missing token.", token: "", wantStatus: http.StatusUnauthorized},
+		{name: "Sad Path. Wrong token.", token: "guess", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := do(t, http.MethodGet, server.URL+"/state/BTC-USD", tt.token, "")
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET /state status = %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServer_State(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	server := newTestServer(&cbSvcFake{}, state)
+	defer server.Close()
+
+	resp, body := do(t, http.MethodGet, server.URL+"/state/BTC-USD", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("BTC-USD", body["Product"])
+	assert.Equal(100.0, body["AvailableFunds"])
+
+	resp, _ = do(t, http.MethodGet, server.URL+"/state/ETH-USD", "secret", "")
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(t, http.MethodPost, server.URL+"/state/BTC-USD", "secret", "")
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServer_Health(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	server := newTestServer(&cbSvcFake{}, state)
+	defer server.Close()
+
+	// no heartbeat yet
+	resp, body := do(t, http.MethodGet, server.URL+"/health", "secret", "")
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(false, body["healthy"])
+
+	state.Guard(func() { state.Heartbeat(nil) })
+	resp, body = do(t, http.MethodGet, server.URL+"/health", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(true, body["healthy"])
+
+	state.Guard(func() { state.Heartbeat(fmt.Errorf("its broke")) })
+	resp, _ = do(t, http.MethodGet, server.URL+"/health", "secret", "")
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestServer_PauseResume(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	server := newTestServer(&cbSvcFake{}, state)
+	defer server.Close()
+
+	resp, body := do(t, http.MethodPost, server.URL+"/pause/BTC-USD", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(true, body["Paused"])
+	assert.True(state.Paused)
+
+	resp, body = do(t, http.MethodPost, server.URL+"/resume/BTC-USD", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(false, body["Paused"])
+	assert.False(state.Paused)
+}
+
+func TestServer_Sell(t *testing.T) {
+	tests := []struct {
+		name          string
+		numberOwn     decimal.Decimal
+		funds         decimal.Decimal
+		cbSvc         *cbSvcFake
+		wantStatus    int
+		wantNumberOwn decimal.Decimal
+		wantFunds     decimal.Decimal
+	}{
+		{
+			name:          "Happy Path. Position is sold at the last price.",
+			numberOwn:     decimal.NewFromInt(2),
+			funds:         decimal.Zero,
+			cbSvc:         &cbSvcFake{lastPrice: decimal.NewFromInt(50)},
+			wantStatus:    http.StatusOK,
+			wantNumberOwn: decimal.Zero,
+			wantFunds:     decimal.NewFromInt(100),
+		},
+		{
+			name:          "Sad Path. Nothing to sell.",
+			numberOwn:     decimal.Zero,
+			funds:         decimal.NewFromInt(100),
+			cbSvc:         &cbSvcFake{lastPrice: decimal.NewFromInt(50)},
+			wantStatus:    http.StatusConflict,
+			wantNumberOwn: decimal.Zero,
+			wantFunds:     decimal.NewFromInt(100),
+		},
+		{
+			name:          "Sad Path. Sell fails, state is unchanged.",
+			numberOwn:     decimal.NewFromInt(2),
+			funds:         decimal.Zero,
+			cbSvc:         &cbSvcFake{lastPrice: decimal.NewFromInt(50), err: fmt.Errorf("its broke")},
+			wantStatus:    http.StatusConflict,
+			wantNumberOwn: decimal.NewFromInt(2),
+			wantFunds:     decimal.Zero,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := svc.NewStateSvc(nil).NewState("BTC-USD", tt.funds)
+			state.NumberOwn = tt.numberOwn
+			server := newTestServer(tt.cbSvc, state)
+			defer server.Close()
+
+			resp, _ := do(t, http.MethodPost, server.URL+"/sell/BTC-USD", "secret", "")
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantNumberOwn, state.NumberOwn)
+			assert.Equal(t, tt.wantFunds, state.AvailableFunds)
+		})
+	}
+}
+
+func TestServer_Strategy(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	server := newTestServer(&cbSvcFake{}, state)
+	defer server.Close()
+
+	resp, _ := do(t, http.MethodPost, server.URL+"/strategy/BTC-USD", "secret", `{"take_profit": 0.05, "cooldown": "30m"}`)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(0.05, state.Strategy.TakeProfit)
+	assert.Equal(time.Minute*30, state.Strategy.Cooldown)
+	assert.Equal(svc.DefaultStrategy().StopLoss, state.Strategy.StopLoss)
+
+	resp, _ = do(t, http.MethodPost, server.URL+"/strategy/BTC-USD", "secret", `{"stop_loss": -1}`)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(svc.DefaultStrategy().StopLoss, state.Strategy.StopLoss)
+
+	resp, _ = do(t, http.MethodPost, server.URL+"/strategy/BTC-USD", "secret", `not json`)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_Trades(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+	state.NumberOwn = decimal.NewFromInt(2)
+	server := newTestServer(&cbSvcFake{lastPrice: decimal.NewFromInt(50)}, state)
+	defer server.Close()
+
+	do(t, http.MethodPost, server.URL+"/sell/BTC-USD", "secret", "")
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/trades/BTC-USD", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	var trades []svc.Trade
+	assert.Nil(json.NewDecoder(resp.Body).Decode(&trades))
+	assert.Len(trades, 1)
+	assert.Equal("force sell", trades[0].Trigger)
+	assert.Equal(decimal.NewFromInt(2), trades[0].Size)
+}
+
+//slowSell holds the sale until release is closed
+type slowSell struct {
+	cbSvcFake
+	selling chan struct{}
+	release chan struct{}
+}
+
+func (f *slowSell) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	close(f.selling)
+	<-f.release
+	return f.cbSvcFake.Sell(ctx, product, numberOwn, sellPrice)
+}
+
+func TestServer_SellDoesNotBlock(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+	state.NumberOwn = decimal.NewFromInt(2)
+	cbSvc := &slowSell{cbSvcFake: cbSvcFake{lastPrice: decimal.NewFromInt(50)}, selling: make(chan struct{}), release: make(chan struct{})}
+	server := newTestServer(cbSvc, state)
+	defer server.Close()
+
+	sold := make(chan int)
+	go func() {
+		resp, _ := do(t, http.MethodPost, server.URL+"/sell/BTC-USD", "secret", "")
+		sold <- resp.StatusCode
+	}()
+	<-cbSvc.selling
+
+	// the other endpoints answer while the sale is with the exchange
+	resp, body := do(t, http.MethodGet, server.URL+"/state/BTC-USD", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(2.0, body["NumberOwn"])
+	resp, _ = do(t, http.MethodPost, server.URL+"/pause/BTC-USD", "secret", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp, _ = do(t, http.MethodPost, server.URL+"/sell/BTC-USD", "secret", "")
+	assert.Equal(http.StatusConflict, resp.StatusCode, "one sale at a time")
+
+	close(cbSvc.release)
+	assert.Equal(http.StatusOK, <-sold)
+	resp, body = do(t, http.MethodGet, server.URL+"/state/BTC-USD", "secret", "")
+	assert.Equal(0.0, body["NumberOwn"])
+	assert.Equal(true, body["Paused"])
+}
@@ -0,0 +1,192 @@
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+)
+
+//Interaction is one request to an exchange and its response, a cassette is a file of them, one JSON object a line
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+//Request headers are never recorded, they carry the keys and signatures
+type Request struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"` //path and query, the host is left out so a cassette replays against any base url
+	Body   string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+//keptHeaders are the response headers the clients read, cookies and tracing ids are dropped
+var keptHeaders = []string{"Content-Type", "Retry-After", "Cb-After", "Cb-Before"}
+
+//accountFields identify the account in Coinbase answers without being a credential
+var accountFields = regexp.MustCompile(`"(profile_id|user_id)"\s*:\s*"[^"]*"`)
+
+//Redactor scrubs known secrets out of a string, see secrets.Redactor
+type Redactor interface {
+	Redact(s string) string
+}
+
+//Recorder is an http.RoundTripper that writes every request and response through Next to W as it happens.
+//Secrets known to the Redactor are scrubbed from uris and bodies, request headers and most response headers are left out.
+type Recorder struct {
+	Next     http.RoundTripper
+	redactor Redactor
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewRecorder(w io.Writer, redactor Redactor, next http.RoundTripper) *Recorder {
+	return &Recorder{Next: next, redactor: redactor, enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URI:    r.scrub(req.URL.RequestURI()),
+			Body:   r.scrub(string(reqBody)),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     map[string]string{},
+			Body:       r.scrub(string(respBody)),
+		},
+	}
+	for _, h := range keptHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			in.Response.Header[h] = v
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(in); err != nil {
+		return nil, fmt.Errorf("failed to record %s %s %s", req.Method, in.Request.URI, err.Error())
+	}
+	return resp, nil
+}
+
+func (r *Recorder) scrub(s string) string {
+	if r.redactor != nil {
+		s = r.redactor.Redact(s)
+	}
+	return accountFields.ReplaceAllString(s, `"$1":"[REDACTED]"`)
+}
+
+//Load reads the interactions of a cassette file
+func Load(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Interaction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var in Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("%s:%d %s", path, line, err.Error())
+		}
+		out = append(out, in)
+	}
+	return out, scanner.Err()
+}
+
+//Replayer is an http.RoundTripper that answers from recorded interactions instead of the network.
+//A request gets the first unused interaction with the same method and uri, so a polled order replays its answers in order.
+//Request bodies are not compared, nonces and client order ids change on every run.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uri := req.URL.RequestURI()
+	for i, in := range r.interactions {
+		if r.used[i] || in.Request.Method != req.Method || in.Request.URI != uri {
+			continue
+		}
+		r.used[i] = true
+		resp := &http.Response{
+			StatusCode:    in.Response.StatusCode,
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{},
+			Body:          io.NopCloser(bytes.NewReader([]byte(in.Response.Body))),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}
+		for k, v := range in.Response.Header {
+			resp.Header.Set(k, v)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction left for %s %s", req.Method, uri)
+}
+
+//Unused are the interactions no request has replayed yet, a regression test expects none
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Interaction
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			out = append(out, in)
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JasonWBrown/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder_Replayer(t *testing.T) {
+	assert := assert.New(t)
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("Cb-After", "42")
+		switch r.URL.Path {
+		case "/orders":
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(`{"size":"1","key":"very-secret-key"}`, string(body), "the body still reaches the exchange")
+			w.Write([]byte(`{"id":"1","status":"pending","profile_id":"8058d771-2d88-4f0f-ab6e-299c153d4308"}`))
+		case "/orders/1":
+			polls++
+			if polls == 1 {
+				w.Write([]byte(`{"id":"1","status":"pending"}`))
+				return
+			}
+			w.Write([]byte(`{"id":"1","status":"done","done_reason":"filled"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"NotFound"}`))
+		}
+	}))
+	defer server.Close()
+
+	redactor := secrets.NewRedactor()
+	redactor.Add("very-secret-key")
+	tape := &bytes.Buffer{}
+	client := &http.Client{Transport: NewRecorder(tape, redactor, nil)}
+	do := func(client *http.Client, method, uri, body string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+uri, strings.NewReader(body))
+		req.Header.Set("CB-ACCESS-KEY", "very-secret-key")
+		resp, err := client.Do(req)
+		if !assert.Nil(err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	_, created := do(client, http.MethodPost, "/orders", `{"size":"1","key":"very-secret-key"}`)
+	assert.Contains(created, "8058d771", "the caller gets the response as is")
+	_, pending := do(client, http.MethodGet, "/orders/1", "")
+	_, filled := do(client, http.MethodGet, "/orders/1", "")
+	status, missing := do(client, http.MethodGet, "/products/ETH-USD/book?level=1", "")
+	assert.Equal(http.StatusNotFound, status)
+
+	recorded := tape.String()
+	assert.Equal(4, strings.Count(recorded, "\n"), "one line an interaction")
+	assert.NotContains(recorded, "very-secret-key")
+	assert.NotContains(recorded, "8058d771")
+	assert.NotContains(recorded, "session=abc")
+	assert.NotContains(recorded, server.URL)
+	assert.Contains(recorded, `"uri":"/products/ETH-USD/book?level=1"`)
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	assert.Nil(os.WriteFile(path, tape.Bytes(), 0600))
+	interactions, err := Load(path)
+	assert.Nil(err)
+	assert.Len(interactions, 4)
+
+	replayer := NewReplayer(interactions)
+	replay := &http.Client{Transport: replayer}
+	_, got := do(replay, http.MethodPost, "/orders", `{"size":"1","client_oid":"changes every run"}`)
+	assert.Equal(strings.Replace(created, "8058d771-2d88-4f0f-ab6e-299c153d4308", "[REDACTED]", 1), got)
+	_, got = do(replay, http.MethodGet, "/orders/1", "")
+	assert.Equal(pending, got, "polls replay in order")
+	assert.Len(replayer.Unused(), 2)
+	_, got = do(replay, http.MethodGet, "/orders/1", "")
+	assert.Equal(filled, got)
+	status, got = do(replay, http.MethodGet, "/products/ETH-USD/book?level=1", "")
+	assert.Equal(http.StatusNotFound, status)
+	assert.Equal(missing, got)
+	assert.Empty(replayer.Unused())
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/orders/1", nil)
+	resp, err := replay.Do(req)
+	assert.Nil(resp)
+	assert.Contains(err.Error(), "no recorded interaction left for GET /orders/1")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.jsonl")
+	os.WriteFile(path, []byte("{\"request\":{\"method\":\"GET\",\"uri\":\"/time\"},\"response\":{\"status_code\":200}}\n\nnot json\n"), 0600)
+
+	_, err := Load(path)
+	assert.Contains(t, err.Error(), "broken.jsonl:3")
+	_, err = Load(filepath.Join(dir, "missing.jsonl"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/advtrade"
+	"github.com/JasonWBrown/cassette"
+	"github.com/JasonWBrown/config"
+	"github.com/JasonWBrown/environment"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/kraken"
+	"github.com/JasonWBrown/logging"
+	"github.com/JasonWBrown/metrics"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/secrets"
+	"github.com/JasonWBrown/svc"
+	"github.com/motemen/go-loghttp"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/spf13/viper"
+)
+
+//App runs the bot subcommands, everything meant for the user is printed to Out, logs go to the log package.
+//	run         trade until SIGINT or SIGTERM, the default
+//	backtest    replay historic candles through the strategy
+//	status      print the persisted state and the exchange balances
+//	sell        sell the position of a product at the last price
+//	reconcile   resolve pending orders and check the state against the exchange balances
+//	orders      list or cancel open orders
+//	seal-secrets encrypt a secrets file
+type App struct {
+	Out io.Writer
+	//NewClient creates the exchange client for the environment, tests replace it
+	NewClient func(env environment.Environment, key, passphrase, secret string) proclient.ProClientInterface
+	//NewAdvancedClient creates the client of environments on the advanced trade api, tests replace it
+	NewAdvancedClient func(env environment.Environment, keyName, secret string) (*advtrade.Client, error)
+	//NewKrakenClient creates the client of environments on kraken, tests replace it
+	NewKrakenClient func(env environment.Environment, key, secret string) (*kraken.Client, error)
+	//Clock is the time source of the states and the run loop
+	Clock svc.Clock
+	//TimeSvc paces the run loop, when nil it ticks on Clock as set in the schedule config
+	TimeSvc svc.TimeSvcInterface
+	//Passphrase unlocks an encrypted secrets file
+	Passphrase func() (string, error)
+
+	//recorder writes the exchange traffic of the session to a cassette when -record is set
+	recorder *cassette.Recorder
+}
+
+func NewApp(out io.Writer) *App {
+	a := &App{
+		Out:        out,
+		Clock:      svc.RealClock{},
+		Passphrase: secrets.Passphrase,
+	}
+	a.NewClient = a.newClient
+	a.NewAdvancedClient = a.newAdvancedClient
+	a.NewKrakenClient = a.newKrakenClient
+	return a
+}
+
+type command struct {
+	usage string
+	run   func(a *App, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"run":          {usage: "trade until SIGINT or SIGTERM", run: (*App).run},
+	"backtest":     {usage: "replay historic candles through the strategy", run: (*App).backtest},
+	"status":       {usage: "print the persisted state and the exchange balances", run: (*App).status},
+	"sell":         {usage: "sell the position of a product at the last price", run: (*App).sell},
+	"reconcile":    {usage: "resolve pending orders and check the state against the exchange balances", run: (*App).reconcile},
+	"orders":       {usage: "list or cancel open orders, orders [flags] list|cancel", run: (*App).orders},
+	"seal-secrets": {usage: "encrypt a secrets file, seal-secrets <secrets.json> <secrets.enc>", run: (*App).sealSecrets},
+}
+
+//Run runs the subcommand in args[0], run is the default when args is empty or starts with a flag
+func (a *App) Run(ctx context.Context, args []string) error {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		a.usage()
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		a.usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	err := cmd.run(a, ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func (a *App) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(a.Out, "usage: cryptobot <command> [flags]")
+	for _, name := range names {
+		fmt.Fprintf(a.Out, "  %-13s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(a.Out, "run cryptobot <command> -h for the flags of a command")
+}
+
+//options are the flags every command that talks to the exchange takes
+type options struct {
+	configPath string
+	env        string
+	confirm    bool
+	record     string
+}
+
+func (a *App) flagSet(name string, o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.Out)
+	fs.StringVar(&o.configPath, "config", "", "config file, defaults to .conf/config.*")
+	fs.StringVar(&o.env, "env", "", "environment, one of "+strings.Join(environment.Names(), ", ")+", defaults to the environment config key")
+	fs.BoolVar(&o.confirm, "confirm-production", false, "required to trade real money in production")
+	fs.StringVar(&o.record, "record", "", "write the exchange requests and responses to this cassette file, secrets scrubbed")
+	return fs
+}
+
+//session is what a command needs after the config is read and the exchange is set up
+type session struct {
+	cfg   config.Config
+	logs  *logging.Logs
+	ex    exchange.Exchange
+	paper *proclient.PaperClient
+	cbSvc svc.CoinbaseSvcInterface
+	stSvc *svc.StateSvc
+	value *svc.Converter //converts to the report currency
+	tape  *os.File       //the -record cassette
+}
+
+//setup reads and validates the config, sets up logging and creates the exchange client.
+//Commands that never place or cancel an order are readOnly, they start in production without the confirm flag.
+//product overrides the product in the config when set.
+func (a *App) setup(o options, product string, readOnly bool) (*session, error) {
+	//any key can be overridden by a CRYPTOBOT_ environment variable
+	if o.configPath != "" {
+		viper.SetConfigFile(o.configPath)
+	} else {
+		viper.AddConfigPath(".conf")
+	}
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config %s", err.Error())
+	}
+	cfg, err := config.Load(o.env)
+	if err == nil && product != "" {
+		cfg.Product = strings.ToUpper(product)
+		cfg.Trading, err = config.TradingScheduleFromViper(cfg.Product)
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s", err.Error())
+	}
+
+	//every log line is prefixed with the environment
+	env := cfg.Environment
+	if err := env.Check(o.confirm || readOnly); err != nil {
+		return nil, err
+	}
+	log.SetPrefix(env.LogPrefix())
+	log.Printf("environment %s, exchange %s, base url %s, paper %t", env.Name, env.Exchange, env.BaseURL, env.Paper)
+
+	//set up log files and rotation, known secrets never reach the logs
+	logs, err := logging.Setup(cfg.Log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up logging %s", err.Error())
+	}
+	redactor := secrets.NewRedactor()
+	redactor.Add(cfg.API.Token, cfg.Telegram.Token, cfg.Notify.SMTPPassword, cfg.Notify.SlackURL, cfg.Notify.WebhookURL)
+	log.SetOutput(redactor.Writer(logs.Out))
+
+	//exchange credentials come from the configured secret source
+	redactor.Add(cfg.Secrets.VaultToken)
+	key, passphrase, secret, err := a.loadCredentials(cfg.Secrets, env, redactor)
+	if err != nil {
+		logs.Close()
+		return nil, fmt.Errorf("failed to load secrets %s", err.Error())
+	}
+
+	//record the exchange traffic for regression tests, credentials never reach the cassette
+	s := &session{cfg: cfg, logs: logs}
+	a.recorder = nil //an earlier command of the App may have recorded
+	if o.record != "" {
+		s.tape, err = os.OpenFile(o.record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			logs.Close()
+			return nil, fmt.Errorf("failed to open the cassette %s", err.Error())
+		}
+		a.recorder = cassette.NewRecorder(s.tape, redactor, metrics.NewTransport(http.DefaultTransport))
+		log.Printf("recording exchange traffic to %s", o.record)
+	}
+
+	//stay under the exchange rate limits and retry GETs on 429 and 5xx
+	switch env.Exchange {
+	case environment.CoinbaseAdvanced:
+		client, err := a.NewAdvancedClient(env, key, secret)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to create the advanced trade client %s", err.Error())
+		}
+		client.SetRateLimit(cfg.RateLimits.PrivatePerSecond, cfg.RateLimits.PrivateBurst)
+		s.ex = client
+	case environment.KrakenExchange:
+		client, err := a.NewKrakenClient(env, key, secret)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to create the kraken client %s", err.Error())
+		}
+		client.SetRateLimit(cfg.RateLimits.PrivatePerSecond, cfg.RateLimits.PrivateBurst)
+		s.ex = client
+	default:
+		var client proclient.ProClientInterface = proclient.NewRateLimitedClient(a.NewClient(env, key, passphrase, secret), cfg.RateLimits)
+		if env.Paper {
+			s.paper = proclient.NewPaperClient(client, cfg.QuoteCurrency(), cfg.Seed)
+			client = s.paper
+		}
+		s.ex = proclient.NewExchange(client)
+	}
+	cbSvc := svc.NewCoinbaseSvc(s.ex, time.Duration(time.Minute*5))
+	s.cbSvc = cbSvc
+	s.value = svc.NewConverter(cfg.ReportCurrency(), cbSvc.Products, cbSvc)
+	s.stSvc = svc.NewStateSvc(logs.Trades)
+	s.stSvc.Dir = cfg.StateDir
+	s.stSvc.Clock = a.Clock
+	return s, nil
+}
+
+func (s *session) Close() error {
+	if s.tape != nil {
+		s.tape.Close()
+	}
+	return s.logs.Close()
+}
+
+//loadState reads the persisted state of the product with the trading schedule, the paper position is carried over from it
+func (s *session) loadState() (*svc.State, error) {
+	state, err := s.stSvc.LoadState(s.cfg.Product, s.cfg.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state %s", err.Error())
+	}
+	state.SetSchedule(s.cfg.Trading)
+	if s.paper != nil {
+		state.Guard(func() {
+			s.paper.SetBalance(s.cfg.QuoteCurrency(), state.AvailableFunds)
+			s.paper.SetBalance(s.cfg.BaseCurrency(), state.NumberOwn)
+		})
+	}
+	return state, nil
+}
+
+//loadCredentials reads api_key, api_passphrase and api_secret in the environment namespace and adds them to the redactor.
+//Environments that do not require credentials start without them, only Coinbase Pro keys have a passphrase.
+func (a *App) loadCredentials(settings secrets.Settings, env environment.Environment, redactor *secrets.Redactor) (key, passphrase, secret string, err error) {
+	src, err := secrets.New(settings, a.Passphrase)
+	if err != nil {
+		return "", "", "", err
+	}
+	if settings.Source == secrets.SourceConfig {
+		log.Println("api credentials are read from the plaintext config, see secrets.source")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	values := make([]string, 3)
+	for i, name := range []string{"api_key", "api_passphrase", "api_secret"} {
+		if name == "api_passphrase" && !env.Passphrase() {
+			continue
+		}
+		values[i], err = src.Get(ctx, env.SecretName(name))
+		if !env.Credentials && errors.Is(err, secrets.ErrNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return "", "", "", err
+		}
+		redactor.Add(values[i])
+	}
+	return values[0], values[1], values[2], nil
+}
+
+//newClient is the coinbase pro client with request logs and metrics
+func (a *App) newClient(env environment.Environment, key, passphrase, secret string) proclient.ProClientInterface {
+	client := coinbasepro.NewClient()
+	client.UpdateConfig(&coinbasepro.ClientConfig{
+		BaseURL:    env.BaseURL,
+		Key:        key,
+		Passphrase: passphrase,
+		Secret:     secret,
+	})
+
+	client.HTTPClient.Transport = a.newTransport()
+	return proclient.NewClient(client)
+}
+
+//newAdvancedClient is the advanced trade client with request logs and metrics, without a key name requests are not signed
+func (a *App) newAdvancedClient(env environment.Environment, keyName, secret string) (*advtrade.Client, error) {
+	var signer *advtrade.Signer
+	if keyName != "" {
+		var err error
+		signer, err = advtrade.NewSigner(keyName, secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client := advtrade.NewClient(env.BaseURL, signer)
+	client.HTTPClient.Transport = a.newTransport()
+	return client, nil
+}
+
+//newKrakenClient is the kraken client with request logs and metrics
+func (a *App) newKrakenClient(env environment.Environment, key, secret string) (*kraken.Client, error) {
+	client, err := kraken.NewClient(env.BaseURL, key, secret)
+	if err != nil {
+		return nil, err
+	}
+	client.HTTPClient.Transport = a.newTransport()
+	return client, nil
+}
+
+//newTransport logs every request and response and counts them in the metrics, with -record it also writes them to the cassette
+func (a *App) newTransport() http.RoundTripper {
+	var next http.RoundTripper = metrics.NewTransport(http.DefaultTransport)
+	if a.recorder != nil {
+		next = a.recorder
+	}
+	return &loghttp.Transport{
+		Transport: next,
+		LogRequest: func(req *http.Request) {
+			log.Printf("[%p] %s %s", req, req.Method, req.URL)
+		},
+		LogResponse: func(resp *http.Response) {
+			log.Printf("[%p] %d %s", resp.Request, resp.StatusCode, resp.Request.URL)
+		},
+	}
+}
+
+//sealSecrets seal-secrets <secrets.json> <secrets.enc> writes an encrypted secrets file
+func (a *App) sealSecrets(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seal-secrets", flag.ContinueOnError)
+	fs.SetOutput(a.Out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: seal-secrets <secrets.json> <secrets.enc>")
+	}
+	plain, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	passphrase, err := a.Passphrase()
+	if err != nil {
+		return err
+	}
+	b, err := secrets.Seal(plain, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.Arg(1), b, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.Out, "sealed %s to %s\n", fs.Arg(0), fs.Arg(1))
+	return nil
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/cassette"
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/environment"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/secrets"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeTime ticks at once Ticks times, then stops the loop like a done context
+type fakeTime struct {
+	Ticks int
+}
+
+func (f *fakeTime) GetStartAndEnd(ctx context.Context, t time.Time) (time.Time, time.Time, time.Time, error) {
+	if f.Ticks == 0 || ctx.Err() != nil {
+		return t, t, t, context.Canceled
+	}
+	f.Ticks--
+	return t, t.Add(time.Hour * -2), t, nil
+}
+
+//writeConfig writes a local-mock config with its state dir in a temp dir, extra is appended to it
+func writeConfig(t *testing.T, extra string) (path, stateDir string) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	dir := t.TempDir()
+	stateDir = filepath.Join(dir, "state")
+	path = filepath.Join(dir, "config.yaml")
+	conf := fmt.Sprintf("product: BTC-USD\nseed: 100\nenvironment: local-mock\nstate_dir: %s\n%s", stateDir, extra)
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, stateDir
+}
+
+func newTestApp(client proclient.ProClientInterface) (*App, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	a := NewApp(out)
+	a.NewClient = func(env environment.Environment, key, passphrase, secret string) proclient.ProClientInterface {
+		return client
+	}
+	a.TimeSvc = &fakeTime{}
+	a.Passphrase = func() (string, error) { return "correct horse", nil }
+	return a, out
+}
+
+//saveTestState persists a state for BTC-USD in dir
+func saveTestState(t *testing.T, dir string, change func(s *svc.State)) {
+	stSvc := svc.NewStateSvc(nil)
+	stSvc.Dir = dir
+	s := stSvc.NewState("BTC-USD", decimal.NewFromInt(100))
+	change(s)
+	if err := stSvc.SaveState(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadTestState(t *testing.T, dir string) *svc.State {
+	stSvc := svc.NewStateSvc(nil)
+	stSvc.Dir = dir
+	s, err := stSvc.LoadState("BTC-USD", decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestApp_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "Happy Path. Help.", args: []string{"help"}, want: "usage: cryptobot <command> [flags]"},
+		{name: "Happy Path. Command help.", args: []string{"status", "-h"}, want: "-confirm-production"},
+		{name: "Sad Path. Unknown command.", args: []string{"buy"}, want: "usage: cryptobot <command> [flags]", wantErr: true},
+		{name: "Sad Path. Unknown flag.", args: []string{"status", "-bogus"}, wantErr: true},
+		{name: "Sad Path. Missing config.", args: []string{"status", "-config", "does-not-exist.yaml"}, wantErr: true},
+		{name: "Sad Path. Unknown environment.", args: []string{"status", "-config", "CONFIG", "-env", "staging"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := writeConfig(t, "")
+			a, out := newTestApp(proclient.NewMockClient())
+			var args []string
+			for _, arg := range tt.args {
+				if arg == "CONFIG" {
+					arg = path
+				}
+				args = append(args, arg)
+			}
+			err := a.Run(context.Background(), args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Contains(t, out.String(), tt.want)
+		})
+	}
+}
+
+func TestApp_ProductionConfirm(t *testing.T) {
+	path, _ := writeConfig(t, "api_key: key\napi_passphrase: passphrase\napi_secret: c2VjcmV0\n")
+	client := proclient.NewMockClient()
+	client.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "150.00", Available: "150.00"}}
+	a, _ := newTestApp(client)
+
+	//read only commands do not need the confirm flag, the ones that trade do
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path, "-env", "production"}))
+	err := a.Run(context.Background(), []string{"sell", "-config", path, "-env", "production"})
+	assert.Contains(t, err.Error(), "-confirm-production")
+	err = a.Run(context.Background(), []string{"orders", "-config", path, "-env", "production", "cancel"})
+	assert.Contains(t, err.Error(), "-confirm-production")
+}
+
+func TestApp_SealSecrets(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "secrets.json"), filepath.Join(dir, "secrets.enc")
+	if err := os.WriteFile(in, []byte(`{"api_key": "key"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := newTestApp(proclient.NewMockClient())
+	assert.Nil(t, a.Run(context.Background(), []string{"seal-secrets", in, out}))
+
+	src := &secrets.EncryptedFile{Path: out, Passphrase: a.Passphrase}
+	key, err := src.Get(context.Background(), "api_key")
+	assert.Nil(t, err)
+	assert.Equal(t, "key", key)
+
+	assert.NotNil(t, a.Run(context.Background(), []string{"seal-secrets", in}))
+}
+
+func TestApp_Record(t *testing.T) {
+	sim := proclient.NewSimulator()
+	defer sim.Close()
+	sim.SetBalance("USD", decimal.NewFromInt(150))
+	sim.SetBook("BTC-USD", decimal.RequireFromString("99.5"), decimal.RequireFromString("100.5"))
+	path, _ := writeConfig(t, fmt.Sprintf("local_api_key: %s\nlocal_api_passphrase: %s\nlocal_api_secret: %s\nenvironments:\n  local-mock:\n    base_url: %s\n",
+		sim.Key, sim.Passphrase, sim.Secret, sim.URL))
+	tape := filepath.Join(t.TempDir(), "status.jsonl")
+	a := NewApp(&bytes.Buffer{})
+	a.TimeSvc = &fakeTime{}
+
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path, "-record", tape}))
+	interactions, err := cassette.Load(tape)
+	assert.Nil(t, err)
+	var uris []string
+	for _, in := range interactions {
+		uris = append(uris, in.Request.URI)
+		if in.Request.URI == "/accounts" {
+			assert.Contains(t, in.Response.Body, `"currency":"USD"`)
+		}
+	}
+	assert.Contains(t, uris, "/accounts")
+	recorded, _ := os.ReadFile(tape)
+	assert.NotContains(t, string(recorded), sim.Key)
+	assert.NotContains(t, string(recorded), sim.Passphrase)
+
+	//without -record nothing more is written
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path}))
+	again, _ := cassette.Load(tape)
+	assert.Len(t, again, len(interactions))
+}
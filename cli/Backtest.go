@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/JasonWBrown/svc"
+)
+
+//backtest replays the product candles between -start and -end through the strategy in the config
+func (a *App) backtest(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("backtest", &o)
+	product := fs.String("product", "", "product to backtest, defaults to the product config key")
+	startFlag := fs.String("start", "", "first candle, 2006-01-02 or RFC3339, defaults to 7 days before end")
+	endFlag := fs.String("end", "", "last candle, 2006-01-02 or RFC3339, defaults to now")
+	granularity := fs.Duration("granularity", time.Minute*5, "candle size, one of 1m, 5m, 15m, 1h, 6h or 24h")
+	fee := fs.Float64("fee", 0.005, "taker fee as a fraction of the order value")
+	verbose := fs.Bool("v", false, "keep the state logs of every candle")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	end := time.Now().UTC()
+	if *endFlag != "" {
+		t, err := parseTime(*endFlag)
+		if err != nil {
+			return err
+		}
+		end = t
+	}
+	start := end.Add(-time.Hour * 24 * 7)
+	if *startFlag != "" {
+		t, err := parseTime(*startFlag)
+		if err != nil {
+			return err
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("start %s must be before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	s, err := a.setup(o, *product, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	candles, err := svc.HistoricCandles(ctx, s.ex, s.cfg.Product, start, end, *granularity)
+	if err != nil {
+		return err
+	}
+
+	//the state logs every candle, they are only useful when debugging the strategy
+	if !*verbose {
+		out := log.Writer()
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(out)
+	}
+	bt := svc.NewBacktest()
+	bt.Fee = *fee
+	bt.Schedule = s.cfg.Trading
+	r, err := bt.Run(ctx, s.cfg.Product, s.cfg.Seed, s.cfg.Strategy, candles)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.Out, "%s strategy %+v\n%s\n", s.cfg.Product, s.cfg.Strategy, r)
+	return nil
+}
+
+//parseTime reads a date or an RFC3339 time
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q, want 2006-01-02 or RFC3339", v)
+	}
+	return t, nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/proclient"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_backtest(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	var rates []coinbasepro.HistoricRate
+	for i, p := range []float64{100, 100, 104, 115} {
+		rates = append(rates, coinbasepro.HistoricRate{Time: start.Add(time.Hour * time.Duration(i)), Open: p, Close: p})
+	}
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Happy Path. Buy and take the profit.",
+			args: []string{"-start", "2021-08-01", "-end", "2021-08-01T04:00:00Z", "-granularity", "1h"},
+			want: []string{"BTC-USD strategy {BuyGrowth:0.03", "4 candles", "buys 1 sells 1", "funds 109.47", "return 9.47%"},
+		},
+		{
+			name:   "Happy Path. Strategy from the config.",
+			config: "strategy:\n  buy_growth: 0.05\n",
+			args:   []string{"-start", "2021-08-01", "-end", "2021-08-01T04:00:00Z", "-granularity", "1h", "-fee", "0"},
+			want:   []string{"BuyGrowth:0.05", "buys 1 sells 0", "position 0.86956521 at 115.00"},
+		},
+		{
+			name:    "Sad Path. Start after end.",
+			args:    []string{"-start", "2021-08-02", "-end", "2021-08-01"},
+			wantErr: true,
+		},
+		{
+			name:    "Sad Path. Bad time.",
+			args:    []string{"-start", "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := writeConfig(t, tt.config)
+			client := proclient.NewMockClient()
+			client.HistoricRates = rates
+			a, out := newTestApp(client)
+
+			err := a.Run(context.Background(), append([]string{"backtest", "-config", path}, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("backtest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				assert.Contains(t, out.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//orders lists or cancels the open orders of the product, -all covers every product
+func (a *App) orders(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("orders", &o)
+	product := fs.String("product", "", "product of the orders, defaults to the product config key")
+	all := fs.Bool("all", false, "orders of every product")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	action := "list"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	if action != "list" && action != "cancel" {
+		return fmt.Errorf("unknown orders action %q, want list or cancel", action)
+	}
+
+	s, err := a.setup(o, *product, action == "list")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	productID := s.cfg.Product
+	if *all {
+		productID = ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	if action == "cancel" {
+		ids, err := s.ex.CancelAllOrders(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("failed to cancel orders %s", err.Error())
+		}
+		for _, id := range ids {
+			fmt.Fprintf(a.Out, "cancelled %s\n", id)
+		}
+		fmt.Fprintf(a.Out, "%d orders cancelled\n", len(ids))
+		return nil
+	}
+
+	orders, err := s.ex.ListOpenOrders(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("failed to list orders %s", err.Error())
+	}
+	for _, order := range orders {
+		fmt.Fprintf(a.Out, "%s %s %s %s size %s price %s funds %s filled %s status %s created %s\n",
+			order.ID, order.Product, order.Side, order.Type, order.Size, order.Price, order.Funds,
+			order.FilledSize, order.Reason, order.Created.Format(time.RFC3339))
+	}
+	fmt.Fprintf(a.Out, "%d open orders\n", len(orders))
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JasonWBrown/proclient"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeOrders serves two pages of open orders and cancels everything
+func fakeOrders(t *testing.T) (*httptest.Server, *[]string) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.Method+" "+r.URL.RawQuery)
+		if r.URL.Path != "/orders" {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			json.NewEncoder(w).Encode([]string{"1", "2"})
+		case r.URL.Query().Get("after") == "":
+			w.Header().Set("CB-AFTER", "page2")
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "1", "product_id": "BTC-USD", "side": "buy", "type": "limit", "price": "90.00", "size": "1.0", "status": "open"}})
+		default:
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "2", "product_id": "BTC-USD", "side": "sell", "type": "limit", "price": "120.00", "size": "1.0", "status": "open"}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries
+}
+
+func TestApp_orders(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        []string
+		wantQueries []string
+		wantErr     bool
+	}{
+		{
+			name:        "Happy Path. List is the default.",
+			want:        []string{"1 BTC-USD buy limit size 1 price 90", "2 BTC-USD sell limit size 1 price 120", "2 open orders"},
+			wantQueries: []string{"GET product_id=BTC-USD&status=open", "GET after=page2&product_id=BTC-USD&status=open"},
+		},
+		{
+			name:        "Happy Path. List every product.",
+			args:        []string{"-all", "list"},
+			want:        []string{"2 open orders"},
+			wantQueries: []string{"GET status=open", "GET after=page2&status=open"},
+		},
+		{
+			name:        "Happy Path. Cancel.",
+			args:        []string{"cancel"},
+			want:        []string{"cancelled 1", "cancelled 2", "2 orders cancelled"},
+			wantQueries: []string{"DELETE product_id=BTC-USD"},
+		},
+		{
+			name:    "Sad Path. Unknown action.",
+			args:    []string{"amend"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := writeConfig(t, "")
+			server, queries := fakeOrders(t)
+			client := coinbasepro.NewClient()
+			client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: server.URL, Key: "key", Passphrase: "passphrase", Secret: "c2VjcmV0"})
+			a, out := newTestApp(proclient.NewClient(client))
+
+			err := a.Run(context.Background(), append([]string{"orders", "-config", path}, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("orders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				assert.Contains(t, out.String(), want)
+			}
+			assert.Equal(t, tt.wantQueries, *queries)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/svc"
+)
+
+//reconcile resolves the orders left pending by the last run and checks the state against the exchange balances.
+//The state can claim more than the account holds after a manual trade or a lost order,
+//-apply caps the position and the funds to the balances.
+func (a *App) reconcile(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("reconcile", &o)
+	product := fs.String("product", "", "product to reconcile, defaults to the product config key")
+	apply := fs.Bool("apply", false, "cap the state position and funds to the exchange balances")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.setup(o, *product, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	state.ResolvePending(ctx, s.cbSvc)
+	state.Guard(func() {
+		saveState(s.stSvc, state)
+		for _, p := range state.PendingOrders {
+			fmt.Fprintf(a.Out, "unresolved %s order %s since %s\n", p.Side, p.ID, p.Created.Format(time.RFC3339))
+		}
+	})
+
+	balancesCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	balances, err := s.ex.GetBalances(balancesCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to get accounts %s", err.Error())
+	}
+	base := exchange.FindBalance(balances, s.cfg.BaseCurrency()).Available
+	quote := exchange.FindBalance(balances, s.cfg.QuoteCurrency()).Available
+
+	state.Guard(func() {
+		drift := false
+		if state.NumberOwn.GreaterThan(base) {
+			drift = true
+			fmt.Fprintf(a.Out, "position %s is above the %s balance %s\n", state.NumberOwn, s.cfg.BaseCurrency(), base)
+		}
+		if state.AvailableFunds.GreaterThan(quote) {
+			drift = true
+			fmt.Fprintf(a.Out, "funds %s are above the %s balance %s\n", state.AvailableFunds, s.cfg.QuoteCurrency(), quote)
+		}
+		if !drift {
+			fmt.Fprintf(a.Out, "%s is in sync with the exchange\n", state.Product)
+			return
+		}
+		if !*apply {
+			fmt.Fprintln(a.Out, "run with -apply to cap the state to the balances")
+			return
+		}
+		capState(state, base, quote)
+		saveState(s.stSvc, state)
+		fmt.Fprintf(a.Out, "applied %s\n", state)
+	})
+	return nil
+}
+
+//capState caps the position to base and the funds to quote, a position that is gone clears the buy
+func capState(state *svc.State, base, quote decimal.Decimal) {
+	if state.NumberOwn.GreaterThan(base) {
+		state.NumberOwn = base
+		if base.IsZero() {
+			state.ResetState()
+		}
+	}
+	if state.AvailableFunds.GreaterThan(quote) {
+		state.AvailableFunds = quote
+	}
+	state.PrintStateChange("reconcile")
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_reconcile(t *testing.T) {
+	position := func(s *svc.State) {
+		s.NumberOwn = decimal.NewFromInt(1)
+		s.BuyPrice = decimal.NewFromInt(100)
+		s.LockPrice = decimal.NewFromInt(103)
+		s.LockPriceSet = true
+		s.AvailableFunds = decimal.Zero
+	}
+	tests := []struct {
+		name      string
+		args      []string
+		state     func(s *svc.State)
+		accounts  []coinbasepro.Account
+		want      string
+		wantOwn   decimal.Decimal
+		wantFunds decimal.Decimal
+		wantLock  bool
+	}{
+		{
+			name:      "Happy Path. In sync.",
+			state:     func(s *svc.State) {},
+			accounts:  []coinbasepro.Account{{Currency: "USD", Available: "150.00"}},
+			want:      "BTC-USD is in sync with the exchange",
+			wantFunds: decimal.NewFromInt(100),
+		},
+		{
+			name:     "Happy Path. Position above the balance is reported.",
+			state:    position,
+			accounts: []coinbasepro.Account{{Currency: "BTC", Available: "0.5"}},
+			want:     "position 1 is above the BTC balance 0.5",
+			wantOwn:  decimal.NewFromInt(1),
+			wantLock: true,
+		},
+		{
+			name:     "Happy Path. Position above the balance is capped.",
+			args:     []string{"-apply"},
+			state:    position,
+			accounts: []coinbasepro.Account{{Currency: "BTC", Available: "0.5"}},
+			want:     "applied",
+			wantOwn:  decimal.RequireFromString("0.5"),
+			wantLock: true,
+		},
+		{
+			name:     "Happy Path. Position that is gone clears the buy.",
+			args:     []string{"-apply"},
+			state:    position,
+			accounts: []coinbasepro.Account{{Currency: "USD", Available: "150.00"}},
+			want:     "applied",
+		},
+		{
+			name:      "Happy Path. Funds above the balance are capped.",
+			args:      []string{"-apply"},
+			state:     func(s *svc.State) {},
+			accounts:  []coinbasepro.Account{{Currency: "USD", Available: "60.00"}},
+			want:      "funds 100 are above the USD balance 60",
+			wantFunds: decimal.NewFromInt(60),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, stateDir := writeConfig(t, "")
+			saveTestState(t, stateDir, tt.state)
+			client := proclient.NewMockClient()
+			client.Accounts = tt.accounts
+			a, out := newTestApp(client)
+
+			assert.Nil(t, a.Run(context.Background(), append([]string{"reconcile", "-config", path}, tt.args...)))
+			assert.Contains(t, out.String(), tt.want)
+			s := loadTestState(t, stateDir)
+			assert.Equal(t, tt.wantOwn, s.NumberOwn)
+			assert.Equal(t, tt.wantFunds, s.AvailableFunds)
+			assert.Equal(t, tt.wantLock, s.LockPriceSet)
+		})
+	}
+}
+
+func TestApp_reconcilePending(t *testing.T) {
+	path, stateDir := writeConfig(t, "")
+	saveTestState(t, stateDir, func(s *svc.State) {
+		s.AvailableFunds = decimal.Zero
+		s.PendingOrders = []svc.PendingOrder{{ID: "1", Side: "buy", Trigger: "buy", Size: decimal.NewFromInt(100), Price: decimal.NewFromInt(100)}}
+	})
+	client := proclient.NewMockClient()
+	client.SavedOrder = coinbasepro.Order{ID: "1", Status: "done", DoneReason: "filled", FilledSize: "0.9", ExecutedValue: "90.00"}
+	client.Accounts = []coinbasepro.Account{{Currency: "BTC", Available: "0.9"}}
+	a, out := newTestApp(client)
+
+	assert.Nil(t, a.Run(context.Background(), []string{"reconcile", "-config", path}))
+	assert.Contains(t, out.String(), "BTC-USD is in sync with the exchange")
+	s := loadTestState(t, stateDir)
+	assert.Empty(t, s.PendingOrders)
+	assert.Equal(t, decimal.RequireFromString("0.9"), s.NumberOwn)
+	assert.Equal(t, decimal.NewFromInt(100), s.BuyPrice)
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JasonWBrown/api"
+	"github.com/JasonWBrown/config"
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/metrics"
+	"github.com/JasonWBrown/notify"
+	"github.com/JasonWBrown/svc"
+	"github.com/JasonWBrown/telegram"
+)
+
+//run trades until ctx is done or an error halts the bot
+func (a *App) run(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("run", &o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.setup(o, "", false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	cfg := s.cfg
+
+	//stop when ctx is done, orders in flight get shutdown.grace to be confirmed
+	orderCtx, cancelOrders := orderContext(ctx, cfg.Shutdown.Grace)
+	defer cancelOrders()
+
+	//expose prometheus metrics
+	if addr := cfg.MetricsAddr; addr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, addr); err != nil {
+				log.Printf("metrics server stopped %s", err.Error())
+			}
+		}()
+	}
+
+	//notify on state changes, repeated loop errors and breaker trips
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return err
+	}
+	if notifier != nil {
+		queue := notify.NewQueue(notifier, 100, time.Second*10)
+		defer closeQueue(queue)
+		s.stSvc.Notifier = queue
+		s.stSvc.ErrorThreshold = cfg.Notify.ErrorThreshold
+	}
+
+	//state tracker, picks up where the last run stopped
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	//the product must be listed and the funds the bot trades with must be in the account
+	var funds decimal.Decimal
+	state.Guard(func() {
+		funds = state.AvailableFunds
+		if config.HasStrategy() {
+			state.Strategy = cfg.Strategy
+		}
+	})
+	checkCtx, cancelCheck := context.WithTimeout(ctx, time.Second*30)
+	err = cfg.CheckExchange(checkCtx, s.ex, funds)
+	cancelCheck()
+	if err != nil {
+		return err
+	}
+
+	//strategy params are reloaded when the config file changes
+	config.WatchStrategy(func(st svc.Strategy) {
+		state.Guard(func() {
+			state.Strategy = st
+			state.PrintStateChange("config strategy update")
+		})
+	})
+	state.ResolvePending(orderCtx, s.cbSvc)
+	state.Guard(func() {
+		saveState(s.stSvc, state)
+	})
+
+	//status and control api
+	if addr := cfg.API.Addr; addr != "" {
+		server := api.NewServer(cfg.API.Token, s.cbSvc, state)
+		server.OrderCtx = orderCtx
+		go func() {
+			if err := server.ListenAndServe(ctx, addr); err != nil {
+				log.Printf("api server stopped %s", err.Error())
+			}
+		}()
+	}
+
+	//telegram commands from the allowed chats
+	if token := cfg.Telegram.Token; token != "" {
+		bot := telegram.NewBot(token, cfg.Telegram.AllowedChats, s.cbSvc, state)
+		bot.OrderCtx = orderCtx
+		go func() {
+			if err := bot.Run(ctx); err != nil {
+				log.Printf("telegram bot stopped %s", err.Error())
+			}
+		}()
+	}
+
+	tSvc := a.TimeSvc
+	if tSvc == nil {
+		t := svc.NewTimeSvc()
+		t.Clock = a.Clock
+		t.Interval = cfg.Schedule.Interval
+		t.Align = cfg.Schedule.Align
+		tSvc = t
+	}
+	loop(ctx, orderCtx, a.Clock, tSvc, s.cbSvc, s.value, state, func() { saveState(s.stSvc, state) })
+
+	log.Println("shutting down")
+	flatten := false
+	state.Guard(func() {
+		flatten = cfg.Shutdown.Flatten && !state.NumberOwn.IsZero()
+	})
+	if flatten {
+		if err := state.ForceSell(orderCtx, s.cbSvc); err != nil {
+			log.Printf("failed to flatten position %s", err.Error())
+		}
+	}
+	state.Guard(func() {
+		saveState(s.stSvc, state)
+		state.PrintStateChange("shutdown")
+	})
+	return nil
+}
+
+//loop checks the market on every tick of tSvc until ctx is done or an error halts the bot, save runs under the state lock.
+//The value of the state is reported in the currency of value.
+func loop(ctx, orderCtx context.Context, clock svc.Clock, tSvc svc.TimeSvcInterface, cbSvc svc.CoinbaseSvcInterface, value *svc.Converter, state *svc.State, save func()) {
+	t := clock.Now()
+	var loopStart time.Time
+	for {
+		if !loopStart.IsZero() {
+			metrics.LoopDuration.Observe(clock.Now().Sub(loopStart).Seconds())
+		}
+		loopStart = clock.Now()
+
+		state.Guard(func() {
+			state.PrintStateChange("loop begin")
+		})
+		_, start, end, err := tSvc.GetStartAndEnd(ctx, t)
+		if err != nil {
+			return
+		}
+		marketCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+		open, close, err := cbSvc.GetMarketConditions(marketCtx, state.Product, start, end)
+		cancel()
+		if err != nil {
+			state.Guard(func() {
+				state.Heartbeat(err)
+			})
+			if handleError(ctx, clock, tSvc, state, err) {
+				return
+			}
+			continue
+		}
+
+		//orders left pending by a timeout are retried until they are filled or rejected
+		state.ResolvePending(orderCtx, cbSvc)
+		if !state.Buy(orderCtx, cbSvc, open, close) {
+			state.Guard(func() {
+				state.Lock(close)
+			})
+
+			state.Sell(orderCtx, cbSvc, close)
+		}
+		var orderErr error
+		state.Guard(func() {
+			orderErr = state.OrderErr()
+			state.Heartbeat(orderErr)
+			state.ReportMetrics(close)
+			save()
+		})
+		if handleError(ctx, clock, tSvc, state, orderErr) {
+			return
+		}
+		reportValue(ctx, value, state, close)
+	}
+}
+
+//reportValue sets the value metric of state at lastPrice, a failed conversion is logged and the metric keeps its last value
+func reportValue(ctx context.Context, value *svc.Converter, state *svc.State, lastPrice decimal.Decimal) {
+	var amount decimal.Decimal
+	var product, currency string
+	var err error
+	state.Guard(func() {
+		amount, err = state.Value(lastPrice)
+		product, currency = state.Product, state.QuoteCurrency
+	})
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	converted := decimal.Zero
+	if err == nil {
+		converted, err = value.Convert(ctx, amount, currency)
+	}
+	if err != nil {
+		log.Printf("failed to convert the value of %s to %s %s", product, value.Currency, err.Error())
+		return
+	}
+	metrics.Value.WithLabelValues(product, value.Currency).Set(converted.Float64())
+}
+
+//orderContext stays alive for grace after ctx is done so orders in flight can still be confirmed
+func orderContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	orderCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-orderCtx.Done():
+			return
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-orderCtx.Done():
+		}
+	}()
+	return orderCtx, cancel
+}
+
+//errorPause is how long buying stays paused after an error, the exchange gets the time to come back
+const errorPause = time.Hour
+
+//handleError applies the policy for the kind of err, it returns true when the bot must stop
+func handleError(ctx context.Context, clock svc.Clock, tSvc svc.TimeSvcInterface, state *svc.State, err error) bool {
+	if err == nil {
+		return false
+	}
+	switch policy := svc.PolicyFor(err); policy {
+	case svc.PolicyHalt:
+		log.Printf("ALERT halting %s %s", state.Product, err.Error())
+		state.Notify(notify.KindCircuitBreaker, policy.String(), err.Error())
+		return true
+	case svc.PolicyPauseBuying:
+		log.Printf("ALERT pausing buys for %s for %s %s", state.Product, errorPause, err.Error())
+		state.Notify(notify.KindCircuitBreaker, policy.String(), err.Error())
+		state.Guard(func() {
+			state.PauseFor(errorPause)
+			state.PrintStateChange("error paused")
+		})
+	case svc.PolicySkip:
+		log.Printf("ALERT skipping a tick of %s %s", state.Product, err.Error())
+		state.Notify(notify.KindAPIErrors, policy.String(), err.Error())
+		if _, _, _, err := tSvc.GetStartAndEnd(ctx, clock.Now()); err != nil {
+			return true
+		}
+	case svc.PolicyBackoff:
+		wait := svc.RetryAfter(err)
+		if wait <= 0 {
+			wait = time.Minute
+		}
+		log.Printf("backing off %s for %s %s", wait, state.Product, err.Error())
+		clock.Sleep(ctx, wait)
+	}
+	return false
+}
+
+//closeQueue gives queued notifications a few seconds to be sent
+func closeQueue(queue *notify.Queue) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	queue.Close(ctx)
+}
+
+func saveState(stSvc *svc.StateSvc, state *svc.State) {
+	if err := stSvc.SaveState(state); err != nil {
+		log.Printf("failed to save state %s", err.Error())
+	}
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_run(t *testing.T) {
+	tests := []struct {
+		name     string
+		ticks    int
+		accounts []coinbasepro.Account
+		wantOwn  decimal.Decimal
+		wantErr  bool
+	}{
+		{
+			name:     "Happy Path. Buys on growth and saves the state.",
+			ticks:    1,
+			accounts: []coinbasepro.Account{{Currency: "USD", Balance: "150.00", Available: "150.00"}},
+			wantOwn:  decimal.RequireFromString("0.9"),
+		},
+		{
+			name:     "Happy Path. Stops without a tick.",
+			accounts: []coinbasepro.Account{{Currency: "USD", Balance: "150.00", Available: "150.00"}},
+		},
+		{
+			name:     "Sad Path. Seed above the balance.",
+			ticks:    1,
+			accounts: []coinbasepro.Account{{Currency: "USD", Balance: "50.00", Available: "50.00"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, stateDir := writeConfig(t, "")
+			client := proclient.NewMockClient()
+			client.Products = []proclient.Product{{ID: "BTC-USD"}}
+			client.Accounts = tt.accounts
+			client.HistoricRates = []coinbasepro.HistoricRate{{Open: 100.0}}
+			client.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "104.00"}}}
+			client.SavedOrder = coinbasepro.Order{ID: "1", Status: "done", DoneReason: "filled", FilledSize: "0.9", ExecutedValue: "93.60"}
+			a, _ := newTestApp(client)
+			a.TimeSvc = &fakeTime{Ticks: tt.ticks}
+
+			err := a.Run(context.Background(), []string{"run", "-config", path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			s := loadTestState(t, stateDir)
+			assert.Equal(t, tt.wantOwn, s.NumberOwn)
+			assert.False(t, s.LastCheck.IsZero() && tt.ticks > 0, "the loop heartbeat is saved")
+		})
+	}
+}
+
+func TestApp_runDefault(t *testing.T) {
+	path, _ := writeConfig(t, "")
+	client := proclient.NewMockClient()
+	client.Products = []proclient.Product{{ID: "BTC-USD"}}
+	client.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "150.00", Available: "150.00"}}
+	a, out := newTestApp(client)
+
+	//flags without a command run the bot
+	assert.Nil(t, a.Run(context.Background(), []string{"-config", path}))
+	assert.Empty(t, out.String())
+}
+
+func TestApp_runSchedule(t *testing.T) {
+	path, stateDir := writeConfig(t, "schedule:\n  align: 5m\n")
+	client := proclient.NewMockClient()
+	client.Products = []proclient.Product{{ID: "BTC-USD"}}
+	client.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "150.00", Available: "150.00"}}
+	client.HistoricRates = []coinbasepro.HistoricRate{{Open: 100.0}}
+	client.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "100.00"}}}
+	a, _ := newTestApp(client)
+	start := time.Date(2021, time.August, 1, 12, 3, 0, 0, time.UTC)
+	clock := svc.NewFakeClock(start)
+	a.Clock = clock
+	a.TimeSvc = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run(ctx, []string{"run", "-config", path})
+	}()
+
+	//the first tick waits for the 12:05 candle
+	waitSleepers(t, clock, 1)
+	clock.Advance(time.Minute * 2)
+	waitSleepers(t, clock, 1)
+	cancel()
+	assert.Nil(t, <-done)
+
+	s := loadTestState(t, stateDir)
+	assert.Equal(t, time.Date(2021, time.August, 1, 12, 5, 0, 0, time.UTC), s.LastCheck.UTC())
+}
+
+func Test_handleError(t *testing.T) {
+	exErr := func(kind error) error {
+		return &svc.ExchangeError{Kind: kind, Err: fmt.Errorf("its broke")}
+	}
+	tests := []struct {
+		name          string
+		err           error
+		ticks         int
+		wantStop      bool
+		wantPaused    bool
+		wantTicksLeft int
+	}{
+		{name: "Happy Path. No error.", ticks: 1, wantTicksLeft: 1},
+		{name: "Happy Path. Network errors retry on the next tick.", err: exErr(svc.ErrNetwork), ticks: 1, wantTicksLeft: 1},
+		{name: "Happy Path. A rejected order sits out a tick.", err: exErr(svc.ErrOrderRejected), ticks: 2, wantTicksLeft: 1},
+		{name: "Happy Path. A malformed response sits out a tick.", err: exErr(svc.ErrMalformedResponse), ticks: 2, wantTicksLeft: 1},
+		{name: "Happy Path. Insufficient funds pauses buying.", err: exErr(svc.ErrInsufficientFunds), ticks: 1, wantPaused: true, wantTicksLeft: 1},
+		{name: "Sad Path. Stopped while sitting out a tick.", err: exErr(svc.ErrOrderRejected), wantStop: true},
+		{name: "Sad Path. Bad key halts.", err: exErr(svc.ErrAuthFailed), ticks: 1, wantStop: true, wantTicksLeft: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+			clock := svc.NewFakeClock(start)
+			stSvc := svc.NewStateSvc(nil)
+			stSvc.Clock = clock
+			state := stSvc.NewState("BTC-USD", decimal.NewFromInt(100))
+			tSvc := &fakeTime{Ticks: tt.ticks}
+
+			assert.Equal(t, tt.wantStop, handleError(context.Background(), clock, tSvc, state, tt.err))
+			assert.Equal(t, tt.wantPaused, state.Paused)
+			assert.Equal(t, tt.wantTicksLeft, tSvc.Ticks)
+			if tt.wantPaused {
+				assert.Equal(t, start.Add(errorPause), state.PausedUntil, "the pause expires")
+			}
+		})
+	}
+}
+
+//waitSleepers waits for n goroutines to block in Sleep on c
+func waitSleepers(t *testing.T, c *svc.FakeClock, n int) {
+	deadline := time.Now().Add(time.Second * 5)
+	for c.Sleepers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d sleepers, want %d", c.Sleepers(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
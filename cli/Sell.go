@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+//sell liquidates the position of a product at the last price, regardless of strategy.
+//The bot must not be running for the product, the state would be changed under it.
+func (a *App) sell(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("sell", &o)
+	product := fs.String("product", "", "product to sell, defaults to the product config key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.setup(o, *product, false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	//an order left pending by the last run may already have sold the position
+	state.ResolvePending(ctx, s.cbSvc)
+	pending := 0
+	state.Guard(func() {
+		pending = len(state.PendingOrders)
+	})
+	if pending == 0 {
+		err = state.ForceSell(ctx, s.cbSvc)
+	}
+	state.Guard(func() {
+		saveState(s.stSvc, state)
+		if pending != 0 {
+			err = fmt.Errorf("%s has %d pending orders, run reconcile", state.Product, pending)
+			return
+		}
+		if err != nil {
+			return
+		}
+		log.Printf("manual sell of %s", state.Product)
+		fmt.Fprintf(a.Out, "sold %s, funds %s %s realized %s\n", state.Product, state.AvailableFunds, state.QuoteCurrency, state.RealizedPnL)
+	})
+	return err
+}
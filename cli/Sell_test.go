@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_sell(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     func(s *svc.State)
+		want      string
+		wantFunds decimal.Decimal
+		wantErr   bool
+	}{
+		{
+			name: "Happy Path. Sells the position.",
+			state: func(s *svc.State) {
+				s.NumberOwn = decimal.NewFromInt(1)
+				s.BuyPrice = decimal.NewFromInt(100)
+				s.AvailableFunds = decimal.Zero
+			},
+			want:      "sold BTC-USD, funds 109 USD realized 9",
+			wantFunds: decimal.NewFromInt(109),
+		},
+		{
+			name:      "Sad Path. No position.",
+			state:     func(s *svc.State) {},
+			wantFunds: decimal.NewFromInt(100),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, stateDir := writeConfig(t, "")
+			saveTestState(t, stateDir, tt.state)
+			client := proclient.NewMockClient()
+			client.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "110.00"}}}
+			client.SavedOrder = coinbasepro.Order{ID: "1", Status: "done", DoneReason: "filled"}
+			client.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "109.00", Available: "109.00"}}
+			a, out := newTestApp(client)
+
+			err := a.Run(context.Background(), []string{"sell", "-config", path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sell() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Contains(t, out.String(), tt.want)
+			s := loadTestState(t, stateDir)
+			assert.Equal(t, tt.wantFunds, s.AvailableFunds)
+			assert.Equal(t, decimal.Zero, s.NumberOwn)
+		})
+	}
+}
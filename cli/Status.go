@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+//status prints the persisted state of the product, its P&L and value at the last price and the exchange balances
+func (a *App) status(ctx context.Context, args []string) error {
+	var o options
+	fs := a.flagSet("status", &o)
+	product := fs.String("product", "", "product to show, defaults to the product config key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.setup(o, *product, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	state.Guard(func() {
+		fmt.Fprintf(a.Out, "state %s\n", state)
+		fmt.Fprintf(a.Out, "strategy %+v\n", state.Strategy)
+		if ok, reason := state.BuyAllowed(); !ok {
+			fmt.Fprintf(a.Out, "buying blocked, %s\n", reason)
+		}
+		for _, p := range state.PendingOrders {
+			fmt.Fprintf(a.Out, "pending %s order %s %s size %s price %s since %s\n", p.Side, p.ID, p.Trigger, p.Size, p.Price, p.Created.Format(time.RFC3339))
+		}
+		if lastPrice, err := s.cbSvc.GetLastPrice(ctx, state.Product); err == nil {
+			if realized, unrealized, err := state.PnL(lastPrice); err == nil {
+				fmt.Fprintf(a.Out, "last price %.2f realized %.2f unrealized %.2f\n", lastPrice, realized, unrealized)
+			} else {
+				fmt.Fprintf(a.Out, "last price %.2f pnl unavailable %s\n", lastPrice, err.Error())
+			}
+			value, err := state.Value(lastPrice)
+			if err == nil {
+				value, err = s.value.Convert(ctx, value, state.QuoteCurrency)
+			}
+			if err == nil {
+				fmt.Fprintf(a.Out, "value %.2f %s\n", value, s.value.Currency)
+			} else {
+				fmt.Fprintf(a.Out, "value unavailable %s\n", err.Error())
+			}
+		} else {
+			fmt.Fprintf(a.Out, "last price unavailable %s\n", err.Error())
+		}
+	})
+
+	return s.printBalances(ctx, a.Out)
+}
+
+//printBalances prints every account with a balance, the report currency in cents
+func (s *session) printBalances(ctx context.Context, out io.Writer) error {
+	balances, err := s.ex.GetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get accounts %s", err.Error())
+	}
+	for _, b := range balances {
+		if b.Total.IsZero() {
+			continue
+		}
+		format := "balance %s %.8f available %.8f hold %.8f\n"
+		if b.Currency == s.value.Currency {
+			format = "balance %s %.2f available %.2f hold %.2f\n"
+		}
+		fmt.Fprintf(out, format, b.Currency, b.Total, b.Available, b.Hold)
+	}
+	return nil
+}
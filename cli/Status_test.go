@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_status(t *testing.T) {
+	path, stateDir := writeConfig(t, "")
+	saveTestState(t, stateDir, func(s *svc.State) {
+		s.NumberOwn = decimal.NewFromInt(1)
+		s.BuyPrice = decimal.NewFromInt(100)
+		s.AvailableFunds = decimal.Zero
+		s.RealizedPnL = decimal.NewFromInt(5)
+		s.PendingOrders = []svc.PendingOrder{{ID: "42", Side: "sell", Trigger: "8% sell"}}
+	})
+	client := proclient.NewMockClient()
+	client.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "110.00"}}}
+	client.Accounts = []coinbasepro.Account{
+		{Currency: "USD", Balance: "150.00", Available: "150.00", Hold: "0.00"},
+		{Currency: "BTC", Balance: "1.00", Available: "1.00", Hold: "0.00"},
+		{Currency: "ETH", Balance: "0.00", Available: "0.00", Hold: "0.00"},
+	}
+	a, out := newTestApp(client)
+
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path}))
+	assert.Contains(t, out.String(), "state &{Product:BTC-USD NumberOwn:1 ")
+	assert.Contains(t, out.String(), "pending sell order 42 8% sell")
+	assert.Contains(t, out.String(), "last price 110.00 realized 5.00 unrealized 10.00")
+	assert.Contains(t, out.String(), "value 110.00 USD")
+	assert.Contains(t, out.String(), "balance USD 150.00 available 150.00 hold 0.00")
+	assert.Contains(t, out.String(), "balance BTC 1.00")
+	assert.NotContains(t, out.String(), "balance ETH", "empty accounts are left out")
+
+	client.Err = &proclient.HTTPError{StatusCode: 401, Err: coinbasepro.Error{Message: "invalid signature"}}
+	out.Reset()
+	assert.NotNil(t, a.Run(context.Background(), []string{"status", "-config", path}))
+	assert.Contains(t, out.String(), "last price unavailable")
+}
+
+func TestApp_statusReportCurrency(t *testing.T) {
+	path, stateDir := writeConfig(t, "report_currency: eur\n")
+	saveTestState(t, stateDir, func(s *svc.State) {
+		s.NumberOwn = decimal.NewFromInt(1)
+		s.BuyPrice = decimal.NewFromInt(100)
+		s.AvailableFunds = decimal.NewFromInt(110)
+	})
+	client := proclient.NewMockClient()
+	client.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "110.00"}}}
+	client.Accounts = []coinbasepro.Account{
+		{Currency: "USD", Balance: "110.00", Available: "110.00", Hold: "0.00"},
+		{Currency: "EUR", Balance: "2.50", Available: "2.50", Hold: "0.00"},
+	}
+	a, out := newTestApp(client)
+
+	// the mock book prices every product at 110, EUR-USD included
+	client.Products = []proclient.Product{{ID: "BTC-USD"}, {ID: "EUR-USD"}}
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path}))
+	assert.Contains(t, out.String(), "value 2.00 EUR")
+	assert.Contains(t, out.String(), "balance EUR 2.50 available 2.50 hold 0.00", "the report currency is in cents")
+	assert.Contains(t, out.String(), "balance USD 110.00000000")
+
+	client.Products = []proclient.Product{{ID: "BTC-USD"}}
+	out.Reset()
+	assert.Nil(t, a.Run(context.Background(), []string{"status", "-config", path}))
+	assert.Contains(t, out.String(), "value unavailable no product converts USD to EUR")
+}
@@ -0,0 +1,385 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/environment"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/logging"
+	"github.com/JasonWBrown/notify"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/secrets"
+	"github.com/JasonWBrown/svc"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+//EnvPrefix every key can be overridden from the environment, seed is CRYPTOBOT_SEED and api.addr is CRYPTOBOT_API_ADDR
+const EnvPrefix = "CRYPTOBOT"
+
+//Config is everything main needs, read from the .conf file and the environment
+type Config struct {
+	Environment environment.Environment
+	Product     string
+	Report      string          //balances and the value of the state are reported in this currency, see ReportCurrency
+	Seed        decimal.Decimal //quote currency the bot starts trading with
+	StateDir    string
+	Strategy    svc.Strategy
+	RateLimits  proclient.RateLimits
+	Schedule    Schedule
+	Trading     svc.TradingSchedule //when buys may be opened for Product
+	Shutdown    Shutdown
+	MetricsAddr string
+	API         API
+	Telegram    Telegram
+	Log         logging.Config
+	Notify      notify.Config
+	Secrets     secrets.Settings
+}
+
+//Schedule paces the main loop, see svc.TimeSvc
+type Schedule struct {
+	Interval time.Duration
+	Align    time.Duration //tick on candle boundaries of this size instead of every Interval, 0 is off
+}
+
+type Shutdown struct {
+	Grace   time.Duration
+	Flatten bool
+}
+
+type API struct {
+	Addr  string
+	Token string
+}
+
+type Telegram struct {
+	Token        string
+	AllowedChats []int64
+}
+
+//Load reads the config from viper after ReadInConfig, envName overrides the environment key when set
+func Load(envName string) (Config, error) {
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+	viper.SetDefault("state_dir", ".state")
+	viper.SetDefault("shutdown.grace", time.Second*25)
+	viper.SetDefault("schedule.interval", time.Second*20)
+
+	env, err := environment.FromViper(envName)
+	if err != nil {
+		return Config{}, err
+	}
+	strategy, err := StrategyFromViper()
+	if err != nil {
+		return Config{}, err
+	}
+	product := strings.ToUpper(viper.GetString("product"))
+	trading, err := TradingScheduleFromViper(product)
+	if err != nil {
+		return Config{}, err
+	}
+	chats, err := int64s(viper.GetStringSlice("telegram.allowed_chats"))
+	if err != nil {
+		return Config{}, fmt.Errorf("telegram.allowed_chats %s", err.Error())
+	}
+
+	seed := decimal.Zero
+	if viper.IsSet("seed") {
+		seed, err = decimal.Parse(viper.GetString("seed"))
+		if err != nil {
+			return Config{}, fmt.Errorf("seed %s", err.Error())
+		}
+	}
+
+	limits := proclient.DefaultRateLimits()
+	if viper.IsSet("rate_limit.public_per_second") {
+		limits.PublicPerSecond = viper.GetFloat64("rate_limit.public_per_second")
+	}
+	if viper.IsSet("rate_limit.private_per_second") {
+		limits.PrivatePerSecond = viper.GetFloat64("rate_limit.private_per_second")
+	}
+	if viper.IsSet("rate_limit.retries") {
+		limits.Retries = viper.GetInt("rate_limit.retries")
+	}
+
+	return Config{
+		Environment: env,
+		Product:     product,
+		Report:      strings.ToUpper(viper.GetString("report_currency")),
+		Seed:        seed,
+		StateDir:    viper.GetString("state_dir"),
+		Strategy:    strategy,
+		RateLimits:  limits,
+		Schedule: Schedule{
+			Interval: viper.GetDuration("schedule.interval"),
+			Align:    viper.GetDuration("schedule.align"),
+		},
+		Trading: trading,
+		Shutdown: Shutdown{
+			Grace:   viper.GetDuration("shutdown.grace"),
+			Flatten: viper.GetBool("shutdown.flatten"),
+		},
+		MetricsAddr: viper.GetString("metrics.addr"),
+		API: API{
+			Addr:  viper.GetString("api.addr"),
+			Token: viper.GetString("api.token"),
+		},
+		Telegram: Telegram{
+			Token:        viper.GetString("telegram.token"),
+			AllowedChats: chats,
+		},
+		Log:     logging.ConfigFromViper(),
+		Notify:  notify.ConfigFromViper(),
+		Secrets: secrets.SettingsFromViper(),
+	}, nil
+}
+
+//int64s chat ids from the config file are a list, from the environment they are separated by commas or spaces
+func int64s(values []string) ([]int64, error) {
+	var out []int64
+	for _, v := range values {
+		for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+			i, err := strconv.ParseInt(f, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+//StrategyFromViper is DefaultStrategy with the keys set in the strategy section
+//	strategy:
+//	  buy_growth: 0.03
+//	  cooldown: 2h
+//	  lock_growth: 0.03
+//	  lock_step: 0.01
+//	  take_profit: 0.08
+//	  stop_loss: 0.10
+func StrategyFromViper() (svc.Strategy, error) {
+	st := svc.DefaultStrategy()
+	for key, p := range map[string]*float64{
+		"strategy.buy_growth":  &st.BuyGrowth,
+		"strategy.lock_growth": &st.LockGrowth,
+		"strategy.lock_step":   &st.LockStep,
+		"strategy.take_profit": &st.TakeProfit,
+		"strategy.stop_loss":   &st.StopLoss,
+	} {
+		if viper.IsSet(key) {
+			*p = viper.GetFloat64(key)
+		}
+	}
+	if viper.IsSet("strategy.cooldown") {
+		st.Cooldown = viper.GetDuration("strategy.cooldown")
+	}
+	return st, st.Validate()
+}
+
+type tradingSchedule struct {
+	Timezone  string
+	Active    []string
+	Blackouts []blackout
+	Products  map[string]struct {
+		Active    []string
+		Blackouts []blackout
+	}
+}
+
+type blackout struct {
+	Start  string
+	End    string
+	Reason string
+}
+
+//TradingScheduleFromViper reads the trading_schedule section for product.
+//The active windows of a product replace the global ones, its blackouts are added to the global ones.
+//	trading_schedule:
+//	  timezone: America/New_York
+//	  active: ["mon-fri 09:30-16:00"]
+//	  blackouts:
+//	    - {start: 2021-09-22T14:00:00, end: 2021-09-22T15:00:00, reason: fomc}
+//	  products:
+//	    BTC-USD:
+//	      active: ["* 00:00-24:00"]
+func TradingScheduleFromViper(product string) (svc.TradingSchedule, error) {
+	var ts svc.TradingSchedule
+	var raw tradingSchedule
+	if err := viper.UnmarshalKey("trading_schedule", &raw); err != nil {
+		return ts, fmt.Errorf("trading_schedule %s", err.Error())
+	}
+	ts.Location = time.UTC
+	if raw.Timezone != "" {
+		loc, err := time.LoadLocation(raw.Timezone)
+		if err != nil {
+			return ts, fmt.Errorf("trading_schedule.timezone %s", err.Error())
+		}
+		ts.Location = loc
+	}
+	active, blackouts := raw.Active, raw.Blackouts
+	//viper lowercases map keys
+	if p, ok := raw.Products[strings.ToLower(product)]; ok {
+		if len(p.Active) > 0 {
+			active = p.Active
+		}
+		blackouts = append(append([]blackout{}, blackouts...), p.Blackouts...)
+	}
+	for _, spec := range active {
+		w, err := svc.ParseWindow(spec)
+		if err != nil {
+			return ts, fmt.Errorf("trading_schedule %s", err.Error())
+		}
+		ts.Active = append(ts.Active, w)
+	}
+	for _, b := range blackouts {
+		start, err := parseDate(b.Start, ts.Location, false)
+		if err != nil {
+			return ts, fmt.Errorf("trading_schedule blackout %s %s", b.Reason, err.Error())
+		}
+		end, err := parseDate(b.End, ts.Location, true)
+		if err != nil {
+			return ts, fmt.Errorf("trading_schedule blackout %s %s", b.Reason, err.Error())
+		}
+		ts.Blackouts = append(ts.Blackouts, svc.Blackout{Start: start, End: end, Reason: b.Reason})
+	}
+	return ts, ts.Validate()
+}
+
+//parseDate reads 2006-01-02, 2006-01-02T15:04:05 in loc or RFC3339.
+//A plain date as the end of a range includes the whole day.
+func parseDate(v string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", v, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, loc)
+	if err != nil {
+		return t, fmt.Errorf("date %q must look like 2006-01-02 or 2006-01-02T15:04:05", v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+//HasStrategy is true when the config sets strategy params, they win over the persisted state
+func HasStrategy() bool {
+	return viper.IsSet("strategy")
+}
+
+//Validate checks everything that can be checked without the exchange
+func (c Config) Validate() error {
+	if c.BaseCurrency() == "" || c.QuoteCurrency() == "" {
+		return fmt.Errorf("product must look like BTC-USD, got %q", c.Product)
+	}
+	if strings.Trim(c.Report, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("report_currency must look like USD, got %q", c.Report)
+	}
+	if !c.Seed.IsPositive() {
+		return fmt.Errorf("seed must be positive, got %s", c.Seed)
+	}
+	if c.Schedule.Interval <= 0 {
+		return fmt.Errorf("schedule.interval must be positive, got %s", c.Schedule.Interval)
+	}
+	if c.Schedule.Align < 0 {
+		return fmt.Errorf("schedule.align can not be negative, got %s", c.Schedule.Align)
+	}
+	if c.Shutdown.Grace < 0 {
+		return fmt.Errorf("shutdown.grace can not be negative, got %s", c.Shutdown.Grace)
+	}
+	if c.RateLimits.PublicPerSecond < 0 || c.RateLimits.PrivatePerSecond < 0 || c.RateLimits.Retries < 0 {
+		return fmt.Errorf("rate_limit values can not be negative")
+	}
+	if c.API.Addr != "" && c.API.Token == "" {
+		return fmt.Errorf("api.token is required with api.addr")
+	}
+	if c.Telegram.Token != "" && len(c.Telegram.AllowedChats) == 0 {
+		return fmt.Errorf("telegram.allowed_chats is required with telegram.token")
+	}
+	if err := c.Strategy.Validate(); err != nil {
+		return err
+	}
+	if err := c.Trading.Validate(); err != nil {
+		return err
+	}
+	return c.Log.Validate()
+}
+
+//CheckExchange checks the product is listed and the quote balance covers funds
+func (c Config) CheckExchange(ctx context.Context, ex exchange.Exchange, funds decimal.Decimal) error {
+	products, err := ex.GetProducts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get products %s", err.Error())
+	}
+	found := false
+	for _, p := range products {
+		if p.ID == c.Product {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("product %s is not listed on the exchange", c.Product)
+	}
+
+	balances, err := ex.GetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get accounts %s", err.Error())
+	}
+	balance := exchange.FindBalance(balances, c.QuoteCurrency()).Available
+	if funds.GreaterThan(balance) {
+		return fmt.Errorf("funds %s are above the available %s balance %s", funds, c.QuoteCurrency(), balance)
+	}
+	return nil
+}
+
+//BaseCurrency BTC of BTC-USD
+func (c Config) BaseCurrency() string {
+	parts := strings.SplitN(c.Product, "-", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+//QuoteCurrency USD of BTC-USD
+func (c Config) QuoteCurrency() string {
+	parts := strings.SplitN(c.Product, "-", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+//ReportCurrency is report_currency, the quote currency of Product when it is not set
+func (c Config) ReportCurrency() string {
+	if c.Report != "" {
+		return c.Report
+	}
+	return c.QuoteCurrency()
+}
+
+//WatchStrategy calls f with the strategy every time the config file changes.
+//An invalid strategy is logged and f is not called, the bot keeps trading with the last good one.
+func WatchStrategy(f func(svc.Strategy)) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		st, err := StrategyFromViper()
+		if err != nil {
+			log.Printf("ignoring strategy change in %s %s", e.Name, err.Error())
+			return
+		}
+		log.Printf("strategy reloaded from %s", e.Name)
+		f(st)
+	})
+	viper.WatchConfig()
+}
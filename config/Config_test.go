@@ -0,0 +1,236 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/svc"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func readConfig(t *testing.T, yaml string) string {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	readConfig(t, `
+product: btc-usd
+seed: 100
+environment: sandbox
+schedule:
+  align: 5m
+strategy:
+  take_profit: 0.05
+  cooldown: 30m
+telegram:
+  token: bot-token
+  allowed_chats: [42, 43]
+`)
+	os.Setenv("CRYPTOBOT_SEED", "250.5")
+	defer os.Unsetenv("CRYPTOBOT_SEED")
+
+	c, err := Load("")
+	assert.Nil(err)
+	assert.Nil(c.Validate())
+	assert.Equal("BTC-USD", c.Product)
+	assert.Equal(decimal.RequireFromString("250.5"), c.Seed, "the environment wins over the file")
+	assert.Equal("sandbox", c.Environment.Name)
+	assert.Equal(".state", c.StateDir)
+	assert.Equal(time.Second*25, c.Shutdown.Grace)
+	assert.Equal(Schedule{Interval: time.Second * 20, Align: time.Minute * 5}, c.Schedule)
+	assert.Equal(0.05, c.Strategy.TakeProfit)
+	assert.Equal(time.Minute*30, c.Strategy.Cooldown)
+	assert.Equal(svc.DefaultStrategy().StopLoss, c.Strategy.StopLoss)
+	assert.Equal([]int64{42, 43}, c.Telegram.AllowedChats)
+	assert.Equal(proclient.DefaultRateLimits(), c.RateLimits)
+	assert.Equal("BTC", c.BaseCurrency())
+	assert.Equal("USD", c.QuoteCurrency())
+	assert.Equal("USD", c.ReportCurrency(), "the quote currency without report_currency")
+	assert.True(HasStrategy())
+
+	c, err = Load("paper")
+	assert.Nil(err)
+	assert.Equal("paper", c.Environment.Name, "the flag wins over the file")
+
+	readConfig(t, "product: eth-btc\nseed: 0.5\nreport_currency: eur\n")
+	c, err = Load("")
+	assert.Nil(err)
+	assert.Nil(c.Validate())
+	assert.Equal("BTC", c.QuoteCurrency())
+	assert.Equal("EUR", c.ReportCurrency())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() Config {
+		readConfig(t, "product: BTC-USD\nseed: 100\n")
+		c, err := Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr bool
+	}{
+		{name: "Happy Path. Valid config.", change: func(c *Config) {}},
+		{name: "Sad Path. Missing product.", change: func(c *Config) { c.Product = "" }, wantErr: true},
+		{name: "Sad Path. Product without a quote currency.", change: func(c *Config) { c.Product = "BTC" }, wantErr: true},
+		{name: "Sad Path. Report currency that is not a currency.", change: func(c *Config) { c.Report = "US-D" }, wantErr: true},
+		{name: "Sad Path. Seed of 0.", change: func(c *Config) { c.Seed = decimal.Zero }, wantErr: true},
+		{name: "Sad Path. Negative seed.", change: func(c *Config) { c.Seed = decimal.NewFromInt(-1) }, wantErr: true},
+		{name: "Sad Path. Api without a token.", change: func(c *Config) { c.API.Addr = ":8081" }, wantErr: true},
+		{name: "Sad Path. Telegram without chats.", change: func(c *Config) { c.Telegram.Token = "bot-token" }, wantErr: true},
+		{name: "Sad Path. Invalid strategy.", change: func(c *Config) { c.Strategy.StopLoss = 2 }, wantErr: true},
+		{name: "Sad Path. Negative grace.", change: func(c *Config) { c.Shutdown.Grace = -time.Second }, wantErr: true},
+		{name: "Happy Path. Aligned schedule.", change: func(c *Config) { c.Schedule.Align = time.Minute * 5 }},
+		{name: "Sad Path. Interval of 0.", change: func(c *Config) { c.Schedule.Interval = 0 }, wantErr: true},
+		{name: "Sad Path. Negative align.", change: func(c *Config) { c.Schedule.Align = -time.Minute }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.change(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidStrategy(t *testing.T) {
+	readConfig(t, "product: BTC-USD\nseed: 100\nstrategy:\n  stop_loss: 0\n")
+	_, err := Load("")
+	assert.NotNil(t, err)
+}
+
+func TestTradingScheduleFromViper(t *testing.T) {
+	assert := assert.New(t)
+	readConfig(t, `
+product: BTC-USD
+seed: 100
+trading_schedule:
+  timezone: America/New_York
+  active: ["mon-fri 09:30-16:00"]
+  blackouts:
+    - {start: "2021-09-22T13:00:00", end: "2021-09-22T15:00:00", reason: fomc}
+  products:
+    ETH-USD:
+      active: ["* 00:00-24:00"]
+      blackouts:
+        - {start: "2021-12-24", end: "2021-12-26", reason: holidays}
+`)
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database", err)
+	}
+
+	cfg, err := Load("")
+	assert.Nil(err)
+	assert.Nil(cfg.Validate())
+	assert.Equal(ny, cfg.Trading.Location)
+	assert.Len(cfg.Trading.Active, 1)
+	assert.Equal("mon-fri 09:30-16:00", cfg.Trading.Active[0].Spec)
+	assert.Equal([]svc.Blackout{{Start: time.Date(2021, time.September, 22, 13, 0, 0, 0, ny), End: time.Date(2021, time.September, 22, 15, 0, 0, 0, ny), Reason: "fomc"}}, cfg.Trading.Blackouts)
+
+	eth, err := TradingScheduleFromViper("ETH-USD")
+	assert.Nil(err)
+	assert.Len(eth.Active, 1)
+	assert.Equal("* 00:00-24:00", eth.Active[0].Spec, "the product windows replace the global ones")
+	assert.Len(eth.Blackouts, 2, "the product blackouts are added to the global ones")
+	assert.Equal(time.Date(2021, time.December, 27, 0, 0, 0, 0, ny), eth.Blackouts[1].End, "a plain end date includes the whole day")
+	ok, _ := eth.Allows(time.Date(2021, time.December, 26, 23, 0, 0, 0, ny))
+	assert.False(ok)
+}
+
+func TestTradingScheduleFromViper_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "Sad Path. Unknown time zone.", yaml: "trading_schedule:\n  timezone: Mars/Olympus\n"},
+		{name: "Sad Path. Bad window.", yaml: "trading_schedule:\n  active: [\"weekdays 9-5\"]\n"},
+		{name: "Sad Path. Bad date.", yaml: "trading_schedule:\n  blackouts:\n    - {start: tomorrow, end: 2021-12-26}\n"},
+		{name: "Sad Path. Ends before it starts.", yaml: "trading_schedule:\n  blackouts:\n    - {start: 2021-12-26, end: 2021-12-24}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readConfig(t, "product: BTC-USD\nseed: 100\n"+tt.yaml)
+			_, err := Load("")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestConfig_CheckExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		product  string
+		funds    decimal.Decimal
+		accounts []coinbasepro.Account
+		wantErr  bool
+	}{
+		{name: "Happy Path. Funds under the balance.", product: "BTC-USD", funds: decimal.NewFromInt(100), accounts: []coinbasepro.Account{{Currency: "USD", Available: "150.00"}}},
+		{name: "Happy Path. Funds equal the balance.", product: "BTC-USD", funds: decimal.NewFromInt(150), accounts: []coinbasepro.Account{{Currency: "USD", Available: "150.00"}}},
+		{name: "Sad Path. Funds above the balance.", product: "BTC-USD", funds: decimal.NewFromInt(200), accounts: []coinbasepro.Account{{Currency: "USD", Available: "150.00"}}, wantErr: true},
+		{name: "Sad Path. No quote account.", product: "BTC-USD", funds: decimal.NewFromInt(1), accounts: []coinbasepro.Account{{Currency: "BTC", Available: "1.00"}}, wantErr: true},
+		{name: "Sad Path. Product is not listed.", product: "BTC-USDX", funds: decimal.NewFromInt(1), accounts: []coinbasepro.Account{{Currency: "USDX", Available: "150.00"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := proclient.NewMockClient()
+			c.Products = []proclient.Product{{ID: "BTC-USD"}, {ID: "ETH-USD"}}
+			c.Accounts = tt.accounts
+			cfg := Config{Product: tt.product}
+			if err := cfg.CheckExchange(context.Background(), proclient.NewExchange(c), tt.funds); (err != nil) != tt.wantErr {
+				t.Errorf("CheckExchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWatchStrategy(t *testing.T) {
+	path := readConfig(t, "product: BTC-USD\nseed: 100\nstrategy:\n  take_profit: 0.05\n")
+	changes := make(chan svc.Strategy, 10)
+	WatchStrategy(func(st svc.Strategy) { changes <- st })
+
+	// an invalid strategy is skipped, the next good one goes through
+	if err := os.WriteFile(path, []byte("product: BTC-USD\nseed: 100\nstrategy:\n  take_profit: -1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if err := os.WriteFile(path, []byte("product: BTC-USD\nseed: 100\nstrategy:\n  take_profit: 0.12\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case st := <-changes:
+			assert.NotEqual(t, -1.0, st.TakeProfit)
+			if st.TakeProfit == 0.12 {
+				return
+			}
+		case <-timeout:
+			t.Fatal("strategy was not reloaded")
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+//Places is the precision of a Decimal, a satoshi
+const Places = 8
+
+const scale = 100000000
+
+var (
+	Zero = Decimal{}
+	One  = Decimal{units: scale}
+
+	errSyntax = errors.New("invalid syntax")
+
+	//ErrOverflow is the error of arithmetic and of Parse out of the range of a Decimal
+	ErrOverflow = errors.New("decimal: overflow")
+
+	//ErrDivisionByZero is the error of Div by 0
+	ErrDivisionByZero = errors.New("decimal: division by zero")
+)
+
+//Decimal is a fixed-point number with 8 decimal places for prices, sizes and funds.
+//Sums are exact and products are cut at the 8th place, values go up to ±92 billion (±92233720368.54775807).
+//Add, Sub, Mul and Div return an ErrOverflow out of that range, a size of 1e11 of a token priced at 1e-5 is out of range.
+//The zero value is 0, Decimals compare with ==.
+//It formats like a float64 with %f and %.2f and is a JSON number, states saved with float64 fields load as they are.
+type Decimal struct {
+	units int64 //value * 10^8
+}
+
+//New is value * 10^exp for constants, digits past the 8th place are dropped. It panics with ErrOverflow out of range.
+func New(value int64, exp int) Decimal {
+	d := NewFromInt(value)
+	var err error
+	for ; exp > 0; exp-- {
+		if d, err = d.Mul(Decimal{units: 10 * scale}); err != nil {
+			panic(err)
+		}
+	}
+	for ; exp < 0; exp++ {
+		d.units /= 10
+	}
+	return d
+}
+
+//NewFromInt is i for constants, it panics with ErrOverflow out of range
+func NewFromInt(i int64) Decimal {
+	if i > math.MaxInt64/scale || i < math.MinInt64/scale {
+		panic(ErrOverflow)
+	}
+	return Decimal{units: i * scale}
+}
+
+//NewFromFloat is f rounded to 8 places, 0.1 is exactly 0.1. NaN, infinities and values out of range are 0.
+func NewFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) >= math.MaxInt64/scale {
+		return Zero
+	}
+	d, err := Parse(strconv.FormatFloat(f, 'f', Places, 64))
+	if err != nil {
+		return Zero
+	}
+	return d
+}
+
+//Parse reads "-12.345", "1e-05" and the like, digits past the 8th place are dropped.
+//A value out of range is an ErrOverflow.
+func Parse(s string) (Decimal, error) {
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return Zero, fmt.Errorf("decimal: parsing %q: %w", s, errSyntax)
+		}
+		if math.IsInf(f, 0) || math.Abs(f) >= math.MaxInt64/scale {
+			return Zero, fmt.Errorf("decimal: parsing %q: %w", s, ErrOverflow)
+		}
+		return NewFromFloat(f), nil
+	}
+	str := s
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return Zero, fmt.Errorf("decimal: parsing %q: %w", str, errSyntax)
+	}
+	if len(frac) > Places {
+		frac = frac[:Places]
+	}
+	frac += strings.Repeat("0", Places-len(frac))
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Zero, fmt.Errorf("decimal: parsing %q: %w", str, ErrOverflow)
+	}
+	if neg {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//RequireFromString is Parse for constants, it panics on a bad string
+func RequireFromString(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+//Add is exact, a sum out of range is an ErrOverflow
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	sum := d.units + o.units
+	if (sum > d.units) != (o.units > 0) || sum == math.MinInt64 {
+		return Zero, ErrOverflow
+	}
+	return Decimal{units: sum}, nil
+}
+
+//Sub is exact, a difference out of range is an ErrOverflow
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	return d.Add(o.Neg())
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: -d.units}
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+//Mul is cut toward zero at the 8th place, a product out of range is an ErrOverflow
+func (d Decimal) Mul(o Decimal) (Decimal, error) {
+	hi, lo := bits.Mul64(abs(d.units), abs(o.units))
+	if hi >= scale {
+		return Zero, ErrOverflow
+	}
+	quo, _ := bits.Div64(hi, lo, scale)
+	return signed(quo, (d.units < 0) != (o.units < 0))
+}
+
+//Div is cut toward zero at the 8th place, a quotient out of range is an ErrOverflow and o of 0 an ErrDivisionByZero
+func (d Decimal) Div(o Decimal) (Decimal, error) {
+	if o.units == 0 {
+		return Zero, ErrDivisionByZero
+	}
+	hi, lo := bits.Mul64(abs(d.units), scale)
+	if hi >= abs(o.units) {
+		return Zero, ErrOverflow
+	}
+	quo, _ := bits.Div64(hi, lo, abs(o.units))
+	return signed(quo, (d.units < 0) != (o.units < 0))
+}
+
+func abs(i int64) uint64 {
+	if i < 0 {
+		return uint64(-i)
+	}
+	return uint64(i)
+}
+
+func signed(u uint64, neg bool) (Decimal, error) {
+	if u > math.MaxInt64 {
+		return Zero, ErrOverflow
+	}
+	if neg {
+		return Decimal{units: -int64(u)}, nil
+	}
+	return Decimal{units: int64(u)}, nil
+}
+
+//RoundDown is the largest multiple of increment toward zero, e.g. a size the exchange accepts.
+//An increment of 0 or less leaves d as it is.
+func (d Decimal) RoundDown(increment Decimal) Decimal {
+	if increment.units <= 0 {
+		return d
+	}
+	return Decimal{units: d.units - d.units%increment.units}
+}
+
+//Round is the nearest multiple of increment, halves away from zero. An increment of 0 or less leaves d as it is.
+//A multiple past the range is cut toward zero.
+func (d Decimal) Round(increment Decimal) Decimal {
+	if increment.units <= 0 {
+		return d
+	}
+	down := d.RoundDown(increment)
+	rest := abs(d.units - down.units)
+	if rest*2 < uint64(increment.units) {
+		return down
+	}
+	away := increment
+	if d.units < 0 {
+		away = increment.Neg()
+	}
+	up, err := down.Add(away)
+	if err != nil {
+		return down
+	}
+	return up
+}
+
+//Cmp is -1, 0 or +1 as d is less than, equal to or greater than o
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.units < o.units:
+		return -1
+	case d.units > o.units:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.units < o.units
+}
+
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.units > o.units
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+func (d Decimal) IsPositive() bool {
+	return d.units > 0
+}
+
+func (d Decimal) IsNegative() bool {
+	return d.units < 0
+}
+
+func Max(a, b Decimal) Decimal {
+	if a.units > b.units {
+		return a
+	}
+	return b
+}
+
+func Min(a, b Decimal) Decimal {
+	if a.units < b.units {
+		return a
+	}
+	return b
+}
+
+//Float64 is for ratios, metrics and charts, never for amounts sent to an exchange
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+//String is the shortest exact form, "0.5", "100", "-0.00000001"
+func (d Decimal) String() string {
+	s := d.StringFixed(Places)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+//StringFixed rounds to places, halves away from zero, and always prints them, StringFixed(2) of 1.005 is "1.01"
+func (d Decimal) StringFixed(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	if places < Places {
+		d = d.Round(New(1, -places))
+	}
+	u := abs(d.units)
+	whole, frac := u/scale, fmt.Sprintf("%08d", u%scale)
+	if places < Places {
+		frac = frac[:places]
+	} else {
+		frac += strings.Repeat("0", places-Places)
+	}
+	s := strconv.FormatUint(whole, 10)
+	if places > 0 {
+		s += "." + frac
+	}
+	if d.units < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+//Format prints %f and %.2f like a float64 does, 6 places by default, %v and %s are String
+func (d Decimal) Format(f fmt.State, verb rune) {
+	var s string
+	switch verb {
+	case 'f', 'F':
+		places, ok := f.Precision()
+		if !ok {
+			places = 6
+		}
+		s = d.StringFixed(places)
+		if f.Flag('+') && d.units >= 0 {
+			s = "+" + s
+		}
+	case 'v', 's':
+		s = d.String()
+	case 'q':
+		s = strconv.Quote(d.String())
+	default:
+		fmt.Fprintf(f, "%"+string(verb), d.Float64())
+		return
+	}
+	if width, ok := f.Width(); ok && len(s) < width {
+		pad := strings.Repeat(" ", width-len(s))
+		if f.Flag('-') {
+			s += pad
+		} else {
+			s = pad + s
+		}
+	}
+	f.Write([]byte(s))
+}
+
+//MarshalJSON is a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+//UnmarshalJSON reads a JSON number or string, null is 0
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		*d = Zero
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,176 @@
+package decimal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "Happy Path. Integer", in: "100", want: "100"},
+		{name: "Happy Path. Fraction", in: "0.01291771", want: "0.01291771"},
+		{name: "Happy Path. Trailing zeros", in: "1000.00000000", want: "1000"},
+		{name: "Happy Path. Negative", in: "-0.5", want: "-0.5"},
+		{name: "Happy Path. Plus sign", in: "+2", want: "2"},
+		{name: "Happy Path. No whole part", in: ".25", want: "0.25"},
+		{name: "Happy Path. Exponent, as float64 encodes small numbers", in: "1e-05", want: "0.00001"},
+		{name: "Happy Path. Past the 8th place is dropped", in: "0.0249376391550000", want: "0.02493763"},
+		{name: "Happy Path. Leading zeros", in: "007.5", want: "7.5"},
+		{name: "Sad Path. Empty", in: "", wantErr: true},
+		{name: "Sad Path. Sign only", in: "-", wantErr: true},
+		{name: "Sad Path. Letters", in: "12a", wantErr: true},
+		{name: "Sad Path. Two points", in: "1.2.3", wantErr: true},
+		{name: "Sad Path. Out of range", in: "100000000000", wantErr: true},
+		{name: "Sad Path. Bad exponent", in: "1e", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+	assert.Panics(t, func() { RequireFromString("x") })
+
+	//a size out of range is refused, not read as 0
+	for _, in := range []string{"100000000000", "-100000000000", "1e11", "1e400"} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, ErrOverflow, in)
+	}
+}
+
+func TestNewFromFloat(t *testing.T) {
+	assert.Equal(t, RequireFromString("0.1"), NewFromFloat(0.1))
+	assert.Equal(t, RequireFromString("103.1"), NewFromFloat(103.1))
+	assert.Equal(t, RequireFromString("0.00000002"), NewFromFloat(0.000000016), "rounded to the 8th place")
+	assert.Equal(t, RequireFromString("-2.5"), NewFromFloat(-2.5))
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e12} {
+		assert.Equal(t, Zero, NewFromFloat(f))
+	}
+	assert.Equal(t, RequireFromString("0.01"), New(1, -2))
+	assert.Equal(t, RequireFromString("1500"), New(15, 2))
+	assert.Equal(t, Zero, New(1, -9), "past the 8th place")
+	assert.Equal(t, 0.01291771, RequireFromString("0.01291771").Float64())
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	assert := assert.New(t)
+	d := RequireFromString
+
+	tests := []struct {
+		name    string
+		op      func() (Decimal, error)
+		want    string
+		wantErr error
+	}{
+		{name: "Happy Path. 0.1 + 0.2 is 0.3, unlike float64", op: func() (Decimal, error) { return d("0.1").Add(d("0.2")) }, want: "0.3"},
+		{name: "Happy Path. Sub below 0", op: func() (Decimal, error) { return d("0.1").Sub(d("0.2")) }, want: "-0.1"},
+		{name: "Happy Path. Div cut at the 8th place", op: func() (Decimal, error) { return d("1000").Div(d("1.005")) }, want: "995.02487562"},
+		{name: "Happy Path. Mul cut at the 8th place", op: func() (Decimal, error) { return d("0.02116517").Mul(d("47012.36")) }, want: "995.0245915"},
+		{name: "Happy Path. Negative product", op: func() (Decimal, error) { return d("-2").Mul(d("3")) }, want: "-6"},
+		{name: "Happy Path. Negative quotient", op: func() (Decimal, error) { return d("2").Div(d("-3")) }, want: "-0.66666666"},
+		{name: "Happy Path. Large product", op: func() (Decimal, error) { return d("40000000000").Mul(d("2")) }, want: "80000000000"},
+		{name: "Happy Path. Large sum", op: func() (Decimal, error) { return d("90000000000").Add(d("1")) }, want: "90000000001"},
+		{name: "Sad Path. Sum out of range", op: func() (Decimal, error) { return d("90000000000").Add(d("90000000000")) }, wantErr: ErrOverflow},
+		{name: "Sad Path. Difference out of range", op: func() (Decimal, error) { return d("-90000000000").Sub(d("90000000000")) }, wantErr: ErrOverflow},
+		{name: "Sad Path. Product out of range", op: func() (Decimal, error) { return d("90000000000").Mul(d("-2")) }, wantErr: ErrOverflow},
+		{name: "Sad Path. A $1M buy of a 1e-5 token", op: func() (Decimal, error) { return d("1000000").Div(d("0.00001")) }, wantErr: ErrOverflow},
+		{name: "Sad Path. Division by zero", op: func() (Decimal, error) { return d("1").Div(Zero) }, wantErr: ErrDivisionByZero},
+	}
+	for _, tt := range tests {
+		got, err := tt.op()
+		if tt.wantErr != nil {
+			assert.ErrorIs(err, tt.wantErr, tt.name)
+			continue
+		}
+		assert.Nil(err, tt.name)
+		assert.Equal(d(tt.want), got, tt.name)
+	}
+	assert.Equal(d("0.1"), d("-0.1").Abs())
+	assert.Panics(func() { New(1, 11) }, "constants out of range")
+
+	assert.Equal(-1, d("1").Cmp(d("2")))
+	assert.Equal(0, d("2").Cmp(d("2.0")))
+	assert.Equal(1, d("3").Cmp(d("2")))
+	assert.True(d("1").LessThan(d("1.00000001")))
+	assert.True(d("1").GreaterThan(d("-1")))
+	assert.True(Zero.IsZero())
+	assert.True(d("0.00000001").IsPositive())
+	assert.True(d("-0.00000001").IsNegative())
+	assert.Equal(d("2"), Max(d("1"), d("2")))
+	assert.Equal(d("1"), Min(d("1"), d("2")))
+}
+
+func TestDecimal_Round(t *testing.T) {
+	d := RequireFromString
+	tests := []struct {
+		name      string
+		in        string
+		increment string
+		wantDown  string
+		wantRound string
+	}{
+		{name: "Happy Path. Size to a base increment", in: "0.02116517", increment: "0.0001", wantDown: "0.0211", wantRound: "0.0212"},
+		{name: "Happy Path. Funds to the cent", in: "107.5595", increment: "0.01", wantDown: "107.55", wantRound: "107.56"},
+		{name: "Happy Path. Already a multiple", in: "47012.36", increment: "0.01", wantDown: "47012.36", wantRound: "47012.36"},
+		{name: "Happy Path. Half is rounded away from zero", in: "0.125", increment: "0.25", wantDown: "0", wantRound: "0.25"},
+		{name: "Happy Path. Increment that is not a power of ten", in: "1.37", increment: "0.25", wantDown: "1.25", wantRound: "1.25"},
+		{name: "Happy Path. Negative toward zero", in: "-1.37", increment: "0.1", wantDown: "-1.3", wantRound: "-1.4"},
+		{name: "Happy Path. No increment", in: "1.37", increment: "0", wantDown: "1.37", wantRound: "1.37"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, d(tt.wantDown), d(tt.in).RoundDown(d(tt.increment)))
+			assert.Equal(t, d(tt.wantRound), d(tt.in).Round(d(tt.increment)))
+		})
+	}
+	assert.Equal(t, d("92233720368.54"), Decimal{units: math.MaxInt64}.Round(d("0.01")), "cut toward zero past the range")
+}
+
+func TestDecimal_Format(t *testing.T) {
+	assert := assert.New(t)
+	d := RequireFromString("1234.5678")
+	assert.Equal("1234.5678", d.String())
+	assert.Equal("1234.57", d.StringFixed(2))
+	assert.Equal("1235", d.StringFixed(0))
+	assert.Equal("1234.5678000000", d.StringFixed(10))
+	assert.Equal("1.01", RequireFromString("1.005").StringFixed(2))
+	assert.Equal("-0.50", RequireFromString("-0.5").StringFixed(2))
+	assert.Equal("0.00", RequireFromString("-0.001").StringFixed(2), "no negative zero")
+
+	assert.Equal("1234.567800 1234.57 1234.5678 1234.5678", fmt.Sprintf("%f %.2f %v %s", d, d, d, d))
+	assert.Equal("|   1.50|1.50   |+1.50|", fmt.Sprintf("|%7.2f|%-7.2f|%+.2f|", RequireFromString("1.5"), RequireFromString("1.5"), RequireFromString("1.5")))
+	assert.Equal(`"1.5" 1.5`, fmt.Sprintf("%q %g", RequireFromString("1.5"), RequireFromString("1.5")))
+	assert.Equal("{Size:0.5}", fmt.Sprintf("%+v", struct{ Size Decimal }{RequireFromString("0.5")}))
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	assert := assert.New(t)
+	type state struct {
+		NumberOwn Decimal
+		BuyPrice  Decimal
+		Funds     Decimal
+	}
+	b, err := json.Marshal(state{NumberOwn: RequireFromString("0.01291771"), BuyPrice: RequireFromString("103.1")})
+	assert.Nil(err)
+	assert.Equal(`{"NumberOwn":0.01291771,"BuyPrice":103.1,"Funds":0}`, string(b))
+
+	//states saved with float64 fields, strings and nulls load
+	var got state
+	assert.Nil(json.Unmarshal([]byte(`{"NumberOwn":1e-05,"BuyPrice":"103.10","Funds":null}`), &got))
+	assert.Equal(state{NumberOwn: RequireFromString("0.00001"), BuyPrice: RequireFromString("103.1")}, got)
+	assert.NotNil(json.Unmarshal([]byte(`{"NumberOwn":true}`), &got))
+}
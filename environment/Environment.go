@@ -0,0 +1,193 @@
+package environment
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	Production = "production"
+	Sandbox    = "sandbox"
+	LocalMock  = "local-mock"
+	Paper      = "paper"
+	//AdvancedTrade is production over the Coinbase Advanced Trade api
+	AdvancedTrade = "advanced-trade"
+	Kraken        = "kraken"
+)
+
+//Exchange apis an environment can talk to
+const (
+	CoinbasePro      = "coinbasepro"
+	CoinbaseAdvanced = "advanced-trade"
+	KrakenExchange   = "kraken"
+)
+
+//Environment is where the bot trades and how careful it has to be about it
+type Environment struct {
+	Name         string
+	Exchange     string //CoinbasePro, CoinbaseAdvanced or KrakenExchange
+	BaseURL      string
+	WebsocketURL string
+	//CredentialsNamespace prefixes the secret names, sandbox reads sandbox_api_key instead of api_key
+	CredentialsNamespace string
+	Credentials          bool //credentials are required to start
+	Paper                bool //orders are filled in memory at the book price, they never reach the exchange
+	RequireConfirm       bool //real money, the bot only starts with the confirm flag
+}
+
+//Profiles are the known environments, fields can be overridden under environments.<name> in the .conf file
+var Profiles = map[string]Environment{
+	Production: {
+		Name:           Production,
+		Exchange:       CoinbasePro,
+		BaseURL:        "https://api.pro.coinbase.com",
+		WebsocketURL:   "wss://ws-feed.pro.coinbase.com",
+		Credentials:    true,
+		RequireConfirm: true,
+	},
+	Sandbox: {
+		Name:                 Sandbox,
+		Exchange:             CoinbasePro,
+		BaseURL:              "https://api-public.sandbox.pro.coinbase.com",
+		WebsocketURL:         "wss://ws-feed-public.sandbox.pro.coinbase.com",
+		CredentialsNamespace: "sandbox",
+		Credentials:          true,
+	},
+	LocalMock: {
+		Name:                 LocalMock,
+		Exchange:             CoinbasePro,
+		BaseURL:              "http://0.0.0.0:8080",
+		CredentialsNamespace: "local",
+	},
+	Paper: {
+		Name:         Paper,
+		Exchange:     CoinbasePro,
+		BaseURL:      "https://api.pro.coinbase.com",
+		WebsocketURL: "wss://ws-feed.pro.coinbase.com",
+		Paper:        true,
+	},
+	AdvancedTrade: {
+		Name:                 AdvancedTrade,
+		Exchange:             CoinbaseAdvanced,
+		BaseURL:              "https://api.coinbase.com",
+		CredentialsNamespace: "advanced",
+		Credentials:          true,
+		RequireConfirm:       true,
+	},
+	Kraken: {
+		Name:                 Kraken,
+		Exchange:             KrakenExchange,
+		BaseURL:              "https://api.kraken.com",
+		CredentialsNamespace: "kraken",
+		Credentials:          true,
+		RequireConfirm:       true,
+	},
+}
+
+//Names of the known environments, sorted
+func Names() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//FromViper returns the profile for name, an empty name reads the environment key.
+//	environment: sandbox
+//	environments:
+//	  local-mock:
+//	    base_url: http://127.0.0.1:8080
+//	    exchange: advanced-trade
+func FromViper(name string) (Environment, error) {
+	viper.SetDefault("environment", Production)
+	if name == "" {
+		name = viper.GetString("environment")
+	}
+	e, ok := Profiles[name]
+	if !ok {
+		return Environment{}, fmt.Errorf("unknown environment %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	key := "environments." + name + "."
+	if viper.IsSet(key + "base_url") {
+		e.BaseURL = viper.GetString(key + "base_url")
+	}
+	if viper.IsSet(key + "websocket_url") {
+		e.WebsocketURL = viper.GetString(key + "websocket_url")
+	}
+	if viper.IsSet(key + "credentials_namespace") {
+		e.CredentialsNamespace = viper.GetString(key + "credentials_namespace")
+	}
+	if viper.IsSet(key + "exchange") {
+		e.Exchange = viper.GetString(key + "exchange")
+	}
+	if e.Exchange != CoinbasePro && e.Exchange != CoinbaseAdvanced && e.Exchange != KrakenExchange {
+		return Environment{}, fmt.Errorf("environment %s has unknown exchange %q, want %s, %s or %s", name, e.Exchange, CoinbasePro, CoinbaseAdvanced, KrakenExchange)
+	}
+	if e.Paper && e.Exchange != CoinbasePro {
+		return Environment{}, fmt.Errorf("environment %s paper trading is only supported on %s", name, CoinbasePro)
+	}
+	//an override can point any profile at real money, the confirm follows where the bot trades, not the name
+	if !e.Paper && e.production() {
+		e.RequireConfirm = true
+	}
+	return e, nil
+}
+
+//production is true when the base url is one of a real money profile,
+//or the exchange has no sandbox and the base url is not on this machine
+func (e Environment) production() bool {
+	u, err := url.Parse(strings.TrimSpace(e.BaseURL))
+	if err != nil {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, p := range Profiles {
+		if !p.RequireConfirm {
+			continue
+		}
+		if pu, err := url.Parse(p.BaseURL); err == nil && strings.ToLower(pu.Hostname()) == host {
+			return true
+		}
+	}
+	if e.Exchange == CoinbasePro || host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !(ip.IsLoopback() || ip.IsUnspecified())
+}
+
+//Check refuses to trade real money without the confirm flag
+func (e Environment) Check(confirmed bool) error {
+	if e.BaseURL == "" {
+		return fmt.Errorf("environment %s has no base url", e.Name)
+	}
+	if e.RequireConfirm && !confirmed {
+		return fmt.Errorf("environment %s trades real money, start with -confirm-production to continue", e.Name)
+	}
+	return nil
+}
+
+//Passphrase is true when the api keys of the exchange have a passphrase, only Coinbase Pro keys do
+func (e Environment) Passphrase() bool {
+	return e.Exchange == CoinbasePro
+}
+
+//SecretName is name in the credentials namespace
+func (e Environment) SecretName(name string) string {
+	if e.CredentialsNamespace == "" {
+		return name
+	}
+	return e.CredentialsNamespace + "_" + name
+}
+
+//LogPrefix is put in front of every log line
+func (e Environment) LogPrefix() string {
+	return "[" + e.Name + "] "
+}
@@ -0,0 +1,120 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromViper(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	e, err := FromViper("")
+	assert.Nil(err)
+	assert.Equal(Production, e.Name, "production is the default")
+
+	viper.Set("environment", Sandbox)
+	e, err = FromViper("")
+	assert.Nil(err)
+	assert.Equal("https://api-public.sandbox.pro.coinbase.com", e.BaseURL)
+
+	// the flag wins over the config
+	viper.Set("environments.local-mock.base_url", "http://127.0.0.1:9090")
+	e, err = FromViper(LocalMock)
+	assert.Nil(err)
+	assert.Equal("http://127.0.0.1:9090", e.BaseURL)
+	assert.Equal("http://0.0.0.0:8080", Profiles[LocalMock].BaseURL, "overrides do not change the profile")
+
+	_, err = FromViper("prod")
+	assert.NotNil(err)
+
+	viper.Set("environments.sandbox.exchange", CoinbaseAdvanced)
+	e, err = FromViper(Sandbox)
+	assert.Nil(err)
+	assert.Equal(CoinbaseAdvanced, e.Exchange)
+	assert.True(e.RequireConfirm, "advanced trade has no sandbox")
+
+	viper.Set("environments.paper.exchange", CoinbaseAdvanced)
+	_, err = FromViper(Paper)
+	assert.NotNil(err, "paper trading wraps the coinbase pro client")
+
+	viper.Set("environments.local-mock.exchange", "binance")
+	_, err = FromViper(LocalMock)
+	assert.NotNil(err)
+
+	e, err = FromViper(Kraken)
+	assert.Nil(err)
+	assert.Equal(KrakenExchange, e.Exchange)
+	assert.False(e.Passphrase(), "kraken keys have no passphrase")
+	assert.True(Profiles[Sandbox].Passphrase())
+}
+
+func TestEnvironment_Check(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		confirmed bool
+		wantErr   bool
+	}{
+		{name: "Happy Path. Production confirmed.", env: Production, confirmed: true},
+		{name: "Sad Path. Production not confirmed.", env: Production, wantErr: true},
+		{name: "Happy Path. Sandbox needs no confirm.", env: Sandbox},
+		{name: "Happy Path. Paper needs no confirm.", env: Paper},
+		{name: "Happy Path. Local mock needs no confirm.", env: LocalMock},
+		{name: "Sad Path. Advanced trade not confirmed.", env: AdvancedTrade, wantErr: true},
+		{name: "Sad Path. Kraken not confirmed.", env: Kraken, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Profiles[tt.env].Check(tt.confirmed); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromViper_RequireConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		baseURL  string
+		exchange string
+		want     bool
+	}{
+		{name: "Happy Path. Sandbox as it is.", env: Sandbox},
+		{name: "Happy Path. Local mock on another port.", env: LocalMock, baseURL: "http://127.0.0.1:9090"},
+		{name: "Happy Path. Local mock of advanced trade.", env: LocalMock, baseURL: "http://localhost:8080", exchange: CoinbaseAdvanced},
+		{name: "Happy Path. Paper never sends an order.", env: Paper},
+		{name: "Sad Path. Sandbox pointed at production.", env: Sandbox, baseURL: "https://api.pro.coinbase.com", want: true},
+		{name: "Sad Path. Local mock pointed at production.", env: LocalMock, baseURL: "https://API.pro.coinbase.com/", want: true},
+		{name: "Sad Path. Sandbox pointed at kraken.", env: Sandbox, baseURL: "https://api.kraken.com", exchange: KrakenExchange, want: true},
+		{name: "Sad Path. Local mock with advanced trade on a remote host.", env: LocalMock, baseURL: "https://trade.example.com", exchange: CoinbaseAdvanced, want: true},
+		{name: "Sad Path. Production stays confirmed.", env: Production, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer viper.Reset()
+			if tt.baseURL != "" {
+				viper.Set("environments."+tt.env+".base_url", tt.baseURL)
+			}
+			if tt.exchange != "" {
+				viper.Set("environments."+tt.env+".exchange", tt.exchange)
+			}
+			e, err := FromViper(tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, e.RequireConfirm)
+			assert.Equal(t, tt.want, e.Check(false) != nil, "Check without -confirm-production")
+		})
+	}
+}
+
+func TestEnvironment_SecretName(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("api_key", Profiles[Production].SecretName("api_key"))
+	assert.Equal("sandbox_api_key", Profiles[Sandbox].SecretName("api_key"))
+	assert.Equal("[paper] ", Profiles[Paper].LogPrefix())
+}
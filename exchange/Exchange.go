@@ -0,0 +1,256 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+)
+
+//Exchange is what the bot needs from an exchange, in its own types.
+//Every call takes a context, cancelling it cancels the request in flight.
+//Adapters return *HTTPError for non 2xx answers so failures can be classified the same way for every exchange.
+type Exchange interface {
+	//Name identifies the exchange in logs, e.g. coinbasepro
+	Name() string
+
+	//Market data
+	GetProducts(ctx context.Context) ([]Product, error)
+	//GetBook is the top of the book
+	GetBook(ctx context.Context, product string) (Book, error)
+	//GetCandles are oldest first, a zero granularity lets the exchange pick one
+	GetCandles(ctx context.Context, product string, start, end time.Time, granularity time.Duration) ([]Candle, error)
+
+	//Account
+	GetBalances(ctx context.Context) ([]Balance, error)
+
+	//Orders
+	PlaceOrder(ctx context.Context, req OrderRequest) (Order, error)
+	GetOrder(ctx context.Context, id string) (Order, error)
+	CancelOrder(ctx context.Context, id string) error
+	//CancelAllOrders cancels the open orders of product, every product when it is empty
+	CancelAllOrders(ctx context.Context, product string) ([]string, error)
+	//ListOpenOrders lists the open orders of product, every product when it is empty
+	ListOpenOrders(ctx context.Context, product string) ([]Order, error)
+	ListFills(ctx context.Context, orderID string) ([]Fill, error)
+}
+
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	TypeMarket = "market"
+	TypeLimit  = "limit"
+)
+
+//OrderStatus is where an order is in its life, exchanges name these differently
+type OrderStatus string
+
+const (
+	StatusPending   OrderStatus = "pending" //accepted, not done yet
+	StatusFilled    OrderStatus = "filled"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusRejected  OrderStatus = "rejected"
+)
+
+//Done is true once the order can no longer fill
+func (s OrderStatus) Done() bool {
+	return s == StatusFilled || s == StatusCancelled || s == StatusRejected
+}
+
+//ProductStatus is which orders a product accepts
+type ProductStatus string
+
+const (
+	ProductOnline     ProductStatus = "online"
+	ProductPostOnly   ProductStatus = "post_only"   //only limit orders that rest on the book
+	ProductLimitOnly  ProductStatus = "limit_only"  //only limit orders
+	ProductCancelOnly ProductStatus = "cancel_only" //no new orders, open orders can be cancelled
+	ProductOffline    ProductStatus = "offline"     //no orders at all
+)
+
+//Product is a market listed on the exchange
+type Product struct {
+	ID             string
+	BaseCurrency   string
+	QuoteCurrency  string
+	BaseMinSize    decimal.Decimal
+	BaseMaxSize    decimal.Decimal //0 when the exchange does not say
+	BaseIncrement  decimal.Decimal //0 when the exchange does not say
+	QuoteIncrement decimal.Decimal
+	MinFunds       decimal.Decimal //smallest market buy in the quote currency, 0 when the exchange does not say
+	Status         ProductStatus   //empty is online
+	Disabled       bool            //trading is disabled whatever the status, orders are rejected
+}
+
+//Tradable is true when the product takes market orders, the only ones the bot places
+func (p Product) Tradable() bool {
+	return !p.Disabled && (p.Status == "" || p.Status == ProductOnline)
+}
+
+//Accepts is true when the product takes orders of type typ
+func (p Product) Accepts(typ string) bool {
+	switch {
+	case p.Disabled:
+		return false
+	case p.Status == "" || p.Status == ProductOnline:
+		return true
+	case p.Status == ProductPostOnly || p.Status == ProductLimitOnly:
+		return typ == TypeLimit
+	}
+	return false
+}
+
+//CheckOrder rounds the size of req down to BaseIncrement and the funds down to QuoteIncrement.
+//It returns an error when the product would reject the rounded order.
+func (p Product) CheckOrder(req OrderRequest) (OrderRequest, error) {
+	if !p.Accepts(req.Type) {
+		return req, fmt.Errorf("%s is %s and does not take %s orders", p.ID, p.describeStatus(), req.Type)
+	}
+	req.Size = req.Size.RoundDown(p.BaseIncrement)
+	req.Funds = req.Funds.RoundDown(p.QuoteIncrement)
+	switch {
+	case !req.Size.IsPositive() && !req.Funds.IsPositive():
+		return req, fmt.Errorf("%s order has no size or funds after rounding", p.ID)
+	case req.Size.IsPositive() && req.Size.LessThan(p.BaseMinSize):
+		return req, fmt.Errorf("%s size %s is below the minimum %s", p.ID, req.Size, p.BaseMinSize)
+	case req.Size.IsPositive() && p.BaseMaxSize.IsPositive() && req.Size.GreaterThan(p.BaseMaxSize):
+		return req, fmt.Errorf("%s size %s is above the maximum %s", p.ID, req.Size, p.BaseMaxSize)
+	case req.Funds.IsPositive() && req.Funds.LessThan(p.MinFunds):
+		return req, fmt.Errorf("%s funds %s are below the minimum %s", p.ID, req.Funds, p.MinFunds)
+	}
+	return req, nil
+}
+
+func (p Product) describeStatus() string {
+	if p.Disabled {
+		return "trading disabled"
+	}
+	if p.Status == "" {
+		return string(ProductOnline)
+	}
+	return string(p.Status)
+}
+
+type BookLevel struct {
+	Price decimal.Decimal
+	Size  decimal.Decimal
+}
+
+//Book Bids are best first, as are Asks
+type Book struct {
+	Bids []BookLevel
+	Asks []BookLevel
+}
+
+//Candle prices are float64, they are signals and never sent back to the exchange
+type Candle struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+type Balance struct {
+	Currency  string
+	Total     decimal.Decimal
+	Available decimal.Decimal
+	Hold      decimal.Decimal
+}
+
+//FindBalance is the balance of the currency code, a zero balance when there is no account for it
+func FindBalance(balances []Balance, currency string) Balance {
+	for _, b := range balances {
+		if strings.EqualFold(b.Currency, currency) {
+			return b
+		}
+	}
+	return Balance{Currency: currency}
+}
+
+//OrderRequest market buys set Funds, market sells set Size, limit orders set Size and Price.
+//Amounts are sent as they are, round them to the product increments first.
+type OrderRequest struct {
+	Product  string
+	Side     string
+	Type     string
+	Size     decimal.Decimal
+	Funds    decimal.Decimal
+	Price    decimal.Decimal
+	ClientID string //optional idempotency key
+}
+
+type Order struct {
+	ID            string
+	Product       string
+	Side          string
+	Type          string
+	Size          decimal.Decimal
+	Funds         decimal.Decimal
+	Price         decimal.Decimal
+	Status        OrderStatus
+	Reason        string //the status as the exchange reported it, for logs
+	FilledSize    decimal.Decimal
+	ExecutedValue decimal.Decimal //quote currency spent or received, before fees
+	Fees          decimal.Decimal
+	Created       time.Time
+}
+
+//AveragePrice is the average fill price, 0 before anything filled. A price out of range is a decimal.ErrOverflow.
+func (o Order) AveragePrice() (decimal.Decimal, error) {
+	if o.FilledSize.IsZero() {
+		return decimal.Zero, nil
+	}
+	return o.ExecutedValue.Div(o.FilledSize)
+}
+
+type Fill struct {
+	TradeID string
+	OrderID string
+	Product string
+	Side    string
+	Price   decimal.Decimal
+	Size    decimal.Decimal
+	Fee     decimal.Decimal
+	Time    time.Time
+}
+
+//HTTPError is returned when the exchange answers with a status other than 2xx
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration //zero when the response had no Retry-After header
+	Err        error
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Err.Error())
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+//Message is the error message of the exchange without the status code
+func (e *HTTPError) Message() string {
+	return e.Err.Error()
+}
+
+//ParseRetryAfter reads the Retry-After header, either delay seconds or an http date
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package exchange
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		v    string
+		want time.Duration
+	}{
+		{name: "seconds", v: "3", want: time.Second * 3},
+		{name: "http date", v: "Sun, 01 Aug 2021 12:00:05 GMT", want: time.Second * 5},
+		{name: "date in the past", v: "Sun, 01 Aug 2021 11:00:00 GMT", want: 0},
+		{name: "empty", v: "", want: 0},
+		{name: "garbage", v: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.v, now); got != tt.want {
+				t.Errorf("ParseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrder_AveragePrice(t *testing.T) {
+	d := decimal.RequireFromString
+	tests := []struct {
+		name    string
+		order   Order
+		want    decimal.Decimal
+		wantErr error
+	}{
+		{name: "filled", order: Order{FilledSize: d("0.02116517"), ExecutedValue: d("995.02")}, want: d("47012.14306334")},
+		{name: "nothing filled", order: Order{ExecutedValue: d("1")}, want: decimal.Zero},
+		{name: "out of range", order: Order{FilledSize: d("0.00000001"), ExecutedValue: d("1000000")}, wantErr: decimal.ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.order.AveragePrice()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AveragePrice() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AveragePrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProduct_CheckOrder(t *testing.T) {
+	d := decimal.RequireFromString
+	btc := Product{ID: "BTC-USD", BaseMinSize: d("0.0001"), BaseMaxSize: d("280"), BaseIncrement: d("0.00000001"), QuoteIncrement: d("0.01"), MinFunds: d("1")}
+	limitOnly := btc
+	limitOnly.Status = ProductLimitOnly
+	cancelOnly := btc
+	cancelOnly.Status = ProductCancelOnly
+	disabled := btc
+	disabled.Disabled = true
+	tests := []struct {
+		name    string
+		product Product
+		req     OrderRequest
+		want    OrderRequest
+		wantErr string
+	}{
+		{name: "funds rounded down", product: btc, req: OrderRequest{Type: TypeMarket, Funds: d("10.019")}, want: OrderRequest{Type: TypeMarket, Funds: d("10.01")}},
+		{name: "size rounded down", product: btc, req: OrderRequest{Type: TypeMarket, Size: d("0.123456789")}, want: OrderRequest{Type: TypeMarket, Size: d("0.12345678")}},
+		{name: "no increments", product: Product{ID: "BTC-USD"}, req: OrderRequest{Type: TypeMarket, Funds: d("10.019")}, want: OrderRequest{Type: TypeMarket, Funds: d("10.019")}},
+		{name: "size below the minimum", product: btc, req: OrderRequest{Type: TypeMarket, Size: d("0.00009")}, wantErr: "BTC-USD size 0.00009 is below the minimum 0.0001"},
+		{name: "size above the maximum", product: btc, req: OrderRequest{Type: TypeMarket, Size: d("281")}, wantErr: "BTC-USD size 281 is above the maximum 280"},
+		{name: "funds below the minimum", product: btc, req: OrderRequest{Type: TypeMarket, Funds: d("0.99")}, wantErr: "BTC-USD funds 0.99 are below the minimum 1"},
+		{name: "nothing left after rounding", product: btc, req: OrderRequest{Type: TypeMarket, Funds: d("0.001")}, wantErr: "BTC-USD order has no size or funds after rounding"},
+		{name: "market order on a limit only product", product: limitOnly, req: OrderRequest{Type: TypeMarket, Funds: d("10")}, wantErr: "BTC-USD is limit_only and does not take market orders"},
+		{name: "limit order on a limit only product", product: limitOnly, req: OrderRequest{Type: TypeLimit, Size: d("1"), Price: d("10")}, want: OrderRequest{Type: TypeLimit, Size: d("1"), Price: d("10")}},
+		{name: "cancel only", product: cancelOnly, req: OrderRequest{Type: TypeLimit, Size: d("1"), Price: d("10")}, wantErr: "BTC-USD is cancel_only and does not take limit orders"},
+		{name: "trading disabled", product: disabled, req: OrderRequest{Type: TypeMarket, Funds: d("10")}, wantErr: "BTC-USD is trading disabled and does not take market orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.product.CheckOrder(tt.req)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("CheckOrder() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckOrder() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckOrder() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProduct_Tradable(t *testing.T) {
+	tests := []struct {
+		name    string
+		product Product
+		want    bool
+	}{
+		{name: "no status", product: Product{}, want: true},
+		{name: "online", product: Product{Status: ProductOnline}, want: true},
+		{name: "post only", product: Product{Status: ProductPostOnly}, want: false},
+		{name: "limit only", product: Product{Status: ProductLimitOnly}, want: false},
+		{name: "cancel only", product: Product{Status: ProductCancelOnly}, want: false},
+		{name: "online but trading disabled", product: Product{Status: ProductOnline, Disabled: true}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.product.Tradable(); got != tt.want {
+				t.Errorf("Tradable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindBalance(t *testing.T) {
+	d := decimal.RequireFromString
+	balances := []Balance{{Currency: "BTC", Total: d("0.5")}, {Currency: "EUR", Total: d("100"), Available: d("90"), Hold: d("10")}}
+	if got := FindBalance(balances, "EUR"); got != balances[1] {
+		t.Errorf("FindBalance() = %+v, want %+v", got, balances[1])
+	}
+	if got := FindBalance(balances, "eur"); got != balances[1] {
+		t.Errorf("FindBalance() = %+v, want %+v, currency codes are not case sensitive", got, balances[1])
+	}
+	if got := FindBalance(balances, "USDC"); got != (Balance{Currency: "USDC"}) {
+		t.Errorf("FindBalance() = %+v, want a zero USDC balance", got)
+	}
+}
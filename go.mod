@@ -4,10 +4,15 @@ go 1.16
 
 require (
 	github.com/cenkalti/backoff/v4 v4.1.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/motemen/go-loghttp v0.0.0-20170804080138-974ac5ceac27
 	github.com/motemen/go-nuts v0.0.0-20210718141713-347ff8a12a40 // indirect
 	github.com/preichenberger/go-coinbasepro/v2 v2.0.5
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/viper v1.8.1
 	github.com/stretchr/testify v1.7.0
-	github.com/walkerus/go-wiremock v1.2.0
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
+	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
@@ -0,0 +1,736 @@
+package kraken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"golang.org/x/time/rate"
+)
+
+//BaseURL is the Kraken REST api
+const BaseURL = "https://api.kraken.com"
+
+//maxCandles is the most candles one OHLC request returns
+const maxCandles = 720
+
+//Client is an exchange.Exchange over the Kraken REST api.
+//Products are named BASE-QUOTE as on Coinbase, BTC-USD is the Kraken pair XBTUSD.
+//Private requests are signed with the api key, without one only market data works.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	key        string
+	secret     []byte
+	limiter    *rate.Limiter
+
+	mu    sync.Mutex
+	nonce int64
+	//names maps the pair names Kraken answers with, altname and pair key, to products
+	names map[string]string
+}
+
+//NewClient secret is the base64 private key of the api key
+func NewClient(baseURL, key, secret string) (*Client, error) {
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("kraken api secret is not base64 %s", err.Error())
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		key:        key,
+		secret:     decoded,
+		limiter:    rate.NewLimiter(rate.Inf, 0),
+	}, nil
+}
+
+//SetRateLimit waits on a token bucket before every request, a rate of 0 turns it off
+func (c *Client) SetRateLimit(perSecond float64, burst int) {
+	if perSecond <= 0 {
+		c.limiter = rate.NewLimiter(rate.Inf, 0)
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+func (c *Client) Name() string {
+	return "kraken"
+}
+
+//Sign is the API-Sign header, HMAC-SHA512 of the path and the SHA256 of nonce and body, keyed with the secret
+func Sign(path, nonce, body string, secret []byte) string {
+	sha := sha256.Sum256([]byte(nonce + body))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write([]byte(path))
+	mac.Write(sha[:])
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//nextNonce increases on every call, Kraken rejects a nonce that is not larger than the last one of the key
+func (c *Client) nextNonce() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UnixNano() / int64(time.Microsecond)
+	if now <= c.nonce {
+		now = c.nonce + 1
+	}
+	c.nonce = now
+	return strconv.FormatInt(now, 10)
+}
+
+//public GETs a /0/public method and decodes its result into out
+func (c *Client) public(ctx context.Context, method string, query url.Values, out interface{}) error {
+	return c.do(ctx, http.MethodGet, "/0/public/"+method, query, out)
+}
+
+//private POSTs a signed /0/private method and decodes its result into out
+func (c *Client) private(ctx context.Context, method string, form url.Values, out interface{}) error {
+	if form == nil {
+		form = url.Values{}
+	}
+	return c.do(ctx, http.MethodPost, "/0/private/"+method, form, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, values url.Values, out interface{}) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequestWithContext(ctx, method, c.BaseURL+path+"?"+values.Encode(), nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		nonce := c.nextNonce()
+		values.Set("nonce", nonce)
+		body := values.Encode()
+		req, err = http.NewRequestWithContext(ctx, method, c.BaseURL+path, strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.key != "" {
+			req.Header.Set("API-Key", c.key)
+			req.Header.Set("API-Sign", Sign(path, nonce, body, c.secret))
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Error  []string        `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		json.Unmarshal(b, &envelope)
+		message := strings.Join(envelope.Error, "; ")
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &exchange.HTTPError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: exchange.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:        errors.New(message),
+		}
+	}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return err
+	}
+	//Kraken answers errors with a 200 and a list of messages
+	if len(envelope.Error) > 0 {
+		return &exchange.HTTPError{StatusCode: statusOf(envelope.Error), Err: errors.New(strings.Join(envelope.Error, "; "))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, out)
+}
+
+//statusOf the http status the first error message stands for, so failures are classified as for Coinbase.
+//	EAPI:Invalid key, EAPI:Invalid signature, EAPI:Invalid nonce, EGeneral:Permission denied   401
+//	EAPI:Rate limit exceeded, EOrder:Rate limit exceeded                                        429
+//	EQuery:Unknown asset pair                                                                   404
+//	EService:Unavailable, EService:Busy, EGeneral:Internal error                                503
+//	anything else, e.g. EOrder:Insufficient funds                                               400
+func statusOf(messages []string) int {
+	m := messages[0]
+	switch {
+	case strings.HasPrefix(m, "EAPI:Invalid") || strings.HasPrefix(m, "EGeneral:Permission denied"):
+		return http.StatusUnauthorized
+	case strings.Contains(m, "Rate limit"):
+		return http.StatusTooManyRequests
+	case strings.HasPrefix(m, "EQuery:Unknown asset pair"):
+		return http.StatusNotFound
+	case strings.HasPrefix(m, "EService:") || strings.HasPrefix(m, "EGeneral:Internal error"):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+//assets Kraken names differently, legacy names start with X for crypto and Z for fiat
+var assets = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+//Currency is the name of a Kraken asset the rest of the bot uses, XXBT and XBT are BTC, ZUSD is USD
+func Currency(asset string) string {
+	if len(asset) == 4 && (asset[0] == 'X' || asset[0] == 'Z') {
+		asset = asset[1:]
+	}
+	if name, ok := assets[asset]; ok {
+		return name
+	}
+	return asset
+}
+
+//Pair is the Kraken pair of a BASE-QUOTE product, BTC-USD is XBTUSD
+func Pair(product string) string {
+	parts := strings.SplitN(product, "-", 2)
+	if len(parts) != 2 {
+		return product
+	}
+	for i, part := range parts {
+		for kraken, name := range assets {
+			if part == name {
+				parts[i] = kraken
+			}
+		}
+	}
+	return parts[0] + parts[1]
+}
+
+type assetPair struct {
+	Altname     string `json:"altname"`
+	Wsname      string `json:"wsname"`
+	Base        string `json:"base"`
+	Quote       string `json:"quote"`
+	LotDecimals int    `json:"lot_decimals"`
+	OrderMin    string `json:"ordermin"`
+	CostMin     string `json:"costmin"`
+	TickSize    string `json:"tick_size"`
+	Status      string `json:"status"`
+}
+
+func (c *Client) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	var pairs map[string]assetPair
+	if err := c.public(ctx, "AssetPairs", nil, &pairs); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := map[string]string{}
+	out := make([]exchange.Product, 0, len(pairs))
+	for _, key := range keys {
+		p := pairs[key]
+		base, quote := Currency(p.Base), Currency(p.Quote)
+		id := base + "-" + quote
+		names[key], names[p.Altname] = id, id
+		out = append(out, exchange.Product{
+			ID:             id,
+			BaseCurrency:   base,
+			QuoteCurrency:  quote,
+			BaseMinSize:    parseOptional(p.OrderMin),
+			BaseIncrement:  lotSize(p.LotDecimals),
+			QuoteIncrement: parseOptional(p.TickSize),
+			MinFunds:       parseOptional(p.CostMin),
+			Status:         productStatus(p.Status),
+		})
+	}
+	c.mu.Lock()
+	c.names = names
+	c.mu.Unlock()
+	return out, nil
+}
+
+//productStatus reduce_only and the statuses Kraken uses for maintenance take no orders the bot places
+func productStatus(s string) exchange.ProductStatus {
+	switch s {
+	case "", "online":
+		return exchange.ProductOnline
+	case "cancel_only":
+		return exchange.ProductCancelOnly
+	case "post_only":
+		return exchange.ProductPostOnly
+	case "limit_only":
+		return exchange.ProductLimitOnly
+	}
+	return exchange.ProductOffline
+}
+
+//product is the BASE-QUOTE name of a pair Kraken answered with, the asset pairs are loaded on first use
+func (c *Client) product(ctx context.Context, pair string) (string, error) {
+	c.mu.Lock()
+	names := c.names
+	c.mu.Unlock()
+	if names == nil {
+		if _, err := c.GetProducts(ctx); err != nil {
+			return "", err
+		}
+		c.mu.Lock()
+		names = c.names
+		c.mu.Unlock()
+	}
+	if id, ok := names[pair]; ok {
+		return id, nil
+	}
+	return pair, nil
+}
+
+//single is the one entry of a result keyed by the pair name, next to keys like last
+func single(result map[string]json.RawMessage, out interface{}) error {
+	for key, raw := range result {
+		if key == "last" {
+			continue
+		}
+		return json.Unmarshal(raw, out)
+	}
+	return errors.New("kraken answered without the pair")
+}
+
+func (c *Client) GetBook(ctx context.Context, product string) (exchange.Book, error) {
+	var result map[string]json.RawMessage
+	if err := c.public(ctx, "Depth", url.Values{"pair": {Pair(product)}, "count": {"1"}}, &result); err != nil {
+		return exchange.Book{}, err
+	}
+	var book struct {
+		Asks [][]interface{} `json:"asks"`
+		Bids [][]interface{} `json:"bids"`
+	}
+	if err := single(result, &book); err != nil {
+		return exchange.Book{}, err
+	}
+	bids, err := levels(book.Bids)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	asks, err := levels(book.Asks)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	return exchange.Book{Bids: bids, Asks: asks}, nil
+}
+
+//levels entries are [price, volume, timestamp]
+func levels(entries [][]interface{}) ([]exchange.BookLevel, error) {
+	var out []exchange.BookLevel
+	for _, entry := range entries {
+		if len(entry) < 2 {
+			return nil, errors.New("kraken book entry has no volume")
+		}
+		price, err := decimal.Parse(fmt.Sprint(entry[0]))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.BookLevel{Price: price, Size: parseOptional(fmt.Sprint(entry[1]))})
+	}
+	return out, nil
+}
+
+//intervals of OHLC in minutes, smallest first
+var intervals = []time.Duration{
+	time.Minute,
+	time.Minute * 5,
+	time.Minute * 15,
+	time.Minute * 30,
+	time.Hour,
+	time.Hour * 4,
+	time.Hour * 24,
+	time.Hour * 24 * 7,
+	time.Hour * 24 * 15,
+}
+
+//interval is d in minutes, a zero d is the smallest interval that covers start to end in one request
+func interval(d time.Duration, start, end time.Time) (int, error) {
+	for _, i := range intervals {
+		if d == i || (d == 0 && end.Sub(start) <= i*maxCandles) {
+			return int(i.Minutes()), nil
+		}
+	}
+	if d == 0 {
+		return int(intervals[len(intervals)-1].Minutes()), nil
+	}
+	return 0, fmt.Errorf("granularity %s is not supported by kraken", d)
+}
+
+//GetCandles Kraken answers oldest first from start, up to the last 720 candles, the ones after end are dropped
+func (c *Client) GetCandles(ctx context.Context, product string, start, end time.Time, granularity time.Duration) ([]exchange.Candle, error) {
+	minutes, err := interval(granularity, start, end)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"pair":     {Pair(product)},
+		"interval": {strconv.Itoa(minutes)},
+		//since is exclusive
+		"since": {strconv.FormatInt(start.Unix()-1, 10)},
+	}
+	var result map[string]json.RawMessage
+	if err := c.public(ctx, "OHLC", query, &result); err != nil {
+		return nil, err
+	}
+	//entries are [time, open, high, low, close, vwap, volume, count]
+	var entries [][]interface{}
+	if err := single(result, &entries); err != nil {
+		return nil, err
+	}
+	var out []exchange.Candle
+	for _, entry := range entries {
+		if len(entry) < 7 {
+			return nil, errors.New("kraken candle has less than 7 fields")
+		}
+		seconds, ok := entry[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("kraken candle time %v is not a number", entry[0])
+		}
+		t := time.Unix(int64(seconds), 0).UTC()
+		if t.Before(start) || !t.Before(end) {
+			continue
+		}
+		out = append(out, exchange.Candle{
+			Time:   t,
+			Open:   parseFloat(fmt.Sprint(entry[1])),
+			High:   parseFloat(fmt.Sprint(entry[2])),
+			Low:    parseFloat(fmt.Sprint(entry[3])),
+			Close:  parseFloat(fmt.Sprint(entry[4])),
+			Volume: parseFloat(fmt.Sprint(entry[6])),
+		})
+	}
+	return out, nil
+}
+
+//GetBalances staking and other balances with a suffix, e.g. XBT.F, are left out
+func (c *Client) GetBalances(ctx context.Context) ([]exchange.Balance, error) {
+	var result map[string]struct {
+		Balance   string `json:"balance"`
+		HoldTrade string `json:"hold_trade"`
+	}
+	if err := c.private(ctx, "BalanceEx", nil, &result); err != nil {
+		return nil, err
+	}
+	assets := make([]string, 0, len(result))
+	for asset := range result {
+		if !strings.Contains(asset, ".") {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+	out := make([]exchange.Balance, 0, len(assets))
+	for _, asset := range assets {
+		if err := checkRange(result[asset].Balance, result[asset].HoldTrade); err != nil {
+			return nil, err
+		}
+		total, hold := parseOptional(result[asset].Balance), parseOptional(result[asset].HoldTrade)
+		available, err := total.Sub(hold)
+		if err != nil {
+			return nil, fmt.Errorf("%s balance %s and hold %s: %w", asset, total, hold, err)
+		}
+		out = append(out, exchange.Balance{
+			Currency:  Currency(asset),
+			Total:     total,
+			Available: available,
+			Hold:      hold,
+		})
+	}
+	return out, nil
+}
+
+//PlaceOrder market buys of Funds are sent with the viqc flag, the volume in the quote currency.
+//Amounts are sent exactly, a numeric ClientID is sent as userref.
+func (c *Client) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Order, error) {
+	form := url.Values{
+		"pair":      {Pair(req.Product)},
+		"type":      {req.Side},
+		"ordertype": {req.Type},
+	}
+	switch {
+	case req.Type == exchange.TypeMarket && !req.Funds.IsZero():
+		form.Set("volume", req.Funds.String())
+		form.Set("oflags", "viqc")
+	case req.Type == exchange.TypeMarket:
+		form.Set("volume", req.Size.String())
+	case req.Type == exchange.TypeLimit:
+		form.Set("volume", req.Size.String())
+		form.Set("price", req.Price.String())
+	default:
+		return exchange.Order{}, fmt.Errorf("order type %q is not supported by kraken", req.Type)
+	}
+	if _, err := strconv.ParseInt(req.ClientID, 10, 32); err == nil {
+		form.Set("userref", req.ClientID)
+	}
+	var result struct {
+		Txid []string `json:"txid"`
+	}
+	if err := c.private(ctx, "AddOrder", form, &result); err != nil {
+		return exchange.Order{}, err
+	}
+	if len(result.Txid) == 0 {
+		return exchange.Order{}, errors.New("kraken added an order without a txid")
+	}
+	return exchange.Order{
+		ID:      result.Txid[0],
+		Product: req.Product,
+		Side:    req.Side,
+		Type:    req.Type,
+		Size:    req.Size,
+		Funds:   req.Funds,
+		Price:   req.Price,
+		Status:  exchange.StatusPending,
+		Reason:  "added",
+	}, nil
+}
+
+type order struct {
+	Status string  `json:"status"`
+	Reason *string `json:"reason"`
+	Opentm float64 `json:"opentm"`
+	Descr  struct {
+		Pair      string `json:"pair"`
+		Type      string `json:"type"`
+		Ordertype string `json:"ordertype"`
+		Price     string `json:"price"`
+	} `json:"descr"`
+	Vol     string   `json:"vol"`
+	VolExec string   `json:"vol_exec"`
+	Cost    string   `json:"cost"`
+	Fee     string   `json:"fee"`
+	Oflags  string   `json:"oflags"`
+	Trades  []string `json:"trades"`
+}
+
+//toOrder vol is in the quote currency for viqc orders, amounts out of range are an error
+func (c *Client) toOrder(ctx context.Context, id string, o order) (exchange.Order, error) {
+	if err := checkRange(o.VolExec, o.Cost, o.Fee, o.Vol, o.Descr.Price); err != nil {
+		return exchange.Order{}, err
+	}
+	product, err := c.product(ctx, o.Descr.Pair)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	out := exchange.Order{
+		ID:            id,
+		Product:       product,
+		Side:          o.Descr.Type,
+		Type:          o.Descr.Ordertype,
+		Status:        status(o.Status),
+		Reason:        o.Status,
+		FilledSize:    parseOptional(o.VolExec),
+		ExecutedValue: parseOptional(o.Cost),
+		Fees:          parseOptional(o.Fee),
+		Created:       unixTime(o.Opentm),
+	}
+	if strings.Contains(o.Oflags, "viqc") {
+		out.Funds = parseOptional(o.Vol)
+	} else {
+		out.Size = parseOptional(o.Vol)
+	}
+	if out.Type == exchange.TypeLimit {
+		out.Price = parseOptional(o.Descr.Price)
+	}
+	if o.Reason != nil && *o.Reason != "" {
+		out.Reason = o.Status + " " + *o.Reason
+	}
+	return out, nil
+}
+
+//status Kraken orders are pending or open until they are closed, canceled or expired
+func status(s string) exchange.OrderStatus {
+	switch s {
+	case "closed":
+		return exchange.StatusFilled
+	case "canceled":
+		return exchange.StatusCancelled
+	case "expired":
+		return exchange.StatusRejected
+	}
+	return exchange.StatusPending
+}
+
+func (c *Client) queryOrder(ctx context.Context, id string, trades bool) (order, error) {
+	var result map[string]order
+	form := url.Values{"txid": {id}}
+	if trades {
+		form.Set("trades", "true")
+	}
+	if err := c.private(ctx, "QueryOrders", form, &result); err != nil {
+		return order{}, err
+	}
+	o, ok := result[id]
+	if !ok {
+		return order{}, &exchange.HTTPError{StatusCode: http.StatusNotFound, Err: fmt.Errorf("order %s not found", id)}
+	}
+	return o, nil
+}
+
+func (c *Client) GetOrder(ctx context.Context, id string) (exchange.Order, error) {
+	o, err := c.queryOrder(ctx, id, false)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return c.toOrder(ctx, id, o)
+}
+
+func (c *Client) CancelOrder(ctx context.Context, id string) error {
+	return c.private(ctx, "CancelOrder", url.Values{"txid": {id}}, nil)
+}
+
+//CancelAllOrders Kraken cancels every pair at once, the open orders of a product are cancelled one by one
+func (c *Client) CancelAllOrders(ctx context.Context, product string) ([]string, error) {
+	orders, err := c.ListOpenOrders(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	var cancelled []string
+	for _, o := range orders {
+		if err := c.CancelOrder(ctx, o.ID); err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, o.ID)
+	}
+	return cancelled, nil
+}
+
+//ListOpenOrders are sorted by the time they were opened
+func (c *Client) ListOpenOrders(ctx context.Context, product string) ([]exchange.Order, error) {
+	var result struct {
+		Open map[string]order `json:"open"`
+	}
+	if err := c.private(ctx, "OpenOrders", nil, &result); err != nil {
+		return nil, err
+	}
+	var out []exchange.Order
+	for id, o := range result.Open {
+		order, err := c.toOrder(ctx, id, o)
+		if err != nil {
+			return nil, err
+		}
+		if product == "" || order.Product == product {
+			out = append(out, order)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+//ListFills are the trades of the order, sorted by time
+func (c *Client) ListFills(ctx context.Context, orderID string) ([]exchange.Fill, error) {
+	o, err := c.queryOrder(ctx, orderID, true)
+	if err != nil || len(o.Trades) == 0 {
+		return nil, err
+	}
+	var result map[string]struct {
+		OrderTxid string  `json:"ordertxid"`
+		Pair      string  `json:"pair"`
+		Time      float64 `json:"time"`
+		Type      string  `json:"type"`
+		Price     string  `json:"price"`
+		Vol       string  `json:"vol"`
+		Fee       string  `json:"fee"`
+	}
+	if err := c.private(ctx, "QueryTrades", url.Values{"txid": {strings.Join(o.Trades, ",")}}, &result); err != nil {
+		return nil, err
+	}
+	var out []exchange.Fill
+	for id, t := range result {
+		price, err := decimal.Parse(t.Price)
+		if err != nil {
+			return nil, err
+		}
+		size, err := decimal.Parse(t.Vol)
+		if err != nil {
+			return nil, err
+		}
+		product, err := c.product(ctx, t.Pair)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.Fill{
+			TradeID: id,
+			OrderID: t.OrderTxid,
+			Product: product,
+			Side:    t.Type,
+			Price:   price,
+			Size:    size,
+			Fee:     parseOptional(t.Fee),
+			Time:    unixTime(t.Time),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+//unixTime Kraken times are seconds with a fraction, rounded to microseconds
+func unixTime(seconds float64) time.Time {
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e6))*1e3).UTC()
+}
+
+//lotSize is 10^-decimals, the decimals are never negative on Kraken and are read as 0 if they were
+func lotSize(decimals int) decimal.Decimal {
+	if decimals < 0 {
+		decimals = 0
+	}
+	return decimal.New(1, -decimals)
+}
+
+//parseOptional amounts that are left out of an answer are 0
+func parseOptional(v string) decimal.Decimal {
+	d, err := decimal.Parse(v)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+//checkRange amounts past the range of a decimal are a decimal.ErrOverflow, not a 0 that reads as nothing held or filled
+func checkRange(amounts ...string) error {
+	for _, v := range amounts {
+		if _, err := decimal.Parse(v); errors.Is(err, decimal.ErrOverflow) {
+			return err
+		}
+	}
+	return nil
+}
+
+//parseFloat candle values that are left out of an answer are 0
+func parseFloat(v string) float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
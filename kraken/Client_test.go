@@ -0,0 +1,418 @@
+package kraken
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/svc"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = base64.StdEncoding.EncodeToString([]byte("kraken test secret"))
+
+//fakeKraken answers the api methods the client uses from memory.
+//Private requests must carry a valid signature and a nonce larger than the last one.
+//Market orders fill at once at 50000 with a 0.26% fee, limit orders stay open.
+type fakeKraken struct {
+	t         *testing.T
+	mu        sync.Mutex
+	lastNonce int64
+	orders    map[string]map[string]interface{}
+	trades    map[string]map[string]interface{}
+	balances  map[string]map[string]string
+	added     []url.Values
+}
+
+func newFakeKraken(t *testing.T) (*fakeKraken, *httptest.Server) {
+	f := &fakeKraken{
+		t:      t,
+		orders: map[string]map[string]interface{}{},
+		trades: map[string]map[string]interface{}{},
+		balances: map[string]map[string]string{
+			"ZUSD":  {"balance": "1000.0000", "hold_trade": "100.0000"},
+			"XXBT":  {"balance": "0.5000000000", "hold_trade": "0.0000000000"},
+			"XBT.F": {"balance": "1.0000000000", "hold_trade": "0.0000000000"},
+		},
+	}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeKraken) reply(w http.ResponseWriter, result interface{}, errs ...string) {
+	if errs == nil {
+		errs = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": errs, "result": result})
+}
+
+func (f *fakeKraken) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if strings.HasPrefix(r.URL.Path, "/0/public/") {
+		f.public(w, method, r.URL.Query())
+		return
+	}
+	b, _ := io.ReadAll(r.Body)
+	form, _ := url.ParseQuery(string(b))
+	secret, _ := base64.StdEncoding.DecodeString(testSecret)
+	if r.Header.Get("API-Key") != "key" || r.Header.Get("API-Sign") != Sign(r.URL.Path, form.Get("nonce"), string(b), secret) {
+		f.reply(w, nil, "EAPI:Invalid signature")
+		return
+	}
+	nonce, _ := strconv.ParseInt(form.Get("nonce"), 10, 64)
+	if nonce <= f.lastNonce {
+		f.reply(w, nil, "EAPI:Invalid nonce")
+		return
+	}
+	f.lastNonce = nonce
+	f.private(w, method, form)
+}
+
+func (f *fakeKraken) public(w http.ResponseWriter, method string, query url.Values) {
+	switch method {
+	case "AssetPairs":
+		f.reply(w, map[string]interface{}{
+			"XXBTZUSD": map[string]interface{}{"altname": "XBTUSD", "wsname": "XBT/USD", "base": "XXBT", "quote": "ZUSD", "lot_decimals": 8, "ordermin": "0.0001", "costmin": "0.5", "tick_size": "0.1", "status": "online"},
+			"XETHZEUR": map[string]interface{}{"altname": "ETHEUR", "wsname": "ETH/EUR", "base": "XETH", "quote": "ZEUR", "lot_decimals": 8, "ordermin": "0.002", "costmin": "0.5", "tick_size": "0.01", "status": "reduce_only"},
+		})
+	case "Depth":
+		if query.Get("pair") != "XBTUSD" {
+			f.reply(w, nil, "EQuery:Unknown asset pair")
+			return
+		}
+		f.reply(w, map[string]interface{}{"XXBTZUSD": map[string]interface{}{
+			"asks": [][]interface{}{{"50000.10000", "1.500", 1627819200}},
+			"bids": [][]interface{}{{"49999.90000", "0.250", 1627819200}},
+		}})
+	case "OHLC":
+		assert.Equal(f.t, "60", query.Get("interval"))
+		since, _ := strconv.ParseInt(query.Get("since"), 10, 64)
+		var entries [][]interface{}
+		for i := int64(0); i < 4; i++ {
+			t := since + 1 + i*3600
+			entries = append(entries, []interface{}{t, "100.0", "110.0", "90.0", fmt.Sprintf("%d.0", 101+i), "100.5", "12.5", 42})
+		}
+		f.reply(w, map[string]interface{}{"XXBTZUSD": entries, "last": since + 3*3600})
+	default:
+		f.reply(w, nil, "EGeneral:Unknown method")
+	}
+}
+
+func (f *fakeKraken) private(w http.ResponseWriter, method string, form url.Values) {
+	switch method {
+	case "BalanceEx":
+		f.reply(w, f.balances)
+	case "AddOrder":
+		f.added = append(f.added, form)
+		if form.Get("pair") != "XBTUSD" {
+			f.reply(w, nil, "EQuery:Unknown asset pair")
+			return
+		}
+		volume, _ := strconv.ParseFloat(form.Get("volume"), 64)
+		if form.Get("type") == "buy" && volume > 100000 {
+			f.reply(w, nil, "EOrder:Insufficient funds")
+			return
+		}
+		id := fmt.Sprintf("O%d-KRKN", len(f.orders)+1)
+		o := map[string]interface{}{
+			"status": "open",
+			"reason": nil,
+			"opentm": 1627819200.5,
+			"descr":  map[string]string{"pair": "XBTUSD", "type": form.Get("type"), "ordertype": form.Get("ordertype"), "price": form.Get("price")},
+			"vol":    form.Get("volume"), "vol_exec": "0.00000000", "cost": "0.00000", "fee": "0.00000",
+			"oflags": form.Get("oflags"),
+		}
+		if form.Get("ordertype") == "market" {
+			size, cost := volume, volume*50000
+			if strings.Contains(form.Get("oflags"), "viqc") {
+				size, cost = volume/50000, volume
+			}
+			trade := fmt.Sprintf("T%d-KRKN", len(f.trades)+1)
+			o["status"], o["vol_exec"], o["cost"], o["fee"] = "closed", fmt.Sprintf("%.8f", size), fmt.Sprintf("%.5f", cost), fmt.Sprintf("%.5f", cost*0.0026)
+			o["trades"] = []string{trade}
+			f.trades[trade] = map[string]interface{}{"ordertxid": id, "pair": "XXBTZUSD", "time": 1627819201.25, "type": form.Get("type"), "price": "50000.0", "vol": o["vol_exec"], "fee": o["fee"]}
+		}
+		f.orders[id] = o
+		f.reply(w, map[string]interface{}{"descr": map[string]string{"order": "added"}, "txid": []string{id}})
+	case "QueryOrders":
+		result := map[string]interface{}{}
+		for _, id := range strings.Split(form.Get("txid"), ",") {
+			if o, ok := f.orders[id]; ok {
+				result[id] = o
+			}
+		}
+		if len(result) == 0 {
+			f.reply(w, nil, "EOrder:Unknown order")
+			return
+		}
+		f.reply(w, result)
+	case "QueryTrades":
+		result := map[string]interface{}{}
+		for _, id := range strings.Split(form.Get("txid"), ",") {
+			result[id] = f.trades[id]
+		}
+		f.reply(w, result)
+	case "OpenOrders":
+		open := map[string]interface{}{}
+		for id, o := range f.orders {
+			if o["status"] == "open" {
+				open[id] = o
+			}
+		}
+		f.reply(w, map[string]interface{}{"open": open})
+	case "CancelOrder":
+		o, ok := f.orders[form.Get("txid")]
+		if !ok {
+			f.reply(w, nil, "EOrder:Unknown order")
+			return
+		}
+		o["status"] = "canceled"
+		f.reply(w, map[string]int{"count": 1})
+	default:
+		f.reply(w, nil, "EGeneral:Unknown method")
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeKraken, func()) {
+	f, server := newFakeKraken(t)
+	client, err := NewClient(server.URL, "key", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, f, server.Close
+}
+
+func TestSign(t *testing.T) {
+	//the example of the Kraken api documentation
+	secret, _ := base64.StdEncoding.DecodeString("kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==")
+	body := "nonce=1616492376594&ordertype=limit&pair=XBTUSD&price=37500&type=buy&volume=1.25"
+	assert.Equal(t, "4/dpxb3iT4tp/ZCVEwSnEsLxx0bqyhLpdfOpc6fn7OR8+UClSV5n9E6aSS8MPtnRfp32bAb0nmbRn6H8ndwLUQ==",
+		Sign("/0/private/AddOrder", "1616492376594", body, secret))
+}
+
+func TestNames(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("BTC", Currency("XXBT"))
+	assert.Equal("BTC", Currency("XBT"))
+	assert.Equal("USD", Currency("ZUSD"))
+	assert.Equal("DOGE", Currency("XXDG"))
+	assert.Equal("USDT", Currency("USDT"))
+	assert.Equal("XBTUSD", Pair("BTC-USD"))
+	assert.Equal("ETHEUR", Pair("ETH-EUR"))
+	assert.Equal("XBTUSD", Pair("XBTUSD"))
+}
+
+func TestClient_MarketData(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	client, _, done := newTestClient(t)
+	defer done()
+	assert.Equal("kraken", client.Name())
+
+	products, err := client.GetProducts(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Product{
+		{ID: "ETH-EUR", BaseCurrency: "ETH", QuoteCurrency: "EUR", BaseMinSize: d("0.002"), BaseIncrement: d("0.00000001"), QuoteIncrement: d("0.01"), MinFunds: d("0.5"), Status: exchange.ProductOffline},
+		{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", BaseMinSize: d("0.0001"), BaseIncrement: d("0.00000001"), QuoteIncrement: d("0.1"), MinFunds: d("0.5"), Status: exchange.ProductOnline},
+	}, products)
+
+	book, err := client.GetBook(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(exchange.Book{
+		Bids: []exchange.BookLevel{{Price: d("49999.9"), Size: d("0.25")}},
+		Asks: []exchange.BookLevel{{Price: d("50000.1"), Size: d("1.5")}},
+	}, book)
+
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	candles, err := client.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour*3), time.Hour)
+	assert.Nil(err)
+	assert.Len(candles, 3, "candles from end on are dropped")
+	assert.Equal(exchange.Candle{Time: start, Open: 100, High: 110, Low: 90, Close: 101, Volume: 12.5}, candles[0])
+	assert.Equal(start.Add(time.Hour*2), candles[2].Time)
+
+	_, err = client.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour), time.Hour*2)
+	assert.NotNil(err, "two hours is not an interval of kraken")
+
+	balances, err := client.GetBalances(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Balance{
+		{Currency: "BTC", Total: d("0.5"), Available: d("0.5")},
+		{Currency: "USD", Total: d("1000"), Available: d("900"), Hold: d("100")},
+	}, balances, "staked XBT.F is left out")
+}
+
+func TestClient_interval(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		d       time.Duration
+		end     time.Time
+		want    int
+		wantErr bool
+	}{
+		{name: "Happy Path. Exact.", d: time.Hour * 4, end: start.Add(time.Hour), want: 240},
+		{name: "Happy Path. Smallest that fits a day.", end: start.Add(time.Hour * 24), want: 5},
+		{name: "Happy Path. Smallest that fits a month.", end: start.Add(time.Hour * 24 * 30), want: 60},
+		{name: "Happy Path. Ten years are in weeks.", end: start.Add(time.Hour * 24 * 3650), want: 10080},
+		{name: "Sad Path. Not supported.", d: time.Hour * 6, end: start.Add(time.Hour), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interval(tt.d, start, tt.end)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_Orders(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	client, f, done := newTestClient(t)
+	defer done()
+
+	placed, err := client.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("100"), ClientID: "42"})
+	assert.Nil(err)
+	assert.Equal(exchange.StatusPending, placed.Status)
+	assert.Equal(url.Values{
+		"pair":      {"XBTUSD"},
+		"type":      {"buy"},
+		"ordertype": {"market"},
+		"volume":    {"100"},
+		"oflags":    {"viqc"},
+		"userref":   {"42"},
+		"nonce":     f.added[0]["nonce"],
+	}, f.added[0])
+
+	order, err := client.GetOrder(ctx, placed.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.Order{
+		ID:            placed.ID,
+		Product:       "BTC-USD",
+		Side:          "buy",
+		Type:          "market",
+		Funds:         d("100"),
+		Status:        exchange.StatusFilled,
+		Reason:        "closed",
+		FilledSize:    d("0.002"),
+		ExecutedValue: d("100"),
+		Fees:          d("0.26"),
+		Created:       time.Date(2021, time.August, 1, 12, 0, 0, 500000000, time.UTC),
+	}, order)
+	avg, err := order.AveragePrice()
+	assert.Nil(err)
+	assert.Equal(d("50000"), avg)
+
+	fills, err := client.ListFills(ctx, placed.ID)
+	assert.Nil(err)
+	assert.Equal([]exchange.Fill{{
+		TradeID: "T1-KRKN",
+		OrderID: placed.ID,
+		Product: "BTC-USD",
+		Side:    "buy",
+		Price:   d("50000"),
+		Size:    d("0.002"),
+		Fee:     d("0.26"),
+		Time:    time.Date(2021, time.August, 1, 12, 0, 1, 250000000, time.UTC),
+	}}, fills)
+
+	limit, err := client.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideSell, Type: exchange.TypeLimit, Size: d("0.5"), Price: d("60000")})
+	assert.Nil(err)
+	assert.Equal("0.5", f.added[1].Get("volume"))
+	assert.Equal("60000", f.added[1].Get("price"))
+
+	orders, err := client.ListOpenOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Len(orders, 1)
+	assert.Equal(limit.ID, orders[0].ID)
+	assert.Equal(d("0.5"), orders[0].Size)
+	assert.Equal(d("60000"), orders[0].Price)
+
+	orders, err = client.ListOpenOrders(ctx, "ETH-EUR")
+	assert.Nil(err)
+	assert.Len(orders, 0, "orders of other products are left out")
+
+	cancelled, err := client.CancelAllOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal([]string{limit.ID}, cancelled)
+	order, err = client.GetOrder(ctx, limit.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.StatusCancelled, order.Status)
+}
+
+func TestClient_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     exchange.OrderRequest
+		status  int
+		message string
+	}{
+		{name: "Sad Path. Insufficient funds.", req: exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: decimal.RequireFromString("1000000")}, status: http.StatusBadRequest, message: "EOrder:Insufficient funds"},
+		{name: "Sad Path. Unknown pair.", req: exchange.OrderRequest{Product: "FOO-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: decimal.RequireFromString("10")}, status: http.StatusNotFound, message: "EQuery:Unknown asset pair"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _, done := newTestClient(t)
+			defer done()
+			_, err := client.PlaceOrder(context.Background(), tt.req)
+			var httpErr *exchange.HTTPError
+			assert.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.status, httpErr.StatusCode)
+			assert.Equal(t, tt.message, httpErr.Message())
+		})
+	}
+
+	assert := assert.New(t)
+	client, _, done := newTestClient(t)
+	defer done()
+	client.secret = []byte("wrong")
+	_, err := client.GetBalances(context.Background())
+	var httpErr *exchange.HTTPError
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusUnauthorized, httpErr.StatusCode)
+
+	_, err = NewClient(BaseURL, "key", "not base64!")
+	assert.NotNil(err)
+
+	//a filled volume out of range is refused, not read as nothing filled
+	_, err = client.toOrder(context.Background(), "1", order{Status: "closed", VolExec: "100000000000", Cost: "1000000"})
+	assert.ErrorIs(err, decimal.ErrOverflow)
+}
+
+//TestClient_CoinbaseSvc the bot trades on Kraken without changes to CoinbaseSvc
+func TestClient_CoinbaseSvc(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	client, _, done := newTestClient(t)
+	defer done()
+	cbSvc := svc.NewCoinbaseSvc(client, time.Second*5)
+
+	price, err := cbSvc.GetLastPrice(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(d("49999.9"), price)
+
+	numberOwn, buyPrice, err := cbSvc.Buy(ctx, "BTC-USD", price, d("100"))
+	assert.Nil(err)
+	assert.Equal(d("0.002"), numberOwn)
+	assert.Equal(d("50000"), buyPrice)
+
+	_, _, err = cbSvc.Buy(ctx, "BTC-USD", price, d("1000000"))
+	assert.True(errors.Is(err, svc.ErrInsufficientFunds))
+}
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	RotateDaily = "daily"
+	RotateSize  = "size"
+)
+
+//Config is read from the log section of the .conf file
+//	log:
+//	  file: logs/bot.log
+//	  rotation: daily
+//	  max_size_mb: 100
+//	  max_age_days: 30
+//	  max_backups: 10
+//	  compress: true
+//	  trade_file: logs/trades.log
+type Config struct {
+	File       string
+	Rotation   string
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+	Compress   bool
+	TradeFile  string
+}
+
+func ConfigFromViper() Config {
+	viper.SetDefault("log.rotation", RotateDaily)
+	viper.SetDefault("log.max_size_mb", 100)
+	viper.SetDefault("log.compress", true)
+	return Config{
+		File:       viper.GetString("log.file"),
+		Rotation:   viper.GetString("log.rotation"),
+		MaxSizeMB:  viper.GetInt("log.max_size_mb"),
+		MaxAgeDays: viper.GetInt("log.max_age_days"),
+		MaxBackups: viper.GetInt("log.max_backups"),
+		Compress:   viper.GetBool("log.compress"),
+		TradeFile:  viper.GetString("log.trade_file"),
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Rotation != RotateDaily && c.Rotation != RotateSize {
+		return fmt.Errorf("log.rotation must be %s or %s, got %q", RotateDaily, RotateSize, c.Rotation)
+	}
+	if c.MaxSizeMB <= 0 {
+		return fmt.Errorf("log.max_size_mb must be positive, got %d", c.MaxSizeMB)
+	}
+	if c.MaxAgeDays < 0 || c.MaxBackups < 0 {
+		return fmt.Errorf("log.max_age_days and log.max_backups can not be negative")
+	}
+	if c.TradeFile != "" && filepath.Clean(c.TradeFile) == filepath.Clean(c.File) {
+		return fmt.Errorf("log.trade_file must be different from log.file")
+	}
+	return nil
+}
+
+//Logs holds the writers built from Config.
+//Out receives all bot output, Trades only receives the trade audit trail.
+type Logs struct {
+	Out    io.Writer
+	Trades io.Writer
+
+	rotator *lumberjack.Logger
+	trades  *os.File
+	done    chan struct{}
+}
+
+//Setup builds the log writers. When no file is configured output stays on stdout.
+//The trade audit log is opened append only and is never rotated.
+func Setup(c Config) (*Logs, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &Logs{
+		Out:    os.Stdout,
+		Trades: io.Discard,
+		done:   make(chan struct{}),
+	}
+
+	if c.File != "" {
+		if err := os.MkdirAll(filepath.Dir(c.File), 0755); err != nil {
+			return nil, err
+		}
+		l.rotator = &lumberjack.Logger{
+			Filename:   c.File,
+			MaxSize:    c.MaxSizeMB,
+			MaxAge:     c.MaxAgeDays,
+			MaxBackups: c.MaxBackups,
+			Compress:   c.Compress,
+			LocalTime:  true,
+		}
+		l.Out = io.MultiWriter(os.Stdout, l.rotator)
+		if c.Rotation == RotateDaily {
+			go l.rotateDaily()
+		}
+	}
+
+	if c.TradeFile != "" {
+		if err := os.MkdirAll(filepath.Dir(c.TradeFile), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(c.TradeFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.trades = f
+		l.Trades = f
+	}
+	return l, nil
+}
+
+func (l *Logs) rotateDaily() {
+	for {
+		timer := time.NewTimer(time.Until(nextMidnight(time.Now())))
+		select {
+		case <-timer.C:
+			if err := l.rotator.Rotate(); err != nil {
+				log.Printf("failed to rotate log %s", err.Error())
+			}
+		case <-l.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (l *Logs) Close() error {
+	close(l.done)
+	var err error
+	if l.rotator != nil {
+		err = l.rotator.Close()
+	}
+	if l.trades != nil {
+		if tErr := l.trades.Close(); tErr != nil {
+			err = tErr
+		}
+	}
+	return err
+}
+
+func nextMidnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
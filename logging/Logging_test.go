@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:    "Happy Path. Daily rotation.",
+			config:  Config{File: "logs/bot.log", Rotation: RotateDaily, MaxSizeMB: 100, TradeFile: "logs/trades.log"},
+			wantErr: false,
+		},
+		{
+			name:    "Sad Path. Unknown rotation.",
+			config:  Config{Rotation: "hourly", MaxSizeMB: 100},
+			wantErr: true,
+		},
+		{
+			name:    "Sad Path. Size must be positive.",
+			config:  Config{Rotation: RotateSize, MaxSizeMB: 0},
+			wantErr: true,
+		},
+		{
+			name:    "Sad Path. Negative retention.",
+			config:  Config{Rotation: RotateSize, MaxSizeMB: 1, MaxAgeDays: -1},
+			wantErr: true,
+		},
+		{
+			name:    "Sad Path. Trade log can not be the rotated log.",
+			config:  Config{File: "logs/bot.log", Rotation: RotateDaily, MaxSizeMB: 100, TradeFile: "logs/./bot.log"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	c := Config{
+		File:      filepath.Join(dir, "logs", "bot.log"),
+		Rotation:  RotateSize,
+		MaxSizeMB: 1,
+		TradeFile: filepath.Join(dir, "audit", "trades.log"),
+	}
+
+	logs, err := Setup(c)
+	assert.Nil(err)
+	fmt.Fprintln(logs.Out, "loop begin")
+	fmt.Fprintln(logs.Trades, "buy")
+	assert.Nil(logs.Close())
+
+	out, err := os.ReadFile(c.File)
+	assert.Nil(err)
+	assert.Equal("loop begin\n", string(out))
+
+	trades, err := os.ReadFile(c.TradeFile)
+	assert.Nil(err)
+	assert.Equal("buy\n", string(trades))
+
+	// the audit log is appended to, never truncated
+	logs, err = Setup(c)
+	assert.Nil(err)
+	fmt.Fprintln(logs.Trades, "8% sell")
+	assert.Nil(logs.Close())
+	trades, err = os.ReadFile(c.TradeFile)
+	assert.Nil(err)
+	assert.Equal("buy\n8% sell\n", string(trades))
+}
+
+func Test_nextMidnight(t *testing.T) {
+	got := nextMidnight(time.Date(2021, time.December, 31, 23, 59, 0, 0, time.UTC))
+	want := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("nextMidnight() = %v, want %v", got, want)
+	}
+}
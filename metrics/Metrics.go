@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cryptobot"
+
+//State gauges, labeled by product
+var (
+	LastPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_price",
+		Help:      "Last close price seen by the main loop.",
+	}, []string{"product"})
+	PositionSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "position_size",
+		Help:      "Number of coins currently owned.",
+	}, []string{"product"})
+	BuyPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "buy_price",
+		Help:      "Price of the open position, 0 when nothing is owned.",
+	}, []string{"product"})
+	LockPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "lock_price",
+		Help:      "Current lock price, 0 when no lock is set.",
+	}, []string{"product"})
+	BottomPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bottom_price",
+		Help:      "Price the position is sold at to stop a loss.",
+	}, []string{"product"})
+	AvailableFunds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "available_funds",
+		Help:      "Funds available to buy with, in the quote currency.",
+	}, []string{"product", "currency"})
+	Value = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "value",
+		Help:      "Funds and position at the last price, in the report currency.",
+	}, []string{"product", "currency"})
+)
+
+//Order counters, labeled by product and side
+var (
+	OrdersPlaced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_placed_total",
+		Help:      "Orders accepted by CreateOrder.",
+	}, []string{"product", "side"})
+	OrdersFilled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_filled_total",
+		Help:      "Orders confirmed done and filled.",
+	}, []string{"product", "side"})
+	OrdersFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_failed_total",
+		Help:      "Orders that failed to be created or confirmed.",
+	}, []string{"product", "side"})
+)
+
+var (
+	APILatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of exchange API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint", "code"})
+	LoopDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "loop_duration_seconds",
+		Help:      "Duration of a main loop iteration, including the wait for the next tick.",
+		Buckets:   []float64{1, 5, 10, 15, 20, 25, 30, 45, 60, 120, 300},
+	})
+)
+
+//Transport records APILatency for every request sent through Next
+type Transport struct {
+	Next http.RoundTripper
+}
+
+func NewTransport(next http.RoundTripper) *Transport {
+	return &Transport{
+		Next: next,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	APILatency.WithLabelValues(req.Method, endpoint(req.URL.Path), code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+//endpoint keeps the first path segment so order and account ids don't become labels
+func endpoint(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	return "/" + parts[0]
+}
+
+//Serve exposes /metrics on addr, it blocks until ctx is done
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	log.Printf("serving metrics on %s/metrics", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_endpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "order id is dropped", path: "/orders/d0c5340b-6d6c-49d9-b567-48c4bfca13d2", want: "/orders"},
+		{name: "product is dropped", path: "/products/BTC-USD/candles", want: "/products"},
+		{name: "single segment", path: "/accounts", want: "/accounts"},
+		{name: "root", path: "", want: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpoint(tt.path); got != tt.want {
+				t.Errorf("endpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/time")
+	assert.Nil(err)
+	resp.Body.Close()
+
+	assert.Equal(1, testutil.CollectAndCount(APILatency, "cryptobot_api_request_duration_seconds"))
+
+	_, err = client.Get("http://127.0.0.1:0/time")
+	assert.NotNil(err)
+	assert.Equal(2, testutil.CollectAndCount(APILatency, "cryptobot_api_request_duration_seconds"))
+}
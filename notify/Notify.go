@@ -0,0 +1,374 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
+)
+
+//Kinds of events
+const (
+	KindStateChange    = "state change"
+	KindAPIErrors      = "api errors"
+	KindCircuitBreaker = "circuit breaker"
+)
+
+//DefaultTemplate renders an Event to the text of a message
+const DefaultTemplate = `[{{.Kind}}] {{.Product}} {{.Trigger}}: {{.Message}}`
+
+//Event is something a person should hear about without reading the logs
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Product string    `json:"product"`
+	Trigger string    `json:"trigger"`
+	Message string    `json:"message"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+//Text renders e with tmpl, DefaultTemplate when tmpl is nil
+func Text(tmpl *template.Template, e Event) (string, error) {
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, e); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+var defaultTemplate = template.Must(template.New("notify").Parse(DefaultTemplate))
+
+//Webhook posts the event and its text as json
+//	{"time": "...", "kind": "state change", "product": "BTC-USD", "trigger": "buy", "message": "...", "text": "..."}
+type Webhook struct {
+	URL      string
+	Template *template.Template
+	Client   *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	text, err := Text(w.Template, e)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.Client, w.URL, struct {
+		Event
+		Text string `json:"text"`
+	}{e, text})
+}
+
+//Slack posts the text of the event to a Slack incoming webhook
+type Slack struct {
+	URL      string
+	Template *template.Template
+	Client   *http.Client
+}
+
+func (s *Slack) Notify(ctx context.Context, e Event) error {
+	text, err := Text(s.Template, e)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": text})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify %s failed with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+//SMTP emails the text of the event, the subject is the first line of the text.
+//The connection is upgraded with STARTTLS when the server offers it, as on port 587.
+//Auth is optional, smtp.PlainAuth only sends credentials over TLS or to localhost.
+type SMTP struct {
+	Addr      string
+	Auth      smtp.Auth
+	From      string
+	To        []string
+	Template  *template.Template
+	TLSConfig *tls.Config //for STARTTLS, nil verifies the server with the system roots
+}
+
+func (s *SMTP) Notify(ctx context.Context, e Event) error {
+	text, err := Text(s.Template, e)
+	if err != nil {
+		return err
+	}
+	subject := strings.SplitN(text, "\n", 2)[0]
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		s.From, strings.Join(s.To, ", "), subject, e.Time.Format(time.RFC1123Z), text)
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		config := &tls.Config{}
+		if s.TLSConfig != nil {
+			config = s.TLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		if err := c.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if err := c.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+//Multi sends the event to every notifier, one failing does not stop the others
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, e Event) error {
+	var failed []string
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("notify failed %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+//Throttle drops events past the rate, each kind, product and trigger has its own bucket
+//so a burst of errors or lock moves never swallows a trade.
+type Throttle struct {
+	Next  Notifier
+	every time.Duration
+	burst int
+	mu    sync.Mutex
+	rates map[string]*rate.Limiter
+}
+
+func NewThrottle(next Notifier, every time.Duration, burst int) *Throttle {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Throttle{
+		Next:  next,
+		every: every,
+		burst: burst,
+		rates: map[string]*rate.Limiter{},
+	}
+}
+
+func (t *Throttle) Notify(ctx context.Context, e Event) error {
+	if !t.allow(e.Kind + " " + e.Product + " " + e.Trigger) {
+		log.Printf("notify rate limited, dropped %s %s %s", e.Kind, e.Product, e.Trigger)
+		return nil
+	}
+	return t.Next.Notify(ctx, e)
+}
+
+func (t *Throttle) allow(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rates[key]
+	if !ok {
+		r = rate.NewLimiter(rate.Every(t.every), t.burst)
+		t.rates[key] = r
+	}
+	return r.Allow()
+}
+
+//Queue sends events from a goroutine so the caller never waits on the network.
+//Events are dropped when the queue is full.
+type Queue struct {
+	Next    Notifier
+	Timeout time.Duration //per event
+	events  chan Event
+	done    chan struct{}
+}
+
+func NewQueue(next Notifier, size int, timeout time.Duration) *Queue {
+	q := &Queue{
+		Next:    next,
+		Timeout: timeout,
+		events:  make(chan Event, size),
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		ctx, cancel := context.WithTimeout(context.Background(), q.Timeout)
+		if err := q.Next.Notify(ctx, e); err != nil {
+			log.Printf("failed to notify %s", err.Error())
+		}
+		cancel()
+	}
+}
+
+//Notify never blocks and never fails, failures are logged by the queue
+func (q *Queue) Notify(ctx context.Context, e Event) error {
+	select {
+	case q.events <- e:
+	default:
+		log.Printf("notify queue full, dropped %s %s %s", e.Kind, e.Product, e.Trigger)
+	}
+	return nil
+}
+
+//Close sends the events already queued, it waits until ctx is done at most
+func (q *Queue) Close(ctx context.Context) {
+	close(q.events)
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+	}
+}
+
+//Config is read from the notify section of the .conf file, a notifier without a url or addr is off
+//	notify:
+//	  template: "[{{.Kind}}] {{.Product}} {{.Trigger}}: {{.Message}}"
+//	  every: 1m
+//	  burst: 5
+//	  error_threshold: 3
+//	  webhook_url: https://example.com/hook
+//	  slack_url: https://hooks.slack.com/services/...
+//	  smtp:
+//	    addr: smtp.example.com:587
+//	    username: bot
+//	    password: secret
+//	    from: bot@example.com
+//	    to: [me@example.com]
+type Config struct {
+	Template       string
+	Every          time.Duration
+	Burst          int
+	ErrorThreshold int //consecutive loop errors before an api errors event
+	WebhookURL     string
+	SlackURL       string
+	SMTPAddr       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SMTPFrom       string
+	SMTPTo         []string
+}
+
+func ConfigFromViper() Config {
+	viper.SetDefault("notify.template", DefaultTemplate)
+	viper.SetDefault("notify.every", time.Minute)
+	viper.SetDefault("notify.burst", 5)
+	viper.SetDefault("notify.error_threshold", 3)
+	return Config{
+		Template:       viper.GetString("notify.template"),
+		Every:          viper.GetDuration("notify.every"),
+		Burst:          viper.GetInt("notify.burst"),
+		ErrorThreshold: viper.GetInt("notify.error_threshold"),
+		WebhookURL:     viper.GetString("notify.webhook_url"),
+		SlackURL:       viper.GetString("notify.slack_url"),
+		SMTPAddr:       viper.GetString("notify.smtp.addr"),
+		SMTPUsername:   viper.GetString("notify.smtp.username"),
+		SMTPPassword:   viper.GetString("notify.smtp.password"),
+		SMTPFrom:       viper.GetString("notify.smtp.from"),
+		SMTPTo:         viper.GetStringSlice("notify.smtp.to"),
+	}
+}
+
+//New builds the configured notifiers behind a Throttle, it returns nil when none are configured
+func New(c Config) (Notifier, error) {
+	tmpl, err := template.New("notify").Parse(c.Template)
+	if err != nil {
+		return nil, fmt.Errorf("notify.template %s", err.Error())
+	}
+	var m Multi
+	if c.WebhookURL != "" {
+		m = append(m, &Webhook{URL: c.WebhookURL, Template: tmpl})
+	}
+	if c.SlackURL != "" {
+		m = append(m, &Slack{URL: c.SlackURL, Template: tmpl})
+	}
+	if c.SMTPAddr != "" {
+		if c.SMTPFrom == "" || len(c.SMTPTo) == 0 {
+			return nil, fmt.Errorf("notify.smtp.from and notify.smtp.to are required")
+		}
+		s := &SMTP{Addr: c.SMTPAddr, From: c.SMTPFrom, To: c.SMTPTo, Template: tmpl}
+		if c.SMTPUsername != "" {
+			host, _, _ := net.SplitHostPort(c.SMTPAddr)
+			s.Auth = smtp.PlainAuth("", c.SMTPUsername, c.SMTPPassword, host)
+		}
+		m = append(m, s)
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return NewThrottle(m, c.Every, c.Burst), nil
+}
@@ -0,0 +1,298 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = Event{
+	Time:    time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC),
+	Kind:    KindStateChange,
+	Product: "BTC-USD",
+	Trigger: "buy",
+	Message: "bought 2.0",
+}
+
+//recorder is a webhook stand-in, it keeps the decoded bodies
+type recorder struct {
+	mu     sync.Mutex
+	status int
+	bodies []map[string]interface{}
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var body map[string]interface{}
+	json.NewDecoder(req.Body).Decode(&body)
+	r.bodies = append(r.bodies, body)
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func (r *recorder) received() []map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]interface{}{}, r.bodies...)
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	assert := assert.New(t)
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL}
+	assert.Nil(w.Notify(context.Background(), testEvent))
+	bodies := rec.received()
+	assert.Len(bodies, 1)
+	assert.Equal("BTC-USD", bodies[0]["product"])
+	assert.Equal("buy", bodies[0]["trigger"])
+	assert.Equal("[state change] BTC-USD buy: bought 2.0", bodies[0]["text"])
+
+	rec.status = http.StatusInternalServerError
+	assert.NotNil(w.Notify(context.Background(), testEvent))
+}
+
+func TestSlack_Notify(t *testing.T) {
+	assert := assert.New(t)
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := &Slack{URL: server.URL, Template: template.Must(template.New("t").Parse(`*{{.Trigger}}* {{.Product}}`))}
+	assert.Nil(s.Notify(context.Background(), testEvent))
+	bodies := rec.received()
+	assert.Len(bodies, 1)
+	assert.Equal(map[string]interface{}{"text": "*buy* BTC-USD"}, bodies[0])
+}
+
+//smtpServer is just enough of an SMTP server for net/smtp, it keeps the DATA of every mail.
+//With a tls config it offers STARTTLS and takes AUTH PLAIN only after it, the credentials go to auths.
+func smtpServer(t *testing.T, config *tls.Config) (string, <-chan string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	mails := make(chan string, 10)
+	auths := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, config, mails, auths)
+		}
+	}()
+	return l.Addr().String(), mails, auths
+}
+
+func serveSMTP(conn net.Conn, config *tls.Config, mails, auths chan<- string) {
+	defer func() { conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+	reply("220 localhost ESMTP")
+	secure := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO") && config != nil && !secure:
+			reply("250-localhost")
+			reply("250 STARTTLS")
+		case strings.HasPrefix(cmd, "EHLO") && secure:
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, config)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case strings.HasPrefix(cmd, "AUTH PLAIN") && secure:
+			b, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line)[len("AUTH PLAIN "):])
+			auths <- strings.ReplaceAll(string(b), "\x00", " ")
+			reply("235 OK")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			mails <- data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTP_Notify(t *testing.T) {
+	assert := assert.New(t)
+	addr, mails, _ := smtpServer(t, nil)
+
+	s := &SMTP{Addr: addr, From: "bot@example.com", To: []string{"me@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	assert.Nil(s.Notify(ctx, testEvent))
+
+	select {
+	case mail := <-mails:
+		assert.Contains(mail, "Subject: [state change] BTC-USD buy: bought 2.0\r\n")
+		assert.Contains(mail, "To: me@example.com\r\n")
+	case <-time.After(time.Second * 5):
+		t.Fatal("no mail received")
+	}
+}
+
+//TestSMTP_NotifyStartTLS credentials go over STARTTLS, the server does not take AUTH before it
+func TestSMTP_NotifyStartTLS(t *testing.T) {
+	assert := assert.New(t)
+	//httptest has a certificate for 127.0.0.1 and a client that trusts it
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	addr, mails, auths := smtpServer(t, ts.TLS)
+	host, _, _ := net.SplitHostPort(addr)
+
+	s := &SMTP{
+		Addr:      addr,
+		Auth:      smtp.PlainAuth("", "bot", "hunter2", host),
+		From:      "bot@example.com",
+		To:        []string{"me@example.com"},
+		TLSConfig: ts.Client().Transport.(*http.Transport).TLSClientConfig,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	assert.Nil(s.Notify(ctx, testEvent))
+	assert.Equal(" bot hunter2", <-auths)
+	assert.Contains(<-mails, "Subject: [state change] BTC-USD buy: bought 2.0\r\n")
+
+	// a server the client does not trust gets no credentials
+	s.TLSConfig = nil
+	assert.NotNil(s.Notify(ctx, testEvent))
+	assert.Empty(auths)
+}
+
+type countNotifier struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (c *countNotifier) Notify(ctx context.Context, e Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return c.err
+}
+
+func (c *countNotifier) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.events)
+}
+
+func TestThrottle_Notify(t *testing.T) {
+	assert := assert.New(t)
+	next := &countNotifier{}
+	throttle := NewThrottle(next, time.Hour, 2)
+
+	errEvent := testEvent
+	errEvent.Kind = KindAPIErrors
+	for i := 0; i < 5; i++ {
+		assert.Nil(throttle.Notify(context.Background(), errEvent))
+	}
+	assert.Equal(2, next.count())
+
+	// errors do not use up the bucket for trades
+	assert.Nil(throttle.Notify(context.Background(), testEvent))
+	assert.Equal(3, next.count())
+
+	// neither do lock moves of the same kind and product
+	lockEvent := testEvent
+	lockEvent.Trigger = "Lock growth of 1%"
+	for i := 0; i < 5; i++ {
+		assert.Nil(throttle.Notify(context.Background(), lockEvent))
+	}
+	assert.Equal(5, next.count())
+	sellEvent := testEvent
+	sellEvent.Trigger = "8% sell"
+	assert.Nil(throttle.Notify(context.Background(), sellEvent))
+	assert.Equal(6, next.count())
+	assert.Equal(sellEvent, next.events[5])
+}
+
+func TestMulti_Notify(t *testing.T) {
+	assert := assert.New(t)
+	failing := &countNotifier{err: fmt.Errorf("its broke")}
+	ok := &countNotifier{}
+	err := Multi{failing, ok}.Notify(context.Background(), testEvent)
+	assert.NotNil(err)
+	assert.Equal(1, ok.count())
+}
+
+func TestQueue_Notify(t *testing.T) {
+	assert := assert.New(t)
+	next := &countNotifier{}
+	queue := NewQueue(next, 10, time.Second)
+	for i := 0; i < 3; i++ {
+		assert.Nil(queue.Notify(context.Background(), testEvent))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	queue.Close(ctx)
+	assert.Equal(3, next.count())
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	n, err := New(Config{Template: DefaultTemplate})
+	assert.Nil(err)
+	assert.Nil(n)
+
+	n, err = New(Config{Template: DefaultTemplate, SlackURL: "http://localhost", Every: time.Minute, Burst: 5})
+	assert.Nil(err)
+	assert.NotNil(n)
+
+	_, err = New(Config{Template: "{{.Nope"})
+	assert.NotNil(err)
+
+	_, err = New(Config{Template: DefaultTemplate, SMTPAddr: "localhost:25"})
+	assert.NotNil(err)
+}
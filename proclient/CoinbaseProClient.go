@@ -0,0 +1,184 @@
+package proclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/JasonWBrown/exchange"
+	"github.com/preichenberger/go-coinbasepro/v2"
+)
+
+//Client adapts coinbasepro.Client to ProClientInterface.
+//coinbasepro.Client has no context support, so every call runs on a copy of the client
+//whose transport attaches ctx to the request.
+type Client struct {
+	client *coinbasepro.Client
+}
+
+func NewClient(client *coinbasepro.Client) *Client {
+	return &Client{
+		client: client,
+	}
+}
+
+//HTTPError is returned when the exchange answers with a status other than 200
+type HTTPError = exchange.HTTPError
+
+//contextTransport sends every request with ctx and keeps the last response status
+type contextTransport struct {
+	ctx        context.Context
+	next       http.RoundTripper
+	statusCode int
+	header     http.Header
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req.WithContext(t.ctx))
+	if err == nil {
+		t.statusCode = resp.StatusCode
+		t.header = resp.Header
+	}
+	return resp, err
+}
+
+//wrap turns an error from a non 200 response into an HTTPError
+func (t *contextTransport) wrap(err error) error {
+	if err == nil || t.statusCode == 0 || t.statusCode == http.StatusOK {
+		return err
+	}
+	return &HTTPError{
+		StatusCode: t.statusCode,
+		RetryAfter: exchange.ParseRetryAfter(t.header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+func (c *Client) with(ctx context.Context) (*coinbasepro.Client, *contextTransport) {
+	client := *c.client
+	httpClient := http.Client{}
+	if c.client.HTTPClient != nil {
+		httpClient = *c.client.HTTPClient
+	}
+	transport := &contextTransport{ctx: ctx, next: httpClient.Transport}
+	httpClient.Transport = transport
+	client.HTTPClient = &httpClient
+	return &client, transport
+}
+
+// Product funcs
+func (c *Client) GetBook(ctx context.Context, product string, level int) (coinbasepro.Book, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetBook(product, level)
+	return v, t.wrap(err)
+}
+
+func (c *Client) GetTicker(ctx context.Context, product string) (coinbasepro.Ticker, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetTicker(product)
+	return v, t.wrap(err)
+}
+
+func (c *Client) ListTrades(ctx context.Context, product string, p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor {
+	client, _ := c.with(ctx)
+	return client.ListTrades(product, p...)
+}
+
+//Product is a Coinbase Pro product with the increments and trading flags coinbasepro.Product leaves out
+type Product struct {
+	ID              string `json:"id"`
+	BaseCurrency    string `json:"base_currency"`
+	QuoteCurrency   string `json:"quote_currency"`
+	BaseMinSize     string `json:"base_min_size"`
+	BaseMaxSize     string `json:"base_max_size"`
+	BaseIncrement   string `json:"base_increment"`
+	QuoteIncrement  string `json:"quote_increment"`
+	MinMarketFunds  string `json:"min_market_funds"`
+	Status          string `json:"status"` //online, offline, internal or delisted
+	StatusMessage   string `json:"status_message"`
+	PostOnly        bool   `json:"post_only"`
+	LimitOnly       bool   `json:"limit_only"`
+	CancelOnly      bool   `json:"cancel_only"`
+	TradingDisabled bool   `json:"trading_disabled"`
+}
+
+//GetProducts is requested directly, coinbasepro.Client.GetProducts drops the fields of Product it does not know
+func (c *Client) GetProducts(ctx context.Context) ([]Product, error) {
+	client, t := c.with(ctx)
+	var v []Product
+	_, err := client.Request(http.MethodGet, "/products", nil, &v)
+	return v, t.wrap(err)
+}
+
+func (c *Client) GetHistoricRates(ctx context.Context, product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetHistoricRates(product, p...)
+	return v, t.wrap(err)
+}
+
+func (c *Client) GetStats(ctx context.Context, product string) (coinbasepro.Stats, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetStats(product)
+	return v, t.wrap(err)
+}
+
+// Account Funcs
+func (c *Client) GetAccounts(ctx context.Context) ([]coinbasepro.Account, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetAccounts()
+	return v, t.wrap(err)
+}
+
+func (c *Client) GetAccount(ctx context.Context, id string) (coinbasepro.Account, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetAccount(id)
+	return v, t.wrap(err)
+}
+
+func (c *Client) ListAccountLedger(ctx context.Context, id string, p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor {
+	client, _ := c.with(ctx)
+	return client.ListAccountLedger(id, p...)
+}
+
+func (c *Client) ListHolds(ctx context.Context, id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor {
+	client, _ := c.with(ctx)
+	return client.ListHolds(id, p...)
+}
+
+//order funcs
+func (c *Client) CreateOrder(ctx context.Context, newOrder *coinbasepro.Order) (coinbasepro.Order, error) {
+	client, t := c.with(ctx)
+	v, err := client.CreateOrder(newOrder)
+	return v, t.wrap(err)
+}
+
+func (c *Client) CancelOrder(ctx context.Context, id string) error {
+	client, t := c.with(ctx)
+	return t.wrap(client.CancelOrder(id))
+}
+
+func (c *Client) CancelAllOrders(ctx context.Context, p ...coinbasepro.CancelAllOrdersParams) ([]string, error) {
+	client, t := c.with(ctx)
+	v, err := client.CancelAllOrders(p...)
+	return v, t.wrap(err)
+}
+
+func (c *Client) GetOrder(ctx context.Context, id string) (coinbasepro.Order, error) {
+	client, t := c.with(ctx)
+	v, err := client.GetOrder(id)
+	return v, t.wrap(err)
+}
+
+func (c *Client) ListOrders(ctx context.Context, p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor {
+	client, _ := c.with(ctx)
+	return client.ListOrders(p...)
+}
+
+func (c *Client) ListFills(ctx context.Context, p coinbasepro.ListFillsParams) *coinbasepro.Cursor {
+	client, _ := c.with(ctx)
+	return client.ListFills(p)
+}
@@ -1,26 +1,32 @@
 package proclient
 
-import "github.com/preichenberger/go-coinbasepro/v2"
+import (
+	"context"
 
+	"github.com/preichenberger/go-coinbasepro/v2"
+)
+
+//ProClientInterface every call takes a context, cancelling it cancels the http request
 type ProClientInterface interface {
 	//Product
-	GetBook(product string, level int) (coinbasepro.Book, error)
-	GetTicker(product string) (coinbasepro.Ticker, error)
-	ListTrades(product string, p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor
-	GetProducts() ([]coinbasepro.Product, error)
-	GetHistoricRates(product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error)
-	GetStats(product string) (coinbasepro.Stats, error)
+	GetBook(ctx context.Context, product string, level int) (coinbasepro.Book, error)
+	GetTicker(ctx context.Context, product string) (coinbasepro.Ticker, error)
+	ListTrades(ctx context.Context, product string, p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor
+	GetProducts(ctx context.Context) ([]Product, error)
+	GetHistoricRates(ctx context.Context, product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error)
+	GetStats(ctx context.Context, product string) (coinbasepro.Stats, error)
 
 	//Account
-	GetAccounts() ([]coinbasepro.Account, error)
-	GetAccount(id string) (coinbasepro.Account, error)
-	ListAccountLedger(id string, p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor
-	ListHolds(id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor
+	GetAccounts(ctx context.Context) ([]coinbasepro.Account, error)
+	GetAccount(ctx context.Context, id string) (coinbasepro.Account, error)
+	ListAccountLedger(ctx context.Context, id string, p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor
+	ListHolds(ctx context.Context, id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor
 
 	//Order
-	CreateOrder(newOrder *coinbasepro.Order) (coinbasepro.Order, error)
-	CancelOrder(id string) error
-	CancelAllOrders(p ...coinbasepro.CancelAllOrdersParams) ([]string, error)
-	GetOrder(id string) (coinbasepro.Order, error)
-	ListOrders(p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor
+	CreateOrder(ctx context.Context, newOrder *coinbasepro.Order) (coinbasepro.Order, error)
+	CancelOrder(ctx context.Context, id string) error
+	CancelAllOrders(ctx context.Context, p ...coinbasepro.CancelAllOrdersParams) ([]string, error)
+	GetOrder(ctx context.Context, id string) (coinbasepro.Order, error)
+	ListOrders(ctx context.Context, p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor
+	ListFills(ctx context.Context, p coinbasepro.ListFillsParams) *coinbasepro.Cursor
 }
@@ -1,91 +1,358 @@
 package proclient
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+
 	"github.com/preichenberger/go-coinbasepro/v2"
 )
 
+//MockClient is a programmable ProClientInterface for tests.
+//Every call is recorded. A call answers with the next response queued for its method with Queue or QueueCursor,
+//once the queue is empty it falls back to the fields below, with Err as the error. A done context wins over both.
+//	m.Queue("GetOrder", coinbasepro.Order{Status: "pending"}, nil).
+//		Queue("GetOrder", coinbasepro.Order{Status: "done", DoneReason: "filled"}, nil)
+//	...
+//	m.AssertCalled(t, "GetOrder", 2)
 type MockClient struct {
 	Err           error
 	HistoricRates []coinbasepro.HistoricRate
 	Book          coinbasepro.Book
+	Ticker        coinbasepro.Ticker
+	Stats         coinbasepro.Stats
 	SavedOrder    coinbasepro.Order
 	Accounts      []coinbasepro.Account
+	Products      []Product
+
+	mu      sync.Mutex
+	calls   []MockCall
+	queues  map[string][]mockResponse
+	cursors map[string][][]interface{}
+}
+
+//MockCall is one recorded call, Args are the arguments after the context with variadic params spread
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+type mockResponse struct {
+	value interface{}
+	err   error
 }
 
 func NewMockClient() *MockClient {
 	return &MockClient{}
 }
 
+//Queue adds a response for the next call of method, value is its first result or nil for the zero value.
+//It panics when method is unknown or value has the wrong type, cursor methods are queued with QueueCursor.
+func (c *MockClient) Queue(method string, value interface{}, err error) *MockClient {
+	m, ok := reflect.TypeOf(c).MethodByName(method)
+	if !ok || m.Type.NumOut() == 0 || m.Type.Out(m.Type.NumOut()-1) != reflect.TypeOf((*error)(nil)).Elem() {
+		panic(fmt.Sprintf("MockClient has no method %s that returns an error", method))
+	}
+	if m.Type.NumOut() == 1 {
+		if value != nil {
+			panic(fmt.Sprintf("MockClient.%s only returns an error, got value %T", method, value))
+		}
+	} else if out := m.Type.Out(0); value == nil {
+		value = reflect.Zero(out).Interface()
+	} else if reflect.TypeOf(value) != out {
+		panic(fmt.Sprintf("MockClient.%s returns %s, got %T", method, out, value))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queues == nil {
+		c.queues = map[string][]mockResponse{}
+	}
+	c.queues[method] = append(c.queues[method], mockResponse{value: value, err: err})
+	return c
+}
+
+//QueueCursor adds the pages of the cursor the next call of method returns.
+//A page is a slice of what the method lists, e.g. []coinbasepro.Order, or an error that NextPage returns.
+func (c *MockClient) QueueCursor(method string, pages ...interface{}) *MockClient {
+	m, ok := reflect.TypeOf(c).MethodByName(method)
+	if !ok || m.Type.NumOut() != 1 || m.Type.Out(0) != reflect.TypeOf(&coinbasepro.Cursor{}) {
+		panic(fmt.Sprintf("MockClient has no method %s that returns a cursor", method))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cursors == nil {
+		c.cursors = map[string][][]interface{}{}
+	}
+	c.cursors[method] = append(c.cursors[method], pages)
+	return c
+}
+
+//record adds the call and pops the next queued response of method, ok is false when there is none
+func (c *MockClient) record(ctx context.Context, method string, args ...interface{}) (value interface{}, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, MockCall{Method: method, Args: args})
+	if queue := c.queues[method]; len(queue) > 0 {
+		c.queues[method] = queue[1:]
+		value, ok, err = queue[0].value, true, queue[0].err
+	}
+	if ctx.Err() != nil {
+		return value, ok, ctx.Err()
+	}
+	if !ok {
+		return nil, false, c.Err
+	}
+	return value, ok, err
+}
+
+//cursor records the call and returns the next queued cursor of method, an empty one when there is none
+func (c *MockClient) cursor(method string, args ...interface{}) *coinbasepro.Cursor {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, MockCall{Method: method, Args: args})
+	queue := c.cursors[method]
+	if len(queue) == 0 {
+		return &coinbasepro.Cursor{}
+	}
+	c.cursors[method] = queue[1:]
+	return newPagesCursor(queue[0])
+}
+
+//newPagesCursor is a cursor over pages, its client answers from memory and pages with CB-AFTER like Coinbase Pro
+func newPagesCursor(pages []interface{}) *coinbasepro.Cursor {
+	client := coinbasepro.NewClient()
+	client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: "http://mock", Key: "mock", Passphrase: "mock", Secret: "bW9jaw=="})
+	client.HTTPClient = &http.Client{Transport: pagesTransport(pages)}
+	cursor := coinbasepro.NewCursor(client, http.MethodGet, "/mock", &coinbasepro.PaginationParams{})
+	cursor.HasMore = len(pages) > 0
+	return cursor
+}
+
+type pagesTransport []interface{}
+
+func (pages pagesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i, _ := strconv.Atoi(req.URL.Query().Get("after"))
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+	var body interface{}
+	if i >= len(pages) {
+		resp.StatusCode, body = http.StatusNotFound, coinbasepro.Error{Message: "no such page"}
+	} else if err, ok := pages[i].(error); ok {
+		resp.StatusCode, body = http.StatusBadRequest, coinbasepro.Error{Message: err.Error()}
+	} else {
+		body = pages[i]
+	}
+	if i+1 < len(pages) {
+		resp.Header.Set("CB-AFTER", strconv.Itoa(i+1))
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	return resp, nil
+}
+
+//Calls are the recorded calls of method in order, every call when method is empty
+func (c *MockClient) Calls(method string) []MockCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []MockCall
+	for _, call := range c.calls {
+		if method == "" || call.Method == method {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+//CreatedOrders are the orders passed to CreateOrder in order
+func (c *MockClient) CreatedOrders() []coinbasepro.Order {
+	var out []coinbasepro.Order
+	for _, call := range c.Calls("CreateOrder") {
+		out = append(out, call.Args[0].(coinbasepro.Order))
+	}
+	return out
+}
+
+//TestingT is the part of *testing.T the assertions need
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+//AssertCalled fails t unless method was called times times
+func (c *MockClient) AssertCalled(t TestingT, method string, times int) bool {
+	t.Helper()
+	if got := len(c.Calls(method)); got != times {
+		t.Errorf("MockClient.%s called %d times, want %d", method, got, times)
+		return false
+	}
+	return true
+}
+
+//AssertCalledWith fails t unless method was called with args at least once, args are compared with reflect.DeepEqual.
+//	m.AssertCalledWith(t, "CreateOrder", coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Type: "market", Size: "0.500000"})
+func (c *MockClient) AssertCalledWith(t TestingT, method string, args ...interface{}) bool {
+	t.Helper()
+	calls := c.Calls(method)
+	for _, call := range calls {
+		if reflect.DeepEqual(call.Args, args) || len(call.Args)+len(args) == 0 {
+			return true
+		}
+	}
+	got := make([]interface{}, 0, len(calls))
+	for _, call := range calls {
+		got = append(got, call.Args)
+	}
+	t.Errorf("MockClient.%s not called with %+v, calls %+v", method, args, got)
+	return false
+}
+
 // Product funcs
-func (c *MockClient) GetBook(product string, level int) (coinbasepro.Book, error) {
-	return c.Book, c.Err
+func (c *MockClient) GetBook(ctx context.Context, product string, level int) (coinbasepro.Book, error) {
+	v, ok, err := c.record(ctx, "GetBook", product, level)
+	if ok {
+		return v.(coinbasepro.Book), err
+	}
+	return c.Book, err
 }
 
-func (c *MockClient) GetTicker(product string) (coinbasepro.Ticker, error) {
-	var ticker coinbasepro.Ticker
-	return ticker, nil
+func (c *MockClient) GetTicker(ctx context.Context, product string) (coinbasepro.Ticker, error) {
+	v, ok, err := c.record(ctx, "GetTicker", product)
+	if ok {
+		return v.(coinbasepro.Ticker), err
+	}
+	return c.Ticker, err
 }
 
-func (c *MockClient) ListTrades(product string,
+func (c *MockClient) ListTrades(ctx context.Context, product string,
 	p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor {
-	return &coinbasepro.Cursor{}
+	args := []interface{}{product}
+	for _, param := range p {
+		args = append(args, param)
+	}
+	return c.cursor("ListTrades", args...)
 }
 
-func (c *MockClient) GetProducts() ([]coinbasepro.Product, error) {
-	var products []coinbasepro.Product
-	return products, nil
+func (c *MockClient) GetProducts(ctx context.Context) ([]Product, error) {
+	v, ok, err := c.record(ctx, "GetProducts")
+	if ok {
+		return v.([]Product), err
+	}
+	return c.Products, err
 }
 
-func (c *MockClient) GetHistoricRates(product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error) {
-	if c.Err != nil {
-		return c.HistoricRates, c.Err
+func (c *MockClient) GetHistoricRates(ctx context.Context, product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error) {
+	args := []interface{}{product}
+	for _, param := range p {
+		args = append(args, param)
 	}
-
-	return c.HistoricRates, nil
+	v, ok, err := c.record(ctx, "GetHistoricRates", args...)
+	if ok {
+		return v.([]coinbasepro.HistoricRate), err
+	}
+	return c.HistoricRates, err
 }
 
-func (c *MockClient) GetStats(product string) (coinbasepro.Stats, error) {
-	var stats coinbasepro.Stats
-	return stats, nil
+func (c *MockClient) GetStats(ctx context.Context, product string) (coinbasepro.Stats, error) {
+	v, ok, err := c.record(ctx, "GetStats", product)
+	if ok {
+		return v.(coinbasepro.Stats), err
+	}
+	return c.Stats, err
 }
 
 // Account Funcs
-func (c *MockClient) GetAccounts() ([]coinbasepro.Account, error) {
-	return c.Accounts, c.Err
+func (c *MockClient) GetAccounts(ctx context.Context) ([]coinbasepro.Account, error) {
+	v, ok, err := c.record(ctx, "GetAccounts")
+	if ok {
+		return v.([]coinbasepro.Account), err
+	}
+	return c.Accounts, err
 }
 
-func (c *MockClient) GetAccount(id string) (coinbasepro.Account, error) {
-	account := coinbasepro.Account{}
-	return account, nil
+//GetAccount falls back to the account in Accounts with the id
+func (c *MockClient) GetAccount(ctx context.Context, id string) (coinbasepro.Account, error) {
+	v, ok, err := c.record(ctx, "GetAccount", id)
+	if ok {
+		return v.(coinbasepro.Account), err
+	}
+	for _, account := range c.Accounts {
+		if account.ID == id {
+			return account, err
+		}
+	}
+	return coinbasepro.Account{}, err
 }
 
-func (c *MockClient) ListAccountLedger(id string,
+func (c *MockClient) ListAccountLedger(ctx context.Context, id string,
 	p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor {
-	return &coinbasepro.Cursor{}
+	args := []interface{}{id}
+	for _, param := range p {
+		args = append(args, param)
+	}
+	return c.cursor("ListAccountLedger", args...)
 }
 
-func (c *MockClient) ListHolds(id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor {
-	return &coinbasepro.Cursor{}
+func (c *MockClient) ListHolds(ctx context.Context, id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor {
+	args := []interface{}{id}
+	for _, param := range p {
+		args = append(args, param)
+	}
+	return c.cursor("ListHolds", args...)
 }
 
 //order funcs
-func (c *MockClient) CreateOrder(newOrder *coinbasepro.Order) (coinbasepro.Order, error) {
-	return c.SavedOrder, c.Err
+
+//CreateOrder records a copy of newOrder
+func (c *MockClient) CreateOrder(ctx context.Context, newOrder *coinbasepro.Order) (coinbasepro.Order, error) {
+	v, ok, err := c.record(ctx, "CreateOrder", *newOrder)
+	if ok {
+		return v.(coinbasepro.Order), err
+	}
+	return c.SavedOrder, err
+}
+
+func (c *MockClient) CancelOrder(ctx context.Context, id string) error {
+	_, _, err := c.record(ctx, "CancelOrder", id)
+	return err
 }
 
-func (c *MockClient) CancelOrder(id string) error {
-	return nil
+func (c *MockClient) CancelAllOrders(ctx context.Context, p ...coinbasepro.CancelAllOrdersParams) ([]string, error) {
+	args := []interface{}{}
+	for _, param := range p {
+		args = append(args, param)
+	}
+	v, ok, err := c.record(ctx, "CancelAllOrders", args...)
+	if ok {
+		return v.([]string), err
+	}
+	return nil, err
 }
 
-func (c *MockClient) CancelAllOrders(p ...coinbasepro.CancelAllOrdersParams) ([]string, error) {
-	var orderIDs []string
-	return orderIDs, nil
+func (c *MockClient) GetOrder(ctx context.Context, id string) (coinbasepro.Order, error) {
+	v, ok, err := c.record(ctx, "GetOrder", id)
+	if ok {
+		return v.(coinbasepro.Order), err
+	}
+	return c.SavedOrder, err
 }
 
-func (c *MockClient) GetOrder(id string) (coinbasepro.Order, error) {
-	return c.SavedOrder, c.Err
+func (c *MockClient) ListOrders(ctx context.Context, p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor {
+	args := []interface{}{}
+	for _, param := range p {
+		args = append(args, param)
+	}
+	return c.cursor("ListOrders", args...)
 }
 
-func (c *MockClient) ListOrders(p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor {
-	return &coinbasepro.Cursor{}
+func (c *MockClient) ListFills(ctx context.Context, p coinbasepro.ListFillsParams) *coinbasepro.Cursor {
+	return c.cursor("ListFills", p)
 }
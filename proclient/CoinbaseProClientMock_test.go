@@ -0,0 +1,137 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeT records the failures of the mock assertions
+type fakeT struct {
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func TestMockClient_Queue(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	m := NewMockClient()
+	m.SavedOrder = coinbasepro.Order{ID: "default"}
+	m.Queue("GetOrder", coinbasepro.Order{ID: "1", Status: "pending"}, nil).
+		Queue("GetOrder", nil, errors.New("502 bad gateway")).
+		Queue("GetOrder", coinbasepro.Order{ID: "1", Status: "done", DoneReason: "filled"}, nil)
+
+	order, err := m.GetOrder(ctx, "1")
+	assert.Nil(err)
+	assert.Equal("pending", order.Status)
+	order, err = m.GetOrder(ctx, "1")
+	assert.EqualError(err, "502 bad gateway")
+	assert.Equal(coinbasepro.Order{}, order, "nil is the zero value")
+	order, err = m.GetOrder(ctx, "1")
+	assert.Nil(err)
+	assert.Equal("filled", order.DoneReason)
+	order, err = m.GetOrder(ctx, "1")
+	assert.Nil(err)
+	assert.Equal("default", order.ID, "falls back to the fields once the queue is empty")
+
+	m.Err = errors.New("its broke")
+	_, err = m.GetTicker(ctx, "BTC-USD")
+	assert.EqualError(err, "its broke")
+	m.Queue("CancelOrder", nil, nil)
+	assert.Nil(m.CancelOrder(ctx, "1"), "a queued response wins over Err")
+	assert.EqualError(m.CancelOrder(ctx, "1"), "its broke")
+
+	m.Queue("GetStats", coinbasepro.Stats{Last: "100.00"}, nil)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = m.GetStats(cancelled, "BTC-USD")
+	assert.Equal(context.Canceled, err, "a done context wins")
+}
+
+func TestMockClient_QueuePanics(t *testing.T) {
+	m := NewMockClient()
+	assert.Panics(t, func() { m.Queue("GetOrders", nil, nil) }, "unknown method")
+	assert.Panics(t, func() { m.Queue("GetOrder", coinbasepro.Book{}, nil) }, "wrong type")
+	assert.Panics(t, func() { m.Queue("CancelOrder", "1", nil) }, "only returns an error")
+	assert.Panics(t, func() { m.Queue("ListOrders", nil, nil) }, "cursors are queued with QueueCursor")
+	assert.Panics(t, func() { m.QueueCursor("GetOrder") }, "not a cursor")
+}
+
+func TestMockClient_Assertions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	m := NewMockClient()
+	sell := coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Type: "market", Size: "0.500000"}
+	m.CreateOrder(ctx, &sell)
+	sell.Size = "changed"
+	m.GetBook(ctx, "BTC-USD", 1)
+	m.GetProducts(ctx)
+
+	assert.Len(m.Calls(""), 3)
+	assert.Equal([]MockCall{{Method: "GetBook", Args: []interface{}{"BTC-USD", 1}}}, m.Calls("GetBook"))
+	assert.Equal([]coinbasepro.Order{{ProductID: "BTC-USD", Side: "sell", Type: "market", Size: "0.500000"}}, m.CreatedOrders(), "a copy is recorded")
+
+	ft := &fakeT{}
+	assert.True(m.AssertCalled(ft, "CreateOrder", 1))
+	assert.True(m.AssertCalled(ft, "CancelOrder", 0))
+	assert.True(m.AssertCalledWith(ft, "CreateOrder", coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Type: "market", Size: "0.500000"}))
+	assert.True(m.AssertCalledWith(ft, "GetProducts"))
+	assert.Empty(ft.failures)
+
+	assert.False(m.AssertCalled(ft, "GetBook", 2))
+	assert.False(m.AssertCalledWith(ft, "CreateOrder", coinbasepro.Order{ProductID: "BTC-USD", Side: "buy"}))
+	assert.Len(ft.failures, 2)
+	assert.Contains(ft.failures[0], "MockClient.GetBook called 1 times, want 2")
+}
+
+func TestMockClient_QueueCursor(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	m := NewMockClient()
+	m.QueueCursor("ListOrders",
+		[]coinbasepro.Order{{ID: "1"}, {ID: "2"}},
+		[]coinbasepro.Order{{ID: "3"}},
+	).QueueCursor("ListAccountLedger",
+		[]coinbasepro.LedgerEntry{{ID: "L1", Amount: "10.00"}},
+		errors.New("rate limit exceeded"),
+	)
+
+	cursor := m.ListOrders(ctx, coinbasepro.ListOrdersParams{Status: "open"})
+	var ids []string
+	for cursor.HasMore {
+		var orders []coinbasepro.Order
+		assert.Nil(cursor.NextPage(&orders))
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+	}
+	assert.Equal([]string{"1", "2", "3"}, ids)
+	assert.True(m.AssertCalledWith(t, "ListOrders", coinbasepro.ListOrdersParams{Status: "open"}))
+
+	cursor = m.ListAccountLedger(ctx, "account-1")
+	var entries []coinbasepro.LedgerEntry
+	assert.Nil(cursor.NextPage(&entries))
+	assert.Equal("L1", entries[0].ID)
+	assert.True(cursor.HasMore)
+	err := cursor.NextPage(&entries)
+	assert.Equal(coinbasepro.Error{Message: "rate limit exceeded"}, err)
+	assert.False(cursor.HasMore)
+
+	assert.False(m.ListOrders(ctx).HasMore, "an empty cursor once the queue is empty")
+	assert.False(m.ListFills(ctx, coinbasepro.ListFillsParams{OrderID: "1"}).HasMore)
+
+	//the adapter reads every page of a queued cursor
+	m.QueueCursor("ListOrders", []coinbasepro.Order{{ID: "4", Status: "open"}}, []coinbasepro.Order{{ID: "5", Status: "open"}})
+	orders, err := NewExchange(m).ListOpenOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Len(orders, 2)
+}
@@ -0,0 +1,48 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Context(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "GUID-1", "status": "done"}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cb := coinbasepro.NewClient()
+	cb.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: server.URL})
+	client := NewClient(cb)
+
+	order, err := client.GetOrder(context.Background(), "fast")
+	assert.Nil(err)
+	assert.Equal("GUID-1", order.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	begin := time.Now()
+	_, err = client.GetOrder(ctx, "slow")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Less(int64(time.Since(begin)), int64(time.Second*5))
+
+	// the wrapped client is never changed
+	assert.Nil(cb.HTTPClient.Transport)
+}
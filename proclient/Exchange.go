@@ -0,0 +1,286 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/preichenberger/go-coinbasepro/v2"
+)
+
+//Exchange adapts a ProClientInterface to exchange.Exchange, Coinbase Pro strings become numbers and statuses
+type Exchange struct {
+	client ProClientInterface
+}
+
+func NewExchange(client ProClientInterface) *Exchange {
+	return &Exchange{
+		client: client,
+	}
+}
+
+func (e *Exchange) Name() string {
+	return "coinbasepro"
+}
+
+func (e *Exchange) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	products, err := e.client.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Product, 0, len(products))
+	for _, p := range products {
+		out = append(out, exchange.Product{
+			ID:             p.ID,
+			BaseCurrency:   p.BaseCurrency,
+			QuoteCurrency:  p.QuoteCurrency,
+			BaseMinSize:    parseOptional(p.BaseMinSize),
+			BaseMaxSize:    parseOptional(p.BaseMaxSize),
+			BaseIncrement:  parseOptional(p.BaseIncrement),
+			QuoteIncrement: parseOptional(p.QuoteIncrement),
+			MinFunds:       parseOptional(p.MinMarketFunds),
+			Status:         productStatus(p),
+			Disabled:       p.TradingDisabled,
+		})
+	}
+	return out, nil
+}
+
+//productStatus the flags are checked first, Coinbase Pro keeps the status online while they are set
+func productStatus(p Product) exchange.ProductStatus {
+	switch {
+	case p.CancelOnly:
+		return exchange.ProductCancelOnly
+	case p.LimitOnly:
+		return exchange.ProductLimitOnly
+	case p.PostOnly:
+		return exchange.ProductPostOnly
+	case p.Status != "" && p.Status != "online":
+		return exchange.ProductOffline
+	}
+	return exchange.ProductOnline
+}
+
+func (e *Exchange) GetBook(ctx context.Context, product string) (exchange.Book, error) {
+	book, err := e.client.GetBook(ctx, product, 1)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	bids, err := levels(book.Bids)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	asks, err := levels(book.Asks)
+	if err != nil {
+		return exchange.Book{}, err
+	}
+	return exchange.Book{Bids: bids, Asks: asks}, nil
+}
+
+func levels(entries []coinbasepro.BookEntry) ([]exchange.BookLevel, error) {
+	var out []exchange.BookLevel
+	for _, entry := range entries {
+		price, err := decimal.Parse(entry.Price)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.BookLevel{Price: price, Size: parseOptional(entry.Size)})
+	}
+	return out, nil
+}
+
+//GetCandles Coinbase Pro answers newest first, the candles are reversed
+func (e *Exchange) GetCandles(ctx context.Context, product string, start, end time.Time, granularity time.Duration) ([]exchange.Candle, error) {
+	rates, err := e.client.GetHistoricRates(ctx, product, coinbasepro.GetHistoricRatesParams{
+		Start:       start,
+		End:         end,
+		Granularity: int(granularity.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Candle, len(rates))
+	for i, r := range rates {
+		out[len(rates)-1-i] = exchange.Candle{
+			Time:   r.Time,
+			Open:   r.Open,
+			High:   r.High,
+			Low:    r.Low,
+			Close:  r.Close,
+			Volume: r.Volume,
+		}
+	}
+	return out, nil
+}
+
+func (e *Exchange) GetBalances(ctx context.Context) ([]exchange.Balance, error) {
+	accounts, err := e.client.GetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Balance, 0, len(accounts))
+	for _, a := range accounts {
+		if err := checkRange(a.Balance, a.Available, a.Hold); err != nil {
+			return nil, err
+		}
+		out = append(out, exchange.Balance{
+			Currency:  a.Currency,
+			Total:     parseOptional(a.Balance),
+			Available: parseOptional(a.Available),
+			Hold:      parseOptional(a.Hold),
+		})
+	}
+	return out, nil
+}
+
+//PlaceOrder amounts are sent exactly, 0 amounts are left out
+func (e *Exchange) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Order, error) {
+	order := &coinbasepro.Order{
+		ProductID: req.Product,
+		Side:      req.Side,
+		Type:      req.Type,
+		ClientOID: req.ClientID,
+	}
+	if !req.Size.IsZero() {
+		order.Size = req.Size.String()
+	}
+	if !req.Funds.IsZero() {
+		order.Funds = req.Funds.String()
+	}
+	if !req.Price.IsZero() {
+		order.Price = req.Price.String()
+	}
+	saved, err := e.client.CreateOrder(ctx, order)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return toOrder(saved)
+}
+
+func (e *Exchange) GetOrder(ctx context.Context, id string) (exchange.Order, error) {
+	order, err := e.client.GetOrder(ctx, id)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	return toOrder(order)
+}
+
+func (e *Exchange) CancelOrder(ctx context.Context, id string) error {
+	return e.client.CancelOrder(ctx, id)
+}
+
+func (e *Exchange) CancelAllOrders(ctx context.Context, product string) ([]string, error) {
+	return e.client.CancelAllOrders(ctx, coinbasepro.CancelAllOrdersParams{ProductID: product})
+}
+
+func (e *Exchange) ListOpenOrders(ctx context.Context, product string) ([]exchange.Order, error) {
+	var out []exchange.Order
+	cursor := e.client.ListOrders(ctx, coinbasepro.ListOrdersParams{ProductID: product, Status: "open"})
+	for cursor.HasMore {
+		var orders []coinbasepro.Order
+		if err := cursor.NextPage(&orders); err != nil {
+			return nil, err
+		}
+		for _, o := range orders {
+			order, err := toOrder(o)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, order)
+		}
+	}
+	return out, nil
+}
+
+func (e *Exchange) ListFills(ctx context.Context, orderID string) ([]exchange.Fill, error) {
+	var out []exchange.Fill
+	cursor := e.client.ListFills(ctx, coinbasepro.ListFillsParams{OrderID: orderID})
+	for cursor.HasMore {
+		var fills []coinbasepro.Fill
+		if err := cursor.NextPage(&fills); err != nil {
+			return nil, err
+		}
+		for _, f := range fills {
+			price, err := decimal.Parse(f.Price)
+			if err != nil {
+				return nil, err
+			}
+			size, err := decimal.Parse(f.Size)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, exchange.Fill{
+				TradeID: strconv.Itoa(f.TradeID),
+				OrderID: f.FillID,
+				Product: f.ProductID,
+				Side:    f.Side,
+				Price:   price,
+				Size:    size,
+				Fee:     parseOptional(f.Fee),
+				Time:    time.Time(f.CreatedAt),
+			})
+		}
+	}
+	return out, nil
+}
+
+//toOrder amounts the exchange leaves out are 0, e.g. the size of a market buy, amounts out of range are an error
+func toOrder(o coinbasepro.Order) (exchange.Order, error) {
+	if err := checkRange(o.Size, o.Funds, o.Price, o.FilledSize, o.ExecutedValue, o.FillFees); err != nil {
+		return exchange.Order{}, err
+	}
+	order := exchange.Order{
+		ID:            o.ID,
+		Product:       o.ProductID,
+		Side:          o.Side,
+		Type:          o.Type,
+		Size:          parseOptional(o.Size),
+		Funds:         parseOptional(o.Funds),
+		Price:         parseOptional(o.Price),
+		Status:        status(o),
+		Reason:        o.Status,
+		FilledSize:    parseOptional(o.FilledSize),
+		ExecutedValue: parseOptional(o.ExecutedValue),
+		Fees:          parseOptional(o.FillFees),
+		Created:       time.Time(o.CreatedAt),
+	}
+	if o.DoneReason != "" {
+		order.Reason = o.Status + " " + o.DoneReason
+	}
+	return order, nil
+}
+
+//status Coinbase Pro orders are pending, open or active until they are done with a reason, or rejected
+func status(o coinbasepro.Order) exchange.OrderStatus {
+	switch {
+	case o.Status == "done" && o.DoneReason == "filled":
+		return exchange.StatusFilled
+	case o.Status == "done" && o.DoneReason == "canceled":
+		return exchange.StatusCancelled
+	case o.Status == "done" || o.Status == "rejected":
+		return exchange.StatusRejected
+	}
+	return exchange.StatusPending
+}
+
+//parseOptional fields that are left out of an answer are 0
+func parseOptional(v string) decimal.Decimal {
+	d, err := decimal.Parse(v)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+//checkRange amounts past the range of a decimal are a decimal.ErrOverflow, not a 0 that reads as nothing held or filled
+func checkRange(amounts ...string) error {
+	for _, v := range amounts {
+		if _, err := decimal.Parse(v); errors.Is(err, decimal.ErrOverflow) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package proclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchange_status(t *testing.T) {
+	tests := []struct {
+		name  string
+		order coinbasepro.Order
+		want  exchange.OrderStatus
+	}{
+		{name: "Happy Path. Filled.", order: coinbasepro.Order{Status: "done", DoneReason: "filled"}, want: exchange.StatusFilled},
+		{name: "Happy Path. Canceled.", order: coinbasepro.Order{Status: "done", DoneReason: "canceled"}, want: exchange.StatusCancelled},
+		{name: "Happy Path. Rejected.", order: coinbasepro.Order{Status: "rejected"}, want: exchange.StatusRejected},
+		{name: "Happy Path. Done for another reason.", order: coinbasepro.Order{Status: "done", DoneReason: "expired"}, want: exchange.StatusRejected},
+		{name: "Happy Path. Open.", order: coinbasepro.Order{Status: "open"}, want: exchange.StatusPending},
+		{name: "Happy Path. Pending.", order: coinbasepro.Order{Status: "pending"}, want: exchange.StatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status(tt.order))
+		})
+	}
+}
+
+func TestExchange_MarketData(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMockClient()
+	m.HistoricRates = []coinbasepro.HistoricRate{{Time: start.Add(time.Hour), Open: 2}, {Time: start, Open: 1}}
+	m.Book = coinbasepro.Book{
+		Bids: []coinbasepro.BookEntry{{Price: "99.50", Size: "0.1"}},
+		Asks: []coinbasepro.BookEntry{{Price: "100.50", Size: "0.2"}},
+	}
+	m.Products = []Product{
+		{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", BaseMinSize: "0.0001", BaseIncrement: "0.00000001", QuoteIncrement: "0.01", MinMarketFunds: "1", Status: "online"},
+		{ID: "ETH-USD", Status: "online", LimitOnly: true},
+		{ID: "LTC-USD", Status: "online", CancelOnly: true, TradingDisabled: true},
+		{ID: "XRP-USD", Status: "delisted"},
+	}
+	m.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "150.25", Available: "100.25", Hold: "50.00"}}
+	ex := NewExchange(m)
+
+	candles, err := ex.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour*2), time.Hour)
+	assert.Nil(err)
+	assert.Equal([]exchange.Candle{{Time: start, Open: 1}, {Time: start.Add(time.Hour), Open: 2}}, candles, "oldest first")
+
+	book, err := ex.GetBook(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(exchange.Book{Bids: []exchange.BookLevel{{Price: d("99.5"), Size: d("0.1")}}, Asks: []exchange.BookLevel{{Price: d("100.5"), Size: d("0.2")}}}, book)
+
+	products, err := ex.GetProducts(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Product{
+		{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", BaseMinSize: d("0.0001"), BaseIncrement: d("0.00000001"), QuoteIncrement: d("0.01"), MinFunds: d("1"), Status: exchange.ProductOnline},
+		{ID: "ETH-USD", Status: exchange.ProductLimitOnly},
+		{ID: "LTC-USD", Status: exchange.ProductCancelOnly, Disabled: true},
+		{ID: "XRP-USD", Status: exchange.ProductOffline},
+	}, products)
+
+	balances, err := ex.GetBalances(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Balance{{Currency: "USD", Total: d("150.25"), Available: d("100.25"), Hold: d("50")}}, balances)
+
+	m.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: ""}}}
+	_, err = ex.GetBook(ctx, "BTC-USD")
+	assert.NotNil(err, "a price that does not parse is an error")
+
+	//a balance out of range is refused, not read as 0
+	m.Accounts = []coinbasepro.Account{{Currency: "SHIB", Balance: "100000000000", Available: "100000000000"}}
+	_, err = ex.GetBalances(ctx)
+	assert.ErrorIs(err, decimal.ErrOverflow)
+}
+
+func TestExchange_Orders(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	m := NewMockClient()
+	m.Book = coinbasepro.Book{
+		Bids: []coinbasepro.BookEntry{{Price: "99.00"}},
+		Asks: []coinbasepro.BookEntry{{Price: "100.00"}},
+	}
+	paper := NewPaperClient(m, "USD", d("1000"))
+	paper.Fee = 0.0
+	ex := NewExchange(paper)
+
+	placed, err := ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("500")})
+	assert.Nil(err)
+	order, err := ex.GetOrder(ctx, placed.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.StatusFilled, order.Status)
+	assert.Equal("done filled", order.Reason)
+	assert.Equal(d("5"), order.FilledSize)
+	assert.Equal(d("500"), order.ExecutedValue)
+	avg, err := order.AveragePrice()
+	assert.Nil(err)
+	assert.Equal(d("100"), avg)
+	assert.Equal(d("500"), order.Funds)
+	assert.Equal(decimal.Zero, order.Size, "a market buy has no size")
+
+	//amounts are sent exactly, not cut to 6 or 2 places
+	m.Book.Bids[0].Price = "99.12345678"
+	paper.SetBalance("BTC", d("0.02116517"))
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideSell, Type: exchange.TypeMarket, Size: d("0.02116517")})
+	assert.Nil(err)
+	assert.Equal(decimal.Zero, balance(t, paper, "BTC"))
+
+	//a filled size out of range is refused, not read as nothing filled
+	m.SavedOrder = coinbasepro.Order{ID: "GUID-2", Status: "done", DoneReason: "filled", FilledSize: "100000000000", ExecutedValue: "1000000"}
+	_, err = NewExchange(m).GetOrder(ctx, "GUID-2")
+	assert.ErrorIs(err, decimal.ErrOverflow)
+}
+
+func TestExchange_Lists(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orders":
+			if r.URL.Query().Get("after") == "" {
+				w.Header().Set("CB-AFTER", "page2")
+				json.NewEncoder(w).Encode([]map[string]string{{"id": "1", "product_id": "BTC-USD", "side": "buy", "type": "limit", "price": "90.00", "size": "1.0", "status": "open"}})
+				return
+			}
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "2", "product_id": "BTC-USD", "side": "sell", "type": "limit", "price": "120.00", "size": "1.0", "status": "open"}})
+		case "/fills":
+			assert.Equal("GUID-1", r.URL.Query().Get("order_id"))
+			w.Write([]byte(`[{"trade_id": 7, "product_id": "BTC-USD", "order_id": "GUID-1", "price": "100.00", "size": "0.5", "fee": "0.25", "side": "buy", "created_at": "2021-08-01T12:00:00Z"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := coinbasepro.NewClient()
+	client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: server.URL, Key: "key", Passphrase: "passphrase", Secret: "c2VjcmV0"})
+	ex := NewExchange(NewClient(client))
+
+	orders, err := ex.ListOpenOrders(context.Background(), "BTC-USD")
+	assert.Nil(err)
+	assert.Len(orders, 2, "every page")
+	assert.Equal(decimal.RequireFromString("90"), orders[0].Price)
+	assert.Equal(exchange.StatusPending, orders[1].Status)
+
+	fills, err := ex.ListFills(context.Background(), "GUID-1")
+	assert.Nil(err)
+	assert.Equal([]exchange.Fill{{
+		TradeID: "7",
+		OrderID: "GUID-1",
+		Product: "BTC-USD",
+		Side:    "buy",
+		Price:   decimal.RequireFromString("100"),
+		Size:    decimal.RequireFromString("0.5"),
+		Fee:     decimal.RequireFromString("0.25"),
+		Time:    time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC),
+	}}, fills)
+}
@@ -0,0 +1,240 @@
+package proclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/preichenberger/go-coinbasepro/v2"
+)
+
+//PaperClient trades in memory, market data comes from next.
+//Market orders fill at once at the top of the book less Fee, balances start with the funds given to NewPaperClient.
+//Ledger, holds, order listing and fills are passed through to next.
+type PaperClient struct {
+	next     ProClientInterface
+	Fee      float64 //taker fee as a fraction of the order value
+	mu       sync.Mutex
+	balances map[string]decimal.Decimal
+	orders   map[string]coinbasepro.Order
+	seq      int
+}
+
+func NewPaperClient(next ProClientInterface, currency string, funds decimal.Decimal) *PaperClient {
+	return &PaperClient{
+		next:     next,
+		Fee:      0.005,
+		balances: map[string]decimal.Decimal{currency: funds},
+		orders:   map[string]coinbasepro.Order{},
+	}
+}
+
+// Product funcs
+func (c *PaperClient) GetBook(ctx context.Context, product string, level int) (coinbasepro.Book, error) {
+	return c.next.GetBook(ctx, product, level)
+}
+
+func (c *PaperClient) GetTicker(ctx context.Context, product string) (coinbasepro.Ticker, error) {
+	return c.next.GetTicker(ctx, product)
+}
+
+func (c *PaperClient) ListTrades(ctx context.Context, product string, p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor {
+	return c.next.ListTrades(ctx, product, p...)
+}
+
+func (c *PaperClient) GetProducts(ctx context.Context) ([]Product, error) {
+	return c.next.GetProducts(ctx)
+}
+
+func (c *PaperClient) GetHistoricRates(ctx context.Context, product string, p ...coinbasepro.GetHistoricRatesParams) ([]coinbasepro.HistoricRate, error) {
+	return c.next.GetHistoricRates(ctx, product, p...)
+}
+
+func (c *PaperClient) GetStats(ctx context.Context, product string) (coinbasepro.Stats, error) {
+	return c.next.GetStats(ctx, product)
+}
+
+// Account Funcs, the account id is the currency
+func (c *PaperClient) GetAccounts(ctx context.Context) ([]coinbasepro.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var accounts []coinbasepro.Account
+	for currency := range c.balances {
+		accounts = append(accounts, c.account(currency))
+	}
+	return accounts, nil
+}
+
+func (c *PaperClient) GetAccount(ctx context.Context, id string) (coinbasepro.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.balances[id]; !ok {
+		return coinbasepro.Account{}, &HTTPError{StatusCode: 404, Err: coinbasepro.Error{Message: "NotFound"}}
+	}
+	return c.account(id), nil
+}
+
+func (c *PaperClient) account(currency string) coinbasepro.Account {
+	balance := c.balances[currency].String()
+	return coinbasepro.Account{
+		ID:        currency,
+		Currency:  currency,
+		Balance:   balance,
+		Available: balance,
+		Hold:      "0",
+	}
+}
+
+func (c *PaperClient) ListAccountLedger(ctx context.Context, id string, p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor {
+	return c.next.ListAccountLedger(ctx, id, p...)
+}
+
+func (c *PaperClient) ListHolds(ctx context.Context, id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor {
+	return c.next.ListHolds(ctx, id, p...)
+}
+
+//order funcs, only market orders are supported
+func (c *PaperClient) CreateOrder(ctx context.Context, newOrder *coinbasepro.Order) (coinbasepro.Order, error) {
+	if newOrder.Type != "market" {
+		return coinbasepro.Order{}, rejected("paper trading only supports market orders")
+	}
+	currencies := strings.SplitN(newOrder.ProductID, "-", 2)
+	if len(currencies) != 2 {
+		return coinbasepro.Order{}, rejected("product not found")
+	}
+	base, quote := currencies[0], currencies[1]
+	book, err := c.next.GetBook(ctx, newOrder.ProductID, 1)
+	if err != nil {
+		return coinbasepro.Order{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var size, value, fee decimal.Decimal
+	rate := decimal.NewFromFloat(c.Fee)
+	switch newOrder.Side {
+	case "buy":
+		price, err := topOfBook(book.Asks, book.Bids)
+		if err != nil {
+			return coinbasepro.Order{}, err
+		}
+		funds, err := decimal.Parse(newOrder.Funds)
+		if err != nil || !funds.IsPositive() {
+			return coinbasepro.Order{}, rejected("funds is invalid")
+		}
+		if funds.GreaterThan(c.balances[quote]) {
+			return coinbasepro.Order{}, rejected("Insufficient funds")
+		}
+		var a amounts
+		fee = a.of(funds.Mul(rate))
+		value = a.of(funds.Sub(fee))
+		size = a.of(value.Div(price))
+		quoteLeft, baseHeld := a.of(c.balances[quote].Sub(funds)), a.of(c.balances[base].Add(size))
+		if a.err != nil {
+			return coinbasepro.Order{}, rejected(a.err.Error())
+		}
+		c.balances[quote], c.balances[base] = quoteLeft, baseHeld
+	case "sell":
+		price, err := topOfBook(book.Bids, book.Asks)
+		if err != nil {
+			return coinbasepro.Order{}, err
+		}
+		size, err = decimal.Parse(newOrder.Size)
+		if err != nil || !size.IsPositive() {
+			return coinbasepro.Order{}, rejected("size is invalid")
+		}
+		if size.GreaterThan(c.balances[base]) {
+			return coinbasepro.Order{}, rejected("Insufficient funds")
+		}
+		var a amounts
+		value = a.of(size.Mul(price))
+		fee = a.of(value.Mul(rate))
+		baseLeft, quoteHeld := a.of(c.balances[base].Sub(size)), a.of(c.balances[quote].Add(a.of(value.Sub(fee))))
+		if a.err != nil {
+			return coinbasepro.Order{}, rejected(a.err.Error())
+		}
+		c.balances[base], c.balances[quote] = baseLeft, quoteHeld
+	default:
+		return coinbasepro.Order{}, rejected("side is invalid")
+	}
+
+	c.seq++
+	order := *newOrder
+	order.ID = fmt.Sprintf("paper-%d", c.seq)
+	order.Status = "done"
+	order.DoneReason = "filled"
+	order.Settled = true
+	order.FilledSize = size.String()
+	order.ExecutedValue = value.String()
+	order.FillFees = fee.String()
+	order.CreatedAt = coinbasepro.Time(time.Now())
+	c.orders[order.ID] = order
+	return order, nil
+}
+
+func topOfBook(side, other []coinbasepro.BookEntry) (decimal.Decimal, error) {
+	if len(side) == 0 {
+		side = other
+	}
+	if len(side) == 0 {
+		return decimal.Zero, rejected("book is empty")
+	}
+	price, err := decimal.Parse(side[0].Price)
+	if err != nil || !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("failed to parse book price %q", side[0].Price)
+	}
+	return price, nil
+}
+
+//amounts keeps the first error of a chain of decimal operations, an order out of range is rejected as a whole
+type amounts struct {
+	err error
+}
+
+func (a *amounts) of(d decimal.Decimal, err error) decimal.Decimal {
+	if a.err == nil {
+		a.err = err
+	}
+	return d
+}
+
+func rejected(message string) error {
+	return &HTTPError{StatusCode: 400, Err: coinbasepro.Error{Message: message}}
+}
+
+//CancelOrder market orders fill on create, there is never anything to cancel
+func (c *PaperClient) CancelOrder(ctx context.Context, id string) error {
+	return nil
+}
+
+func (c *PaperClient) CancelAllOrders(ctx context.Context, p ...coinbasepro.CancelAllOrdersParams) ([]string, error) {
+	return []string{}, nil
+}
+
+func (c *PaperClient) GetOrder(ctx context.Context, id string) (coinbasepro.Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	order, ok := c.orders[id]
+	if !ok {
+		return coinbasepro.Order{}, &HTTPError{StatusCode: 404, Err: coinbasepro.Error{Message: "NotFound"}}
+	}
+	return order, nil
+}
+
+func (c *PaperClient) ListOrders(ctx context.Context, p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor {
+	return c.next.ListOrders(ctx, p...)
+}
+
+func (c *PaperClient) ListFills(ctx context.Context, p coinbasepro.ListFillsParams) *coinbasepro.Cursor {
+	return c.next.ListFills(ctx, p)
+}
+
+//SetBalance sets the balance of a currency, e.g. to carry a position over a restart
+func (c *PaperClient) SetBalance(currency string, balance decimal.Decimal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances[currency] = balance
+}
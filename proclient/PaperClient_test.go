@@ -0,0 +1,73 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func balance(t *testing.T, c *PaperClient, currency string) decimal.Decimal {
+	a, err := c.GetAccount(context.Background(), currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decimal.RequireFromString(a.Balance)
+}
+
+func TestPaperClient_Orders(t *testing.T) {
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	m := NewMockClient()
+	m.Book = coinbasepro.Book{
+		Bids: []coinbasepro.BookEntry{{Price: "99.00"}},
+		Asks: []coinbasepro.BookEntry{{Price: "100.00"}},
+	}
+	c := NewPaperClient(m, "USD", d("1000"))
+	c.Fee = 0.01
+
+	// buys at the ask less the fee
+	order, err := c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "buy", Funds: "500.00", Type: "market"})
+	assert.Nil(err)
+	saved, err := c.GetOrder(ctx, order.ID)
+	assert.Nil(err)
+	assert.Equal("done", saved.Status)
+	assert.Equal("filled", saved.DoneReason)
+	assert.Equal("4.95", saved.FilledSize)
+	assert.Equal("495", saved.ExecutedValue)
+	assert.Equal(d("500"), balance(t, c, "USD"))
+	assert.Equal(d("4.95"), balance(t, c, "BTC"))
+
+	// sells at the bid less the fee
+	_, err = c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Size: "4.95", Type: "market"})
+	assert.Nil(err)
+	assert.Equal(d("985.1495"), balance(t, c, "USD"), "500 + 4.95*99 less 1%, exactly")
+	assert.Equal(decimal.Zero, balance(t, c, "BTC"))
+
+	// sad paths are rejected like the exchange would
+	var httpErr *HTTPError
+	_, err = c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Size: "1.0", Type: "market"})
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(400, httpErr.StatusCode)
+	_, err = c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "buy", Funds: "5000.00", Type: "market"})
+	assert.NotNil(err)
+	_, err = c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "buy", Funds: "5.00", Price: "1.0", Type: "limit"})
+	assert.NotNil(err)
+	_, err = c.GetOrder(ctx, "GUID-1")
+	assert.NotNil(err)
+
+	// a sale worth more than a decimal holds is rejected and leaves the balances alone
+	c.SetBalance("BTC", d("1000000000"))
+	_, err = c.CreateOrder(ctx, &coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Size: "1000000000", Type: "market"})
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(400, httpErr.StatusCode)
+	assert.Equal(d("1000000000"), balance(t, c, "BTC"))
+	assert.Equal(d("985.1495"), balance(t, c, "USD"))
+
+	c.SetBalance("BTC", d("2"))
+	assert.Equal(d("2"), balance(t, c, "BTC"))
+}
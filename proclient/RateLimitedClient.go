@@ -0,0 +1,234 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"golang.org/x/time/rate"
+)
+
+//RateLimits Coinbase Pro limits public and private endpoints separately.
+//Retries only apply to idempotent GETs that fail with 429 or 5xx.
+type RateLimits struct {
+	PublicPerSecond  float64
+	PublicBurst      int
+	PrivatePerSecond float64
+	PrivateBurst     int
+	Retries          int
+	RetryBase        time.Duration //first backoff, doubled on every retry
+	RetryMax         time.Duration //backoff cap, Retry-After from the exchange is honored past this
+}
+
+//DefaultRateLimits stays under the documented limits of 3/s public and 5/s private
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		PublicPerSecond:  3,
+		PublicBurst:      6,
+		PrivatePerSecond: 5,
+		PrivateBurst:     10,
+		Retries:          3,
+		RetryBase:        time.Millisecond * 250,
+		RetryMax:         time.Second * 10,
+	}
+}
+
+//RateLimitedClient decorates a ProClientInterface with a token bucket per endpoint class and retries.
+//Cursors are passed through, their pages are requested outside of the limiter.
+type RateLimitedClient struct {
+	next    ProClientInterface
+	limits  RateLimits
+	public  *rate.Limiter
+	private *rate.Limiter
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+func NewRateLimitedClient(next ProClientInterface, limits RateLimits) *RateLimitedClient {
+	return &RateLimitedClient{
+		next:    next,
+		limits:  limits,
+		public:  newLimiter(limits.PublicPerSecond, limits.PublicBurst),
+		private: newLimiter(limits.PrivatePerSecond, limits.PrivateBurst),
+		sleep:   sleep,
+	}
+}
+
+//newLimiter a rate of 0 turns the bucket off
+func newLimiter(perSecond float64, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//do waits on the bucket and sends the request once
+func (c *RateLimitedClient) do(ctx context.Context, bucket *rate.Limiter, call func() error) error {
+	if err := bucket.Wait(ctx); err != nil {
+		return err
+	}
+	return call()
+}
+
+//get is do with retries, only use it for idempotent requests
+func (c *RateLimitedClient) get(ctx context.Context, bucket *rate.Limiter, call func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.do(ctx, bucket, call)
+		if err == nil || attempt >= c.limits.Retries || !retryable(err) {
+			return err
+		}
+		wait := c.backoff(attempt, err)
+		log.Printf("retrying in %s after %s", wait, err.Error())
+		if sErr := c.sleep(ctx, wait); sErr != nil {
+			return err
+		}
+	}
+}
+
+func retryable(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+}
+
+//backoff is full jitter exponential backoff, Retry-After wins when the exchange sends it
+func (c *RateLimitedClient) backoff(attempt int, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+	d := c.limits.RetryBase << uint(attempt)
+	if d <= 0 || d > c.limits.RetryMax {
+		d = c.limits.RetryMax
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// Product funcs
+func (c *RateLimitedClient) GetBook(ctx context.Context, product string, level int) (book coinbasepro.Book, err error) {
+	err = c.get(ctx, c.public, func() error {
+		book, err = c.next.GetBook(ctx, product, level)
+		return err
+	})
+	return book, err
+}
+
+func (c *RateLimitedClient) GetTicker(ctx context.Context, product string) (ticker coinbasepro.Ticker, err error) {
+	err = c.get(ctx, c.public, func() error {
+		ticker, err = c.next.GetTicker(ctx, product)
+		return err
+	})
+	return ticker, err
+}
+
+func (c *RateLimitedClient) ListTrades(ctx context.Context, product string, p ...coinbasepro.ListTradesParams) *coinbasepro.Cursor {
+	return c.next.ListTrades(ctx, product, p...)
+}
+
+func (c *RateLimitedClient) GetProducts(ctx context.Context) (products []Product, err error) {
+	err = c.get(ctx, c.public, func() error {
+		products, err = c.next.GetProducts(ctx)
+		return err
+	})
+	return products, err
+}
+
+func (c *RateLimitedClient) GetHistoricRates(ctx context.Context, product string, p ...coinbasepro.GetHistoricRatesParams) (rates []coinbasepro.HistoricRate, err error) {
+	err = c.get(ctx, c.public, func() error {
+		rates, err = c.next.GetHistoricRates(ctx, product, p...)
+		return err
+	})
+	return rates, err
+}
+
+func (c *RateLimitedClient) GetStats(ctx context.Context, product string) (stats coinbasepro.Stats, err error) {
+	err = c.get(ctx, c.public, func() error {
+		stats, err = c.next.GetStats(ctx, product)
+		return err
+	})
+	return stats, err
+}
+
+// Account Funcs
+func (c *RateLimitedClient) GetAccounts(ctx context.Context) (accounts []coinbasepro.Account, err error) {
+	err = c.get(ctx, c.private, func() error {
+		accounts, err = c.next.GetAccounts(ctx)
+		return err
+	})
+	return accounts, err
+}
+
+func (c *RateLimitedClient) GetAccount(ctx context.Context, id string) (account coinbasepro.Account, err error) {
+	err = c.get(ctx, c.private, func() error {
+		account, err = c.next.GetAccount(ctx, id)
+		return err
+	})
+	return account, err
+}
+
+func (c *RateLimitedClient) ListAccountLedger(ctx context.Context, id string, p ...coinbasepro.GetAccountLedgerParams) *coinbasepro.Cursor {
+	return c.next.ListAccountLedger(ctx, id, p...)
+}
+
+func (c *RateLimitedClient) ListHolds(ctx context.Context, id string, p ...coinbasepro.ListHoldsParams) *coinbasepro.Cursor {
+	return c.next.ListHolds(ctx, id, p...)
+}
+
+//order funcs, only GetOrder is retried
+func (c *RateLimitedClient) CreateOrder(ctx context.Context, newOrder *coinbasepro.Order) (order coinbasepro.Order, err error) {
+	err = c.do(ctx, c.private, func() error {
+		order, err = c.next.CreateOrder(ctx, newOrder)
+		return err
+	})
+	return order, err
+}
+
+func (c *RateLimitedClient) CancelOrder(ctx context.Context, id string) error {
+	return c.do(ctx, c.private, func() error {
+		return c.next.CancelOrder(ctx, id)
+	})
+}
+
+func (c *RateLimitedClient) CancelAllOrders(ctx context.Context, p ...coinbasepro.CancelAllOrdersParams) (ids []string, err error) {
+	err = c.do(ctx, c.private, func() error {
+		ids, err = c.next.CancelAllOrders(ctx, p...)
+		return err
+	})
+	return ids, err
+}
+
+func (c *RateLimitedClient) GetOrder(ctx context.Context, id string) (order coinbasepro.Order, err error) {
+	err = c.get(ctx, c.private, func() error {
+		order, err = c.next.GetOrder(ctx, id)
+		return err
+	})
+	return order, err
+}
+
+func (c *RateLimitedClient) ListOrders(ctx context.Context, p ...coinbasepro.ListOrdersParams) *coinbasepro.Cursor {
+	return c.next.ListOrders(ctx, p...)
+}
+
+func (c *RateLimitedClient) ListFills(ctx context.Context, p coinbasepro.ListFillsParams) *coinbasepro.Cursor {
+	return c.next.ListFills(ctx, p)
+}
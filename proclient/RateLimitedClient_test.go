@@ -0,0 +1,168 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeExchange answers with statuses in order, then 200
+type fakeExchange struct {
+	statuses   []int
+	retryAfter string
+	calls      int32
+}
+
+func (f *fakeExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := int(atomic.AddInt32(&f.calls, 1)) - 1
+	w.Header().Set("Content-Type", "application/json")
+	if n < len(f.statuses) {
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.WriteHeader(f.statuses[n])
+		w.Write([]byte(`{"message": "slow down"}`))
+		return
+	}
+	w.Write([]byte(`{"id": "GUID-1", "status": "done", "bids": [["1.0", "1.0", 1]]}`))
+}
+
+func newRateLimitedTestClient(url string, limits RateLimits) (*RateLimitedClient, *[]time.Duration) {
+	cb := coinbasepro.NewClient()
+	cb.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: url})
+	c := NewRateLimitedClient(NewClient(cb), limits)
+	slept := &[]time.Duration{}
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		*slept = append(*slept, d)
+		return ctx.Err()
+	}
+	return c, slept
+}
+
+func TestRateLimitedClient_Retry(t *testing.T) {
+	limits := DefaultRateLimits()
+	limits.PublicPerSecond = 0
+	limits.PrivatePerSecond = 0
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		call       func(c *RateLimitedClient) error
+		wantErr    int
+		wantCalls  int32
+		wantSleep  time.Duration
+	}{
+		{
+			name:       "Happy Path. 429 is retried after Retry-After.",
+			statuses:   []int{429},
+			retryAfter: "2",
+			call: func(c *RateLimitedClient) error {
+				_, err := c.GetOrder(context.Background(), "GUID-1")
+				return err
+			},
+			wantCalls: 2,
+			wantSleep: time.Second * 2,
+		},
+		{
+			name:     "Happy Path. 5xx is retried with jitter under the base backoff.",
+			statuses: []int{503, 502},
+			call: func(c *RateLimitedClient) error {
+				_, err := c.GetBook(context.Background(), "BTC-USD", 1)
+				return err
+			},
+			wantCalls: 3,
+		},
+		{
+			name:     "Sad Path. Retries run out.",
+			statuses: []int{500, 500, 500, 500, 500},
+			call: func(c *RateLimitedClient) error {
+				_, err := c.GetAccounts(context.Background())
+				return err
+			},
+			wantErr:   500,
+			wantCalls: 4,
+		},
+		{
+			name:     "Sad Path. 400 is not retried.",
+			statuses: []int{400},
+			call: func(c *RateLimitedClient) error {
+				_, err := c.GetTicker(context.Background(), "BTC-USD")
+				return err
+			},
+			wantErr:   400,
+			wantCalls: 1,
+		},
+		{
+			name:     "Sad Path. CreateOrder is not idempotent and is not retried.",
+			statuses: []int{503},
+			call: func(c *RateLimitedClient) error {
+				_, err := c.CreateOrder(context.Background(), &coinbasepro.Order{})
+				return err
+			},
+			wantErr:   503,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := &fakeExchange{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			server := httptest.NewServer(exchange)
+			defer server.Close()
+			c, slept := newRateLimitedTestClient(server.URL, limits)
+
+			err := tt.call(c)
+			if tt.wantErr == 0 {
+				assert.Nil(t, err)
+			} else {
+				var httpErr *HTTPError
+				assert.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, tt.wantErr, httpErr.StatusCode)
+			}
+			assert.Equal(t, tt.wantCalls, atomic.LoadInt32(&exchange.calls))
+			for i, d := range *slept {
+				if tt.wantSleep != 0 {
+					assert.Equal(t, tt.wantSleep, d)
+					continue
+				}
+				assert.LessOrEqual(t, int64(d), int64(limits.RetryBase<<uint(i)))
+				assert.Greater(t, int64(d), int64(0))
+			}
+		})
+	}
+}
+
+func TestRateLimitedClient_Limit(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(&fakeExchange{})
+	defer server.Close()
+	limits := DefaultRateLimits()
+	limits.PublicPerSecond = 20
+	limits.PublicBurst = 1
+	c, _ := newRateLimitedTestClient(server.URL, limits)
+
+	begin := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.GetBook(context.Background(), "BTC-USD", 1)
+		assert.Nil(err)
+	}
+	assert.GreaterOrEqual(int64(time.Since(begin)), int64(time.Millisecond*90))
+
+	// private bucket is separate
+	begin = time.Now()
+	_, err := c.GetOrder(context.Background(), "GUID-1")
+	assert.Nil(err)
+	assert.Less(int64(time.Since(begin)), int64(time.Millisecond*40))
+
+	// waiting on the bucket stops with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.GetBook(ctx, "BTC-USD", 1)
+	assert.NotNil(err)
+}
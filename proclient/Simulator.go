@@ -0,0 +1,650 @@
+package proclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/preichenberger/go-coinbasepro/v2"
+)
+
+//FillBehavior is what the Simulator does with a new order
+type FillBehavior int
+
+const (
+	//FillImmediately market orders are done filled when they are created, limit orders rest until the book crosses them
+	FillImmediately FillBehavior = iota
+	//FillAfterPolls orders are pending for PendingPolls GetOrder calls, then they fill like FillImmediately
+	FillAfterPolls
+	//FillNever orders stay pending
+	FillNever
+	//FillCancel orders are done canceled without a fill
+	FillCancel
+	//FillReject orders are rejected when they are created
+	FillReject
+)
+
+//simTimeLayout is the time format of Coinbase Pro answers
+const simTimeLayout = "2006-01-02T15:04:05.999999Z"
+
+//Simulator is an in process Coinbase Pro on httptest for integration tests.
+//It checks the signature of private requests, serves products, books, candles and accounts from memory,
+//and runs orders through their lifecycle, holding and moving balances as they fill at the top of the book.
+//Every Simulator has its own state, tests can run in parallel.
+//	sim := proclient.NewSimulator()
+//	defer sim.Close()
+//	sim.SetBook("BTC-USD", 99.99, 100.01)
+//	sim.SetBalance("USD", 1000)
+//	ex := proclient.NewExchange(sim.Client())
+type Simulator struct {
+	URL        string
+	Key        string
+	Passphrase string
+	Secret     string //base64, as Coinbase Pro hands it out
+
+	server *httptest.Server
+	now    func() time.Time
+
+	mu           sync.Mutex
+	fee          decimal.Decimal
+	fill         FillBehavior
+	pendingPolls int
+	products     []Product
+	books        map[string][2]decimal.Decimal
+	candles      map[string][]coinbasepro.HistoricRate
+	accounts     map[string]*simAccount
+	orders       map[string]*simOrder
+	fills        []coinbasepro.Fill
+	failures     []simFailure
+	nextID       int
+}
+
+type simAccount struct {
+	id      string
+	balance decimal.Decimal
+	hold    decimal.Decimal
+}
+
+//available the hold is never more than the balance, both are positive and the difference is in range
+func (a *simAccount) available() decimal.Decimal {
+	available, _ := a.balance.Sub(a.hold)
+	return available
+}
+
+type simOrder struct {
+	order coinbasepro.Order
+	hold  decimal.Decimal //what the order holds in its hold currency
+	polls int             //GetOrder calls left before it fills, FillAfterPolls only
+}
+
+type simFailure struct {
+	method  string
+	path    string
+	status  int
+	message string
+}
+
+//NewSimulator listens on a local port with BTC-USD listed, a 0.5% taker fee and orders filled immediately
+func NewSimulator() *Simulator {
+	s := &Simulator{
+		Key:        "sim-key",
+		Passphrase: "sim-passphrase",
+		Secret:     base64.StdEncoding.EncodeToString([]byte("sim-secret")),
+		now:        time.Now,
+		fee:        decimal.RequireFromString("0.005"),
+		books:      map[string][2]decimal.Decimal{},
+		candles:    map[string][]coinbasepro.HistoricRate{},
+		accounts:   map[string]*simAccount{},
+		orders:     map[string]*simOrder{},
+	}
+	s.products = []Product{{
+		ID:             "BTC-USD",
+		BaseCurrency:   "BTC",
+		QuoteCurrency:  "USD",
+		BaseMinSize:    "0.0001",
+		BaseMaxSize:    "280",
+		BaseIncrement:  "0.00000001",
+		QuoteIncrement: "0.01",
+		Status:         "online",
+	}}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+func (s *Simulator) Close() {
+	s.server.Close()
+}
+
+//Client is a Coinbase Pro client with the credentials of the simulator
+func (s *Simulator) Client() ProClientInterface {
+	client := coinbasepro.NewClient()
+	client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: s.URL, Key: s.Key, Passphrase: s.Passphrase, Secret: s.Secret})
+	return NewClient(client)
+}
+
+//SetFee is the taker fee rate charged on every fill
+func (s *Simulator) SetFee(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fee = decimal.NewFromFloat(rate)
+}
+
+//SetFill sets what happens to new orders, pendingPolls is used by FillAfterPolls
+func (s *Simulator) SetFill(fill FillBehavior, pendingPolls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fill = fill
+	s.pendingPolls = pendingPolls
+}
+
+//AddProduct lists p, or replaces the product with its ID.
+//Orders are rejected as Coinbase Pro does when TradingDisabled, CancelOnly, LimitOnly or PostOnly is set.
+func (s *Simulator) AddProduct(p Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.products {
+		if s.products[i].ID == p.ID {
+			s.products[i] = p
+			return
+		}
+	}
+	s.products = append(s.products, p)
+}
+
+//SetBook is the top of the book of product, resting limit orders it crosses fill at their price
+func (s *Simulator) SetBook(product string, bid, ask decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.books[product] = [2]decimal.Decimal{bid, ask}
+	for _, id := range s.orderIDs() {
+		o := s.orders[id]
+		if o.order.ProductID == product && o.order.Status == "open" {
+			s.fillLimit(o)
+		}
+	}
+}
+
+//SetCandles are the candles of product, GetHistoricRates answers with the ones between start and end
+func (s *Simulator) SetCandles(product string, rates []coinbasepro.HistoricRate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles[product] = append([]coinbasepro.HistoricRate(nil), rates...)
+}
+
+//SetBalance sets the balance of currency, holds are kept
+func (s *Simulator) SetBalance(currency string, amount decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(currency).balance = amount
+}
+
+//Balance is the balance of currency, holds included
+func (s *Simulator) Balance(currency string) decimal.Decimal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.account(currency).balance
+}
+
+//Fail makes the next request with method and a path starting with path answer status with message.
+//Failures are used in the order they were added.
+func (s *Simulator) Fail(method, path string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, simFailure{method: method, path: path, status: status, message: message})
+}
+
+//Orders are every order the simulator has seen, oldest first
+func (s *Simulator) Orders() []coinbasepro.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []coinbasepro.Order
+	for _, id := range s.orderIDs() {
+		out = append(out, s.orders[id].order)
+	}
+	return out
+}
+
+func (s *Simulator) account(currency string) *simAccount {
+	a, ok := s.accounts[currency]
+	if !ok {
+		a = &simAccount{id: "account-" + currency}
+		s.accounts[currency] = a
+	}
+	return a
+}
+
+func (s *Simulator) product(id string) (Product, bool) {
+	for _, p := range s.products {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Product{}, false
+}
+
+//orderIDs are sorted by creation
+func (s *Simulator) orderIDs() []string {
+	ids := make([]string, 0, len(s.orders))
+	for id := range s.orders {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(ids[i], "sim-order-"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(ids[j], "sim-order-"))
+		return a < b
+	})
+	return ids
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: err.Error()})
+		return
+	}
+	private := !strings.HasPrefix(r.URL.Path, "/products")
+	if private {
+		if message := s.verify(r, string(body)); message != "" {
+			s.reply(w, http.StatusUnauthorized, coinbasepro.Error{Message: message})
+			return
+		}
+	}
+	for i, f := range s.failures {
+		if f.method == r.Method && strings.HasPrefix(r.URL.Path, f.path) {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			s.reply(w, f.status, coinbasepro.Error{Message: f.message})
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/products":
+		s.reply(w, http.StatusOK, s.products)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "products":
+		s.productData(w, parts[1], parts[2], query)
+	case r.Method == http.MethodGet && r.URL.Path == "/accounts":
+		s.reply(w, http.StatusOK, s.accountList())
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "accounts":
+		for _, a := range s.accountList() {
+			if a.ID == parts[1] {
+				s.reply(w, http.StatusOK, a)
+				return
+			}
+		}
+		s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "NotFound"})
+	case r.Method == http.MethodPost && r.URL.Path == "/orders":
+		s.createOrder(w, body)
+	case r.Method == http.MethodGet && r.URL.Path == "/orders":
+		s.listOrders(w, query)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "orders":
+		s.getOrder(w, parts[1])
+	case r.Method == http.MethodDelete && r.URL.Path == "/orders":
+		var ids []string
+		for _, id := range s.orderIDs() {
+			o := s.orders[id]
+			if isOpen(o.order) && (query.Get("product_id") == "" || o.order.ProductID == query.Get("product_id")) {
+				s.cancel(o)
+				ids = append(ids, id)
+			}
+		}
+		s.reply(w, http.StatusOK, ids)
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "orders":
+		o, ok := s.orders[parts[1]]
+		if !ok || !isOpen(o.order) {
+			s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "order not found"})
+			return
+		}
+		s.cancel(o)
+		s.reply(w, http.StatusOK, []string{parts[1]})
+	case r.Method == http.MethodGet && r.URL.Path == "/fills":
+		fills := []coinbasepro.Fill{}
+		for _, f := range s.fills {
+			if (query.Get("order_id") == "" || f.FillID == query.Get("order_id")) && (query.Get("product_id") == "" || f.ProductID == query.Get("product_id")) {
+				fills = append(fills, f)
+			}
+		}
+		s.reply(w, http.StatusOK, fills)
+	default:
+		s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "NotFound"})
+	}
+}
+
+//verify checks the CB-ACCESS headers, the signature is over timestamp, method, path with query and body
+func (s *Simulator) verify(r *http.Request, body string) string {
+	if r.Header.Get("CB-ACCESS-KEY") != s.Key {
+		return "invalid api key"
+	}
+	if r.Header.Get("CB-ACCESS-PASSPHRASE") != s.Passphrase {
+		return "invalid passphrase"
+	}
+	timestamp := r.Header.Get("CB-ACCESS-TIMESTAMP")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || math.Abs(float64(s.now().Unix()-seconds)) > 30 {
+		return "request timestamp expired"
+	}
+	secret, _ := base64.StdEncoding.DecodeString(s.Secret)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + r.Method + r.URL.RequestURI() + body))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get("CB-ACCESS-SIGN"))) {
+		return "invalid signature"
+	}
+	return ""
+}
+
+func (s *Simulator) reply(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Simulator) productData(w http.ResponseWriter, id, kind string, query map[string][]string) {
+	if _, ok := s.product(id); !ok {
+		s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "NotFound"})
+		return
+	}
+	book, ok := s.books[id]
+	switch kind {
+	case "book":
+		if !ok {
+			s.reply(w, http.StatusOK, map[string]interface{}{"sequence": 1, "bids": []interface{}{}, "asks": []interface{}{}})
+			return
+		}
+		s.reply(w, http.StatusOK, map[string]interface{}{
+			"sequence": 1,
+			"bids":     [][]interface{}{{book[0].String(), "1", 1}},
+			"asks":     [][]interface{}{{book[1].String(), "1", 1}},
+		})
+	case "ticker":
+		s.reply(w, http.StatusOK, map[string]interface{}{
+			"trade_id": len(s.fills),
+			"price":    book[0].String(),
+			"size":     "0",
+			"bid":      book[0].String(),
+			"ask":      book[1].String(),
+			"volume":   "0",
+			"time":     s.now().UTC().Format(simTimeLayout),
+		})
+	case "candles":
+		start, _ := time.Parse(time.RFC3339, first(query["start"]))
+		end, _ := time.Parse(time.RFC3339, first(query["end"]))
+		//newest first, [time, low, high, open, close, volume]
+		out := [][]float64{}
+		rates := s.candles[id]
+		for i := len(rates) - 1; i >= 0; i-- {
+			r := rates[i]
+			if (!start.IsZero() && r.Time.Before(start)) || (!end.IsZero() && r.Time.After(end)) {
+				continue
+			}
+			out = append(out, []float64{float64(r.Time.Unix()), r.Low, r.High, r.Open, r.Close, r.Volume})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i][0] > out[j][0] })
+		s.reply(w, http.StatusOK, out)
+	default:
+		s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "NotFound"})
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (s *Simulator) accountList() []coinbasepro.Account {
+	currencies := make([]string, 0, len(s.accounts))
+	for currency := range s.accounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	out := []coinbasepro.Account{}
+	for _, currency := range currencies {
+		a := s.accounts[currency]
+		out = append(out, coinbasepro.Account{
+			ID:        a.id,
+			Currency:  currency,
+			Balance:   a.balance.String(),
+			Hold:      a.hold.String(),
+			Available: a.available().String(),
+		})
+	}
+	return out
+}
+
+//createOrder holds the funds of the order and applies the fill behavior
+func (s *Simulator) createOrder(w http.ResponseWriter, body []byte) {
+	var req coinbasepro.Order
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Invalid order"})
+		return
+	}
+	p, ok := s.product(req.ProductID)
+	if !ok {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Product not found"})
+		return
+	}
+	size, funds, price := parseOptional(req.Size), parseOptional(req.Funds), parseOptional(req.Price)
+	if req.Type == "" {
+		req.Type = "limit"
+	}
+	switch {
+	case p.TradingDisabled || (p.Status != "" && p.Status != "online"):
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Trading is disabled for " + p.ID})
+		return
+	case p.CancelOnly:
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: p.ID + " is in cancel only mode"})
+		return
+	case (p.LimitOnly || p.PostOnly) && req.Type != "limit":
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: p.ID + " is in limit only mode"})
+		return
+	}
+	var hold decimal.Decimal
+	var currency string
+	var n amounts
+	switch {
+	case req.Side == "buy" && req.Type == "market" && funds.IsPositive():
+		hold, currency = funds, p.QuoteCurrency
+	case req.Side == "buy" && req.Type == "market" && size.IsPositive():
+		hold, currency = n.of(n.of(size.Mul(s.books[p.ID][1])).Mul(n.of(decimal.One.Add(s.fee)))), p.QuoteCurrency
+	case req.Side == "buy" && req.Type == "limit" && size.IsPositive() && price.IsPositive():
+		hold, currency = n.of(n.of(size.Mul(price)).Mul(n.of(decimal.One.Add(s.fee)))), p.QuoteCurrency
+	case req.Side == "sell" && size.IsPositive() && (req.Type == "market" || price.IsPositive()):
+		hold, currency = size, p.BaseCurrency
+	default:
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Invalid order, size, funds or price missing"})
+		return
+	}
+	if n.err != nil {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Invalid order, " + n.err.Error()})
+		return
+	}
+	if size.IsPositive() && size.LessThan(parseOptional(p.BaseMinSize)) {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "size is too small. Minimum size is " + p.BaseMinSize})
+		return
+	}
+	if funds.IsPositive() && funds.LessThan(parseOptional(p.MinMarketFunds)) {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "funds is too small. Minimum funds is " + p.MinMarketFunds})
+		return
+	}
+	a := s.account(currency)
+	if a.available().LessThan(hold) {
+		s.reply(w, http.StatusBadRequest, coinbasepro.Error{Message: "Insufficient funds"})
+		return
+	}
+
+	s.nextID++
+	o := &simOrder{order: req, hold: hold, polls: s.pendingPolls}
+	o.order.ID = fmt.Sprintf("sim-order-%d", s.nextID)
+	o.order.Status = "pending"
+	o.order.FilledSize, o.order.ExecutedValue, o.order.FillFees = "0", "0", "0"
+	o.order.CreatedAt = coinbasepro.Time(s.now().UTC())
+	if s.fill == FillReject {
+		o.order.Status = "rejected"
+		o.hold = decimal.Zero
+		s.orders[o.order.ID] = o
+		s.reply(w, http.StatusOK, o.order)
+		return
+	}
+	a.hold, _ = a.hold.Add(hold) //hold is at most what is available, the sum is at most the balance
+	s.orders[o.order.ID] = o
+	switch s.fill {
+	case FillImmediately:
+		s.advance(o)
+	case FillCancel:
+		s.cancel(o)
+	}
+	s.reply(w, http.StatusOK, o.order)
+}
+
+//advance fills a market order at the book, limit orders open and fill once the book crosses them
+func (s *Simulator) advance(o *simOrder) {
+	if o.order.Type == "limit" {
+		o.order.Status = "open"
+		s.fillLimit(o)
+		return
+	}
+	book, ok := s.books[o.order.ProductID]
+	if !ok {
+		return
+	}
+	if o.order.Side == "buy" {
+		s.fillAt(o, book[1])
+	} else {
+		s.fillAt(o, book[0])
+	}
+}
+
+func (s *Simulator) fillLimit(o *simOrder) {
+	book, ok := s.books[o.order.ProductID]
+	price := parseOptional(o.order.Price)
+	if ok && ((o.order.Side == "buy" && !book[1].GreaterThan(price)) || (o.order.Side == "sell" && !book[0].LessThan(price))) {
+		s.fillAt(o, price)
+	}
+}
+
+//fillAt fills the whole order at price, the fee is charged in the quote currency on top of buys and off sells
+//Buys with funds spend funds exactly, the fee is what is left after the value. An order that takes an amount out of range is rejected.
+func (s *Simulator) fillAt(o *simOrder, price decimal.Decimal) {
+	p, _ := s.product(o.order.ProductID)
+	base, quote := s.account(p.BaseCurrency), s.account(p.QuoteCurrency)
+	var size, value, fee decimal.Decimal
+	var n amounts
+	if funds := parseOptional(o.order.Funds); o.order.Side == "buy" && o.order.Type == "market" && funds.IsPositive() {
+		value = n.of(funds.Div(n.of(decimal.One.Add(s.fee))))
+		size = n.of(value.Div(price))
+		fee = n.of(funds.Sub(value))
+	} else {
+		size = parseOptional(o.order.Size)
+		value = n.of(size.Mul(price))
+		fee = n.of(value.Mul(s.fee))
+	}
+	var baseBalance, quoteBalance decimal.Decimal
+	if o.order.Side == "buy" {
+		quoteBalance = n.of(quote.balance.Sub(n.of(value.Add(fee))))
+		baseBalance = n.of(base.balance.Add(size))
+	} else {
+		baseBalance = n.of(base.balance.Sub(size))
+		quoteBalance = n.of(quote.balance.Add(n.of(value.Sub(fee))))
+	}
+	if n.err != nil {
+		s.cancel(o)
+		o.order.Status, o.order.DoneReason = "rejected", ""
+		return
+	}
+	if o.order.Side == "buy" {
+		quote.hold, _ = quote.hold.Sub(o.hold)
+	} else {
+		base.hold, _ = base.hold.Sub(o.hold)
+	}
+	base.balance, quote.balance = baseBalance, quoteBalance
+	o.hold = decimal.Zero
+	o.order.Status, o.order.DoneReason, o.order.Settled = "done", "filled", true
+	o.order.FilledSize, o.order.ExecutedValue, o.order.FillFees = size.String(), value.String(), fee.String()
+	s.fills = append(s.fills, coinbasepro.Fill{
+		TradeID:   len(s.fills) + 1,
+		ProductID: p.ID,
+		Price:     price.String(),
+		Size:      size.String(),
+		FillID:    o.order.ID,
+		CreatedAt: coinbasepro.Time(s.now().UTC()),
+		Fee:       fee.String(),
+		Settled:   true,
+		Side:      o.order.Side,
+		Liquidity: "T",
+	})
+}
+
+func (s *Simulator) cancel(o *simOrder) {
+	p, _ := s.product(o.order.ProductID)
+	currency := p.QuoteCurrency
+	if o.order.Side == "sell" {
+		currency = p.BaseCurrency
+	}
+	a := s.account(currency)
+	a.hold, _ = a.hold.Sub(o.hold) //o.hold is part of the hold
+	o.hold = decimal.Zero
+	o.order.Status, o.order.DoneReason = "done", "canceled"
+}
+
+//getOrder counts down the polls of FillAfterPolls orders
+func (s *Simulator) getOrder(w http.ResponseWriter, id string) {
+	o, ok := s.orders[id]
+	if !ok {
+		s.reply(w, http.StatusNotFound, coinbasepro.Error{Message: "NotFound"})
+		return
+	}
+	if s.fill == FillAfterPolls && o.order.Status == "pending" {
+		if o.polls <= 0 {
+			s.advance(o)
+		}
+		o.polls--
+	}
+	s.reply(w, http.StatusOK, o.order)
+}
+
+func (s *Simulator) listOrders(w http.ResponseWriter, query map[string][]string) {
+	statuses := query["status"]
+	out := []coinbasepro.Order{}
+	for _, id := range s.orderIDs() {
+		o := s.orders[id].order
+		if product := first(query["product_id"]); product != "" && o.ProductID != product {
+			continue
+		}
+		if len(statuses) == 0 && !isOpen(o) {
+			continue
+		}
+		for _, status := range statuses {
+			if status == "all" || status == o.Status {
+				out = append(out, o)
+				break
+			}
+		}
+		if len(statuses) == 0 {
+			out = append(out, o)
+		}
+	}
+	s.reply(w, http.StatusOK, out)
+}
+
+//isOpen orders can still fill
+func isOpen(o coinbasepro.Order) bool {
+	return o.Status == "pending" || o.Status == "open" || o.Status == "active"
+}
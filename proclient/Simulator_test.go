@@ -0,0 +1,219 @@
+package proclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulator_Auth(t *testing.T) {
+	t.Parallel()
+	sim := NewSimulator()
+	defer sim.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		config  coinbasepro.ClientConfig
+		wantErr string
+	}{
+		{name: "Happy Path. Signed with the simulator credentials", config: coinbasepro.ClientConfig{Key: sim.Key, Passphrase: sim.Passphrase, Secret: sim.Secret}},
+		{name: "Sad Path. Wrong key", config: coinbasepro.ClientConfig{Key: "other", Passphrase: sim.Passphrase, Secret: sim.Secret}, wantErr: "invalid api key"},
+		{name: "Sad Path. Wrong passphrase", config: coinbasepro.ClientConfig{Key: sim.Key, Passphrase: "other", Secret: sim.Secret}, wantErr: "invalid passphrase"},
+		{name: "Sad Path. Wrong secret", config: coinbasepro.ClientConfig{Key: sim.Key, Passphrase: sim.Passphrase, Secret: "b3RoZXI="}, wantErr: "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.BaseURL = sim.URL
+			client := coinbasepro.NewClient()
+			client.UpdateConfig(&tt.config)
+			ex := NewExchange(NewClient(client))
+
+			_, err := ex.GetBalances(ctx)
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+				return
+			}
+			var httpErr *HTTPError
+			assert.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			_, err = ex.GetBook(ctx, "BTC-USD")
+			assert.Nil(t, err, "market data is public")
+		})
+	}
+}
+
+func TestSimulator_MarketData(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	sim := NewSimulator()
+	defer sim.Close()
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	sim.SetBook("BTC-USD", d("99.5"), d("100.5"))
+	sim.SetCandles("BTC-USD", []coinbasepro.HistoricRate{
+		{Time: start, Open: 1, Close: 2},
+		{Time: start.Add(time.Hour), Open: 2, Close: 3},
+		{Time: start.Add(time.Hour * 2), Open: 3, Close: 4},
+	})
+	ex := NewExchange(sim.Client())
+
+	products, err := ex.GetProducts(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Product{{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", BaseMinSize: d("0.0001"), BaseMaxSize: d("280"), BaseIncrement: d("0.00000001"), QuoteIncrement: d("0.01"), Status: exchange.ProductOnline}}, products)
+
+	book, err := ex.GetBook(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(exchange.Book{Bids: []exchange.BookLevel{{Price: d("99.5"), Size: d("1")}}, Asks: []exchange.BookLevel{{Price: d("100.5"), Size: d("1")}}}, book)
+
+	candles, err := ex.GetCandles(ctx, "BTC-USD", start, start.Add(time.Hour), time.Hour)
+	assert.Nil(err)
+	for i := range candles {
+		candles[i].Time = candles[i].Time.UTC() //unix times are parsed in Local
+	}
+	assert.Equal([]exchange.Candle{{Time: start, Open: 1, Close: 2}, {Time: start.Add(time.Hour), Open: 2, Close: 3}}, candles, "between start and end, oldest first")
+
+	_, err = ex.GetBook(ctx, "ETH-USD")
+	assert.NotNil(err, "unknown product")
+}
+
+func TestSimulator_Orders(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	d := decimal.RequireFromString
+	ctx := context.Background()
+	sim := NewSimulator()
+	defer sim.Close()
+	sim.SetFee(0)
+	sim.SetBook("BTC-USD", d("99"), d("100"))
+	sim.SetBalance("USD", d("1000"))
+	ex := NewExchange(sim.Client())
+
+	//a market buy fills at the ask
+	order, err := ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("500")})
+	assert.Nil(err)
+	assert.Equal(exchange.StatusFilled, order.Status)
+	assert.Equal(d("5"), order.FilledSize)
+	avg, err := order.AveragePrice()
+	assert.Nil(err)
+	assert.Equal(d("100"), avg)
+	fills, err := ex.ListFills(ctx, order.ID)
+	assert.Nil(err)
+	assert.Len(fills, 1)
+
+	//a limit sell rests and holds its size until the book crosses it
+	order, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideSell, Type: exchange.TypeLimit, Size: d("2"), Price: d("110")})
+	assert.Nil(err)
+	assert.Equal(exchange.StatusPending, order.Status)
+	balances, err := ex.GetBalances(ctx)
+	assert.Nil(err)
+	assert.Equal([]exchange.Balance{{Currency: "BTC", Total: d("5"), Available: d("3"), Hold: d("2")}, {Currency: "USD", Total: d("500"), Available: d("500")}}, balances)
+	open, err := ex.ListOpenOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Len(open, 1)
+	sim.SetBook("BTC-USD", d("111"), d("112"))
+	order, err = ex.GetOrder(ctx, order.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.StatusFilled, order.Status)
+	avg, err = order.AveragePrice()
+	assert.Nil(err)
+	assert.Equal(d("110"), avg, "filled at the limit")
+	assert.Equal(d("720"), sim.Balance("USD"))
+
+	//cancelling releases the hold
+	order, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideSell, Type: exchange.TypeLimit, Size: d("3"), Price: d("200")})
+	assert.Nil(err)
+	ids, err := ex.CancelAllOrders(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal([]string{order.ID}, ids)
+	order, err = ex.GetOrder(ctx, order.ID)
+	assert.Nil(err)
+	assert.Equal(exchange.StatusCancelled, order.Status)
+
+	//short funds and unknown products are rejected like Coinbase Pro does
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("5000")})
+	assert.Contains(err.Error(), "Insufficient funds")
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "ETH-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("5")})
+	assert.Contains(err.Error(), "Product not found")
+	assert.Len(sim.Orders(), 3)
+
+	//products that are not fully tradable reject market orders
+	sim.AddProduct(Product{ID: "ETH-USD", BaseCurrency: "ETH", QuoteCurrency: "USD", Status: "online", LimitOnly: true})
+	sim.SetBook("ETH-USD", d("9"), d("10"))
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "ETH-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("5")})
+	assert.Contains(err.Error(), "limit only")
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "ETH-USD", Side: exchange.SideBuy, Type: exchange.TypeLimit, Size: d("1"), Price: d("8")})
+	assert.Nil(err, "limit orders are still taken")
+	sim.AddProduct(Product{ID: "ETH-USD", BaseCurrency: "ETH", QuoteCurrency: "USD", Status: "online", TradingDisabled: true})
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "ETH-USD", Side: exchange.SideBuy, Type: exchange.TypeLimit, Size: d("1"), Price: d("8")})
+	assert.Contains(err.Error(), "Trading is disabled")
+	sim.AddProduct(Product{ID: "ETH-USD", BaseCurrency: "ETH", QuoteCurrency: "USD", Status: "online", MinMarketFunds: "10"})
+	_, err = ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "ETH-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: d("5")})
+	assert.Contains(err.Error(), "Minimum funds is 10")
+}
+
+func TestSimulator_FillBehavior(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		fill       FillBehavior
+		polls      int
+		wantStatus []exchange.OrderStatus //at creation, then after each GetOrder
+	}{
+		{name: "Happy Path. Fill immediately", fill: FillImmediately, wantStatus: []exchange.OrderStatus{exchange.StatusFilled, exchange.StatusFilled}},
+		{name: "Happy Path. Fill after 2 polls", fill: FillAfterPolls, polls: 2, wantStatus: []exchange.OrderStatus{exchange.StatusPending, exchange.StatusPending, exchange.StatusPending, exchange.StatusFilled}},
+		{name: "Happy Path. Never fill", fill: FillNever, wantStatus: []exchange.OrderStatus{exchange.StatusPending, exchange.StatusPending, exchange.StatusPending}},
+		{name: "Sad Path. Cancel", fill: FillCancel, wantStatus: []exchange.OrderStatus{exchange.StatusCancelled, exchange.StatusCancelled}},
+		{name: "Sad Path. Reject", fill: FillReject, wantStatus: []exchange.OrderStatus{exchange.StatusRejected, exchange.StatusRejected}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			sim := NewSimulator()
+			defer sim.Close()
+			sim.SetBook("BTC-USD", decimal.RequireFromString("99"), decimal.RequireFromString("100"))
+			sim.SetBalance("USD", decimal.RequireFromString("100"))
+			sim.SetFill(tt.fill, tt.polls)
+			ex := NewExchange(sim.Client())
+
+			order, err := ex.PlaceOrder(ctx, exchange.OrderRequest{Product: "BTC-USD", Side: exchange.SideBuy, Type: exchange.TypeMarket, Funds: decimal.RequireFromString("100")})
+			assert.Nil(t, err)
+			got := []exchange.OrderStatus{order.Status}
+			for range tt.wantStatus[1:] {
+				order, err = ex.GetOrder(ctx, order.ID)
+				assert.Nil(t, err)
+				got = append(got, order.Status)
+			}
+			assert.Equal(t, tt.wantStatus, got)
+		})
+	}
+}
+
+func TestSimulator_Fail(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	sim := NewSimulator()
+	defer sim.Close()
+	sim.Fail(http.MethodGet, "/products/BTC-USD/book", http.StatusTooManyRequests, "rate limit exceeded")
+	ex := NewExchange(sim.Client())
+
+	_, err := ex.GetBook(ctx, "BTC-USD")
+	var httpErr *HTTPError
+	assert.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusTooManyRequests, httpErr.StatusCode)
+	_, err = ex.GetBook(ctx, "BTC-USD")
+	assert.Nil(err, "a failure is used once")
+}
@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+//minRedactLen shorter values would redact ordinary words and numbers
+const minRedactLen = 4
+
+//Redactor replaces known secrets, as is and url escaped, with [REDACTED]
+type Redactor struct {
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	values   []string
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+//Add registers secrets, empty and very short values are ignored
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		if len(s) < minRedactLen {
+			continue
+		}
+		r.values = append(r.values, s, url.QueryEscape(s), url.PathEscape(s))
+	}
+	var pairs []string
+	for _, v := range r.values {
+		pairs = append(pairs, v, redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+//Writer redacts every write to w, the log package writes a whole line at a time
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactWriter{r: r, w: w}
+}
+
+type redactWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
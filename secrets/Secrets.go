@@ -0,0 +1,345 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+//Sources, set secrets.source in the .conf file to one of these
+const (
+	SourceConfig        = "config"
+	SourceEnv           = "env"
+	SourceFile          = "file"
+	SourceEncryptedFile = "encrypted_file"
+	SourceVault         = "vault"
+)
+
+var ErrNotFound = errors.New("secret not found")
+
+//Source looks up a secret by name, e.g. api_secret
+type Source interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+//Config reads secrets from the .conf file itself, the old plaintext behavior
+type Config struct{}
+
+func (Config) Get(ctx context.Context, name string) (string, error) {
+	if !viper.IsSet(name) {
+		return "", fmt.Errorf("%s %w", name, ErrNotFound)
+	}
+	return viper.GetString(name), nil
+}
+
+//Env reads Prefix + name in upper case, api_secret is CRYPTOBOT_API_SECRET with the default prefix
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Get(ctx context.Context, name string) (string, error) {
+	key := strings.ToUpper(e.Prefix + name)
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("%s %w", key, ErrNotFound)
+	}
+	return v, nil
+}
+
+//File reads a json object of names to secrets, the file must not be readable by group or others
+//	{"api_key": "...", "api_passphrase": "...", "api_secret": "..."}
+type File struct {
+	Path string
+}
+
+func (f File) Get(ctx context.Context, name string) (string, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", err
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return "", fmt.Errorf("%s has mode %04o, it must only be readable by the owner, chmod 600 it", f.Path, perm)
+	}
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	return lookup(f.Path, b, name)
+}
+
+func lookup(from string, b []byte, name string) (string, error) {
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", fmt.Errorf("failed to read secrets from %s %s", from, err.Error())
+	}
+	v, ok := m[name]
+	if !ok {
+		return "", fmt.Errorf("%s in %s %w", name, from, ErrNotFound)
+	}
+	return v, nil
+}
+
+//sealed is the format of an encrypted file, AES-256-GCM with a key derived by scrypt
+type sealed struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+//Seal encrypts a json object of secrets, like the File format, for EncryptedFile
+func Seal(plain []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is required")
+	}
+	var m map[string]string
+	if err := json.Unmarshal(plain, &m); err != nil {
+		return nil, fmt.Errorf("secrets must be a json object of strings %s", err.Error())
+	}
+	s := sealed{Salt: make([]byte, 16)}
+	if _, err := io.ReadFull(rand.Reader, s.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, s.Nonce); err != nil {
+		return nil, err
+	}
+	s.Data = gcm.Seal(nil, s.Nonce, plain, nil)
+	return json.Marshal(s)
+}
+
+func unseal(b []byte, passphrase string) ([]byte, error) {
+	var s sealed
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("bad nonce")
+	}
+	plain, err := gcm.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupt file")
+	}
+	return plain, nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//EncryptedFile is a File sealed with Seal, Passphrase is asked for once on the first Get
+type EncryptedFile struct {
+	Path       string
+	Passphrase func() (string, error)
+	once       sync.Once
+	plain      []byte
+	err        error
+}
+
+func (f *EncryptedFile) Get(ctx context.Context, name string) (string, error) {
+	f.once.Do(func() {
+		var b []byte
+		b, f.err = os.ReadFile(f.Path)
+		if f.err != nil {
+			return
+		}
+		var passphrase string
+		passphrase, f.err = f.Passphrase()
+		if f.err != nil {
+			return
+		}
+		f.plain, f.err = unseal(b, passphrase)
+		if f.err != nil {
+			f.err = fmt.Errorf("failed to decrypt %s %s", f.Path, f.err.Error())
+		}
+	})
+	if f.err != nil {
+		return "", f.err
+	}
+	return lookup(f.Path, f.plain, name)
+}
+
+//Vault reads a HashiCorp Vault compatible kv secret, both kv v1 and v2 responses are understood.
+//Path is the api path after /v1/, e.g. secret/data/cryptobot for kv v2.
+type Vault struct {
+	Addr   string
+	Token  string
+	Path   string
+	Client *http.Client
+	mu     sync.Mutex
+	data   map[string]string
+}
+
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.data == nil {
+		data, err := v.read(ctx)
+		if err != nil {
+			return "", err
+		}
+		v.data = data
+	}
+	s, ok := v.data[name]
+	if !ok {
+		return "", fmt.Errorf("%s in vault %s %w", name, v.Path, ErrNotFound)
+	}
+	return s, nil
+}
+
+func (v *Vault) read(ctx context.Context) (map[string]string, error) {
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(v.Addr, "/")+"/v1/"+strings.TrimPrefix(v.Path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault %s failed with status %d", v.Path, resp.StatusCode)
+	}
+
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	// kv v2 nests the secret in data.data next to data.metadata
+	var v2 struct {
+		Data     map[string]string `json:"data"`
+		Metadata json.RawMessage   `json:"metadata"`
+	}
+	if err := json.Unmarshal(body.Data, &v2); err == nil && v2.Metadata != nil {
+		return v2.Data, nil
+	}
+	var v1 map[string]string
+	if err := json.Unmarshal(body.Data, &v1); err != nil {
+		return nil, fmt.Errorf("vault %s is not a map of strings %s", v.Path, err.Error())
+	}
+	return v1, nil
+}
+
+//Settings is read from the secrets section of the .conf file
+//	secrets:
+//	  source: env              # config, env, file, encrypted_file or vault
+//	  env_prefix: CRYPTOBOT_
+//	  file: .secrets/secrets.json
+//	  encrypted_file: .secrets/secrets.enc
+//	  vault:
+//	    addr: https://vault.example.com:8200 # VAULT_ADDR when empty
+//	    token: ""                            # VAULT_TOKEN when empty
+//	    path: secret/data/cryptobot
+type Settings struct {
+	Source        string
+	EnvPrefix     string
+	File          string
+	EncryptedFile string
+	VaultAddr     string
+	VaultToken    string
+	VaultPath     string
+}
+
+func SettingsFromViper() Settings {
+	viper.SetDefault("secrets.source", SourceConfig)
+	viper.SetDefault("secrets.env_prefix", "CRYPTOBOT_")
+	s := Settings{
+		Source:        viper.GetString("secrets.source"),
+		EnvPrefix:     viper.GetString("secrets.env_prefix"),
+		File:          viper.GetString("secrets.file"),
+		EncryptedFile: viper.GetString("secrets.encrypted_file"),
+		VaultAddr:     viper.GetString("secrets.vault.addr"),
+		VaultToken:    viper.GetString("secrets.vault.token"),
+		VaultPath:     viper.GetString("secrets.vault.path"),
+	}
+	if s.VaultAddr == "" {
+		s.VaultAddr = os.Getenv("VAULT_ADDR")
+	}
+	if s.VaultToken == "" {
+		s.VaultToken = os.Getenv("VAULT_TOKEN")
+	}
+	return s
+}
+
+//New returns the configured Source, passphrase is only called for an encrypted file
+func New(s Settings, passphrase func() (string, error)) (Source, error) {
+	switch s.Source {
+	case SourceConfig:
+		return Config{}, nil
+	case SourceEnv:
+		return Env{Prefix: s.EnvPrefix}, nil
+	case SourceFile:
+		if s.File == "" {
+			return nil, fmt.Errorf("secrets.file is required")
+		}
+		return File{Path: s.File}, nil
+	case SourceEncryptedFile:
+		if s.EncryptedFile == "" {
+			return nil, fmt.Errorf("secrets.encrypted_file is required")
+		}
+		return &EncryptedFile{Path: s.EncryptedFile, Passphrase: passphrase}, nil
+	case SourceVault:
+		if s.VaultAddr == "" || s.VaultToken == "" || s.VaultPath == "" {
+			return nil, fmt.Errorf("secrets.vault addr, token and path are required")
+		}
+		return &Vault{Addr: s.VaultAddr, Token: s.VaultToken, Path: s.VaultPath}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets.source %q", s.Source)
+}
+
+//PassphraseEnv is read before the passphrase is asked for on the terminal
+const PassphraseEnv = "CRYPTOBOT_SECRETS_PASSPHRASE"
+
+//Passphrase reads PassphraseEnv, or asks on the terminal without echo
+func Passphrase() (string, error) {
+	if p, ok := os.LookupEnv(PassphraseEnv); ok {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%s is not set and stdin is not a terminal", PassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "secrets passphrase: ")
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const plain = `{"api_key": "key-1234", "api_passphrase": "pass-1234", "api_secret": "c2VjcmV0LTEyMzQ="}`
+
+func TestEnv_Get(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("CRYPTOBOT_API_SECRET", "c2VjcmV0LTEyMzQ=")
+	defer os.Unsetenv("CRYPTOBOT_API_SECRET")
+	src := Env{Prefix: "CRYPTOBOT_"}
+
+	got, err := src.Get(context.Background(), "api_secret")
+	assert.Nil(err)
+	assert.Equal("c2VjcmV0LTEyMzQ=", got)
+
+	_, err = src.Get(context.Background(), "api_key")
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestFile_Get(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    os.FileMode
+		secret  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Happy Path. Owner only.", mode: 0600, secret: "api_key", want: "key-1234"},
+		{name: "Happy Path. Owner read only.", mode: 0400, secret: "api_passphrase", want: "pass-1234"},
+		{name: "Sad Path. Group readable.", mode: 0640, secret: "api_key", wantErr: true},
+		{name: "Sad Path. World readable.", mode: 0644, secret: "api_key", wantErr: true},
+		{name: "Sad Path. Missing secret.", mode: 0600, secret: "api_token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secrets.json")
+			if err := os.WriteFile(path, []byte(plain), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			got, err := File{Path: path}.Get(context.Background(), tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("File.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEncryptedFile_Get(t *testing.T) {
+	assert := assert.New(t)
+	b, err := Seal([]byte(plain), "correct horse")
+	assert.Nil(err)
+	assert.NotContains(string(b), "key-1234")
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	assert.Nil(os.WriteFile(path, b, 0600))
+
+	asked := 0
+	src := &EncryptedFile{Path: path, Passphrase: func() (string, error) {
+		asked++
+		return "correct horse", nil
+	}}
+	got, err := src.Get(context.Background(), "api_key")
+	assert.Nil(err)
+	assert.Equal("key-1234", got)
+	got, err = src.Get(context.Background(), "api_secret")
+	assert.Nil(err)
+	assert.Equal("c2VjcmV0LTEyMzQ=", got)
+	assert.Equal(1, asked, "the passphrase is asked for once")
+
+	wrong := &EncryptedFile{Path: path, Passphrase: func() (string, error) { return "battery staple", nil }}
+	_, err = wrong.Get(context.Background(), "api_key")
+	assert.NotNil(err)
+
+	_, err = Seal([]byte("not json"), "correct horse")
+	assert.NotNil(err)
+	_, err = Seal([]byte(plain), "")
+	assert.NotNil(err)
+}
+
+func TestVault_Get(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "Happy Path. KV v2.", body: `{"data": {"data": {"api_key": "key-1234"}, "metadata": {"version": 1}}}`, status: http.StatusOK, want: "key-1234"},
+		{name: "Happy Path. KV v1.", body: `{"data": {"api_key": "key-1234"}}`, status: http.StatusOK, want: "key-1234"},
+		{name: "Sad Path. Missing secret.", body: `{"data": {"api_secret": "c2VjcmV0"}}`, status: http.StatusOK, wantErr: true},
+		{name: "Sad Path. Permission denied.", body: `{"errors": ["permission denied"]}`, status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/secret/data/cryptobot" || r.Header.Get("X-Vault-Token") != "s.token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			src := &Vault{Addr: server.URL, Token: "s.token", Path: "secret/data/cryptobot"}
+			got, err := src.Get(context.Background(), "api_key")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Vault.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	for _, s := range []Settings{
+		{Source: SourceConfig},
+		{Source: SourceEnv, EnvPrefix: "CRYPTOBOT_"},
+		{Source: SourceFile, File: "secrets.json"},
+		{Source: SourceEncryptedFile, EncryptedFile: "secrets.enc"},
+		{Source: SourceVault, VaultAddr: "http://localhost:8200", VaultToken: "s.token", VaultPath: "secret/data/cryptobot"},
+	} {
+		_, err := New(s, nil)
+		assert.Nil(err, s.Source)
+	}
+	for _, s := range []Settings{
+		{Source: "keychain"},
+		{Source: SourceFile},
+		{Source: SourceEncryptedFile},
+		{Source: SourceVault, VaultAddr: "http://localhost:8200"},
+	} {
+		_, err := New(s, nil)
+		assert.NotNil(err, s.Source)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRedactor()
+	assert.Equal("nothing to hide", r.Redact("nothing to hide"))
+
+	r.Add("c2VjcmV0/1234+", "", "abc")
+	assert.Equal("secret=[REDACTED]", r.Redact("secret=c2VjcmV0/1234+"))
+	assert.Equal("GET /path?s=[REDACTED]", r.Redact("GET /path?s=c2VjcmV0%2F1234%2B"), "query escaped")
+	assert.Equal("abc is too short to redact", r.Redact("abc is too short to redact"))
+
+	// the log package, and loghttp through it, goes through the writer
+	var out bytes.Buffer
+	logger := log.New(r.Writer(&out), "", 0)
+	logger.Printf("[0x1] GET https://api.telegram.org/botc2VjcmV0/1234+/getUpdates")
+	assert.Equal("[0x1] GET https://api.telegram.org/bot[REDACTED]/getUpdates\n", out.String())
+}
@@ -0,0 +1,199 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+)
+
+//maxCandles is the most candles GetCandles returns for one request
+const maxCandles = 300
+
+//HistoricCandles pages through GetCandles between start and end, the candles are returned oldest first
+func HistoricCandles(ctx context.Context, client exchange.Exchange, product string, start, end time.Time, granularity time.Duration) ([]exchange.Candle, error) {
+	if granularity <= 0 {
+		return nil, fmt.Errorf("granularity must be positive, got %s", granularity)
+	}
+	seen := map[int64]bool{}
+	var candles []exchange.Candle
+	for from := start; from.Before(end); from = from.Add(granularity * maxCandles) {
+		to := from.Add(granularity * maxCandles)
+		if to.After(end) {
+			to = end
+		}
+		rates, err := client.GetCandles(ctx, product, from, to, granularity)
+		if err != nil {
+			return nil, classify("GetHistoricRates", err)
+		}
+		for _, r := range rates {
+			if !seen[r.Time.Unix()] {
+				seen[r.Time.Unix()] = true
+				candles = append(candles, r)
+			}
+		}
+	}
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Time.Before(candles[j].Time) })
+	return candles, nil
+}
+
+//Backtest replays candles through State the way the main loop does.
+//Open is the open of the candle Window before the current one, close is the candle close.
+type Backtest struct {
+	Fee      float64       //taker fee as a fraction of the order value
+	Window   time.Duration //the main loop compares against the price 2 hours ago
+	Schedule TradingSchedule
+}
+
+func NewBacktest() Backtest {
+	return Backtest{
+		Fee:    0.005,
+		Window: time.Hour * 2,
+	}
+}
+
+//BacktestResult Value is the funds plus the position at the last close
+type BacktestResult struct {
+	Start      time.Time
+	End        time.Time
+	Candles    int
+	Buys       int
+	Sells      int
+	Funds      decimal.Decimal
+	Position   decimal.Decimal
+	LastPrice  decimal.Decimal
+	Realized   decimal.Decimal
+	Unrealized decimal.Decimal
+	Value      decimal.Decimal
+	Return     float64 //fraction of the starting funds
+}
+
+func (r BacktestResult) String() string {
+	return fmt.Sprintf("%s to %s, %d candles\nbuys %d sells %d\nfunds %.2f position %s at %.2f\nrealized %.2f unrealized %.2f\nvalue %.2f return %.2f%%",
+		r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), r.Candles, r.Buys, r.Sells,
+		r.Funds, r.Position, r.LastPrice, r.Realized, r.Unrealized, r.Value, r.Return*100)
+}
+
+//Run trades funds with st over candles, which must be oldest first. Orders fill at the candle close less Fee.
+//The state runs on a SimulatedClock set to the time of every candle, cooldowns play out as they would live.
+func (b Backtest) Run(ctx context.Context, product string, funds decimal.Decimal, st Strategy, candles []exchange.Candle) (BacktestResult, error) {
+	if len(candles) == 0 {
+		return BacktestResult{}, fmt.Errorf("no candles to backtest")
+	}
+	clock := NewSimulatedClock(candles[0].Time)
+	stSvc := NewStateSvc(nil)
+	stSvc.Clock = clock
+	s := stSvc.NewState(product, funds)
+	s.Strategy = st
+	s.SetSchedule(b.Schedule)
+	sim := &backtestSvc{fee: decimal.NewFromFloat(b.Fee)}
+
+	first := 0
+	for _, c := range candles {
+		if ctx.Err() != nil {
+			return BacktestResult{}, ctx.Err()
+		}
+		for candles[first].Time.Before(c.Time.Add(-b.Window)) {
+			first++
+		}
+		clock.Set(c.Time)
+		close := decimal.NewFromFloat(c.Close)
+		sim.price = close
+		if c.Time.Sub(candles[0].Time) < b.Window {
+			continue
+		}
+		if !s.Buy(ctx, sim, decimal.NewFromFloat(candles[first].Open), close) {
+			s.Lock(close)
+			s.Sell(ctx, sim, close)
+		}
+		if err := s.OrderErr(); err != nil {
+			return BacktestResult{}, err
+		}
+	}
+
+	last := candles[len(candles)-1]
+	lastPrice := decimal.NewFromFloat(last.Close)
+	realized, unrealized, err := s.PnL(lastPrice)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	value, err := s.Value(lastPrice)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	r := BacktestResult{
+		Start:      candles[0].Time,
+		End:        last.Time,
+		Candles:    len(candles),
+		Buys:       sim.buys,
+		Sells:      sim.sells,
+		Funds:      s.AvailableFunds,
+		Position:   s.NumberOwn,
+		LastPrice:  lastPrice,
+		Realized:   realized,
+		Unrealized: unrealized,
+		Value:      value,
+	}
+	if !funds.IsZero() {
+		r.Return = value.Float64()/funds.Float64() - 1
+	}
+	return r, nil
+}
+
+//backtestSvc fills every order at once at the current candle close, sale funds are rounded down to the cent
+type backtestSvc struct {
+	fee   decimal.Decimal
+	price decimal.Decimal
+	buys  int
+	sells int
+}
+
+//lessFee is amount less the fee fraction of it
+func lessFee(amount, fee decimal.Decimal) (decimal.Decimal, error) {
+	kept, err := decimal.One.Sub(fee)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.Mul(kept)
+}
+
+func (b *backtestSvc) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	value, err := numberOwn.Mul(b.price)
+	var funds decimal.Decimal
+	if err == nil {
+		funds, err = lessFee(value, b.fee)
+	}
+	if err != nil {
+		return numberOwn, decimal.Zero, err
+	}
+	b.sells++
+	return decimal.Zero, funds.RoundDown(defaultQuoteIncrement), nil
+}
+
+func (b *backtestSvc) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	spent, err := lessFee(availablefunds, b.fee)
+	var nOwn decimal.Decimal
+	if err == nil {
+		nOwn, err = spent.Div(b.price)
+	}
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	b.buys++
+	return nOwn, b.price, nil
+}
+
+func (b *backtestSvc) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("backtest orders are never pending")
+}
+
+func (b *backtestSvc) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	return b.price, nil
+}
+
+func (b *backtestSvc) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	return b.price, b.price, nil
+}
@@ -0,0 +1,110 @@
+package svc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/proclient"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+//hourly candles with one price per hour, open and close are the same
+func candles(start time.Time, prices ...float64) []exchange.Candle {
+	var rates []exchange.Candle
+	for i, p := range prices {
+		rates = append(rates, exchange.Candle{Time: start.Add(time.Hour * time.Duration(i)), Open: p, Close: p, Low: p, High: p})
+	}
+	return rates
+}
+
+func TestBacktest_Run(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		prices    []float64
+		wantBuys  int
+		wantSells int
+		wantFunds decimal.Decimal
+		wantOwn   bool
+	}{
+		{
+			name:      "Happy Path. Flat market never trades.",
+			prices:    []float64{100, 100, 100, 100, 100},
+			wantFunds: d("100"),
+		},
+		{
+			name:      "Happy Path. Buy on growth and take the 8% profit.",
+			prices:    []float64{100, 100, 104, 110, 115},
+			wantBuys:  1,
+			wantSells: 1,
+			wantFunds: d("109.47"), //100 * 0.995 / 104 * 115 * 0.995, floored to cents
+		},
+		{
+			name:      "Happy Path. Buy on growth and hold.",
+			prices:    []float64{100, 100, 104, 105, 105},
+			wantBuys:  1,
+			wantFunds: decimal.Zero,
+			wantOwn:   true,
+		},
+		{
+			name:      "Happy Path. Cooldown after a sale.",
+			prices:    []float64{100, 100, 104, 115, 120, 118, 118},
+			wantBuys:  1,
+			wantSells: 1,
+			wantFunds: d("109.47"),
+		},
+		{
+			name:      "Happy Path. Buy again after the cooldown.",
+			prices:    []float64{100, 100, 104, 115, 115, 116, 120},
+			wantBuys:  2,
+			wantSells: 1,
+			wantOwn:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewBacktest().Run(context.Background(), "BTC-USD", d("100"), DefaultStrategy(), candles(start, tt.prices...))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantBuys, r.Buys)
+			assert.Equal(t, tt.wantSells, r.Sells)
+			assert.Equal(t, tt.wantOwn, !r.Position.IsZero())
+			if !tt.wantOwn {
+				assert.Equal(t, tt.wantFunds, r.Funds)
+			}
+			assert.Equal(t, len(tt.prices), r.Candles)
+			position, err := r.Position.Mul(r.LastPrice)
+			assert.Nil(t, err)
+			value, err := r.Funds.Add(position)
+			assert.Nil(t, err)
+			assert.Equal(t, value, r.Value)
+		})
+	}
+}
+
+func TestBacktest_RunNoCandles(t *testing.T) {
+	_, err := NewBacktest().Run(context.Background(), "BTC-USD", d("100"), DefaultStrategy(), nil)
+	assert.NotNil(t, err)
+}
+
+func TestHistoricCandles(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	c := proclient.NewMockClient()
+	//newest first, like the exchange
+	c.HistoricRates = []coinbasepro.HistoricRate{{Time: start.Add(time.Hour), Close: 2}, {Time: start, Close: 1}}
+
+	ex := proclient.NewExchange(c)
+	rates, err := HistoricCandles(context.Background(), ex, "BTC-USD", start, start.Add(time.Hour*400), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, []exchange.Candle{{Time: start, Close: 1}, {Time: start.Add(time.Hour), Close: 2}}, rates, "sorted and without the candles repeated across pages")
+
+	_, err = HistoricCandles(context.Background(), ex, "BTC-USD", start, start.Add(time.Hour), 0)
+	assert.NotNil(t, err)
+
+	c.Err = &proclient.HTTPError{StatusCode: 429, Err: coinbasepro.Error{Message: "slow down"}}
+	_, err = HistoricCandles(context.Background(), ex, "BTC-USD", start, start.Add(time.Hour), time.Hour)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
@@ -0,0 +1,146 @@
+package svc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//Clock is the time source of State and TimeSvc, tests and backtests replace the wall clock
+type Clock interface {
+	Now() time.Time
+	//Sleep waits for d on the clock, it returns ctx.Err() early when ctx is done
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+//RealClock is the wall clock
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//FakeClock only moves on Advance or Set, a Sleep returns once the clock has moved past its deadline
+type FakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []sleeper
+}
+
+type sleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if d <= 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	s := sleeper{until: c.now.Add(d), done: make(chan struct{})}
+	c.sleepers = append(c.sleepers, s)
+	c.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i := range c.sleepers {
+			if c.sleepers[i].done == s.done {
+				c.sleepers = append(c.sleepers[:i], c.sleepers[i+1:]...)
+				break
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+//Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+//Set moves the clock to t and wakes the sleepers whose deadline has passed
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	var waiting []sleeper
+	for _, s := range c.sleepers {
+		if s.until.After(t) {
+			waiting = append(waiting, s)
+			continue
+		}
+		close(s.done)
+	}
+	c.sleepers = waiting
+}
+
+//Sleepers is the number of goroutines blocked in Sleep, tests wait on it before moving the clock
+func (c *FakeClock) Sleepers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sleepers)
+}
+
+//SimulatedClock jumps forward on Sleep instead of waiting, a backtest runs as fast as it can read candles
+type SimulatedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewSimulatedClock(now time.Time) *SimulatedClock {
+	return &SimulatedClock{now: now}
+}
+
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *SimulatedClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	return nil
+}
+
+//Set moves the clock to t, e.g. to the time of the next candle
+func (c *SimulatedClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
@@ -0,0 +1,82 @@
+package svc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//waitSleepers waits for n goroutines to block in Sleep on c
+func waitSleepers(t *testing.T, c *FakeClock, n int) {
+	deadline := time.Now().Add(time.Second * 5)
+	for c.Sleepers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d sleepers, want %d", c.Sleepers(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFakeClock_Sleep(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Sleep(context.Background(), time.Minute)
+	}()
+
+	waitSleepers(t, c, 1)
+	c.Advance(time.Second * 59)
+	select {
+	case <-done:
+		t.Fatal("Sleep() returned before the deadline")
+	case <-time.After(time.Millisecond * 20):
+	}
+	c.Advance(time.Second)
+	assert.Nil(t, <-done)
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+	assert.Equal(t, 0, c.Sleepers())
+
+	assert.Nil(t, c.Sleep(context.Background(), 0), "no wait returns at once")
+}
+
+func TestFakeClock_SleepCancelled(t *testing.T) {
+	c := NewFakeClock(time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Sleep(ctx, time.Minute)
+	}()
+
+	waitSleepers(t, c, 1)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, 0, c.Sleepers())
+	assert.Equal(t, context.Canceled, c.Sleep(ctx, time.Minute))
+}
+
+func TestSimulatedClock_Sleep(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulatedClock(start)
+	assert.Nil(t, c.Sleep(context.Background(), time.Hour))
+	assert.Equal(t, start.Add(time.Hour), c.Now())
+
+	c.Set(start)
+	assert.Equal(t, start, c.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, c.Sleep(ctx, time.Hour))
+	assert.Equal(t, start, c.Now(), "a cancelled sleep does not move the clock")
+}
+
+func TestRealClock_Sleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	begin := time.Now()
+	assert.Equal(t, context.Canceled, RealClock{}.Sleep(ctx, time.Minute))
+	assert.Less(t, int64(time.Since(begin)), int64(time.Second))
+	assert.Nil(t, RealClock{}.Sleep(context.Background(), time.Millisecond))
+}
@@ -1,190 +1,350 @@
 package svc
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
-	"strconv"
+	"log"
+	"strings"
 	"time"
 
-	"github.com/JasonWBrown/proclient"
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/metrics"
 	"github.com/cenkalti/backoff/v4"
-	"github.com/preichenberger/go-coinbasepro/v2"
 )
 
+//CoinbaseSvcInterface every call takes a context.
+//Cancelling it or passing its deadline stops the order backoff and the http request in flight.
 type CoinbaseSvcInterface interface {
-	Sell(product string, numberOwn, sellPrice float64) (float64, float64, error)
-	Buy(product string, buyPrice, availablefunds float64) (float64, float64, error)
-	GetLastPrice(product string) (float64, error)
-	GetMarketConditions(product string, start, end time.Time) (float64, float64, error)
+	Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error)
+	Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error)
+	ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error)
+	GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error)
+	GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error)
 }
 
+//PendingOrderError is returned when an order was created but could not be confirmed before the context ended.
+//The order may still fill, ResumeOrder picks it back up.
+type PendingOrderError struct {
+	OrderID string
+	Err     error
+}
+
+func (e *PendingOrderError) Error() string {
+	return fmt.Sprintf("order %s is pending: %s", e.OrderID, e.Err.Error())
+}
+
+func (e *PendingOrderError) Unwrap() error {
+	return e.Err
+}
+
+//CoinbaseSvc places and confirms the orders of State on any exchange.Exchange, Coinbase Pro is the first.
+//Timeout is the longest an order backoff runs when ctx has no earlier deadline.
+//Orders are checked and rounded against the Products catalog before they are sent,
+//without a catalog the products are listed on every order.
 type CoinbaseSvc struct {
-	Client  proclient.ProClientInterface
-	Timeout time.Duration
+	Client   exchange.Exchange
+	Timeout  time.Duration
+	Products *ProductSvc
 }
 
-func NewCoinbaseSvc(client proclient.ProClientInterface, d time.Duration) CoinbaseSvc {
+func NewCoinbaseSvc(client exchange.Exchange, d time.Duration) CoinbaseSvc {
 	return CoinbaseSvc{
-		Client:  client,
-		Timeout: d,
+		Client:   client,
+		Timeout:  d,
+		Products: NewProductSvc(client, productTTL),
+	}
+}
+
+//defaultQuoteIncrement funds are rounded to the cent when the product does not say its QuoteIncrement
+var defaultQuoteIncrement = decimal.New(1, -2)
+
+//product is the catalog entry of id
+func (svc CoinbaseSvc) product(ctx context.Context, id string) (exchange.Product, error) {
+	products := svc.Products
+	if products == nil {
+		products = NewProductSvc(svc.Client, 0)
+	}
+	p, err := products.Product(ctx, id)
+	if err != nil {
+		return p, err
 	}
+	if !p.QuoteIncrement.IsPositive() {
+		p.QuoteIncrement = defaultQuoteIncrement
+	}
+	return p, nil
 }
 
-//Sell
-//NumberOwn, AvailableUSDFunds, error := Sell()
-func (svc CoinbaseSvc) Sell(product string, numberOwn, sellPrice float64) (float64, float64, error) {
-	fmt.Println("Entering Sell")
-	savedOrder, err := svc.Client.CreateOrder(&coinbasepro.Order{
-		ProductID: product,
-		Side:      "sell",
-		Size:      fmt.Sprintf("%f", numberOwn),
-		Type:      "market",
+//sellProduct a sell is never held back by the catalog.
+//When the product can't be looked up the size is sent as it is and the quote currency is read from the product id.
+func (svc CoinbaseSvc) sellProduct(ctx context.Context, id string) exchange.Product {
+	p, err := svc.product(ctx, id)
+	if err != nil {
+		log.Printf("Failed to get product %s, selling with default increments\n", err.Error())
+		return exchange.Product{ID: id, QuoteCurrency: quoteOf(id), QuoteIncrement: defaultQuoteIncrement}
+	}
+	return p
+}
+
+//quoteOf USD of BTC-USD
+func quoteOf(id string) string {
+	if i := strings.LastIndex(id, "-"); i >= 0 {
+		return id[i+1:]
+	}
+	return ""
+}
+
+//checkOrder rounds req to the increments of p.
+//Orders p would reject are not sent, the error is ErrNotTradable when p takes no orders of the type and ErrOrderRejected otherwise.
+func checkOrder(p exchange.Product, req exchange.OrderRequest) (exchange.OrderRequest, error) {
+	checked, err := p.CheckOrder(req)
+	if err == nil {
+		return checked, nil
+	}
+	if !p.Accepts(req.Type) {
+		return req, &ExchangeError{Kind: ErrNotTradable, Op: "CreateOrder", Err: err}
+	}
+	return req, &ExchangeError{Kind: ErrOrderRejected, Op: "CreateOrder", Err: err}
+}
+
+//placeOrder sends req, a rejection for the product drops the catalog so the next order sees its new status
+func (svc CoinbaseSvc) placeOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Order, error) {
+	order, err := svc.Client.PlaceOrder(ctx, req)
+	err = classify("CreateOrder", err)
+	if errors.Is(err, ErrProductUnavailable) && svc.Products != nil {
+		svc.Products.Invalidate()
+	}
+	return order, err
+}
+
+//Sell the size is rounded down to the product BaseIncrement, what is left under it stays on the account.
+//The funds are what the sale brought in, the executed value less fees in the quote currency of the product.
+//NumberOwn, AvailableFunds, error := Sell()
+func (svc CoinbaseSvc) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	log.Println("Entering Sell")
+	p := svc.sellProduct(ctx, product)
+	req, err := checkOrder(p, exchange.OrderRequest{
+		Product: product,
+		Side:    exchange.SideSell,
+		Size:    numberOwn,
+		Type:    exchange.TypeMarket,
 	})
+	var savedOrder exchange.Order
+	if err == nil {
+		savedOrder, err = svc.placeOrder(ctx, req)
+	}
 	if err != nil {
-		fmt.Printf("Failed to sell %s\n", err.Error())
-		return numberOwn, 0.0, err
+		log.Printf("Failed to sell %s\n", err.Error())
+		metrics.OrdersFailed.WithLabelValues(product, "sell").Inc()
+		return numberOwn, decimal.Zero, err
 	}
+	metrics.OrdersPlaced.WithLabelValues(product, "sell").Inc()
+
+	return svc.confirmSell(ctx, p, savedOrder.ID)
+}
 
+//confirmSell the funds are the executed value less fees rounded down to the QuoteIncrement of p.
+//When the exchange does not report the executed value they are the balance of the quote currency, other funds on the account included.
+//An order that is done without being filled is an ErrOrderRejected, nothing was sold.
+func (svc CoinbaseSvc) confirmSell(ctx context.Context, p exchange.Product, id string) (decimal.Decimal, decimal.Decimal, error) {
+	product := p.ID
 	b := backoff.NewExponentialBackOff()
 	b.MaxElapsedTime = time.Duration(svc.Timeout)
-	funds := 0.0
-	err = backoff.Retry(func() error {
-		fmt.Printf("Entering backoff.\n")
-		so, err := svc.Client.GetOrder(savedOrder.ID)
+	funds := decimal.Zero
+	err := backoff.Retry(func() error {
+		log.Printf("Entering backoff.\n")
+		so, err := svc.Client.GetOrder(ctx, id)
 		if err != nil {
-			fmt.Printf("Failed to get order %s\n", err.Error())
-			return err
+			log.Printf("Failed to get order %s\n", err.Error())
+			return outOfRange(classify("GetOrder", err))
 		}
 
-		fmt.Printf("Saved order sell %+v\n", so)
-		if so.Status != "done" || so.DoneReason != "filled" {
-			errMessage := fmt.Sprintf("failed to get expected order Status got %s, want %s and DoneReason got %s, want %s", so.Status, "done", so.DoneReason, "filled")
-			fmt.Println(errMessage)
-			return fmt.Errorf(errMessage)
-		}
-
-		//FIXME I don't like how this is nested in the backoff, we may get stuck in a state where we can no longer sell
-		accounts, err := svc.Client.GetAccounts()
-		if err != nil {
-			fmt.Printf("Failed to get accounts %s\n", err.Error())
+		log.Printf("Saved order sell %+v\n", so)
+		if err := checkFilled(so); err != nil {
 			return err
 		}
-
-		var account coinbasepro.Account
-		// these might be in order might not have to iterate every single time
-		for _, a := range accounts {
-			if a.Currency == "USD" {
-				account = a
-				break
+		if so.ExecutedValue.IsPositive() {
+			net, err := so.ExecutedValue.Sub(so.Fees)
+			if err != nil {
+				return outOfRange(&ExchangeError{Kind: ErrMalformedResponse, Op: "GetOrder", Err: fmt.Errorf("sell order %s executed value %s less fees %s: %w", so.ID, so.ExecutedValue, so.Fees, err)})
 			}
+			funds = net.RoundDown(p.QuoteIncrement)
+			return nil
 		}
 
-		funds, err = strconv.ParseFloat(account.Balance, 64)
+		//FIXME I don't like how this is nested in the backoff, we may get stuck in a state where we can no longer sell
+		log.Printf("Sell order %s has no executed value, the funds are the %s balance\n", so.ID, p.QuoteCurrency)
+		balances, err := svc.Client.GetBalances(ctx)
 		if err != nil {
-			fmt.Printf("Failed to parse float for account balance %s\n", err.Error())
-			return err
+			log.Printf("Failed to get accounts %s\n", err.Error())
+			return outOfRange(classify("GetAccounts", err))
 		}
-		funds = math.Floor(funds*100) / 100
+
+		funds = exchange.FindBalance(balances, p.QuoteCurrency).Total.RoundDown(p.QuoteIncrement)
 		return nil
-	}, b)
+	}, backoff.WithContext(b, ctx))
 
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Sell order %s not confirmed before the context ended %s\n", id, err.Error())
+		return decimal.Zero, decimal.Zero, &PendingOrderError{OrderID: id, Err: err}
+	}
 	if err != nil {
-		fmt.Printf("Failed to sell %s\n", err.Error())
-		return 0.0, 0.0, nil
+		log.Printf("Failed to sell %s\n", err.Error())
+		metrics.OrdersFailed.WithLabelValues(product, "sell").Inc()
+		return decimal.Zero, decimal.Zero, err
 	}
-	fmt.Println("Sale complete")
-	return 0.0, funds, nil
+	metrics.OrdersFilled.WithLabelValues(product, "sell").Inc()
+	log.Println("Sale complete")
+	return decimal.Zero, funds, nil
 }
 
 //TODO this should return the buy price if not in error then state will change to 0.0 availableFunds
+//The funds are rounded down to the product QuoteIncrement.
+//Nothing is sent when the product is not listed or not fully tradable, the error is ErrProductUnavailable or ErrNotTradable.
 //NumberOwn, BuyPrice returned
-func (svc CoinbaseSvc) Buy(product string, buyPrice, availablefunds float64) (float64, float64, error) {
-	fmt.Println("Entering buy")
-	savedOrder, err := svc.Client.CreateOrder(&coinbasepro.Order{
-		ProductID: product,
-		Side:      "buy",
-		Funds:     fmt.Sprintf("%.2f", availablefunds),
-		Type:      "market",
+func (svc CoinbaseSvc) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	log.Println("Entering buy")
+	p, err := svc.product(ctx, product)
+	if err != nil {
+		log.Printf("Failed to get product %s\n", err.Error())
+		return decimal.Zero, decimal.Zero, err
+	}
+	if !p.Tradable() {
+		log.Printf("Product %s is not tradable, not buying\n", product)
+		return decimal.Zero, decimal.Zero, &ExchangeError{Kind: ErrNotTradable, Op: "CreateOrder", Err: fmt.Errorf("product %s is not tradable", product)}
+	}
+	req, err := checkOrder(p, exchange.OrderRequest{
+		Product: product,
+		Side:    exchange.SideBuy,
+		Funds:   availablefunds,
+		Type:    exchange.TypeMarket,
 	})
+	var savedOrder exchange.Order
+	if err == nil {
+		savedOrder, err = svc.placeOrder(ctx, req)
+	}
 	if err != nil {
-		fmt.Printf("Failed to CreateOrder %s\n", err.Error())
-		return 0.0, 0.0, err
+		log.Printf("Failed to CreateOrder %s\n", err.Error())
+		metrics.OrdersFailed.WithLabelValues(product, "buy").Inc()
+		return decimal.Zero, decimal.Zero, err
 	}
+	metrics.OrdersPlaced.WithLabelValues(product, "buy").Inc()
 
+	return svc.confirmBuy(ctx, product, savedOrder.ID)
+}
+
+func (svc CoinbaseSvc) confirmBuy(ctx context.Context, product, id string) (decimal.Decimal, decimal.Decimal, error) {
 	b := backoff.NewExponentialBackOff()
 	b.MaxElapsedTime = time.Duration(svc.Timeout)
-	totalPurchased := 0.0
-	buyPrice = 0.0
-	err = backoff.Retry(func() error {
-		fmt.Println("Entering backoff.")
-		so, err := svc.Client.GetOrder(savedOrder.ID)
+	totalPurchased := decimal.Zero
+	buyPrice := decimal.Zero
+	err := backoff.Retry(func() error {
+		log.Println("Entering backoff.")
+		so, err := svc.Client.GetOrder(ctx, id)
 		if err != nil {
-			fmt.Printf("Failed to GetOrder %s\n", err.Error())
-			return err
-		}
-		fmt.Printf("Saved order buy %+v\n", so)
-		if so.Status != "done" || so.DoneReason != "filled" {
-			errMessage := fmt.Sprintf("failed to get expected order Status got %s, want %s and DoneReason got %s, want %s", so.Status, "done", so.DoneReason, "filled")
-			fmt.Println(errMessage)
-			return fmt.Errorf(errMessage)
+			log.Printf("Failed to GetOrder %s\n", err.Error())
+			return outOfRange(classify("GetOrder", err))
 		}
-		totalPurchased, err = strconv.ParseFloat(so.FilledSize, 64)
-		if err != nil {
-			fmt.Printf("Failed to parse float for filled order %s\n", err.Error())
+		log.Printf("Saved order buy %+v\n", so)
+		if err := checkFilled(so); err != nil {
 			return err
 		}
-
-		exValue, err := strconv.ParseFloat(so.ExecutedValue, 64)
+		if !so.FilledSize.IsPositive() || !so.ExecutedValue.IsPositive() {
+			log.Printf("Filled order %s has no filled size or executed value\n", so.ID)
+			return &ExchangeError{Kind: ErrMalformedResponse, Op: "GetOrder", Err: fmt.Errorf("filled order %s has filled size %s and executed value %s", so.ID, so.FilledSize, so.ExecutedValue)}
+		}
+		price, err := so.ExecutedValue.Div(so.FilledSize)
 		if err != nil {
-			fmt.Printf("Failed to parse float for filled order %s\n", err.Error())
-			return err
+			log.Printf("Filled order %s has an average price out of range\n", so.ID)
+			return outOfRange(&ExchangeError{Kind: ErrMalformedResponse, Op: "GetOrder", Err: fmt.Errorf("filled order %s executed value %s for filled size %s: %w", so.ID, so.ExecutedValue, so.FilledSize, err)})
 		}
-
-		buyPrice = exValue / totalPurchased
+		totalPurchased = so.FilledSize
+		buyPrice = price
 		return nil
-	}, b)
+	}, backoff.WithContext(b, ctx))
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Buy order %s not confirmed before the context ended %s\n", id, err.Error())
+		return decimal.Zero, decimal.Zero, &PendingOrderError{OrderID: id, Err: err}
+	}
 	if err != nil {
-		return 0.0, 0.0, err
+		metrics.OrdersFailed.WithLabelValues(product, "buy").Inc()
+		return decimal.Zero, decimal.Zero, err
 	}
-	fmt.Println("Buy Complete")
+	metrics.OrdersFilled.WithLabelValues(product, "buy").Inc()
+	log.Println("Buy Complete")
 	return totalPurchased, buyPrice, nil //available funds may be pennies
 }
 
-func (svc CoinbaseSvc) GetLastPrice(product string) (float64, error) {
-	book, err := svc.Client.GetBook(product, 1)
-	if err != nil {
-		fmt.Println(err.Error())
-		return -100.0, err
+//checkFilled an order that is done without being filled will never fill, it is not retried
+func checkFilled(so exchange.Order) error {
+	if so.Status == exchange.StatusFilled {
+		return nil
+	}
+	errMessage := fmt.Sprintf("failed to get expected order status got %s (%s), want %s", so.Status, so.Reason, exchange.StatusFilled)
+	log.Println(errMessage)
+	if so.Status.Done() {
+		return backoff.Permanent(&ExchangeError{Kind: ErrOrderRejected, Op: "GetOrder", Err: fmt.Errorf(errMessage)})
 	}
+	return fmt.Errorf(errMessage)
+}
 
-	if len(book.Bids) == 0 {
-		return -100.0, fmt.Errorf("failed to get books expecting array to be populated")
+//outOfRange an amount past the range of a decimal will not fit on the next try either, it is not retried
+func outOfRange(err error) error {
+	if errors.Is(err, decimal.ErrOverflow) {
+		return backoff.Permanent(err)
 	}
+	return err
+}
 
-	lastPrice, err := strconv.ParseFloat(book.Bids[0].Price, 64)
+//ResumeOrder waits on an order created before a restart.
+//It returns the same values as Buy or Sell for the order side.
+func (svc CoinbaseSvc) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	log.Printf("Resuming %s order %s\n", side, id)
+	switch side {
+	case exchange.SideBuy:
+		return svc.confirmBuy(ctx, product, id)
+	case exchange.SideSell:
+		return svc.confirmSell(ctx, svc.sellProduct(ctx, product), id)
+	}
+	return decimal.Zero, decimal.Zero, fmt.Errorf("unknown order side %s", side)
+}
+
+//invalidPrice is the last price when the book can't be read
+var invalidPrice = decimal.NewFromInt(-100)
+
+func (svc CoinbaseSvc) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	book, err := svc.Client.GetBook(ctx, product)
+	err = classify("GetBook", err)
 	if err != nil {
-		fmt.Println(err.Error())
-		return -100.0, err
+		log.Println(err.Error())
+		return invalidPrice, err
+	}
+
+	//an empty book is a glitch, not a delisting, it must not pause buying
+	if len(book.Bids) == 0 {
+		return invalidPrice, &ExchangeError{Kind: ErrMalformedResponse, Op: "GetBook", Err: fmt.Errorf("failed to get books expecting array to be populated")}
 	}
-	return lastPrice, err
+	return book.Bids[0].Price, nil
 }
 
-func (svc CoinbaseSvc) GetMarketConditions(product string, start, end time.Time) (float64, float64, error) {
-	rates, err := svc.Client.GetHistoricRates(product, coinbasepro.GetHistoricRatesParams{
-		Start:       start,
-		End:         end,
-		Granularity: 0,
-	})
+//GetMarketConditions the open is the open of the first candle after start, the close is the last price
+func (svc CoinbaseSvc) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	rates, err := svc.Client.GetCandles(ctx, product, start, end, 0)
+	err = classify("GetHistoricRates", err)
 	if err != nil {
-		fmt.Printf("failed to get historic rate %s\n", err.Error())
-		return 0.0, 0.0, err
+		log.Printf("failed to get historic rate %s\n", err.Error())
+		return decimal.Zero, decimal.Zero, err
+	}
+	if len(rates) == 0 {
+		return decimal.Zero, decimal.Zero, &ExchangeError{Kind: ErrMalformedResponse, Op: "GetHistoricRates", Err: fmt.Errorf("failed to get historic rates expecting array to be populated")}
 	}
 
-	lastPrice, err := svc.GetLastPrice(product)
+	lastPrice, err := svc.GetLastPrice(ctx, product)
 	if err != nil {
-		fmt.Printf("failed to get last price %s\n", err.Error())
-		return 0.0, 0.0, err
+		log.Printf("failed to get last price %s\n", err.Error())
+		return decimal.Zero, decimal.Zero, err
 	}
 
-	return rates[len(rates)-1].Open, lastPrice, nil
+	return decimal.NewFromFloat(rates[0].Open), lastPrice, nil
 }
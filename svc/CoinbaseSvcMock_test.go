@@ -1,35 +1,45 @@
 package svc
 
 import (
+	"context"
 	"fmt"
 	"time"
+
+	"github.com/JasonWBrown/decimal"
 )
 
 type CoinbaseSvcMock struct {
-	Err               error
-	TotalPurchased    float64
-	AvailableUSDFunds float64
-	BuyPrice          float64
+	Err            error
+	TotalPurchased decimal.Decimal
+	AvailableFunds decimal.Decimal
+	BuyPrice       decimal.Decimal
 }
 
 func NewCoinbaseSvcMock() CoinbaseSvcMock {
 	return CoinbaseSvcMock{}
 }
 
-func (svc CoinbaseSvcMock) Sell(product string, numberOwn, sellPrice float64) (float64, float64, error) {
-	funds := numberOwn * sellPrice
-	fmt.Printf("sold %f at price %f, funds available %f\n", numberOwn, sellPrice, funds)
-	return 0.0, funds, nil
+func (svc CoinbaseSvcMock) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	funds, err := numberOwn.Mul(sellPrice)
+	fmt.Printf("sold %s at price %s, funds available %s\n", numberOwn, sellPrice, funds)
+	return decimal.Zero, funds, err
 }
 
-func (svc CoinbaseSvcMock) Buy(product string, buyPrice, availablefunds float64) (float64, float64, error) {
+func (svc CoinbaseSvcMock) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
 	return svc.TotalPurchased, svc.BuyPrice, svc.Err
 }
 
-func (svc CoinbaseSvcMock) GetLastPrice(product string) (float64, error) {
-	return 1.01, nil
+func (svc CoinbaseSvcMock) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	if side == "buy" {
+		return svc.TotalPurchased, svc.BuyPrice, svc.Err
+	}
+	return decimal.Zero, svc.AvailableFunds, svc.Err
+}
+
+func (svc CoinbaseSvcMock) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	return decimal.RequireFromString("1.01"), nil
 }
 
-func (svc CoinbaseSvcMock) GetMarketConditions(product string, start, end time.Time) (float64, float64, error) {
-	return 2.02, 4.04, nil
+func (svc CoinbaseSvcMock) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.RequireFromString("2.02"), decimal.RequireFromString("4.04"), nil
 }
@@ -1,12 +1,20 @@
 package svc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/JasonWBrown/cassette"
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+	"github.com/JasonWBrown/metrics"
 	"github.com/JasonWBrown/proclient"
 	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCoinbaseSvc_GetMarketConditions(t *testing.T) {
@@ -24,8 +32,8 @@ func TestCoinbaseSvc_GetMarketConditions(t *testing.T) {
 		name      string
 		fields    fields
 		args      args
-		wantStart float64
-		wantEnd   float64
+		wantStart decimal.Decimal
+		wantEnd   decimal.Decimal
 		wantErr   error
 	}{
 		{
@@ -66,8 +74,8 @@ func TestCoinbaseSvc_GetMarketConditions(t *testing.T) {
 				start:   time.Now().Add(time.Hour * -1),
 				end:     time.Now(),
 			},
-			wantStart: 1.3, // len(historicrates)-1.open
-			wantEnd:   4.0,
+			wantStart: d("1.3"), // len(historicrates)-1.open
+			wantEnd:   d("4"),
 			wantErr:   nil,
 		},
 		{
@@ -108,8 +116,8 @@ func TestCoinbaseSvc_GetMarketConditions(t *testing.T) {
 				start:   time.Now().Add(time.Hour * -1),
 				end:     time.Now(),
 			},
-			wantStart: 0.0,
-			wantEnd:   0.0,
+			wantStart: decimal.Zero,
+			wantEnd:   decimal.Zero,
 			wantErr:   fmt.Errorf("Its broke"),
 		},
 	}
@@ -121,10 +129,10 @@ func TestCoinbaseSvc_GetMarketConditions(t *testing.T) {
 			c.Book = tt.fields.book
 
 			svc := CoinbaseSvc{
-				Client:  c,
+				Client:  proclient.NewExchange(c),
 				Timeout: time.Duration(time.Millisecond), // for all tests, no backoff necessary
 			}
-			start, end, err := svc.GetMarketConditions(tt.args.product, tt.args.start, tt.args.end)
+			start, end, err := svc.GetMarketConditions(context.Background(), tt.args.product, tt.args.start, tt.args.end)
 			if start != tt.wantStart {
 				t.Errorf("CoinbaseSvc.GetMarketConditions() got = %v, want %v", start, tt.wantStart)
 			}
@@ -151,7 +159,7 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 		name    string
 		fields  fields
 		args    args
-		want    float64
+		want    decimal.Decimal
 		wantErr error
 	}{
 		{
@@ -178,7 +186,7 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 			args: args{
 				"BTC-USD",
 			},
-			want:    44.0,
+			want:    d("44"),
 			wantErr: nil,
 		},
 		{
@@ -192,7 +200,7 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 			args: args{
 				"BTC-USD",
 			},
-			want:    -100.0,
+			want:    d("-100"),
 			wantErr: fmt.Errorf("failed to get books expecting array to be populated"), //tight coupling between error messages is by design
 		},
 		{
@@ -203,7 +211,7 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 			args: args{
 				"BTC-USD",
 			},
-			want:    -100.0,
+			want:    d("-100"),
 			wantErr: fmt.Errorf("its broke"), //tight coupling between error messages is by design
 		},
 		{
@@ -227,8 +235,8 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 			args: args{
 				"BTC-USD",
 			},
-			want:    -100.0,
-			wantErr: fmt.Errorf(`strconv.ParseFloat: parsing "": invalid syntax`), //tight coupling between error messages is by design
+			want:    d("-100"),
+			wantErr: fmt.Errorf(`decimal: parsing "": invalid syntax`), //tight coupling between error messages is by design
 		},
 	}
 	for _, tt := range tests {
@@ -237,10 +245,10 @@ func TestCoinbaseSvc_GetLastPrice(t *testing.T) {
 			c.Err = tt.fields.WantErr
 			c.Book = tt.fields.book
 			svc := CoinbaseSvc{
-				Client:  c,
+				Client:  proclient.NewExchange(c),
 				Timeout: time.Duration(time.Millisecond), // for all tests, no backoff necessary
 			}
-			got, err := svc.GetLastPrice(tt.args.product)
+			got, err := svc.GetLastPrice(context.Background(), tt.args.product)
 			if err != nil && err.Error() != tt.wantErr.Error() {
 				t.Errorf("CoinbaseSvc.GetLastPrice() err = %v, want %v", err, tt.wantErr)
 			}
@@ -259,15 +267,15 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 	}
 	type args struct {
 		product        string
-		buyPrice       float64
-		availablefunds float64
+		buyPrice       decimal.Decimal
+		availablefunds decimal.Decimal
 	}
 	tests := []struct {
 		name               string
 		fields             fields
 		args               args
-		wantTotalPurchased float64
-		wantBuyPrice       float64
+		wantTotalPurchased decimal.Decimal
+		wantBuyPrice       decimal.Decimal
 		wantErr            error
 	}{
 		{
@@ -284,11 +292,11 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.998,
-				availablefunds: 99.997,
+				buyPrice:       d("99.998"),
+				availablefunds: d("99.997"),
 			},
-			wantTotalPurchased: 2,
-			wantBuyPrice:       500.00,
+			wantTotalPurchased: d("2"),
+			wantBuyPrice:       d("500"),
 			wantErr:            nil,
 		},
 		{
@@ -305,11 +313,11 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.996,
-				availablefunds: 99.997,
+				buyPrice:       d("99.996"),
+				availablefunds: d("99.997"),
 			},
-			wantTotalPurchased: 0.0,
-			wantBuyPrice:       0.0,
+			wantTotalPurchased: decimal.Zero,
+			wantBuyPrice:       decimal.Zero,
 			wantErr:            fmt.Errorf("this is broke"),
 		},
 		{
@@ -326,12 +334,12 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.996,
-				availablefunds: 99.99788,
+				buyPrice:       d("99.996"),
+				availablefunds: d("99.99788"),
 			},
-			wantTotalPurchased: 0.0,
-			wantBuyPrice:       0.0,
-			wantErr:            fmt.Errorf("failed to get expected order Status got not_done, want done and DoneReason got filled, want filled"),
+			wantTotalPurchased: decimal.Zero,
+			wantBuyPrice:       decimal.Zero,
+			wantErr:            fmt.Errorf("failed to get expected order status got pending (not_done filled), want filled"),
 		},
 		{
 			name: "Sad Path.  No error from client. Done reason not filled",
@@ -347,12 +355,12 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.996,
-				availablefunds: 99.99788,
+				buyPrice:       d("99.996"),
+				availablefunds: d("99.99788"),
 			},
-			wantTotalPurchased: 0.0,
-			wantBuyPrice:       0.0,
-			wantErr:            fmt.Errorf("failed to get expected order Status got done, want done and DoneReason got not_filled, want filled"),
+			wantTotalPurchased: decimal.Zero,
+			wantBuyPrice:       decimal.Zero,
+			wantErr:            fmt.Errorf("failed to get expected order status got rejected (done not_filled), want filled"),
 		},
 		{
 			name: "Sad Path.  No fill size returns error",
@@ -367,12 +375,12 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.996,
-				availablefunds: 99.997,
+				buyPrice:       d("99.996"),
+				availablefunds: d("99.997"),
 			},
-			wantTotalPurchased: 0.0,
-			wantBuyPrice:       0.0,
-			wantErr:            fmt.Errorf(`strconv.ParseFloat: parsing "": invalid syntax`),
+			wantTotalPurchased: decimal.Zero,
+			wantBuyPrice:       decimal.Zero,
+			wantErr:            fmt.Errorf("filled order GUID-99 has filled size 0 and executed value 1000"),
 		},
 		{
 			name: "Sad Path.  No ExecutedValue returns error",
@@ -387,12 +395,12 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			},
 			args: args{
 				product:        "SOME-PRODUCT",
-				buyPrice:       99.996,
-				availablefunds: 99.997,
+				buyPrice:       d("99.996"),
+				availablefunds: d("99.997"),
 			},
-			wantTotalPurchased: 0.0,
-			wantBuyPrice:       0.0,
-			wantErr:            fmt.Errorf(`strconv.ParseFloat: parsing "": invalid syntax`),
+			wantTotalPurchased: decimal.Zero,
+			wantBuyPrice:       decimal.Zero,
+			wantErr:            fmt.Errorf("filled order GUID-99 has filled size 1 and executed value 0"),
 		},
 	}
 	for _, tt := range tests {
@@ -400,11 +408,12 @@ func TestCoinbaseSvc_Buy(t *testing.T) {
 			c := proclient.NewMockClient()
 			c.Err = tt.fields.wantErr
 			c.SavedOrder = tt.fields.order
+			c.Products = []proclient.Product{{ID: tt.args.product}}
 			svc := CoinbaseSvc{
-				Client:  c,
+				Client:  proclient.NewExchange(c),
 				Timeout: time.Duration(time.Millisecond), // for all tests, no backoff necessary
 			}
-			totalPurchased, buyPrice, err := svc.Buy(tt.args.product, tt.args.buyPrice, tt.args.buyPrice)
+			totalPurchased, buyPrice, err := svc.Buy(context.Background(), tt.args.product, tt.args.buyPrice, tt.args.buyPrice)
 			if totalPurchased != tt.wantTotalPurchased {
 				t.Errorf("CoinbaseSvc.Buy() totalPurchased = %v, want %v", totalPurchased, tt.wantTotalPurchased)
 			}
@@ -426,15 +435,15 @@ func TestCoinbaseSvc_Sell(t *testing.T) {
 	}
 	type args struct {
 		product   string
-		numberOwn float64
-		sellPrice float64
+		numberOwn decimal.Decimal
+		sellPrice decimal.Decimal
 	}
 	tests := []struct {
 		name               string
 		fields             fields
 		args               args
-		wantNumberOwn      float64
-		wantAvailableFunds float64
+		wantNumberOwn      decimal.Decimal
+		wantAvailableFunds decimal.Decimal
 		wantErr            error
 	}{
 		{
@@ -454,8 +463,9 @@ func TestCoinbaseSvc_Sell(t *testing.T) {
 					},
 				},
 			},
-			wantNumberOwn:      0.0,
-			wantAvailableFunds: 1000.05, //truncated
+			args:               args{product: "BTC-USD", numberOwn: d("99.9999")},
+			wantNumberOwn:      decimal.Zero,
+			wantAvailableFunds: d("1000.05"), //truncated
 			wantErr:            nil,
 		},
 		{
@@ -475,8 +485,9 @@ func TestCoinbaseSvc_Sell(t *testing.T) {
 					},
 				},
 			},
-			wantNumberOwn:      0.0,
-			wantAvailableFunds: 0.0,
+			args:               args{product: "BTC-USD", numberOwn: d("99.9999")},
+			wantNumberOwn:      d("99.9999"), //nothing was sold
+			wantAvailableFunds: decimal.Zero,
 			wantErr:            fmt.Errorf("this is so broke"),
 		},
 	}
@@ -486,11 +497,12 @@ func TestCoinbaseSvc_Sell(t *testing.T) {
 			c.Err = tt.fields.wantErr
 			c.SavedOrder = tt.fields.order
 			c.Accounts = tt.fields.accounts
+			c.Products = []proclient.Product{{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD"}}
 			svc := CoinbaseSvc{
-				Client:  c,
+				Client:  proclient.NewExchange(c),
 				Timeout: time.Duration(time.Millisecond), // for all tests, no backoff necessary
 			}
-			numberOwn, availablefunds, err := svc.Sell(tt.args.product, tt.args.numberOwn, tt.args.sellPrice)
+			numberOwn, availablefunds, err := svc.Sell(context.Background(), tt.args.product, tt.args.numberOwn, tt.args.sellPrice)
 			if numberOwn != tt.wantNumberOwn {
 				t.Errorf("CoinbaseSvc.Sell() numberOwn = %v, want %v", numberOwn, tt.wantNumberOwn)
 			}
@@ -504,3 +516,235 @@ func TestCoinbaseSvc_Sell(t *testing.T) {
 		})
 	}
 }
+
+//TestCoinbaseSvc_SellPendingThenFilled the sell is confirmed once GetOrder goes from pending through an error to filled
+func TestCoinbaseSvc_SellPendingThenFilled(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.Queue("CreateOrder", coinbasepro.Order{ID: "GUID-7", Status: "pending"}, nil).
+		Queue("GetOrder", coinbasepro.Order{ID: "GUID-7", Status: "pending"}, nil).
+		Queue("GetOrder", nil, fmt.Errorf("connection reset")).
+		Queue("GetOrder", coinbasepro.Order{ID: "GUID-7", Status: "done", DoneReason: "filled", FilledSize: "0.5"}, nil).
+		Queue("GetAccounts", []coinbasepro.Account{{Currency: "USD", Balance: "51.2399"}}, nil)
+	svc := NewCoinbaseSvc(proclient.NewExchange(c), time.Second*5)
+
+	numberOwn, funds, err := svc.Sell(context.Background(), "BTC-USD", d("0.5"), d("102.5"))
+	assert.Nil(err)
+	assert.Equal(decimal.Zero, numberOwn)
+	assert.Equal(d("51.23"), funds)
+	c.AssertCalled(t, "CreateOrder", 1)
+	c.AssertCalledWith(t, "CreateOrder", coinbasepro.Order{ProductID: "BTC-USD", Side: "sell", Type: "market", Size: "0.5"})
+	c.AssertCalled(t, "GetOrder", 3)
+}
+
+//TestCoinbaseSvc_SellRejected a sell that is done without being filled sold nothing, the state keeps its position
+func TestCoinbaseSvc_SellRejected(t *testing.T) {
+	for _, reason := range []string{"canceled", "rejected"} {
+		t.Run(reason, func(t *testing.T) {
+			assert := assert.New(t)
+			c := proclient.NewMockClient()
+			c.SavedOrder = coinbasepro.Order{ID: "GUID-8", Status: "done", DoneReason: reason}
+			c.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "1000.00"}}
+			c.Book = coinbasepro.Book{Bids: []coinbasepro.BookEntry{{Price: "110.00"}}}
+			c.Products = []proclient.Product{{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD"}}
+			svc := NewCoinbaseSvc(proclient.NewExchange(c), time.Second*5)
+
+			_, _, err := svc.Sell(context.Background(), "BTC-USD", d("0.5"), d("110"))
+			assert.True(errors.Is(err, ErrOrderRejected), "got %v", err)
+			c.AssertCalled(t, "GetOrder", 1)
+
+			s := NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+			s.NumberOwn, s.BuyPrice = d("0.5"), d("100")
+			assert.False(s.Sell(context.Background(), svc, d("110")))
+			assert.True(errors.Is(s.OrderErr(), ErrOrderRejected))
+			assert.Equal(d("0.5"), s.NumberOwn)
+			assert.Equal(d("100"), s.BuyPrice)
+			assert.Equal(decimal.Zero, s.AvailableFunds)
+			assert.Equal(decimal.Zero, s.RealizedPnL)
+			assert.Empty(s.Trades)
+
+			err = s.ForceSell(context.Background(), svc)
+			assert.True(errors.Is(err, ErrOrderRejected))
+			assert.Equal(d("0.5"), s.NumberOwn)
+		})
+	}
+}
+
+//TestCoinbaseSvc_BuyOverflow a fill the state can't hold is an error, not a panic, and the state is left as it was
+func TestCoinbaseSvc_BuyOverflow(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.SavedOrder = coinbasepro.Order{ID: "GUID-9", Status: "done", DoneReason: "filled", FilledSize: "0.00000001", ExecutedValue: "1000000"}
+	c.Products = []proclient.Product{{ID: "SHIB-USD", BaseCurrency: "SHIB", QuoteCurrency: "USD"}}
+	svc := NewCoinbaseSvc(proclient.NewExchange(c), time.Minute)
+
+	_, _, err := svc.Buy(context.Background(), "SHIB-USD", d("100"), d("1000000"))
+	assert.True(errors.Is(err, ErrMalformedResponse), "got %v", err)
+	assert.True(errors.Is(err, decimal.ErrOverflow))
+	assert.Equal(PolicyPauseBuying, PolicyFor(err))
+	c.AssertCalled(t, "GetOrder", 1)
+
+	s := NewStateSvc(nil).NewState("SHIB-USD", d("1000000"))
+	s.LastSaleTime = time.Now().Add(time.Minute * -121)
+	assert.False(s.Buy(context.Background(), svc, d("96"), d("100")))
+	assert.True(errors.Is(s.OrderErr(), decimal.ErrOverflow))
+	assert.Equal(d("1000000"), s.AvailableFunds)
+	assert.Equal(decimal.Zero, s.NumberOwn)
+	assert.Empty(s.Trades)
+
+	//the order is refused when the size itself is out of range
+	c.SavedOrder.FilledSize = "100000000000"
+	_, _, err = svc.Buy(context.Background(), "SHIB-USD", d("0.00001"), d("1000000"))
+	assert.True(errors.Is(err, decimal.ErrOverflow), "got %v", err)
+}
+
+//productsExchange lists products, everything else goes to the embedded exchange
+type productsExchange struct {
+	exchange.Exchange
+	products []exchange.Product
+}
+
+func (e productsExchange) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	return e.products, nil
+}
+
+//TestCoinbaseSvc_Increments sizes and funds are rounded down to the product increments, not to 6 places and the cent.
+//The funds of a sale are its executed value less fees in the quote currency of the product.
+func TestCoinbaseSvc_Increments(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.SavedOrder = coinbasepro.Order{ID: "GUID-1", Status: "done", DoneReason: "filled", FilledSize: "0.5", ExecutedValue: "0.0351"}
+	c.Accounts = []coinbasepro.Account{{Currency: "USD", Balance: "1000"}, {Currency: "BTC", Balance: "0.07029999"}}
+	svc := NewCoinbaseSvc(productsExchange{
+		Exchange: proclient.NewExchange(c),
+		products: []exchange.Product{
+			{ID: "ETH-BTC", BaseCurrency: "ETH", QuoteCurrency: "BTC", BaseIncrement: d("0.001"), QuoteIncrement: d("0.00001")},
+			{ID: "SOME-PRODUCT"},
+		},
+	}, time.Second*5)
+
+	_, _, err := svc.Buy(context.Background(), "ETH-BTC", d("0.0702"), d("0.07029999"))
+	assert.Nil(err)
+	c.AssertCalledWith(t, "CreateOrder", coinbasepro.Order{ProductID: "ETH-BTC", Side: "buy", Type: "market", Funds: "0.07029"})
+
+	c.SavedOrder.ExecutedValue, c.SavedOrder.FillFees = "0.03515999", "0.00001"
+	_, funds, err := svc.Sell(context.Background(), "ETH-BTC", d("0.50049999"), d("0.0702"))
+	assert.Nil(err)
+	assert.Equal(d("0.03514"), funds, "not the BTC balance")
+	c.AssertCalledWith(t, "CreateOrder", coinbasepro.Order{ProductID: "ETH-BTC", Side: "sell", Type: "market", Size: "0.5"})
+
+	// a product without increments keeps its size and its funds are rounded to the cent
+	_, _, err = svc.Buy(context.Background(), "SOME-PRODUCT", d("1"), d("10.019"))
+	assert.Nil(err)
+	c.AssertCalledWith(t, "CreateOrder", coinbasepro.Order{ProductID: "SOME-PRODUCT", Side: "buy", Type: "market", Funds: "10.01"})
+}
+
+func TestCoinbaseSvc_OrderMetrics(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.SavedOrder = coinbasepro.Order{
+		ID:            "GUID-99",
+		FilledSize:    "2.0",
+		Status:        "done",
+		DoneReason:    "filled",
+		ExecutedValue: "1000.00",
+	}
+	c.Products = []proclient.Product{{ID: "METRICS-USD"}}
+	svc := CoinbaseSvc{
+		Client:  proclient.NewExchange(c),
+		Timeout: time.Duration(time.Millisecond), // for all tests, no backoff necessary
+	}
+
+	svc.Buy(context.Background(), "METRICS-USD", d("1"), d("1"))
+	assert.Equal(1.0, testutil.ToFloat64(metrics.OrdersPlaced.WithLabelValues("METRICS-USD", "buy")))
+	assert.Equal(1.0, testutil.ToFloat64(metrics.OrdersFilled.WithLabelValues("METRICS-USD", "buy")))
+
+	c.Err = fmt.Errorf("its broke")
+	svc.Sell(context.Background(), "METRICS-USD", d("1"), d("1"))
+	assert.Equal(0.0, testutil.ToFloat64(metrics.OrdersPlaced.WithLabelValues("METRICS-USD", "sell")))
+	assert.Equal(1.0, testutil.ToFloat64(metrics.OrdersFailed.WithLabelValues("METRICS-USD", "sell")))
+}
+
+func TestCoinbaseSvc_Cancellation(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.SavedOrder = coinbasepro.Order{
+		ID:         "GUID-99",
+		Status:     "pending",
+		FilledSize: "2.0",
+	}
+	c.Products = []proclient.Product{{ID: "SOME-PRODUCT"}}
+	svc := NewCoinbaseSvc(proclient.NewExchange(c), time.Minute)
+
+	// cancelled before the order is created, nothing is pending
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := svc.Buy(ctx, "SOME-PRODUCT", d("1"), d("1"))
+	var pending *PendingOrderError
+	assert.False(errors.As(err, &pending))
+	assert.True(errors.Is(err, context.Canceled))
+
+	_, _, err = svc.GetMarketConditions(ctx, "SOME-PRODUCT", time.Now().Add(time.Hour*-2), time.Now())
+	assert.True(errors.Is(err, context.Canceled))
+
+	_, err = svc.GetLastPrice(ctx, "SOME-PRODUCT")
+	assert.True(errors.Is(err, context.Canceled))
+
+	// the deadline passes mid backoff, long before svc.Timeout
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	begin := time.Now()
+	_, _, err = svc.Buy(ctx, "SOME-PRODUCT", d("1"), d("1"))
+	assert.True(errors.As(err, &pending))
+	assert.Equal("GUID-99", pending.OrderID)
+	assert.Less(int64(time.Since(begin)), int64(time.Second*5))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, _, err = svc.Sell(ctx, "SOME-PRODUCT", d("1"), d("1"))
+	assert.True(errors.As(err, &pending))
+
+	// once filled the order resumes like a buy
+	c.SavedOrder.Status = "done"
+	c.SavedOrder.DoneReason = "filled"
+	c.SavedOrder.ExecutedValue = "1000.00"
+	numberOwn, buyPrice, err := svc.ResumeOrder(context.Background(), "SOME-PRODUCT", "buy", "GUID-99")
+	assert.Nil(err)
+	assert.Equal(d("2"), numberOwn)
+	assert.Equal(d("500"), buyPrice)
+
+	_, _, err = svc.ResumeOrder(context.Background(), "SOME-PRODUCT", "hold", "GUID-99")
+	assert.NotNil(err)
+}
+
+//TestCoinbaseSvc_ReplaySession replays a recorded buy and sell, both pending for one poll, through the real Coinbase Pro client
+func TestCoinbaseSvc_ReplaySession(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	interactions, err := cassette.Load("testdata/buy_sell_session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := cassette.NewReplayer(interactions)
+	client := coinbasepro.NewClient()
+	client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: "http://replay.invalid", Key: "key", Passphrase: "passphrase", Secret: "c2VjcmV0"})
+	client.HTTPClient.Transport = replayer
+	cbSvc := NewCoinbaseSvc(proclient.NewExchange(proclient.NewClient(client)), time.Second*5)
+
+	price, err := cbSvc.GetLastPrice(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(d("47012.35"), price)
+	numberOwn, buyPrice, err := cbSvc.Buy(ctx, "BTC-USD", price, d("1000"))
+	assert.Nil(err)
+	assert.Equal(d("0.02116517"), numberOwn)
+	assert.Equal(d("47012.37342388"), buyPrice, "the executed value over the filled size")
+
+	price, err = cbSvc.GetLastPrice(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal(d("50790.12"), price)
+	numberOwn, funds, err := cbSvc.Sell(ctx, "BTC-USD", numberOwn, price)
+	assert.Nil(err)
+	assert.Equal(decimal.Zero, numberOwn)
+	assert.Equal(d("1069.6"), funds)
+	assert.Empty(replayer.Unused(), "every recorded request is made again")
+}
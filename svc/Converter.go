@@ -0,0 +1,75 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/JasonWBrown/decimal"
+)
+
+//Converter turns amounts into Currency at the last price of the product that trades the two currencies.
+//EUR is converted to USD through EUR-USD, or through USD-EUR when only that one is listed.
+type Converter struct {
+	Currency string
+	Products *ProductSvc
+	Prices   CoinbaseSvcInterface
+}
+
+func NewConverter(currency string, products *ProductSvc, prices CoinbaseSvcInterface) *Converter {
+	return &Converter{
+		Currency: currency,
+		Products: products,
+		Prices:   prices,
+	}
+}
+
+//Convert amount from the currency from into Currency, an amount already in Currency is returned as it is.
+//An amount that is out of the decimal range in Currency is an error wrapping decimal.ErrOverflow.
+func (c *Converter) Convert(ctx context.Context, amount decimal.Decimal, from string) (decimal.Decimal, error) {
+	if from == c.Currency || amount.IsZero() {
+		return amount, nil
+	}
+	direct, err := c.listed(ctx, from+"-"+c.Currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	product := from + "-" + c.Currency
+	if !direct {
+		inverse, err := c.listed(ctx, c.Currency+"-"+from)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if !inverse {
+			return decimal.Zero, fmt.Errorf("no product converts %s to %s", from, c.Currency)
+		}
+		product = c.Currency + "-" + from
+	}
+
+	price, err := c.Prices.GetLastPrice(ctx, product)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("last price of %s is %s", product, price)
+	}
+	var converted decimal.Decimal
+	if direct {
+		converted, err = amount.Mul(price)
+	} else {
+		converted, err = amount.Div(price)
+	}
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("converting %s %s at %s of %s: %w", amount, from, price, product, err)
+	}
+	return converted, nil
+}
+
+//listed is false when the catalog does not have product, the error is a failure to list the products
+func (c *Converter) listed(ctx context.Context, product string) (bool, error) {
+	_, err := c.Products.Product(ctx, product)
+	if errors.Is(err, ErrProductUnavailable) {
+		return false, nil
+	}
+	return err == nil, err
+}
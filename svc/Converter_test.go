@@ -0,0 +1,49 @@
+package svc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConverter_Convert(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	sim := proclient.NewSimulator()
+	defer sim.Close()
+	sim.SetBook("BTC-USD", d("50000"), d("50000.01"))
+	sim.AddProduct(proclient.Product{ID: "ETH-BTC", BaseCurrency: "ETH", QuoteCurrency: "BTC", Status: "online"})
+	sim.SetBook("ETH-BTC", d("0.05"), d("0.05001"))
+	cbSvc := NewCoinbaseSvc(proclient.NewExchange(sim.Client()), time.Second*5)
+
+	usd := NewConverter("USD", cbSvc.Products, cbSvc)
+	got, err := usd.Convert(ctx, d("0.5"), "BTC")
+	assert.Nil(err)
+	assert.Equal(d("25000"), got, "through BTC-USD")
+	got, err = usd.Convert(ctx, d("100.5"), "USD")
+	assert.Nil(err)
+	assert.Equal(d("100.5"), got, "already in USD")
+	_, err = usd.Convert(ctx, d("1"), "ETH")
+	assert.EqualError(err, "no product converts ETH to USD")
+
+	btc := NewConverter("BTC", cbSvc.Products, cbSvc)
+	got, err = btc.Convert(ctx, d("1000"), "USD")
+	assert.Nil(err)
+	assert.Equal(d("0.02"), got, "through the inverse of BTC-USD")
+	got, err = btc.Convert(ctx, d("2"), "ETH")
+	assert.Nil(err)
+	assert.Equal(d("0.1"), got)
+
+	//an amount out of range in Currency is an error, not a panic
+	sim.SetBook("BTC-USD", d("0.00001"), d("0.00002"))
+	_, err = btc.Convert(ctx, d("1000000"), "USD")
+	assert.ErrorIs(err, decimal.ErrOverflow)
+
+	sim.SetBook("BTC-USD", d("0"), d("0"))
+	_, err = usd.Convert(ctx, d("0.5"), "BTC")
+	assert.NotNil(err, "no price")
+}
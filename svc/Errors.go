@@ -0,0 +1,182 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/exchange"
+)
+
+//Kinds of exchange failures, match them with errors.Is
+var (
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrAuthFailed         = errors.New("authentication failed")
+	ErrProductUnavailable = errors.New("product unavailable")
+	ErrOrderRejected      = errors.New("order rejected")
+	ErrNetwork            = errors.New("network or timeout")
+	ErrMalformedResponse  = errors.New("malformed response")
+	//ErrNotTradable the product catalog says the product takes no market orders right now, nothing was sent
+	ErrNotTradable = errors.New("product not tradable")
+)
+
+//ExchangeError is an error from the exchange with its Kind.
+//Error() is the message of the original error so logs read the same as before.
+type ExchangeError struct {
+	Kind       error
+	Op         string
+	StatusCode int           //0 when there was no http response
+	RetryAfter time.Duration //0 when the exchange did not ask to wait
+	Err        error
+}
+
+func (e *ExchangeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExchangeError) Is(target error) bool {
+	return target == e.Kind
+}
+
+//classify maps an error from the client to an ExchangeError.
+//Errors it does not recognize, and context cancellation, are returned unchanged.
+func classify(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exErr *ExchangeError
+	if errors.As(err, &exErr) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	e := &ExchangeError{Op: op, Err: err}
+	var httpErr *exchange.HTTPError
+	if errors.As(err, &httpErr) {
+		e.StatusCode = httpErr.StatusCode
+		e.RetryAfter = httpErr.RetryAfter
+	}
+	e.Kind = kindOf(op, e.StatusCode, err)
+	if e.Kind == nil {
+		return err
+	}
+	return e
+}
+
+func kindOf(op string, status int, err error) error {
+	msg := strings.ToLower(message(err))
+	switch {
+	case status == http.StatusTooManyRequests || strings.Contains(msg, "rate limit"):
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		strings.Contains(msg, "api key") || strings.Contains(msg, "signature") || strings.Contains(msg, "passphrase"):
+		return ErrAuthFailed
+	case strings.Contains(msg, "insufficient funds"):
+		return ErrInsufficientFunds
+	case strings.Contains(msg, "product not found") || strings.Contains(msg, "trading disabled") ||
+		strings.Contains(msg, "trading is disabled") || strings.Contains(msg, "cancel only") ||
+		strings.Contains(msg, "post only") || strings.Contains(msg, "limit only") ||
+		(status == http.StatusNotFound && isProductOp(op)):
+		return ErrProductUnavailable
+	case status >= http.StatusInternalServerError:
+		return ErrNetwork
+	case status == http.StatusBadRequest && op == "CreateOrder":
+		return ErrOrderRejected
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrNetwork
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &numErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, decimal.ErrOverflow) {
+		return ErrMalformedResponse
+	}
+	return nil
+}
+
+//message is the message of the exchange, without the status code of an HTTPError
+func message(err error) string {
+	var httpErr *exchange.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Message()
+	}
+	return err.Error()
+}
+
+func isProductOp(op string) bool {
+	switch op {
+	case "GetBook", "GetTicker", "GetHistoricRates", "GetStats", "GetProducts":
+		return true
+	}
+	return false
+}
+
+//Policy is what the main loop does about an error
+type Policy int
+
+const (
+	PolicyRetry       Policy = iota //try again on the next tick
+	PolicyBackoff                   //wait before the next tick
+	PolicySkip                      //alert and sit out the next tick
+	PolicyPauseBuying               //stop buying and alert, sells continue
+	PolicyHalt                      //stop the bot and alert
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyBackoff:
+		return "backoff"
+	case PolicySkip:
+		return "skip"
+	case PolicyPauseBuying:
+		return "pause buying"
+	case PolicyHalt:
+		return "halt"
+	}
+	return "retry"
+}
+
+func PolicyFor(err error) Policy {
+	switch {
+	case err == nil:
+		return PolicyRetry
+	case errors.Is(err, ErrAuthFailed):
+		return PolicyHalt
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrProductUnavailable):
+		return PolicyPauseBuying
+	case errors.Is(err, decimal.ErrOverflow): //an order the state can't hold, see the exchange before buying again
+		return PolicyPauseBuying
+	case errors.Is(err, ErrRateLimited):
+		return PolicyBackoff
+	case errors.Is(err, ErrOrderRejected), errors.Is(err, ErrMalformedResponse): //the same request right away gets the same answer
+		return PolicySkip
+	}
+	return PolicyRetry
+}
+
+//RetryAfter is how long the exchange asked to wait, 0 when it did not say
+func RetryAfter(err error) time.Duration {
+	var exErr *ExchangeError
+	if errors.As(err, &exErr) {
+		return exErr.RetryAfter
+	}
+	return 0
+}
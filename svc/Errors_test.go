@@ -0,0 +1,104 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func httpErr(status int, message string) error {
+	return &proclient.HTTPError{StatusCode: status, Err: coinbasepro.Error{Message: message}}
+}
+
+func Test_classify(t *testing.T) {
+	_, numErr := strconv.ParseFloat("", 64)
+	tests := []struct {
+		name       string
+		op         string
+		err        error
+		wantKind   error
+		wantPolicy Policy
+	}{
+		{name: "Rate limited by status.", op: "GetBook", err: httpErr(http.StatusTooManyRequests, "Slow down"), wantKind: ErrRateLimited, wantPolicy: PolicyBackoff},
+		{name: "Bad key.", op: "GetAccounts", err: httpErr(http.StatusUnauthorized, "invalid signature"), wantKind: ErrAuthFailed, wantPolicy: PolicyHalt},
+		{name: "Forbidden.", op: "CreateOrder", err: httpErr(http.StatusForbidden, "Forbidden"), wantKind: ErrAuthFailed, wantPolicy: PolicyHalt},
+		{name: "Insufficient funds.", op: "CreateOrder", err: httpErr(http.StatusBadRequest, "Insufficient funds"), wantKind: ErrInsufficientFunds, wantPolicy: PolicyPauseBuying},
+		{name: "Unknown product.", op: "GetHistoricRates", err: httpErr(http.StatusNotFound, "NotFound"), wantKind: ErrProductUnavailable, wantPolicy: PolicyPauseBuying},
+		{name: "Trading disabled.", op: "CreateOrder", err: httpErr(http.StatusBadRequest, "Trading is disabled for this product"), wantKind: ErrProductUnavailable, wantPolicy: PolicyPauseBuying},
+		{name: "Order rejected.", op: "CreateOrder", err: httpErr(http.StatusBadRequest, "size is too small"), wantKind: ErrOrderRejected, wantPolicy: PolicySkip},
+		{name: "Server error.", op: "GetOrder", err: httpErr(http.StatusBadGateway, "bad gateway"), wantKind: ErrNetwork, wantPolicy: PolicyRetry},
+		{name: "Network error.", op: "GetBook", err: &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, wantKind: ErrNetwork, wantPolicy: PolicyRetry},
+		{name: "Bad json.", op: "GetBook", err: &json.SyntaxError{}, wantKind: ErrMalformedResponse, wantPolicy: PolicySkip},
+		{name: "Bad number.", op: "GetOrder", err: numErr, wantKind: ErrMalformedResponse, wantPolicy: PolicySkip},
+		{name: "Amount out of range.", op: "GetOrder", err: fmt.Errorf("filled size 1e11: %w", decimal.ErrOverflow), wantKind: ErrMalformedResponse, wantPolicy: PolicyPauseBuying},
+		{name: "Message only, no status.", op: "CreateOrder", err: coinbasepro.Error{Message: "Insufficient funds"}, wantKind: ErrInsufficientFunds, wantPolicy: PolicyPauseBuying},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classify(tt.op, tt.err)
+			assert.True(t, errors.Is(err, tt.wantKind), "classify() = %v, want kind %v", err, tt.wantKind)
+			assert.Equal(t, tt.err.Error(), err.Error())
+			assert.Equal(t, tt.wantPolicy, PolicyFor(err))
+		})
+	}
+}
+
+func Test_classifyUnchanged(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(classify("GetBook", nil))
+
+	err := fmt.Errorf("its broke")
+	assert.Equal(err, classify("GetBook", err))
+	assert.Equal(PolicyRetry, PolicyFor(err))
+
+	// cancellation is a shutdown, not an exchange failure
+	assert.Equal(context.Canceled, classify("GetBook", context.Canceled))
+
+	exErr := &ExchangeError{Kind: ErrAuthFailed, Err: err}
+	assert.Equal(exErr, classify("GetBook", exErr))
+}
+
+func Test_RetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	err := classify("GetBook", &proclient.HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second * 3, Err: fmt.Errorf("429")})
+	assert.Equal(time.Second*3, RetryAfter(err))
+	assert.Equal(time.Duration(0), RetryAfter(fmt.Errorf("its broke")))
+}
+
+func TestCoinbaseSvc_TypedErrors(t *testing.T) {
+	assert := assert.New(t)
+	c := proclient.NewMockClient()
+	c.Products = []proclient.Product{{ID: "SOME-PRODUCT"}}
+	svc := NewCoinbaseSvc(proclient.NewExchange(c), time.Minute)
+
+	// a rejected order is not retried until the timeout
+	c.SavedOrder = coinbasepro.Order{ID: "GUID-1", Status: "done", DoneReason: "canceled"}
+	begin := time.Now()
+	_, _, err := svc.Buy(context.Background(), "SOME-PRODUCT", d("1"), d("1"))
+	assert.True(errors.Is(err, ErrOrderRejected))
+	assert.Less(int64(time.Since(begin)), int64(time.Second*5))
+
+	c.Err = httpErr(http.StatusBadRequest, "Insufficient funds")
+	_, _, err = svc.Buy(context.Background(), "SOME-PRODUCT", d("1"), d("1"))
+	assert.True(errors.Is(err, ErrInsufficientFunds))
+
+	c.Err = nil
+	_, _, err = svc.GetMarketConditions(context.Background(), "SOME-PRODUCT", time.Now().Add(time.Hour*-2), time.Now())
+	assert.True(errors.Is(err, ErrMalformedResponse))
+
+	// an empty book skips a tick, buying is not paused
+	_, err = svc.GetLastPrice(context.Background(), "SOME-PRODUCT")
+	assert.True(errors.Is(err, ErrMalformedResponse))
+	assert.Equal(PolicySkip, PolicyFor(err))
+}
@@ -0,0 +1,89 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/JasonWBrown/exchange"
+)
+
+//productTTL is how long NewCoinbaseSvc keeps the product catalog before listing the products again
+const productTTL = time.Minute * 5
+
+//ProductSvc is a cached catalog of the products listed on an exchange.
+//The products are listed again once the catalog is older than TTL, when that fails the old catalog is kept.
+//It is safe for concurrent use.
+type ProductSvc struct {
+	Client exchange.Exchange
+	TTL    time.Duration
+	Clock  Clock //the wall clock when nil
+
+	mu       sync.Mutex
+	products map[string]exchange.Product
+	fetched  time.Time
+}
+
+func NewProductSvc(client exchange.Exchange, ttl time.Duration) *ProductSvc {
+	return &ProductSvc{
+		Client: client,
+		TTL:    ttl,
+		Clock:  RealClock{},
+	}
+}
+
+//Product is the catalog entry of id.
+//The error is an ExchangeError of kind ErrProductUnavailable when id is not listed.
+func (svc *ProductSvc) Product(ctx context.Context, id string) (exchange.Product, error) {
+	products, err := svc.catalog(ctx)
+	if err != nil {
+		return exchange.Product{}, err
+	}
+	p, ok := products[id]
+	if !ok {
+		return exchange.Product{}, &ExchangeError{Kind: ErrProductUnavailable, Op: "GetProducts", Err: fmt.Errorf("product %s is not listed", id)}
+	}
+	return p, nil
+}
+
+//Invalidate drops the catalog, the next Product lists the products again.
+//Call it when the exchange rejects an order the catalog allowed.
+func (svc *ProductSvc) Invalidate() {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.products = nil
+}
+
+func (svc *ProductSvc) catalog(ctx context.Context) (map[string]exchange.Product, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	now := svc.now()
+	if svc.products != nil && now.Sub(svc.fetched) < svc.TTL {
+		return svc.products, nil
+	}
+
+	products, err := svc.Client.GetProducts(ctx)
+	err = classify("GetProducts", err)
+	if err != nil && svc.products != nil {
+		log.Printf("Failed to get products %s, using the catalog from %s\n", err.Error(), svc.fetched.Format(time.RFC3339))
+		return svc.products, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]exchange.Product, len(products))
+	for _, p := range products {
+		catalog[p.ID] = p
+	}
+	svc.products, svc.fetched = catalog, now
+	return catalog, nil
+}
+
+func (svc *ProductSvc) now() time.Time {
+	if svc.Clock == nil {
+		return time.Now()
+	}
+	return svc.Clock.Now()
+}
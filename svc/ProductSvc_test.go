@@ -0,0 +1,86 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/proclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductSvc_Product(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	c := proclient.NewMockClient()
+	c.Products = []proclient.Product{{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", QuoteIncrement: "0.01", Status: "online"}}
+	clock := NewFakeClock(time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC))
+	svc := NewProductSvc(proclient.NewExchange(c), time.Minute)
+	svc.Clock = clock
+
+	p, err := svc.Product(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal("USD", p.QuoteCurrency)
+	assert.Equal(d("0.01"), p.QuoteIncrement)
+	assert.True(p.Tradable())
+
+	_, err = svc.Product(ctx, "ETH-USD")
+	assert.True(errors.Is(err, ErrProductUnavailable), "not listed")
+	c.AssertCalled(t, "GetProducts", 1)
+
+	// the catalog is listed again once it is older than the ttl
+	c.Products[0].LimitOnly = true
+	clock.Advance(time.Minute)
+	p, err = svc.Product(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.False(p.Tradable())
+	c.AssertCalled(t, "GetProducts", 2)
+
+	// the old catalog is kept when it can't be listed again
+	clock.Advance(time.Minute)
+	c.Err = fmt.Errorf("its broke")
+	p, err = svc.Product(ctx, "BTC-USD")
+	assert.Nil(err)
+	assert.Equal("BTC-USD", p.ID)
+	c.AssertCalled(t, "GetProducts", 3)
+
+	// without a catalog the error is returned
+	svc.Invalidate()
+	_, err = svc.Product(ctx, "BTC-USD")
+	assert.Equal("its broke", err.Error())
+}
+
+func TestCoinbaseSvc_ProductCatalog(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	sim := proclient.NewSimulator()
+	defer sim.Close()
+	sim.SetBook("BTC-USD", d("99"), d("100"))
+	sim.SetBalance("USD", d("1000"))
+	sim.AddProduct(proclient.Product{ID: "ETH-USD", BaseCurrency: "ETH", QuoteCurrency: "USD", QuoteIncrement: "0.01", MinMarketFunds: "10", Status: "online"})
+	sim.AddProduct(proclient.Product{ID: "LTC-USD", BaseCurrency: "LTC", QuoteCurrency: "USD", QuoteIncrement: "0.01", Status: "online", LimitOnly: true})
+	svc := NewCoinbaseSvc(proclient.NewExchange(sim.Client()), time.Second*5)
+
+	// buys of products that are not fully tradable, not listed or below the minimum are not sent
+	_, _, err := svc.Buy(ctx, "LTC-USD", d("100"), d("100"))
+	assert.True(errors.Is(err, ErrNotTradable))
+	assert.Equal(PolicyRetry, PolicyFor(err), "buying is not paused, the product may come back")
+	_, _, err = svc.Sell(ctx, "LTC-USD", d("1"), d("100"))
+	assert.True(errors.Is(err, ErrNotTradable))
+	_, _, err = svc.Buy(ctx, "DOGE-USD", d("100"), d("100"))
+	assert.True(errors.Is(err, ErrProductUnavailable))
+	_, _, err = svc.Buy(ctx, "ETH-USD", d("100"), d("9.999"))
+	assert.True(errors.Is(err, ErrOrderRejected))
+	assert.Equal("ETH-USD funds 9.99 are below the minimum 10", err.Error())
+	assert.Empty(sim.Orders())
+
+	// the catalog is dropped when the exchange rejects an order for the product
+	sim.AddProduct(proclient.Product{ID: "BTC-USD", BaseCurrency: "BTC", QuoteCurrency: "USD", QuoteIncrement: "0.01", Status: "online", CancelOnly: true})
+	_, _, err = svc.Buy(ctx, "BTC-USD", d("100"), d("100"))
+	assert.True(errors.Is(err, ErrProductUnavailable), "the cached catalog still says online")
+	_, _, err = svc.Buy(ctx, "BTC-USD", d("100"), d("100"))
+	assert.True(errors.Is(err, ErrNotTradable))
+	assert.Empty(sim.Orders())
+}
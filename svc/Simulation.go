@@ -0,0 +1,242 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+)
+
+//PricePath makes n prices starting at start, every generator keeps prices above 0.
+//Prices are float64 like candles, Run turns every tick into a decimal.
+type PricePath func(r *rand.Rand, start float64, n int) []float64
+
+//RandomWalk moves the price up or down by up to step, a fraction of the price, every tick
+func RandomWalk(step float64) PricePath {
+	return func(r *rand.Rand, start float64, n int) []float64 {
+		return walk(r, start, n, func(p float64) float64 {
+			return p * (1 + step*(2*r.Float64()-1))
+		})
+	}
+}
+
+//GBM is a geometric brownian motion with drift and volatility per tick
+func GBM(drift, volatility float64) PricePath {
+	return func(r *rand.Rand, start float64, n int) []float64 {
+		return walk(r, start, n, func(p float64) float64 {
+			return p * math.Exp(drift-volatility*volatility/2+volatility*r.NormFloat64())
+		})
+	}
+}
+
+//Gaps is a random walk that jumps up or down by gap, a fraction of the price, with probability every tick
+func Gaps(step, gap, probability float64) PricePath {
+	return func(r *rand.Rand, start float64, n int) []float64 {
+		return walk(r, start, n, func(p float64) float64 {
+			p *= 1 + step*(2*r.Float64()-1)
+			if r.Float64() < probability {
+				if r.Intn(2) == 0 {
+					return p * (1 + gap)
+				}
+				return p * (1 - gap)
+			}
+			return p
+		})
+	}
+}
+
+//FlashCrash is a random walk that drops by depth at a random tick and recovers over length ticks
+func FlashCrash(step, depth float64, length int) PricePath {
+	return func(r *rand.Rand, start float64, n int) []float64 {
+		prices := RandomWalk(step)(r, start, n)
+		if n < 2 {
+			return prices
+		}
+		at := 1 + r.Intn(n-1)
+		for i := at; i < n && i < at+length; i++ {
+			recovered := float64(i-at) / float64(length)
+			prices[i] *= 1 - depth*(1-recovered)
+		}
+		return prices
+	}
+}
+
+func walk(r *rand.Rand, start float64, n int, next func(p float64) float64) []float64 {
+	prices := make([]float64, n)
+	p := start
+	for i := range prices {
+		if i > 0 {
+			p = math.Max(next(p), 0.01)
+		}
+		prices[i] = p
+	}
+	return prices
+}
+
+//Simulation drives a State over a price path the way the main loop does and checks invariants after every tick:
+//	funds and position are never negative, and never both held
+//	LockPrice never decreases while locked
+//	no buy during the cooldown after a sale
+//	a position is always sold once the price is below BottomPrice
+type Simulation struct {
+	Strategy Strategy
+	Fee      float64       //taker fee as a fraction of the order value
+	Interval time.Duration //time between prices
+	Window   time.Duration //open is the price Window before the current one
+	FailRate float64       //fraction of orders the fake exchange fails
+}
+
+func NewSimulation() Simulation {
+	return Simulation{
+		Strategy: DefaultStrategy(),
+		Fee:      0.005,
+		Interval: time.Minute * 5,
+		Window:   time.Hour * 2,
+	}
+}
+
+//SimulationResult Violations are the broken invariants, empty when State behaved
+type SimulationResult struct {
+	Ticks      int
+	Buys       int
+	Sells      int
+	Failed     int
+	Value      decimal.Decimal //funds plus the position at the last valid price
+	Violations []string
+}
+
+//Run trades funds over prices, r decides which orders fail. Prices may be anything, invalid ticks must not trade.
+//Prices a decimal can't hold are 0. A path that takes the funds or the position out of the range of a decimal ends with an error
+//wrapping decimal.ErrOverflow.
+func (sim Simulation) Run(ctx context.Context, r *rand.Rand, funds decimal.Decimal, prices []float64) (result SimulationResult, err error) {
+	start := time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	stSvc := NewStateSvc(nil)
+	stSvc.Clock = clock
+	s := stSvc.NewState("BTC-USD", funds)
+	s.Strategy = sim.Strategy
+	ex := &simulationSvc{fee: decimal.NewFromFloat(sim.Fee), failRate: sim.FailRate, r: r}
+	st := s.strategy()
+	window := int(sim.Window / sim.Interval)
+
+	last := decimal.Zero
+	for i, price := range prices {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		now := start.Add(sim.Interval * time.Duration(i))
+		clock.Set(now)
+		close := decimal.NewFromFloat(price)
+		ex.price = close
+		if validPrice(close) {
+			last = close
+		}
+		if i < window {
+			continue
+		}
+		before := struct {
+			NumberOwn, BottomPrice, LockPrice decimal.Decimal
+			LockPriceSet                      bool
+			LastSaleTime                      time.Time
+		}{s.NumberOwn, s.BottomPrice, s.LockPrice, s.LockPriceSet, s.LastSaleTime}
+		buys, sells, failed := ex.buys, ex.sells, ex.failed
+		if !s.Buy(ctx, ex, decimal.NewFromFloat(prices[i-window]), close) {
+			s.Lock(close)
+			s.Sell(ctx, ex, close)
+		}
+		if err := s.OrderErr(); errors.Is(err, decimal.ErrOverflow) {
+			return result, fmt.Errorf("tick %d: %w", i, err)
+		}
+		result.Ticks++
+
+		violation := func(format string, args ...interface{}) {
+			result.Violations = append(result.Violations, fmt.Sprintf("tick %d price %f: ", i, price)+fmt.Sprintf(format, args...))
+		}
+		if s.AvailableFunds.IsNegative() || s.NumberOwn.IsNegative() {
+			violation("negative funds %s or position %s", s.AvailableFunds, s.NumberOwn)
+		}
+		if s.AvailableFunds.IsPositive() && s.NumberOwn.IsPositive() {
+			violation("holding funds %s and position %s", s.AvailableFunds, s.NumberOwn)
+		}
+		if before.LockPriceSet && s.LockPriceSet && s.LockPrice.LessThan(before.LockPrice) {
+			violation("lock price went down from %s to %s", before.LockPrice, s.LockPrice)
+		}
+		if ex.buys > buys && (before.LastSaleTime.IsZero() || now.Sub(before.LastSaleTime) < st.Cooldown) {
+			violation("bought during the cooldown, last sale %s", before.LastSaleTime.Format(time.RFC3339))
+		}
+		if before.NumberOwn.IsPositive() && close.LessThan(before.BottomPrice) && validPrice(close) && ex.sells == sells && ex.failed == failed {
+			violation("held below the bottom price %s", before.BottomPrice)
+		}
+	}
+	result.Buys, result.Sells, result.Failed = ex.buys, ex.sells, ex.failed
+	result.Value, err = s.Value(last)
+	return result, err
+}
+
+//simulationSvc fills every order at once at the current price less the fee, a FailRate of them fail.
+//Sale funds are rounded down to the cent.
+type simulationSvc struct {
+	fee      decimal.Decimal
+	failRate float64
+	r        *rand.Rand
+	price    decimal.Decimal
+	buys     int
+	sells    int
+	failed   int
+}
+
+func (e *simulationSvc) fail() error {
+	if e.failRate > 0 && e.r.Float64() < e.failRate {
+		e.failed++
+		return &ExchangeError{Kind: ErrNetwork, Op: "CreateOrder", Err: fmt.Errorf("simulated failure")}
+	}
+	return nil
+}
+
+func (e *simulationSvc) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if err := e.fail(); err != nil {
+		return numberOwn, decimal.Zero, err
+	}
+	value, err := numberOwn.Mul(e.price)
+	var funds decimal.Decimal
+	if err == nil {
+		funds, err = lessFee(value, e.fee)
+	}
+	if err != nil {
+		return numberOwn, decimal.Zero, err
+	}
+	e.sells++
+	return decimal.Zero, funds.RoundDown(defaultQuoteIncrement), nil
+}
+
+func (e *simulationSvc) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if err := e.fail(); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	spent, err := lessFee(availablefunds, e.fee)
+	var nOwn decimal.Decimal
+	if err == nil {
+		nOwn, err = spent.Div(e.price)
+	}
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	e.buys++
+	return nOwn, e.price, nil
+}
+
+func (e *simulationSvc) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("simulated orders are never pending")
+}
+
+func (e *simulationSvc) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	return e.price, nil
+}
+
+func (e *simulationSvc) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	return e.price, e.price, nil
+}
@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package svc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+//fuzzPrices encodes prices as the fuzz input, 8 bytes a price
+func fuzzPrices(prices ...float64) []byte {
+	data := make([]byte, 8*len(prices))
+	for i, p := range prices {
+		binary.LittleEndian.PutUint64(data[i*8:], math.Float64bits(p))
+	}
+	return data
+}
+
+//FuzzSimulation explores simulatePrices beyond the seeds, go test -fuzz FuzzSimulation ./svc.
+//Fuzzing needs go 1.18, TestSimulation_Seeds runs the same seeds on every go version CI uses.
+func FuzzSimulation(f *testing.F) {
+	for _, s := range simulationSeeds {
+		f.Add(fuzzPrices(s.prices...), s.seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, seed int64) {
+		prices := make([]float64, 0, len(data)/8)
+		for i := 0; i+8 <= len(data); i += 8 {
+			prices = append(prices, math.Float64frombits(binary.LittleEndian.Uint64(data[i:])))
+		}
+		simulatePrices(t, prices, seed)
+	})
+}
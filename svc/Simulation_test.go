@@ -0,0 +1,160 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var pricePaths = map[string]PricePath{
+	"random walk": RandomWalk(0.01),
+	"gbm":         GBM(0.0001, 0.01),
+	"gaps":        Gaps(0.005, 0.15, 0.01),
+	"flash crash": FlashCrash(0.005, 0.5, 24),
+}
+
+func TestPricePath(t *testing.T) {
+	for name, path := range pricePaths {
+		t.Run(name, func(t *testing.T) {
+			prices := path(rand.New(rand.NewSource(1)), 100, 500)
+			assert.Len(t, prices, 500)
+			assert.Equal(t, 100.0, prices[0])
+			for _, p := range prices {
+				assert.True(t, validPrice(decimal.NewFromFloat(p)), "price %f", p)
+			}
+			assert.Equal(t, prices, path(rand.New(rand.NewSource(1)), 100, 500), "the same seed makes the same path")
+		})
+	}
+	assert.Len(t, FlashCrash(0.01, 0.5, 10)(rand.New(rand.NewSource(1)), 100, 1), 1)
+}
+
+func TestSimulation_Invariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		failRate float64
+	}{
+		{name: "Happy Path. Every order fills"},
+		{name: "Sad Path. A fifth of the orders fail", failRate: 0.2},
+	}
+	for _, tt := range tests {
+		for name, path := range pricePaths {
+			t.Run(fmt.Sprintf("%s %s", tt.name, name), func(t *testing.T) {
+				sim := NewSimulation()
+				sim.FailRate = tt.failRate
+				trades := 0
+				for seed := int64(1); seed <= 20; seed++ {
+					r := rand.New(rand.NewSource(seed))
+					result, err := sim.Run(context.Background(), r, d("1000"), path(r, 100, 1000))
+					assert.Nil(t, err)
+					assert.Empty(t, result.Violations, "seed %d", seed)
+					assert.True(t, result.Value.IsPositive(), "seed %d", seed)
+					trades += result.Buys + result.Sells
+				}
+				assert.NotZero(t, trades, "the paths move enough to trade")
+			})
+		}
+	}
+}
+
+func TestSimulation_InvalidPrices(t *testing.T) {
+	for _, bad := range []float64{0, -5, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		t.Run(fmt.Sprintf("Sad Path. %f never trades", bad), func(t *testing.T) {
+			//a position bought at 104, then bad ticks where a sell or a buy would otherwise happen
+			prices := make([]float64, 24)
+			for i := range prices {
+				prices[i] = 100
+			}
+			prices = append(prices, 104, 104, bad, 104, bad)
+			result, err := NewSimulation().Run(context.Background(), rand.New(rand.NewSource(1)), d("1000"), prices)
+			assert.Nil(t, err)
+			assert.Empty(t, result.Violations)
+			assert.Equal(t, 1, result.Buys)
+			assert.Equal(t, 0, result.Sells)
+		})
+	}
+}
+
+//TestSimulation_Overflow a position out of the range of a decimal ends the run with an error, the state never panics
+func TestSimulation_Overflow(t *testing.T) {
+	prices := make([]float64, 24)
+	for i := range prices {
+		prices[i] = 0.00001
+	}
+	prices = append(prices, 0.0000104, 0.0000104)
+	result, err := NewSimulation().Run(context.Background(), rand.New(rand.NewSource(1)), d("1000000"), prices)
+	assert.ErrorIs(t, err, decimal.ErrOverflow, "a $1M buy of a 1e-5 token")
+	assert.Equal(t, 0, result.Buys)
+}
+
+func TestSimulation_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewSimulation().Run(ctx, rand.New(rand.NewSource(1)), d("1000"), []float64{100, 101})
+	assert.Equal(t, context.Canceled, err)
+}
+
+//simulationSeeds are the seed corpus of FuzzSimulation: 0, NaN, negative, infinite and tiny prices around a position
+var simulationSeeds = []struct {
+	prices []float64
+	seed   int64
+}{
+	{prices: []float64{0, 104, 0}, seed: 1},
+	{prices: []float64{math.NaN(), 120, math.NaN(), 90}, seed: 2},
+	{prices: []float64{-1, -2, -3, 104}, seed: 3},
+	{prices: []float64{math.Inf(1), math.Inf(-1), 93.5, 93.6}, seed: 4},
+	{prices: []float64{math.SmallestNonzeroFloat64, math.MaxFloat64, 1e-300}, seed: 5},
+	{prices: []float64{108, 112, 113.2, 110, 80, 80, 100, 104}, seed: 6},
+}
+
+//The prices simulatePrices supports. Over maxFuzzTicks there are at most 3 round trips,
+//funds of 1000 grow at most 100 times a trip and stay far inside the range of a decimal.
+const (
+	maxFuzzTicks = 64
+	minFuzzPrice = 10.0
+	maxFuzzPrice = 1000.0
+)
+
+//fuzzPrice keeps the ticks a decimal reads as invalid, 0, negative, NaN, infinite or out of its range,
+//other prices are limited to minFuzzPrice and maxFuzzPrice
+func fuzzPrice(p float64) float64 {
+	if !validPrice(decimal.NewFromFloat(p)) {
+		return p
+	}
+	return math.Min(math.Max(p, minFuzzPrice), maxFuzzPrice)
+}
+
+//simulatePrices opens a position at 104 then feeds the first maxFuzzTicks prices, every invariant of Simulation must hold.
+//Prices are supported ones, an error, an overflow included, fails the test. TestSimulation_Overflow covers the others.
+func simulatePrices(t *testing.T, input []float64, seed int64) {
+	prices := make([]float64, 24, 24+2+maxFuzzTicks)
+	for i := range prices {
+		prices[i] = 100
+	}
+	prices = append(prices, 104, 104)
+	for i := 0; i < len(input) && i < maxFuzzTicks; i++ {
+		prices = append(prices, fuzzPrice(input[i]))
+	}
+	sim := NewSimulation()
+	sim.FailRate = 0.1
+	result, err := sim.Run(context.Background(), rand.New(rand.NewSource(seed)), d("1000"), prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range result.Violations {
+		t.Error(v)
+	}
+}
+
+//TestSimulation_Seeds the seeds of FuzzSimulation run without fuzzing, CI runs a go version that has none
+func TestSimulation_Seeds(t *testing.T) {
+	for i, s := range simulationSeeds {
+		t.Run(fmt.Sprintf("seed %d", i+1), func(t *testing.T) {
+			simulatePrices(t, s.prices, s.seed)
+		})
+	}
+}
@@ -1,34 +1,147 @@
 package svc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/metrics"
+	"github.com/JasonWBrown/notify"
 )
 
 type StateSvc struct {
 	//postgres client
+	TradeLog io.Writer
+	Dir      string          //state is persisted as one json file per product, empty keeps state in memory
+	Notifier notify.Notifier //hears about state changes and repeated loop errors, it may be nil
+	//ErrorThreshold is the number of loop errors in a row that are notified, 0 never notifies
+	ErrorThreshold int
+	Clock          Clock //time source of the states, the wall clock when nil
+}
+
+//NewStateSvc tradeLog receives the trade audit trail, it may be nil
+func NewStateSvc(tradeLog io.Writer) *StateSvc {
+	return &StateSvc{
+		TradeLog: tradeLog,
+	}
+}
+
+func (svc StateSvc) path(product string) string {
+	return filepath.Join(svc.Dir, product+".json")
+}
+
+//SaveState writes the state to Dir, the caller must hold the state lock
+func (svc StateSvc) SaveState(s *State) error {
+	if svc.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(svc.Dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write then rename so a crash never leaves a half written state
+	tmp := svc.path(s.Product) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, svc.path(s.Product))
 }
 
-func NewStateSvc() *StateSvc {
-	return &StateSvc{}
+//LoadState reads the persisted state for product, when there is none a new state is created with funds
+func (svc StateSvc) LoadState(product string, funds decimal.Decimal) (*State, error) {
+	s := svc.NewState(product, funds)
+	if svc.Dir == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(svc.path(product))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	//state files written before funds were kept in the quote currency name them AvailableUSDFunds
+	var legacy struct {
+		AvailableUSDFunds *decimal.Decimal
+	}
+	if err := json.Unmarshal(b, &legacy); err == nil && legacy.AvailableUSDFunds != nil {
+		s.AvailableFunds = *legacy.AvailableUSDFunds
+	}
+	s.PrintStateChange("loaded")
+	return s, nil
 }
 
+//maxTrades is the number of recent trades kept on State
+const maxTrades = 50
+
+//State amounts are decimals, the ledger of a product adds up to the satoshi and the cent.
+//Prices, funds and P&L are in QuoteCurrency, EUR for BTC-EUR and BTC for ETH-BTC.
 type State struct {
-	Product           string
-	NumberOwn         float64
-	BuyPrice          float64
-	LockPrice         float64
-	BottomPrice       float64
-	LockPriceSet      bool
-	AvailableUSDFunds float64
-	LastSaleTime      time.Time
+	Product        string
+	NumberOwn      decimal.Decimal
+	BuyPrice       decimal.Decimal
+	LockPrice      decimal.Decimal
+	BottomPrice    decimal.Decimal
+	LockPriceSet   bool
+	AvailableFunds decimal.Decimal
+	QuoteCurrency  string
+	LastSaleTime   time.Time
+	Strategy       Strategy
+	Paused         bool      //buying is paused, sells still happen
+	PausedUntil    time.Time //an error pause ends at PausedUntil, zero when buying was paused by hand
+	Trades         []Trade
+	PendingOrders  []PendingOrder
+	LastCheck      time.Time
+	LastError      string
+	RealizedPnL    decimal.Decimal //quote currency made or lost by completed sales
+	orderErr       error           //last Buy or Sell failure, see OrderErr
+	ordering       bool            //an order is with the exchange, no other order is taken
+	errorCount     int             //loop errors in a row
+	errorThreshold int
+	notifier       notify.Notifier
+	clock          Clock
+	schedule       TradingSchedule
+	tradeLog       *log.Logger
+	mu             sync.Mutex
+}
+
+//PendingOrder is an order that was created but not confirmed, see ResolvePending
+type PendingOrder struct {
+	ID      string
+	Side    string
+	Trigger string
+	Size    decimal.Decimal
+	Price   decimal.Decimal
+	Created time.Time
+}
+
+type Trade struct {
+	Time    time.Time
+	Trigger string
+	Side    string
+	Size    decimal.Decimal
+	Price   decimal.Decimal
+	Funds   decimal.Decimal
 }
 
 func (s *State) ResetState() {
-	s.BuyPrice = 0.0
-	s.LockPrice = 0.0
-	s.BottomPrice = 0.0
+	s.BuyPrice = decimal.Zero
+	s.LockPrice = decimal.Zero
+	s.BottomPrice = decimal.Zero
 	s.LockPriceSet = false
 }
 
@@ -36,106 +149,540 @@ func (s *State) SetLastSaleTime(t time.Time) {
 	s.LastSaleTime = t
 }
 
+//SetClock replaces the time source of the state, e.g. with a SimulatedClock for a backtest
+func (s *State) SetClock(c Clock) {
+	s.clock = c
+}
+
+//SetSchedule limits when Buy may open a position
+func (s *State) SetSchedule(ts TradingSchedule) {
+	s.schedule = ts
+}
+
+//BuyAllowed is false with the reason while the trading schedule blocks buys
+func (s *State) BuyAllowed() (bool, string) {
+	return s.schedule.Allows(s.now())
+}
+
+//now is the time on the state clock, states built without NewState use the wall clock
+func (s *State) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+//Guard runs f while holding the state lock.
+//The main loop and the api share State, every read or change must go through Guard.
+//f must not call the exchange, Buy, Sell, ForceSell and ResolvePending hold the lock only around their changes.
+func (s *State) Guard(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f()
+}
+
+func (s *State) strategy() Strategy {
+	if s.Strategy == (Strategy{}) {
+		return DefaultStrategy()
+	}
+	return s.Strategy
+}
+
+//Heartbeat records the outcome of the last loop iteration.
+//The errorThreshold-th error in a row is notified once, the count restarts on success.
+func (s *State) Heartbeat(err error) {
+	s.LastCheck = s.now()
+	s.LastError = ""
+	if err == nil {
+		s.errorCount = 0
+		return
+	}
+	s.LastError = err.Error()
+	s.errorCount++
+	if s.errorCount == s.errorThreshold {
+		s.Notify(notify.KindAPIErrors, fmt.Sprintf("%d errors in a row", s.errorCount), err.Error())
+	}
+}
+
+//Notify sends an event for this product, it is a no-op without a notifier
+func (s *State) Notify(kind, trigger, message string) {
+	if s.notifier == nil {
+		return
+	}
+	err := s.notifier.Notify(context.Background(), notify.Event{
+		Time:    s.now(),
+		Kind:    kind,
+		Product: s.Product,
+		Trigger: trigger,
+		Message: message,
+	})
+	if err != nil {
+		log.Printf("failed to notify %s", err.Error())
+	}
+}
+
+//OrderErr returns the error of the last failed Buy or Sell and clears it.
+//Pending orders are not failures and are not returned.
+func (s *State) OrderErr() error {
+	err := s.orderErr
+	s.orderErr = nil
+	return err
+}
+
 func (s *State) isLastSaleGreater(d time.Duration) bool {
-	return !s.LastSaleTime.Equal(time.Time{}) && time.Now().Add(d*-1).After(s.LastSaleTime)
+	return !s.LastSaleTime.Equal(time.Time{}) && s.now().Add(d*-1).After(s.LastSaleTime)
 }
 
-func (svc StateSvc) NewState(product string, funds float64) *State {
+func (svc StateSvc) NewState(product string, funds decimal.Decimal) *State {
+	var tradeLog *log.Logger
+	if svc.TradeLog != nil {
+		tradeLog = log.New(svc.TradeLog, log.Prefix(), log.LstdFlags|log.LUTC)
+	}
+	clock := svc.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
 	return &State{
-		Product:           product,
-		NumberOwn:         0.0,
-		BuyPrice:          0.0,
-		LockPrice:         0.0,
-		BottomPrice:       0.0,
-		LockPriceSet:      false,
-		AvailableUSDFunds: funds,
-		LastSaleTime:      time.Now().Add(time.Hour * -2),
+		Product:        product,
+		QuoteCurrency:  quoteOf(product),
+		NumberOwn:      decimal.Zero,
+		BuyPrice:       decimal.Zero,
+		LockPrice:      decimal.Zero,
+		BottomPrice:    decimal.Zero,
+		LockPriceSet:   false,
+		AvailableFunds: funds,
+		LastSaleTime:   clock.Now().Add(time.Hour * -2),
+		Strategy:       DefaultStrategy(),
+		errorThreshold: svc.ErrorThreshold,
+		notifier:       svc.Notifier,
+		clock:          clock,
+		tradeLog:       tradeLog,
 	}
 }
 
+//String keeps the recent trades and lock out of the state change logs
+func (s *State) String() string {
+	return fmt.Sprintf("&{Product:%s NumberOwn:%s BuyPrice:%s LockPrice:%s BottomPrice:%s LockPriceSet:%t AvailableFunds:%s LastSaleTime:%s Paused:%t}",
+		s.Product, s.NumberOwn, s.BuyPrice, s.LockPrice, s.BottomPrice, s.LockPriceSet, s.AvailableFunds, s.LastSaleTime.Format(time.RFC3339), s.Paused)
+}
+
+//PrintStateChange logs the state and notifies every trigger but the loop heartbeat
 func (s *State) PrintStateChange(trigger string) {
-	fmt.Printf("%s %s state, %+v\n", time.Now().Format(time.RFC822), trigger, s)
+	log.Printf("%s %s state, %+v\n", s.now().Format(time.RFC822), trigger, s)
+	if trigger != "loop begin" {
+		s.Notify(notify.KindStateChange, trigger, s.String())
+	}
 }
 
-func (s *State) Buy(cbSvc CoinbaseSvcInterface, open, close float64) bool {
+//Pause stops or resumes buying by hand, a pause by hand does not expire. The caller must hold the state lock
+func (s *State) Pause(paused bool) {
+	s.Paused = paused
+	s.PausedUntil = time.Time{}
+}
+
+//PauseFor stops buying for d, a pause by hand is kept as it is. The caller must hold the state lock
+func (s *State) PauseFor(d time.Duration) {
+	if s.Paused && s.PausedUntil.IsZero() {
+		return
+	}
+	s.Paused = true
+	s.PausedUntil = s.now().Add(d)
+}
+
+//resumeExpired resumes buying once a PauseFor is over
+func (s *State) resumeExpired() {
+	if s.Paused && !s.PausedUntil.IsZero() && !s.now().Before(s.PausedUntil) {
+		s.Pause(false)
+		s.PrintStateChange("error pause expired")
+	}
+}
+
+//RecordTrade keeps the trade in Trades and writes a single line to the trade audit log
+func (s *State) RecordTrade(trigger, side string, size, price, funds decimal.Decimal) {
+	s.Trades = append(s.Trades, Trade{
+		Time:    s.now(),
+		Trigger: trigger,
+		Side:    side,
+		Size:    size,
+		Price:   price,
+		Funds:   funds,
+	})
+	if len(s.Trades) > maxTrades {
+		s.Trades = s.Trades[len(s.Trades)-maxTrades:]
+	}
+	if s.tradeLog == nil {
+		return
+	}
+	s.tradeLog.Printf("trigger=%q product=%s side=%s size=%s price=%s funds=%s", trigger, s.Product, side, size, price, funds)
+}
+
+//PnL is the realized profit and loss and the unrealized one of the open position at lastPrice.
+//An unrealized one out of the decimal range is an error wrapping decimal.ErrOverflow.
+func (s *State) PnL(lastPrice decimal.Decimal) (realized, unrealized decimal.Decimal, err error) {
+	if !s.NumberOwn.IsZero() {
+		change, err := lastPrice.Sub(s.BuyPrice)
+		if err == nil {
+			unrealized, err = s.NumberOwn.Mul(change)
+		}
+		if err != nil {
+			return s.RealizedPnL, decimal.Zero, fmt.Errorf("unrealized %s of %s at %s: %w", s.Product, s.NumberOwn, lastPrice, err)
+		}
+	}
+	return s.RealizedPnL, unrealized, nil
+}
+
+//Value is what the funds and the position are worth at lastPrice, in QuoteCurrency.
+//A value out of the decimal range is an error wrapping decimal.ErrOverflow.
+func (s *State) Value(lastPrice decimal.Decimal) (decimal.Decimal, error) {
+	position, err := s.NumberOwn.Mul(lastPrice)
+	value := decimal.Zero
+	if err == nil {
+		value, err = s.AvailableFunds.Add(position)
+	}
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("value of %s %s at %s: %w", s.NumberOwn, s.Product, lastPrice, err)
+	}
+	return value, nil
+}
+
+//ReportMetrics publishes the state and the last price seen to the metrics gauges
+func (s *State) ReportMetrics(lastPrice decimal.Decimal) {
+	metrics.LastPrice.WithLabelValues(s.Product).Set(lastPrice.Float64())
+	metrics.PositionSize.WithLabelValues(s.Product).Set(s.NumberOwn.Float64())
+	metrics.BuyPrice.WithLabelValues(s.Product).Set(s.BuyPrice.Float64())
+	metrics.LockPrice.WithLabelValues(s.Product).Set(s.LockPrice.Float64())
+	metrics.BottomPrice.WithLabelValues(s.Product).Set(s.BottomPrice.Float64())
+	metrics.AvailableFunds.WithLabelValues(s.Product, s.QuoteCurrency).Set(s.AvailableFunds.Float64())
+}
+
+//Buy opens a position with the funds when the strategy and the trading schedule allow it, true when it did.
+//Buy, Sell, ForceSell and ResolvePending take the state lock themselves and let go of it while the order is with the exchange,
+//they must not be called from Guard.
+func (s *State) Buy(ctx context.Context, cbSvc CoinbaseSvcInterface, open, close decimal.Decimal) bool {
+	var funds decimal.Decimal
+	ok := false
+	s.Guard(func() {
+		funds, ok = s.reserveBuy(open, close)
+	})
+	if !ok {
+		return false
+	}
+
+	nOwn, buyPrice, err := cbSvc.Buy(ctx, s.Product, close, funds)
+	s.Guard(func() {
+		s.ordering = false
+		ok = false
+		if s.recordPending(err, "buy", "buy", funds, close) {
+			s.AvailableFunds = decimal.Zero
+			return
+		}
+		if errors.Is(err, ErrNotTradable) {
+			log.Printf("skipping %s buy signal at %s, %s\n", s.Product, close, err.Error())
+			return
+		}
+		if err == nil {
+			err = s.completeBuy("buy", nOwn, buyPrice)
+		}
+		if err != nil {
+			s.orderErr = err
+			return
+		}
+		ok = true
+	})
+	return ok
+}
+
+//reserveBuy is the funds to buy with when the strategy signals a buy at close, no other order is taken until the buy is applied
+func (s *State) reserveBuy(open, close decimal.Decimal) (decimal.Decimal, bool) {
+	if !validPrice(open) || !validPrice(close) {
+		log.Printf("ignoring %s prices open %s close %s\n", s.Product, open, close)
+		return decimal.Zero, false
+	}
+	s.resumeExpired()
+	st := s.strategy()
+	// is buying paused or waiting on an order
 	// is the last sale time 2 hours ago or more
 	// is there available funds to purchase
 	// is the growth high enough
-	if s.isLastSaleGreater(time.Hour*2) && s.AvailableUSDFunds != 0 && isGrowthGreater(open, close, .03) {
-		nOwn, buyPrice, err := cbSvc.Buy(s.Product, close, s.AvailableUSDFunds)
-		if err != nil {
-			return false
+	// is buying allowed by the trading schedule
+	if !s.ordering && !s.Paused && len(s.PendingOrders) == 0 && s.isLastSaleGreater(st.Cooldown) && !s.AvailableFunds.IsZero() && isGrowthGreater(open, close, st.BuyGrowth) {
+		if ok, reason := s.BuyAllowed(); !ok {
+			log.Printf("skipping %s buy signal at %s, %s\n", s.Product, close, reason)
+			return decimal.Zero, false
 		}
-		s.BuyPrice = buyPrice
-		s.NumberOwn = nOwn
-		s.AvailableUSDFunds = 0.0
-		s.BottomPrice = s.BuyPrice - (s.BuyPrice * .10)
-		s.LockPriceSet = false
-		s.LockPrice = 0.0
-		s.SetLastSaleTime(time.Time{})
-		s.PrintStateChange("buy")
-		return true
+		s.ordering = true
+		return s.AvailableFunds, true
 	}
-	return false
+	return decimal.Zero, false
 }
 
-func (s *State) Lock(close float64) {
-	if s.LockPriceSet && isGrowthGreater(s.LockPrice, close, 0.01) {
+//completeBuy applies a filled buy, amounts out of the decimal range leave the state as it is and are the error
+func (s *State) completeBuy(trigger string, nOwn, buyPrice decimal.Decimal) error {
+	st := s.strategy()
+	loss, err := buyPrice.Mul(decimal.NewFromFloat(st.StopLoss))
+	var bottom, funds decimal.Decimal
+	if err == nil {
+		bottom, err = buyPrice.Sub(loss)
+	}
+	if err == nil {
+		funds, err = nOwn.Mul(buyPrice)
+	}
+	if err != nil {
+		return fmt.Errorf("applying %s buy of %s at %s: %w", s.Product, nOwn, buyPrice, err)
+	}
+	s.BuyPrice = buyPrice
+	s.NumberOwn = nOwn
+	s.AvailableFunds = decimal.Zero
+	s.BottomPrice = bottom
+	s.LockPriceSet = false
+	s.LockPrice = decimal.Zero
+	s.SetLastSaleTime(time.Time{})
+	s.PrintStateChange(trigger)
+	s.RecordTrade(trigger, "buy", nOwn, buyPrice, funds)
+	return nil
+}
+
+//Lock raises the lock price with close, the caller must hold the state lock
+func (s *State) Lock(close decimal.Decimal) {
+	if !validPrice(close) {
+		return
+	}
+	st := s.strategy()
+	if s.LockPriceSet && isGrowthGreater(s.LockPrice, close, st.LockStep) {
 		s.LockPrice = getLockPrice(s.LockPrice, close)
 		s.PrintStateChange("Lock growth of 1%")
 	}
 
-	if !s.LockPriceSet && s.AvailableUSDFunds == 0.0 && isGrowthGreater(s.BuyPrice, close, 0.03) {
+	if !s.LockPriceSet && s.AvailableFunds.IsZero() && isGrowthGreater(s.BuyPrice, close, st.LockGrowth) {
 		s.LockPrice = close
 		s.LockPriceSet = true
 		s.PrintStateChange("Lock growth of 3%")
 	}
 }
 
-func (s *State) Sell(cbSvc CoinbaseSvcInterface, close float64) bool {
-	var err error
-	tempNumOwn := s.NumberOwn
-	tempAvailUSDFunds := s.AvailableUSDFunds
-	no := 0.0
-	af := 0.0
+//Sell closes the position when close takes the profit, falls under the lock price or under the bottom price, true when it did
+func (s *State) Sell(ctx context.Context, cbSvc CoinbaseSvcInterface, close decimal.Decimal) bool {
+	var size, sellPrice decimal.Decimal
 	stateChange := ""
+	s.Guard(func() {
+		stateChange, size, sellPrice = s.reserveSell(close)
+	})
+	if stateChange == "" {
+		return false
+	}
+
+	no, af, err := cbSvc.Sell(ctx, s.Product, size, sellPrice)
+	sold := false
+	s.Guard(func() {
+		s.ordering = false
+		if s.recordPending(err, stateChange, "sell", size, sellPrice) {
+			return
+		}
+		if err == nil {
+			err = s.completeSale(stateChange, size, close, no, af)
+		}
+		if err != nil {
+			s.orderErr = err
+			return
+		}
+		sold = true
+	})
+	return sold
+}
 
-	if s.AvailableUSDFunds == 0.0 && isGrowthGreater(s.BuyPrice, close, 0.08) {
-		no, af, err = cbSvc.Sell(s.Product, s.NumberOwn, close)
+//reserveSell is the trigger, size and price of the sale the strategy signals at close, no other order is taken until the sale is applied.
+//The trigger is empty when there is nothing to sell.
+func (s *State) reserveSell(close decimal.Decimal) (string, decimal.Decimal, decimal.Decimal) {
+	if s.ordering || len(s.PendingOrders) != 0 || !validPrice(close) {
+		return "", decimal.Zero, decimal.Zero
+	}
+	st := s.strategy()
+	stateChange := ""
+	sellPrice := close
+	if s.AvailableFunds.IsZero() && isGrowthGreater(s.BuyPrice, close, st.TakeProfit) {
 		stateChange = "8% sell"
-	} else if s.AvailableUSDFunds == 0.0 && s.LockPrice != 0.0 && close < s.LockPrice { //This could be set by the coinbase API
-		no, af, err = cbSvc.Sell(s.Product, s.NumberOwn, s.LockPrice)
+	} else if s.AvailableFunds.IsZero() && !s.LockPrice.IsZero() && close.LessThan(s.LockPrice) { //This could be set by the coinbase API
+		sellPrice = s.LockPrice
 		stateChange = "3% sell"
-	} else if s.AvailableUSDFunds == 0.0 && close < s.BottomPrice { //This could be set by the coinbase API.
-		no, af, err = cbSvc.Sell(s.Product, s.NumberOwn, s.BottomPrice)
+	} else if s.AvailableFunds.IsZero() && close.LessThan(s.BottomPrice) { //This could be set by the coinbase API.
+		sellPrice = s.BottomPrice
 		stateChange = "10% loss"
 	}
+	if stateChange != "" {
+		s.ordering = true
+	}
+	return stateChange, s.NumberOwn, sellPrice
+}
+
+//ForceSell sells the whole position at the last price, regardless of strategy
+func (s *State) ForceSell(ctx context.Context, cbSvc CoinbaseSvcInterface) error {
+	var sold decimal.Decimal
+	var err error
+	s.Guard(func() {
+		if s.ordering || len(s.PendingOrders) != 0 {
+			err = fmt.Errorf("%s is waiting on an order", s.Product)
+			return
+		}
+		if !s.AvailableFunds.IsZero() || s.NumberOwn.IsZero() {
+			err = fmt.Errorf("no position to sell for %s", s.Product)
+			return
+		}
+		sold = s.NumberOwn
+		s.ordering = true
+	})
+	if err != nil {
+		return err
+	}
+
+	lastPrice, err := cbSvc.GetLastPrice(ctx, s.Product)
+	if err == nil && !validPrice(lastPrice) {
+		err = fmt.Errorf("invalid last price %s for %s", lastPrice, s.Product)
+	}
+	no, af := decimal.Zero, decimal.Zero
+	if err == nil {
+		no, af, err = cbSvc.Sell(ctx, s.Product, sold, lastPrice)
+	}
+	s.Guard(func() {
+		s.ordering = false
+		if err != nil {
+			s.recordPending(err, "force sell", "sell", sold, lastPrice)
+			return
+		}
+		err = s.completeSale("force sell", sold, lastPrice, no, af)
+	})
+	return err
+}
+
+//recordPending keeps an order that was created but not confirmed so it can be resolved after a restart
+func (s *State) recordPending(err error, trigger, side string, size, price decimal.Decimal) bool {
+	var pending *PendingOrderError
+	if !errors.As(err, &pending) {
+		return false
+	}
+	s.PendingOrders = append(s.PendingOrders, PendingOrder{
+		ID:      pending.OrderID,
+		Side:    side,
+		Trigger: trigger,
+		Size:    size,
+		Price:   price,
+		Created: s.now(),
+	})
+	s.PrintStateChange(fmt.Sprintf("%s pending", trigger))
+	return true
+}
+
+//ResolvePending waits on orders left pending by a shutdown or a timeout and applies them as if Buy or Sell had completed.
+//An order the exchange rejected or cancelled is dropped and the funds of a buy are given back, others are kept for the next try.
+func (s *State) ResolvePending(ctx context.Context, cbSvc CoinbaseSvcInterface) {
+	var pending []PendingOrder
+	s.Guard(func() {
+		if !s.ordering {
+			pending = s.PendingOrders
+			s.ordering = len(pending) != 0
+		}
+	})
+	if len(pending) == 0 {
+		return
+	}
+
+	type resumed struct {
+		a, b decimal.Decimal
+		err  error
+	}
+	results := make([]resumed, len(pending))
+	for i, p := range pending {
+		a, b, err := cbSvc.ResumeOrder(ctx, s.Product, p.Side, p.ID)
+		results[i] = resumed{a: a, b: b, err: err}
+	}
+
+	s.Guard(func() {
+		s.ordering = false
+		var unresolved []PendingOrder
+		for i, p := range pending {
+			a, b, err := results[i].a, results[i].b, results[i].err
+			if errors.Is(err, ErrOrderRejected) {
+				log.Printf("dropping pending %s order %s %s\n", p.Side, p.ID, err.Error())
+				if err := s.refund(p); err != nil {
+					log.Printf("failed to give back the funds of pending %s order %s %s\n", p.Side, p.ID, err.Error())
+					s.orderErr = err
+				}
+				s.PrintStateChange(fmt.Sprintf("%s rejected", p.Trigger))
+				continue
+			}
+			if err != nil {
+				log.Printf("failed to resolve pending %s order %s %s\n", p.Side, p.ID, err.Error())
+				unresolved = append(unresolved, p)
+				continue
+			}
+			switch p.Side {
+			case "buy":
+				err = s.completeBuy(p.Trigger, a, b)
+			case "sell":
+				err = s.completeSale(p.Trigger, p.Size, p.Price, a, b)
+			}
+			if err != nil {
+				log.Printf("dropping pending %s order %s %s\n", p.Side, p.ID, err.Error())
+				s.orderErr = err
+			}
+		}
+		s.PendingOrders = unresolved
+	})
+}
 
-	if stateChange != "" && err == nil {
-		s.AvailableUSDFunds = af
-		s.NumberOwn = no
-		s.ResetState()
-		s.SetLastSaleTime(time.Now())
-		s.PrintStateChange(stateChange)
-		return true
+//refund gives back the funds of a pending buy that was dropped, funds out of the decimal range leave them as they are and are the error
+func (s *State) refund(p PendingOrder) error {
+	if p.Side != "buy" {
+		return nil
 	}
-	s.AvailableUSDFunds = tempAvailUSDFunds
-	s.NumberOwn = tempNumOwn
-	return false
+	funds, err := s.AvailableFunds.Add(p.Size)
+	if err != nil {
+		return fmt.Errorf("giving back %s funds %s: %w", s.Product, p.Size, err)
+	}
+	s.AvailableFunds = funds
+	return nil
+}
+
+//completeSale applies a filled sale, amounts out of the decimal range leave the state as it is and are the error
+func (s *State) completeSale(trigger string, sold, price, numberOwn, availableFunds decimal.Decimal) error {
+	cost, err := s.BuyPrice.Mul(sold)
+	var realized decimal.Decimal
+	if err == nil {
+		realized, err = availableFunds.Sub(cost)
+	}
+	if err == nil {
+		realized, err = s.RealizedPnL.Add(realized)
+	}
+	if err != nil {
+		return fmt.Errorf("applying %s sale of %s for %s: %w", s.Product, sold, availableFunds, err)
+	}
+	s.RealizedPnL = realized
+	s.AvailableFunds = availableFunds
+	s.NumberOwn = numberOwn
+	s.ResetState()
+	s.SetLastSaleTime(s.now())
+	s.PrintStateChange(trigger)
+	s.RecordTrade(trigger, "sell", sold, price, availableFunds)
+	return nil
+}
+
+//validPrice a bad tick, 0 or negative, never trades
+func validPrice(p decimal.Decimal) bool {
+	return p.IsPositive()
 }
 
-func getLockPrice(currentLockPrice, currentClose float64) float64 {
-	return math.Max(currentLockPrice, currentClose)
+func getLockPrice(currentLockPrice, currentClose decimal.Decimal) decimal.Decimal {
+	return decimal.Max(currentLockPrice, currentClose)
 }
 
-func isGrowthGreater(begin, end, p float64) bool {
-	fmt.Printf("begin %f end %f growth %f\n", begin, end, percentGrowth(begin, end))
+func isGrowthGreater(begin, end decimal.Decimal, p float64) bool {
+	log.Printf("begin %s end %s growth %f\n", begin, end, percentGrowth(begin, end))
 	return percentGrowth(begin, end) > p
 }
 
-func percentGrowth(begin, end float64) float64 {
-	if begin == 0 {
+//percentGrowth is a ratio, it compares to the float64 fractions of Strategy
+func percentGrowth(begin, end decimal.Decimal) float64 {
+	if begin.IsZero() {
 		return 0.0
 	}
-	return (end - begin) / begin
+	return (end.Float64() - begin.Float64()) / begin.Float64()
 }
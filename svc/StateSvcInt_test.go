@@ -1,259 +1,233 @@
-// +build integration
-
 package svc
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
-	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/proclient"
 	"github.com/stretchr/testify/assert"
-	"github.com/walkerus/go-wiremock"
 )
 
-func TestBuy(t *testing.T) {
-	// Set up the cbSvc this will be an integration test so we will create the actual client
-	// the client will call an imposter through wiremock
-	client := coinbasepro.NewClient()
-	client.UpdateConfig(&coinbasepro.ClientConfig{BaseURL: "http://0.0.0.0:8080"})
-	cbSvc := NewCoinbaseSvc(client, time.Duration(time.Millisecond*1))
-	assert := assert.New(t)
-
-	//set up wire mock
-	//given there exists apis in wiremock
-	wiremockClient := wiremock.NewClient("http://0.0.0.0:8080")
+//newSimulatedSvc is a CoinbaseSvc on the real Coinbase Pro client talking to an in process simulator.
+//The book is 103.09 / 103.10 and the account holds 1000 USD.
+func newSimulatedSvc(t *testing.T, timeout time.Duration) (*proclient.Simulator, CoinbaseSvc) {
+	sim := proclient.NewSimulator()
+	t.Cleanup(sim.Close)
+	sim.SetBook("BTC-USD", d("103.09"), d("103.1"))
+	sim.SetBalance("USD", d("1000"))
+	return sim, NewCoinbaseSvc(proclient.NewExchange(sim.Client()), timeout)
+}
 
-	//clean up others mess, and clean up after yourself.
-	wiremockClient.Clear()
-	// defer wiremockClient.Clear()
+func TestBuy(t *testing.T) {
+	t.Parallel()
+	//what the simulator fills a 1000 USD market buy with, at the ask less the 0.5% fee
+	filledSize := d("9.65106571") //1000 / 1.005 is 995.02487562, / 103.1 cut at the 8th place
 
 	type fields struct {
-		WireMockResponseBodyPost string
-		WireMockPostResponse     int
-		WireMockResponseBodyGet  string
-		WireMockGetResponse      int
-		Product                  string
-		NumberOwn                float64
-		BuyPrice                 float64
-		LockPrice                float64
-		BottomPrice              float64
-		LockPriceSet             bool
-		AvailableUSDFunds        float64
-		Executed                 bool
+		Product        string
+		NumberOwn      decimal.Decimal
+		BuyPrice       decimal.Decimal
+		LockPrice      decimal.Decimal
+		BottomPrice    decimal.Decimal
+		LockPriceSet   bool
+		AvailableFunds decimal.Decimal
+		Executed       bool
 	}
 	type args struct {
-		svc   CoinbaseSvcInterface
-		open  float64
-		close float64
+		open  decimal.Decimal
+		close decimal.Decimal
 	}
 	tests := []struct {
 		name       string
+		setup      func(sim *proclient.Simulator)
+		timeout    time.Duration
 		fields     fields
 		args       args
 		wantFields fields
+		wantUSD    decimal.Decimal
+		wantBTC    decimal.Decimal
 	}{
 		{
-			name: "Execute buy when growth is greater than expected and have USD",
-			fields: fields{
-				WireMockResponseBodyPost: `{"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2" }`,
-				WireMockPostResponse:     200,
-				WireMockResponseBodyGet: `{` +
-					`"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2",` +
-					`"product_id": "BTC-USD",` +
-					`"type": "market",` +
-					`"post_only": false,` +
-					`"created_at": "2016-12-08T20:09:05.508883Z",` +
-					`"done_at": "2016-12-08T20:09:05.527Z",` +
-					`"done_reason": "filled",` +
-					`"fill_fees": "0.0249376391550000",` +
-					`"filled_size": "0.01291771",` +
-					`"executed_value": "9.9750556620000000",` +
-					`"status": "done",` +
-					`"settled": true` +
-					`}`,
-				WireMockGetResponse: 200,
-				Product:             "BTC-USD",
-				NumberOwn:           0,
-				BuyPrice:            0,
-				LockPrice:           0,
-				BottomPrice:         0,
-				LockPriceSet:        false,
-				AvailableUSDFunds:   1000.0,
-			},
-			args: args{
-				svc:   cbSvc,
-				open:  100.0,
-				close: 103.1,
-			},
+			name:    "Happy Path. Execute buy when growth is greater than expected and have USD",
+			setup:   func(sim *proclient.Simulator) {},
+			timeout: time.Millisecond,
+			fields:  fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:    args{open: d("100"), close: d("103.1")},
 			wantFields: fields{
-				Product:           "BTC-USD",
-				NumberOwn:         0.01291771, //response body filled size
-				BuyPrice:          103.1,
-				LockPrice:         0,
-				BottomPrice:       0,
-				LockPriceSet:      false,
-				AvailableUSDFunds: 0.0,
-				Executed:          true,
+				Product:        "BTC-USD",
+				NumberOwn:      filledSize,
+				BuyPrice:       d("103.1"), //the ask
+				BottomPrice:    d("92.79"),
+				AvailableFunds: decimal.Zero,
+				Executed:       true,
 			},
+			wantUSD: decimal.Zero,
+			wantBTC: filledSize,
 		},
 		{
-			name: "No buy. When done reason is not equal to filled",
-			fields: fields{
-				WireMockResponseBodyPost: `{"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2" }`,
-				WireMockPostResponse:     200,
-				WireMockResponseBodyGet: `{` +
-					`"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2",` +
-					`"product_id": "BTC-USD",` +
-					`"type": "market",` +
-					`"post_only": false,` +
-					`"created_at": "2016-12-08T20:09:05.508883Z",` +
-					`"done_at": "2016-12-08T20:09:05.527Z",` +
-					`"done_reason": "not_filled",` +
-					`"fill_fees": "0.0249376391550000",` +
-					`"filled_size": "0.01291771",` +
-					`"executed_value": "9.9750556620000000",` +
-					`"status": "done",` +
-					`"settled": true` +
-					`}`,
-				WireMockGetResponse: 200,
-				Product:             "BTC-USD",
-				NumberOwn:           0,
-				BuyPrice:            0,
-				LockPrice:           0,
-				BottomPrice:         0,
-				LockPriceSet:        false,
-				AvailableUSDFunds:   1000.0,
-			},
-			args: args{
-				svc:   cbSvc,
-				open:  99.0,
-				close: 104.1,
+			name: "Happy Path. Execute buy when the order fills after it is polled",
+			setup: func(sim *proclient.Simulator) {
+				sim.SetFill(proclient.FillAfterPolls, 1)
 			},
+			timeout: time.Second * 5,
+			fields:  fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:    args{open: d("100"), close: d("103.1")},
 			wantFields: fields{
-				Product:           "BTC-USD",
-				NumberOwn:         0.0,
-				BuyPrice:          0.0,
-				LockPrice:         0,
-				BottomPrice:       0,
-				LockPriceSet:      false,
-				AvailableUSDFunds: 1000.0,
-				Executed:          false,
+				Product:        "BTC-USD",
+				NumberOwn:      filledSize,
+				BuyPrice:       d("103.1"),
+				BottomPrice:    d("92.79"),
+				AvailableFunds: decimal.Zero,
+				Executed:       true,
 			},
+			wantUSD: decimal.Zero,
+			wantBTC: filledSize,
 		},
 		{
-			name: "No buy.  When Post Fails",
-			fields: fields{
-				WireMockResponseBodyPost: "not found",
-				WireMockPostResponse:     404,
-				WireMockResponseBodyGet: `{` +
-					`"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2",` +
-					`"product_id": "BTC-USD",` +
-					`"type": "market",` +
-					`"post_only": false,` +
-					`"created_at": "2016-12-08T20:09:05.508883Z",` +
-					`"done_at": "2016-12-08T20:09:05.527Z",` +
-					`"done_reason": "filled",` +
-					`"fill_fees": "0.0249376391550000",` +
-					`"filled_size": "0.01291771",` +
-					`"executed_value": "9.9750556620000000",` +
-					`"status": "done",` +
-					`"settled": true` +
-					`}`,
-				WireMockGetResponse: 200,
-				Product:             "BTC-USD",
-				NumberOwn:           0,
-				BuyPrice:            0,
-				LockPrice:           0,
-				BottomPrice:         0,
-				LockPriceSet:        false,
-				AvailableUSDFunds:   1000.0,
-			},
-			args: args{
-				svc:   cbSvc,
-				open:  99.0,
-				close: 104.1,
-			},
-			wantFields: fields{
-				Product:           "BTC-USD",
-				NumberOwn:         0.0,
-				BuyPrice:          0.0,
-				LockPrice:         0,
-				BottomPrice:       0,
-				LockPriceSet:      false,
-				AvailableUSDFunds: 1000.0,
-				Executed:          false,
+			name: "Sad Path. No buy. When the order is canceled",
+			setup: func(sim *proclient.Simulator) {
+				sim.SetFill(proclient.FillCancel, 0)
 			},
+			timeout:    time.Second * 5,
+			fields:     fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:       args{open: d("99"), close: d("104.1")},
+			wantFields: fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			wantUSD:    d("1000"),
 		},
 		{
-			name: "No buy.  When Get Fails",
-			fields: fields{
-				WireMockResponseBodyPost: `{"id": "d0c5340b-6d6c-49d9-b567-48c4bfca13d2" }`,
-				WireMockPostResponse:     200,
-				WireMockResponseBodyGet:  "server error",
-				WireMockGetResponse:      500,
-				Product:                  "BTC-USD",
-				NumberOwn:                0,
-				BuyPrice:                 0,
-				LockPrice:                0,
-				BottomPrice:              0,
-				LockPriceSet:             false,
-				AvailableUSDFunds:        1000.0,
+			name: "Sad Path. No buy. When Post Fails",
+			setup: func(sim *proclient.Simulator) {
+				sim.Fail(http.MethodPost, "/orders", http.StatusNotFound, "NotFound")
 			},
-			args: args{
-				svc:   cbSvc,
-				open:  99.0,
-				close: 104.1,
+			timeout:    time.Millisecond,
+			fields:     fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:       args{open: d("99"), close: d("104.1")},
+			wantFields: fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			wantUSD:    d("1000"),
+		},
+		{
+			name: "Sad Path. No buy. When the funds are not in the account",
+			setup: func(sim *proclient.Simulator) {
+				sim.SetBalance("USD", d("10"))
 			},
-			wantFields: fields{
-				Product:           "BTC-USD",
-				NumberOwn:         0.0,
-				BuyPrice:          0.0,
-				LockPrice:         0,
-				BottomPrice:       0,
-				LockPriceSet:      false,
-				AvailableUSDFunds: 1000.0,
-				Executed:          false,
+			timeout:    time.Millisecond,
+			fields:     fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:       args{open: d("99"), close: d("104.1")},
+			wantFields: fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			wantUSD:    d("10"),
+		},
+		{
+			name: "Sad Path. No buy. When Get Fails before the backoff times out, the order still fills on the exchange",
+			setup: func(sim *proclient.Simulator) {
+				sim.Fail(http.MethodGet, "/orders/", http.StatusInternalServerError, "server error")
 			},
+			timeout:    time.Millisecond,
+			fields:     fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			args:       args{open: d("99"), close: d("104.1")},
+			wantFields: fields{Product: "BTC-USD", AvailableFunds: d("1000")},
+			wantUSD:    decimal.Zero,
+			wantBTC:    filledSize,
 		},
 	}
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			sim, cbSvc := newSimulatedSvc(t, tt.timeout)
+			tt.setup(sim)
 			s := &State{
-				Product:           tt.fields.Product,
-				NumberOwn:         tt.fields.NumberOwn,
-				BuyPrice:          tt.fields.BuyPrice,
-				LockPrice:         tt.fields.LockPrice,
-				BottomPrice:       tt.fields.BottomPrice,
-				LockPriceSet:      tt.fields.LockPriceSet,
-				AvailableUSDFunds: tt.fields.AvailableUSDFunds,
+				Product:        tt.fields.Product,
+				NumberOwn:      tt.fields.NumberOwn,
+				BuyPrice:       tt.fields.BuyPrice,
+				LockPrice:      tt.fields.LockPrice,
+				BottomPrice:    tt.fields.BottomPrice,
+				LockPriceSet:   tt.fields.LockPriceSet,
+				AvailableFunds: tt.fields.AvailableFunds,
+				LastSaleTime:   time.Now().Add(time.Hour * -3),
 			}
-			wiremockClient.Clear()
-			// Set wire mock to return an expected order id
-			wiremockClient.StubFor(wiremock.Post(wiremock.URLPathMatching("/orders")).
-				WillReturn(
-					tt.fields.WireMockResponseBodyPost,
-					map[string]string{"Content-Type": "application/json"},
-					200,
-				).
-				AtPriority(1))
 
-			// Set up mock to return order status
-			wiremockClient.StubFor(wiremock.Get(wiremock.URLPathMatching("/orders/d0c5340b-6d6c-49d9-b567-48c4bfca13d2")).
-				WillReturn(
-					tt.fields.WireMockResponseBodyGet,
-					map[string]string{"Content-Type": "application/json"},
-					200,
-				).
-				AtPriority(2))
-
-			executed := s.Buy(tt.args.svc, tt.args.open, tt.args.close)
-			assert.Equal(tt.wantFields.AvailableUSDFunds, s.AvailableUSDFunds, fmt.Sprintf("%s, AvailableUSDFunds is not equal", tt.name))
+			executed := s.Buy(context.Background(), cbSvc, tt.args.open, tt.args.close)
+			assert.Equal(tt.wantFields.AvailableFunds, s.AvailableFunds, fmt.Sprintf("%s, AvailableFunds is not equal", tt.name))
 			assert.Equal(tt.wantFields.NumberOwn, s.NumberOwn, fmt.Sprintf("%s, NumberOwn is not equal", tt.name))
-			assert.Equal(tt.wantFields.BuyPrice, s.BuyPrice, fmt.Sprintf("%s, BuyPrice is not equal", tt.name))
+			//the average price of the fill is cut at the 8th place
+			assert.InDelta(tt.wantFields.BuyPrice.Float64(), s.BuyPrice.Float64(), 1e-6, fmt.Sprintf("%s, BuyPrice is not equal", tt.name))
+			assert.InDelta(tt.wantFields.BottomPrice.Float64(), s.BottomPrice.Float64(), 1e-6, fmt.Sprintf("%s, BottomPrice is not equal", tt.name))
 			assert.Equal(tt.wantFields.LockPrice, s.LockPrice, fmt.Sprintf("%s, LockPrice is not equal", tt.name))
 			assert.Equal(tt.wantFields.LockPriceSet, s.LockPriceSet, fmt.Sprintf("%s, LockPriceSet flag is not equal", tt.name))
 			assert.Equal(tt.wantFields.Executed, executed)
+			assert.Equal(tt.wantUSD, sim.Balance("USD"), "USD on the exchange")
+			assert.Equal(tt.wantBTC, sim.Balance("BTC"), "BTC on the exchange")
+		})
+	}
+}
+
+func TestSell(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		setup         func(sim *proclient.Simulator)
+		close         decimal.Decimal
+		wantExecuted  bool
+		wantNumberOwn decimal.Decimal
+		wantFunds     decimal.Decimal
+	}{
+		{
+			name:          "Happy Path. Take profit at 8%, the funds are the USD balance rounded down to the cent",
+			setup:         func(sim *proclient.Simulator) { sim.SetBook("BTC-USD", d("108.1"), d("108.11")) },
+			close:         d("108.1"),
+			wantExecuted:  true,
+			wantNumberOwn: decimal.Zero,
+			wantFunds:     d("107.55"), //1 BTC at the bid less the 0.5% fee, 107.5595
+		},
+		{
+			name:          "Happy Path. Stop loss at 10% below the buy price",
+			setup:         func(sim *proclient.Simulator) { sim.SetBook("BTC-USD", d("89.5"), d("89.51")) },
+			close:         d("89.5"),
+			wantExecuted:  true,
+			wantNumberOwn: decimal.Zero,
+			wantFunds:     d("89.05"), //89.0525
+		},
+		{
+			name: "Sad Path. No sale when the order is rejected",
+			setup: func(sim *proclient.Simulator) {
+				sim.SetBook("BTC-USD", d("108.1"), d("108.11"))
+				sim.Fail(http.MethodPost, "/orders", http.StatusBadRequest, "Insufficient funds")
+			},
+			close:         d("108.1"),
+			wantExecuted:  false,
+			wantNumberOwn: d("1"),
+			wantFunds:     decimal.Zero,
+		},
+		{
+			name:          "Sad Path. No sale between the stop loss and the take profit",
+			setup:         func(sim *proclient.Simulator) { sim.SetBook("BTC-USD", d("104"), d("104.01")) },
+			close:         d("104"),
+			wantExecuted:  false,
+			wantNumberOwn: d("1"),
+			wantFunds:     decimal.Zero,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+			sim, cbSvc := newSimulatedSvc(t, time.Millisecond)
+			sim.SetBalance("USD", decimal.Zero)
+			sim.SetBalance("BTC", d("1"))
+			tt.setup(sim)
+			s := &State{Product: "BTC-USD", NumberOwn: d("1"), BuyPrice: d("100"), BottomPrice: d("90")}
+
+			executed := s.Sell(context.Background(), cbSvc, tt.close)
+			assert.Equal(tt.wantExecuted, executed)
+			assert.Equal(tt.wantNumberOwn, s.NumberOwn)
+			assert.Equal(tt.wantFunds, s.AvailableFunds)
+			assert.Equal(tt.wantNumberOwn, sim.Balance("BTC"), "BTC left on the exchange")
 		})
 	}
 }
@@ -1,17 +1,29 @@
 package svc
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/notify"
+	"github.com/JasonWBrown/proclient"
 	"github.com/stretchr/testify/assert"
 )
 
+//d is a decimal constant
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
 func Test_percentGrowth(t *testing.T) {
 	type args struct {
-		begin float64
-		end   float64
+		begin decimal.Decimal
+		end   decimal.Decimal
 	}
 	tests := []struct {
 		name string
@@ -21,24 +33,24 @@ func Test_percentGrowth(t *testing.T) {
 		{
 			name: "valid 3%",
 			args: args{
-				begin: 100,
-				end:   103,
+				begin: d("100"),
+				end:   d("103"),
 			},
 			want: 0.03,
 		},
 		{
 			name: "divide by 0",
 			args: args{
-				begin: 0,
-				end:   100,
+				begin: d("0"),
+				end:   d("100"),
 			},
 			want: 0,
 		},
 		{
 			name: "negative growth",
 			args: args{
-				begin: 100,
-				end:   97,
+				begin: d("100"),
+				end:   d("97"),
 			},
 			want: -0.03,
 		},
@@ -54,8 +66,8 @@ func Test_percentGrowth(t *testing.T) {
 
 func Test_isGrowthGreater(t *testing.T) {
 	type args struct {
-		begin float64
-		end   float64
+		begin decimal.Decimal
+		end   decimal.Decimal
 		p     float64
 	}
 	tests := []struct {
@@ -66,8 +78,8 @@ func Test_isGrowthGreater(t *testing.T) {
 		{
 			name: "Greater",
 			args: args{
-				begin: 100,
-				end:   103,
+				begin: d("100"),
+				end:   d("103"),
 				p:     0.029,
 			},
 			want: true,
@@ -75,8 +87,8 @@ func Test_isGrowthGreater(t *testing.T) {
 		{
 			name: "Less",
 			args: args{
-				begin: 100,
-				end:   103,
+				begin: d("100"),
+				end:   d("103"),
 				p:     0.031,
 			},
 			want: false,
@@ -84,8 +96,8 @@ func Test_isGrowthGreater(t *testing.T) {
 		{
 			name: "Equal",
 			args: args{
-				begin: 100,
-				end:   103,
+				begin: d("100"),
+				end:   d("103"),
 				p:     0.03,
 			},
 			want: false,
@@ -102,29 +114,29 @@ func Test_isGrowthGreater(t *testing.T) {
 
 func Test_getLockPrice(t *testing.T) {
 	type args struct {
-		currentLockPrice float64
-		currentClose     float64
+		currentLockPrice decimal.Decimal
+		currentClose     decimal.Decimal
 	}
 	tests := []struct {
 		name string
 		args args
-		want float64
+		want decimal.Decimal
 	}{
 		{
 			name: "Current lock price higher, expect current lock price",
 			args: args{
-				currentLockPrice: 1.01,
-				currentClose:     1.000001,
+				currentLockPrice: d("1.01"),
+				currentClose:     d("1.000001"),
 			},
-			want: 1.01,
+			want: d("1.01"),
 		},
 		{
 			name: "Current close higher, expect current close",
 			args: args{
-				currentLockPrice: 1.01,
-				currentClose:     1.011,
+				currentLockPrice: d("1.01"),
+				currentClose:     d("1.011"),
 			},
-			want: 1.011,
+			want: d("1.011"),
 		},
 	}
 	for _, tt := range tests {
@@ -139,20 +151,20 @@ func Test_getLockPrice(t *testing.T) {
 func TestState_Lock(t *testing.T) {
 	assert := assert.New(t)
 	type fields struct {
-		Product           string
-		NumberOwn         float64
-		BuyPrice          float64
-		LockPrice         float64
-		BottomPrice       float64
-		LockPriceSet      bool
-		AvailableUSDFunds float64
+		Product        string
+		NumberOwn      decimal.Decimal
+		BuyPrice       decimal.Decimal
+		LockPrice      decimal.Decimal
+		BottomPrice    decimal.Decimal
+		LockPriceSet   bool
+		AvailableFunds decimal.Decimal
 	}
 	type wantFields struct {
-		LockPrice    float64
+		LockPrice    decimal.Decimal
 		LockPriceSet bool
 	}
 	type args struct {
-		close float64
+		close decimal.Decimal
 	}
 	tests := []struct {
 		name       string
@@ -163,63 +175,63 @@ func TestState_Lock(t *testing.T) {
 		{
 			name: "When we haven't purchased anything. Don't change lockPrice or lockPriceSet flag",
 			fields: fields{
-				LockPriceSet:      false,
-				AvailableUSDFunds: 0.01,
-				LockPrice:         0.0,
+				LockPriceSet:   false,
+				AvailableFunds: d("0.01"),
+				LockPrice:      d("0.0"),
 			},
 			args: args{
-				close: 0.00,
+				close: d("0.00"),
 			},
 			wantFields: wantFields{
-				LockPrice:    0.0,
+				LockPrice:    d("0.0"),
 				LockPriceSet: false,
 			},
 		},
 		{
 			name: "When we purchased something for the first time and growth is higher than 3% rate set the lockPrice flag and set lock price to close",
 			fields: fields{
-				LockPriceSet:      false,
-				AvailableUSDFunds: 0.00,
-				LockPrice:         0.0,
-				BuyPrice:          1.0,
+				LockPriceSet:   false,
+				AvailableFunds: d("0.00"),
+				LockPrice:      d("0.0"),
+				BuyPrice:       d("1.0"),
 			},
 			args: args{
-				close: 1.031,
+				close: d("1.031"),
 			},
 			wantFields: wantFields{
-				LockPrice:    1.031,
+				LockPrice:    d("1.031"),
 				LockPriceSet: true,
 			},
 		},
 		{
 			name: "When we purchased something for the first time and growth is lower than 3% rate, state is unchanged",
 			fields: fields{
-				LockPriceSet:      false,
-				AvailableUSDFunds: 0.00,
-				LockPrice:         0.0,
-				BuyPrice:          1.0,
+				LockPriceSet:   false,
+				AvailableFunds: d("0.00"),
+				LockPrice:      d("0.0"),
+				BuyPrice:       d("1.0"),
 			},
 			args: args{
-				close: 1.029,
+				close: d("1.029"),
 			},
 			wantFields: wantFields{
-				LockPrice:    0.0,
+				LockPrice:    d("0.0"),
 				LockPriceSet: false,
 			},
 		},
 		{
 			name: "When we have already lockedState and growth is an additional one percent, change lockPrice",
 			fields: fields{
-				LockPriceSet:      true,
-				AvailableUSDFunds: 0.00,
-				LockPrice:         1.0,
-				BuyPrice:          1.0,
+				LockPriceSet:   true,
+				AvailableFunds: d("0.00"),
+				LockPrice:      d("1.0"),
+				BuyPrice:       d("1.0"),
 			},
 			args: args{
-				close: 1.011,
+				close: d("1.011"),
 			},
 			wantFields: wantFields{
-				LockPrice:    1.011,
+				LockPrice:    d("1.011"),
 				LockPriceSet: true,
 			},
 		},
@@ -227,13 +239,13 @@ func TestState_Lock(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &State{
-				Product:           tt.fields.Product,
-				NumberOwn:         tt.fields.NumberOwn,
-				BuyPrice:          tt.fields.BuyPrice,
-				LockPrice:         tt.fields.LockPrice,
-				BottomPrice:       tt.fields.BottomPrice,
-				LockPriceSet:      tt.fields.LockPriceSet,
-				AvailableUSDFunds: tt.fields.AvailableUSDFunds,
+				Product:        tt.fields.Product,
+				NumberOwn:      tt.fields.NumberOwn,
+				BuyPrice:       tt.fields.BuyPrice,
+				LockPrice:      tt.fields.LockPrice,
+				BottomPrice:    tt.fields.BottomPrice,
+				LockPriceSet:   tt.fields.LockPriceSet,
+				AvailableFunds: tt.fields.AvailableFunds,
 			}
 			s.Lock(tt.args.close)
 			assert.Equal(tt.wantFields.LockPrice, s.LockPrice, fmt.Sprintf("%s, Lock price is not equal", tt.name))
@@ -245,17 +257,17 @@ func TestState_Lock(t *testing.T) {
 func TestState_Sell(t *testing.T) {
 	assert := assert.New(t)
 	type fields struct {
-		Product           string
-		NumberOwn         float64
-		BuyPrice          float64
-		LockPrice         float64
-		BottomPrice       float64
-		LockPriceSet      bool
-		AvailableUSDFunds float64
+		Product        string
+		NumberOwn      decimal.Decimal
+		BuyPrice       decimal.Decimal
+		LockPrice      decimal.Decimal
+		BottomPrice    decimal.Decimal
+		LockPriceSet   bool
+		AvailableFunds decimal.Decimal
 	}
 	type args struct {
 		cbSvc CoinbaseSvcInterface
-		close float64
+		close decimal.Decimal
 	}
 	tests := []struct {
 		name       string
@@ -266,20 +278,20 @@ func TestState_Sell(t *testing.T) {
 		{
 			name: "8% sell will reset state",
 			fields: fields{
-				AvailableUSDFunds: 0.0,
-				BuyPrice:          100.0,
-				LockPrice:         1000.0,
-				BottomPrice:       10.0,
-				LockPriceSet:      true,
+				AvailableFunds: d("0.0"),
+				BuyPrice:       d("100.0"),
+				LockPrice:      d("1000.0"),
+				BottomPrice:    d("10.0"),
+				LockPriceSet:   true,
 			},
 			args: args{
 				cbSvc: NewCoinbaseSvcMock(),
-				close: 109.00,
+				close: d("109.00"),
 			},
 			wantFields: fields{
-				BuyPrice:     0.0,
-				LockPrice:    0.0,
-				BottomPrice:  0.0,
+				BuyPrice:     d("0.0"),
+				LockPrice:    d("0.0"),
+				BottomPrice:  d("0.0"),
 				LockPriceSet: false,
 			},
 		},
@@ -299,15 +311,15 @@ func TestState_Sell(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &State{
-				Product:           tt.fields.Product,
-				NumberOwn:         tt.fields.NumberOwn,
-				BuyPrice:          tt.fields.BuyPrice,
-				LockPrice:         tt.fields.LockPrice,
-				BottomPrice:       tt.fields.BottomPrice,
-				LockPriceSet:      tt.fields.LockPriceSet,
-				AvailableUSDFunds: tt.fields.AvailableUSDFunds,
+				Product:        tt.fields.Product,
+				NumberOwn:      tt.fields.NumberOwn,
+				BuyPrice:       tt.fields.BuyPrice,
+				LockPrice:      tt.fields.LockPrice,
+				BottomPrice:    tt.fields.BottomPrice,
+				LockPriceSet:   tt.fields.LockPriceSet,
+				AvailableFunds: tt.fields.AvailableFunds,
 			}
-			s.Sell(tt.args.cbSvc, tt.args.close)
+			s.Sell(context.Background(), tt.args.cbSvc, tt.args.close)
 			assert.Equal(tt.wantFields.LockPrice, s.LockPrice, fmt.Sprintf("%s, Lock price is not equal", tt.name))
 			assert.Equal(tt.wantFields.LockPriceSet, s.LockPriceSet, fmt.Sprintf("%s, Lock price flag is not equal", tt.name))
 			assert.Equal(tt.wantFields.BottomPrice, s.LockPrice, fmt.Sprintf("%s, Bottom price is not equal", tt.name))
@@ -321,24 +333,24 @@ func TestState_Buy(t *testing.T) {
 	assert := assert.New(t)
 	type MockFields struct {
 		Err            error
-		TotalPurchased float64
-		BuyPrice       float64
+		TotalPurchased decimal.Decimal
+		BuyPrice       decimal.Decimal
 	}
 	type fields struct {
-		Product           string
-		NumberOwn         float64
-		BuyPrice          float64
-		LockPrice         float64
-		BottomPrice       float64
-		LockPriceSet      bool
-		AvailableUSDFunds float64
-		LastSaleTime      time.Time
-		MockFields        MockFields
-		Executed          bool
+		Product        string
+		NumberOwn      decimal.Decimal
+		BuyPrice       decimal.Decimal
+		LockPrice      decimal.Decimal
+		BottomPrice    decimal.Decimal
+		LockPriceSet   bool
+		AvailableFunds decimal.Decimal
+		LastSaleTime   time.Time
+		MockFields     MockFields
+		Executed       bool
 	}
 	type args struct {
-		open  float64
-		close float64
+		open  decimal.Decimal
+		close decimal.Decimal
 	}
 	tests := []struct {
 		name       string
@@ -349,87 +361,87 @@ func TestState_Buy(t *testing.T) {
 		{
 			name: "Happy Path. 3% growth, with available funds, and sold greater than 120 minutes will buy.",
 			fields: fields{
-				AvailableUSDFunds: 1.0, // not zero
-				BuyPrice:          100.1,
-				LockPrice:         1000.0,
-				BottomPrice:       10.0,
-				LockPriceSet:      true,
-				LastSaleTime:      time.Now().Add(time.Minute * -121),
+				AvailableFunds: d("1.0"), // not zero
+				BuyPrice:       d("100.1"),
+				LockPrice:      d("1000.0"),
+				BottomPrice:    d("10.0"),
+				LockPriceSet:   true,
+				LastSaleTime:   time.Now().Add(time.Minute * -121),
 				MockFields: MockFields{
 					Err:            nil,
-					TotalPurchased: 9.9999,
-					BuyPrice:       14.123,
+					TotalPurchased: d("9.9999"),
+					BuyPrice:       d("14.123"),
 				},
 			},
 			args: args{
-				open:  100.00,
-				close: 103.0001,
+				open:  d("100.00"),
+				close: d("103.0001"),
 			},
 			wantFields: fields{
-				BuyPrice:     14.123,
-				LockPrice:    0.0,
-				BottomPrice:  12.7107,
+				BuyPrice:     d("14.123"),
+				LockPrice:    d("0.0"),
+				BottomPrice:  d("12.7107"),
 				LockPriceSet: false,
 				Executed:     true,
-				NumberOwn:    9.9999,
+				NumberOwn:    d("9.9999"),
 				LastSaleTime: time.Time{},
 			},
 		},
 		{
 			name: "Sad Path. 3% growth, with available funds, and sold less than 120 minutes will not buy.",
 			fields: fields{
-				AvailableUSDFunds: 1.0, // not zero
-				BuyPrice:          100.1,
-				LockPrice:         1000.0,
-				BottomPrice:       10.0,
-				LockPriceSet:      true,
-				LastSaleTime:      invalidSaleTime,
+				AvailableFunds: d("1.0"), // not zero
+				BuyPrice:       d("100.1"),
+				LockPrice:      d("1000.0"),
+				BottomPrice:    d("10.0"),
+				LockPriceSet:   true,
+				LastSaleTime:   invalidSaleTime,
 				MockFields: MockFields{
 					Err:            nil,
-					TotalPurchased: 9.9999,
-					BuyPrice:       14.123,
+					TotalPurchased: d("9.9999"),
+					BuyPrice:       d("14.123"),
 				},
 			},
 			args: args{
-				open:  100.00,
-				close: 103.0001,
+				open:  d("100.00"),
+				close: d("103.0001"),
 			},
 			wantFields: fields{
-				BuyPrice:     100.1,
-				LockPrice:    1000.0,
-				BottomPrice:  10.0,
+				BuyPrice:     d("100.1"),
+				LockPrice:    d("1000.0"),
+				BottomPrice:  d("10.0"),
 				LockPriceSet: true,
 				Executed:     false,
-				NumberOwn:    0.0,
+				NumberOwn:    d("0.0"),
 				LastSaleTime: invalidSaleTime,
 			},
 		},
 		{
 			name: "Sad Path. 3% growth, with available funds, and sold time is empty will not buy.",
 			fields: fields{
-				AvailableUSDFunds: 1.0, // not zero
-				BuyPrice:          100.1,
-				LockPrice:         1000.0,
-				BottomPrice:       10.0,
-				LockPriceSet:      true,
-				LastSaleTime:      time.Time{},
+				AvailableFunds: d("1.0"), // not zero
+				BuyPrice:       d("100.1"),
+				LockPrice:      d("1000.0"),
+				BottomPrice:    d("10.0"),
+				LockPriceSet:   true,
+				LastSaleTime:   time.Time{},
 				MockFields: MockFields{
 					Err:            nil,
-					TotalPurchased: 9.9999,
-					BuyPrice:       14.123,
+					TotalPurchased: d("9.9999"),
+					BuyPrice:       d("14.123"),
 				},
 			},
 			args: args{
-				open:  100.00,
-				close: 103.0001,
+				open:  d("100.00"),
+				close: d("103.0001"),
 			},
 			wantFields: fields{
-				BuyPrice:     100.1,
-				LockPrice:    1000.0,
-				BottomPrice:  10.0,
+				BuyPrice:     d("100.1"),
+				LockPrice:    d("1000.0"),
+				BottomPrice:  d("10.0"),
 				LockPriceSet: true,
 				Executed:     false,
-				NumberOwn:    0.0,
+				NumberOwn:    d("0.0"),
 				LastSaleTime: time.Time{},
 			},
 		},
@@ -443,16 +455,16 @@ func TestState_Buy(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 
 			s := &State{
-				Product:           tt.fields.Product,
-				NumberOwn:         tt.fields.NumberOwn,
-				BuyPrice:          tt.fields.BuyPrice,
-				LockPrice:         tt.fields.LockPrice,
-				BottomPrice:       tt.fields.BottomPrice,
-				LockPriceSet:      tt.fields.LockPriceSet,
-				AvailableUSDFunds: tt.fields.AvailableUSDFunds,
-				LastSaleTime:      tt.fields.LastSaleTime,
+				Product:        tt.fields.Product,
+				NumberOwn:      tt.fields.NumberOwn,
+				BuyPrice:       tt.fields.BuyPrice,
+				LockPrice:      tt.fields.LockPrice,
+				BottomPrice:    tt.fields.BottomPrice,
+				LockPriceSet:   tt.fields.LockPriceSet,
+				AvailableFunds: tt.fields.AvailableFunds,
+				LastSaleTime:   tt.fields.LastSaleTime,
 			}
-			executed := s.Buy(cbSvcMock, tt.args.open, tt.args.close)
+			executed := s.Buy(context.Background(), cbSvcMock, tt.args.open, tt.args.close)
 			assert.Equal(tt.wantFields.Executed, executed, fmt.Sprintf("%s, Lock price is not equal", tt.name))
 			assert.Equal(tt.wantFields.LockPrice, s.LockPrice, fmt.Sprintf("%s, Lock price is not equal", tt.name))
 			assert.Equal(tt.wantFields.LockPriceSet, s.LockPriceSet, fmt.Sprintf("%s, Lock price flag is not equal", tt.name))
@@ -463,3 +475,348 @@ func TestState_Buy(t *testing.T) {
 		})
 	}
 }
+
+//TestState_BuyNotTradable a product that is not tradable skips the buy signal, it is not an order failure
+func TestState_BuyNotTradable(t *testing.T) {
+	assert := assert.New(t)
+	s := NewStateSvc(nil).NewState("BTC-USD", d("100"))
+	cbSvcMock := NewCoinbaseSvcMock()
+	cbSvcMock.Err = &ExchangeError{Kind: ErrNotTradable, Op: "CreateOrder", Err: fmt.Errorf("product BTC-USD is not tradable")}
+
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.Nil(s.OrderErr())
+	assert.False(s.Paused)
+	assert.Equal(d("100"), s.AvailableFunds)
+
+	cbSvcMock.Err = &ExchangeError{Kind: ErrOrderRejected, Op: "CreateOrder", Err: fmt.Errorf("BTC-USD funds 100 are below the minimum 200")}
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.True(errors.Is(s.OrderErr(), ErrOrderRejected))
+}
+
+type pendingCbSvcMock struct {
+	CoinbaseSvcMock
+	pendingErr error
+}
+
+func (svc pendingCbSvcMock) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, svc.pendingErr
+}
+
+func (svc pendingCbSvcMock) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, svc.pendingErr
+}
+
+func TestState_PendingOrders(t *testing.T) {
+	assert := assert.New(t)
+	cbSvcMock := pendingCbSvcMock{
+		pendingErr: &PendingOrderError{OrderID: "GUID-1", Err: context.Canceled},
+	}
+	s := &State{
+		Product:        "BTC-USD",
+		AvailableFunds: d("100"),
+		LastSaleTime:   time.Now().Add(time.Minute * -121),
+	}
+
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.Equal(decimal.Zero, s.AvailableFunds, "funds are committed to the pending order")
+	assert.Len(s.PendingOrders, 1)
+	assert.Equal("buy", s.PendingOrders[0].Side)
+
+	// nothing else happens while the order is pending
+	assert.False(s.Sell(context.Background(), cbSvcMock, d("1")))
+	assert.Len(s.PendingOrders, 1)
+
+	resolver := NewCoinbaseSvcMock()
+	resolver.Err = fmt.Errorf("still pending")
+	s.ResolvePending(context.Background(), resolver)
+	assert.Len(s.PendingOrders, 1)
+
+	resolver.Err = nil
+	resolver.TotalPurchased = d("2")
+	resolver.BuyPrice = d("50")
+	s.ResolvePending(context.Background(), resolver)
+	assert.Len(s.PendingOrders, 0)
+	assert.Equal(d("2"), s.NumberOwn)
+	assert.Equal(d("50"), s.BuyPrice)
+	assert.Equal(d("45"), s.BottomPrice)
+}
+
+//TestState_PendingRejected a pending order the exchange rejected is dropped, the state trades again
+func TestState_PendingRejected(t *testing.T) {
+	assert := assert.New(t)
+	rejected := &ExchangeError{Kind: ErrOrderRejected, Op: "GetOrder", Err: fmt.Errorf("failed to get expected order status got cancelled")}
+	s := NewStateSvc(nil).NewState("BTC-USD", d("100"))
+	s.LastSaleTime = time.Now().Add(time.Minute * -121)
+	assert.False(s.Buy(context.Background(), pendingCbSvcMock{pendingErr: &PendingOrderError{OrderID: "GUID-1", Err: context.Canceled}}, d("100"), d("104")))
+	assert.Equal(decimal.Zero, s.AvailableFunds)
+
+	resolver := NewCoinbaseSvcMock()
+	resolver.Err = rejected
+	s.ResolvePending(context.Background(), resolver)
+	assert.Empty(s.PendingOrders)
+	assert.Equal(d("100"), s.AvailableFunds, "the funds of the buy are given back")
+	assert.Equal(decimal.Zero, s.NumberOwn)
+
+	resolver.Err = nil
+	resolver.TotalPurchased, resolver.BuyPrice = d("1"), d("104")
+	assert.True(s.Buy(context.Background(), resolver, d("100"), d("104")), "buying is not blocked")
+
+	// a rejected sell leaves the position as it was
+	s.PendingOrders = []PendingOrder{{ID: "GUID-2", Side: "sell", Trigger: "8% sell", Size: d("1"), Price: d("113")}}
+	resolver.Err = rejected
+	s.ResolvePending(context.Background(), resolver)
+	assert.Empty(s.PendingOrders)
+	assert.Equal(d("1"), s.NumberOwn)
+	assert.Equal(decimal.Zero, s.AvailableFunds)
+	assert.Equal(decimal.Zero, s.RealizedPnL)
+}
+
+//TestState_Overflow fills the state can't hold come back as errors, not panics, and leave the state as it was
+func TestState_Overflow(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	s := NewStateSvc(nil).NewState("SHIB-USD", d("100"))
+	s.LastSaleTime = time.Now().Add(time.Minute * -121)
+	cbSvcMock := NewCoinbaseSvcMock()
+	cbSvcMock.TotalPurchased, cbSvcMock.BuyPrice = d("90000000000"), d("2")
+	assert.NotPanics(func() {
+		assert.False(s.Buy(ctx, cbSvcMock, d("100"), d("104")))
+	})
+	assert.ErrorIs(s.OrderErr(), decimal.ErrOverflow)
+	assert.Equal(d("100"), s.AvailableFunds)
+	assert.Equal(decimal.Zero, s.NumberOwn)
+	assert.Empty(s.Trades)
+
+	//the realized P&L of the sale is out of range
+	s.AvailableFunds, s.NumberOwn, s.BuyPrice = decimal.Zero, d("1000000"), d("1")
+	s.RealizedPnL = d("90000000000")
+	assert.NotPanics(func() {
+		assert.False(s.Sell(ctx, cbSvcMock, d("90000")))
+	})
+	assert.ErrorIs(s.OrderErr(), decimal.ErrOverflow)
+	assert.Equal(d("1000000"), s.NumberOwn)
+	assert.Equal(decimal.Zero, s.AvailableFunds)
+	assert.Equal(d("90000000000"), s.RealizedPnL)
+
+	//the funds of a rejected pending buy can't be given back
+	s = NewStateSvc(nil).NewState("SHIB-USD", d("90000000000"))
+	s.PendingOrders = []PendingOrder{{ID: "GUID-1", Side: "buy", Trigger: "buy", Size: d("90000000000"), Price: d("2")}}
+	cbSvcMock.Err = &ExchangeError{Kind: ErrOrderRejected, Op: "GetOrder", Err: fmt.Errorf("failed to get expected order status got cancelled")}
+	assert.NotPanics(func() {
+		s.ResolvePending(ctx, cbSvcMock)
+	})
+	assert.ErrorIs(s.OrderErr(), decimal.ErrOverflow)
+	assert.Empty(s.PendingOrders)
+	assert.Equal(d("90000000000"), s.AvailableFunds)
+}
+
+func TestStateSvc_SaveLoad(t *testing.T) {
+	assert := assert.New(t)
+	stSvc := NewStateSvc(nil)
+	stSvc.Dir = t.TempDir()
+
+	s, err := stSvc.LoadState("BTC-USD", d("100"))
+	assert.Nil(err)
+	assert.Equal(d("100"), s.AvailableFunds)
+
+	s.NumberOwn = d("0.02116517")
+	s.AvailableFunds = decimal.Zero
+	s.Paused = true
+	s.PendingOrders = []PendingOrder{{ID: "GUID-1", Side: "sell"}}
+	assert.Nil(stSvc.SaveState(s))
+
+	loaded, err := stSvc.LoadState("BTC-USD", d("100"))
+	assert.Nil(err)
+	assert.Equal(d("0.02116517"), loaded.NumberOwn, "to the satoshi")
+	assert.Equal(decimal.Zero, loaded.AvailableFunds)
+	assert.True(loaded.Paused)
+	assert.Equal("GUID-1", loaded.PendingOrders[0].ID)
+	assert.Equal("USD", loaded.QuoteCurrency)
+
+	// state files written before funds were kept in the quote currency
+	legacy := `{"Product":"BTC-EUR","NumberOwn":0,"AvailableUSDFunds":250.5}`
+	assert.Nil(os.WriteFile(filepath.Join(stSvc.Dir, "BTC-EUR.json"), []byte(legacy), 0600))
+	loaded, err = stSvc.LoadState("BTC-EUR", d("100"))
+	assert.Nil(err)
+	assert.Equal(d("250.5"), loaded.AvailableFunds, "not the seed")
+	assert.Equal("EUR", loaded.QuoteCurrency)
+}
+
+func TestState_Value(t *testing.T) {
+	s := NewStateSvc(nil).NewState("ETH-BTC", d("0.5"))
+	assert.Equal(t, "BTC", s.QuoteCurrency)
+	value, err := s.Value(d("0.05"))
+	assert.Nil(t, err)
+	assert.Equal(t, d("0.5"), value)
+	s.AvailableFunds = decimal.Zero
+	s.NumberOwn = d("2")
+	value, err = s.Value(d("0.05"))
+	assert.Nil(t, err)
+	assert.Equal(t, d("0.1"), value)
+
+	//a position of a cheap token at a spike is out of range
+	s.NumberOwn = d("90000000000")
+	_, err = s.Value(d("2"))
+	assert.ErrorIs(t, err, decimal.ErrOverflow)
+	_, _, err = s.PnL(d("2"))
+	assert.ErrorIs(t, err, decimal.ErrOverflow)
+}
+
+type notifierMock struct {
+	events []notify.Event
+}
+
+func (n *notifierMock) Notify(ctx context.Context, e notify.Event) error {
+	n.events = append(n.events, e)
+	return nil
+}
+
+func TestState_Notify(t *testing.T) {
+	assert := assert.New(t)
+	n := &notifierMock{}
+	stSvc := NewStateSvc(nil)
+	stSvc.Notifier = n
+	stSvc.ErrorThreshold = 2
+	s := stSvc.NewState("BTC-USD", d("100"))
+
+	s.PrintStateChange("loop begin")
+	assert.Len(n.events, 0)
+
+	cbSvcMock := NewCoinbaseSvcMock()
+	cbSvcMock.TotalPurchased = d("2")
+	cbSvcMock.BuyPrice = d("50")
+	assert.True(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.Len(n.events, 1)
+	assert.Equal(notify.KindStateChange, n.events[0].Kind)
+	assert.Equal("buy", n.events[0].Trigger)
+	assert.Equal("BTC-USD", n.events[0].Product)
+
+	// only the threshold-th error in a row is notified
+	for i := 0; i < 3; i++ {
+		s.Heartbeat(fmt.Errorf("its broke"))
+	}
+	assert.Len(n.events, 2)
+	assert.Equal(notify.KindAPIErrors, n.events[1].Kind)
+	assert.Equal("its broke", n.events[1].Message)
+
+	s.Heartbeat(nil)
+	s.Heartbeat(fmt.Errorf("its broke"))
+	s.Heartbeat(fmt.Errorf("its broke"))
+	assert.Len(n.events, 3)
+}
+
+func TestState_PnL(t *testing.T) {
+	assert := assert.New(t)
+	s := NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+	s.NumberOwn = d("2")
+	s.BuyPrice = d("50")
+
+	realized, unrealized, err := s.PnL(d("60"))
+	assert.Nil(err)
+	assert.Equal(decimal.Zero, realized)
+	assert.Equal(d("20"), unrealized)
+
+	assert.True(s.Sell(context.Background(), NewCoinbaseSvcMock(), d("60")))
+	realized, unrealized, err = s.PnL(d("70"))
+	assert.Nil(err)
+	assert.Equal(d("20"), realized)
+	assert.Equal(decimal.Zero, unrealized)
+
+	// many small sales add up to the cent, 0.1 + 0.2 is 0.3
+	s = NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+	for _, profit := range []string{"0.1", "0.2"} {
+		s.NumberOwn = d("1")
+		s.BuyPrice = d("100")
+		assert.Nil(s.completeSale("8% sell", d("1"), d("108"), decimal.Zero, d("100"+profit[1:])))
+	}
+	realized, _, _ = s.PnL(d("100"))
+	assert.Equal(d("0.3"), realized)
+}
+
+//TestState_PnLSeedBelowBalance the bot trades its seed, other funds on the account are not profit
+func TestState_PnLSeedBelowBalance(t *testing.T) {
+	assert := assert.New(t)
+	sim := proclient.NewSimulator()
+	defer sim.Close()
+	sim.SetFee(0)
+	sim.SetBalance("USD", d("1000"))
+	sim.SetBook("BTC-USD", d("99"), d("100"))
+	cbSvc := NewCoinbaseSvc(proclient.NewExchange(sim.Client()), time.Second*5)
+	s := NewStateSvc(nil).NewState("BTC-USD", d("100"))
+	s.LastSaleTime = time.Now().Add(time.Minute * -121)
+
+	assert.True(s.Buy(context.Background(), cbSvc, d("96"), d("100")))
+	assert.Equal(d("1"), s.NumberOwn)
+	sim.SetBook("BTC-USD", d("110"), d("111"))
+	assert.True(s.Sell(context.Background(), cbSvc, d("110")))
+
+	assert.Equal(d("1010"), sim.Balance("USD"))
+	assert.Equal(d("110"), s.AvailableFunds, "the proceeds of the sale, not the balance")
+	realized, _, err := s.PnL(d("110"))
+	assert.Nil(err)
+	assert.Equal(d("10"), realized)
+}
+
+func TestState_Cooldown(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	stSvc := NewStateSvc(nil)
+	stSvc.Clock = clock
+	s := stSvc.NewState("BTC-USD", decimal.Zero)
+	s.NumberOwn = d("1")
+	s.BuyPrice = d("100")
+
+	cbSvc := NewCoinbaseSvcMock()
+	cbSvc.TotalPurchased = d("1")
+	cbSvc.BuyPrice = d("104")
+	assert.True(s.Sell(context.Background(), cbSvc, d("110")))
+	assert.Equal(start, s.LastSaleTime, "the sale is stamped with the state clock")
+	assert.Equal(start, s.Trades[0].Time)
+
+	clock.Advance(time.Hour*2 - time.Second)
+	assert.False(s.Buy(context.Background(), cbSvc, d("100"), d("104")), "still in the cooldown")
+	clock.Advance(time.Second * 2)
+	assert.True(s.Buy(context.Background(), cbSvc, d("100"), d("104")))
+	assert.Equal(start.Add(time.Hour*2+time.Second), s.Trades[1].Time)
+}
+
+//blockingCbSvc holds every sale until release is closed
+type blockingCbSvc struct {
+	CoinbaseSvcMock
+	selling chan struct{}
+	release chan struct{}
+}
+
+func (svc blockingCbSvc) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	svc.selling <- struct{}{}
+	<-svc.release
+	return svc.CoinbaseSvcMock.Sell(ctx, product, numberOwn, sellPrice)
+}
+
+//TestState_OrderUnlocked the state lock is not held while an order is with the exchange, no second order is taken meanwhile
+func TestState_OrderUnlocked(t *testing.T) {
+	assert := assert.New(t)
+	s := NewStateSvc(nil).NewState("BTC-USD", decimal.Zero)
+	s.NumberOwn = d("1")
+	s.BuyPrice = d("100")
+	cbSvc := blockingCbSvc{selling: make(chan struct{}), release: make(chan struct{})}
+
+	sold := make(chan bool)
+	go func() {
+		sold <- s.Sell(context.Background(), cbSvc, d("110"))
+	}()
+	<-cbSvc.selling
+
+	s.Guard(func() {
+		s.Paused = true
+	})
+	assert.False(s.Sell(context.Background(), cbSvc, d("110")), "a sale is already with the exchange")
+	assert.Equal("BTC-USD is waiting on an order", s.ForceSell(context.Background(), cbSvc).Error())
+
+	close(cbSvc.release)
+	assert.True(<-sold)
+	assert.Equal(decimal.Zero, s.NumberOwn)
+	assert.Equal(d("110"), s.AvailableFunds)
+	assert.True(s.Paused, "changes made during the sale are kept")
+}
@@ -0,0 +1,98 @@
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//Strategy holds the rules for when to buy, lock and sell.
+//A zero Strategy on State falls back to DefaultStrategy.
+type Strategy struct {
+	BuyGrowth  float64       //growth between open and close needed to buy
+	Cooldown   time.Duration //time to wait after a sale before buying again
+	LockGrowth float64       //growth over the buy price that sets the first lock
+	LockStep   float64       //growth over the lock price that raises the lock
+	TakeProfit float64       //growth over the buy price that sells
+	StopLoss   float64       //loss under the buy price that sells
+}
+
+func DefaultStrategy() Strategy {
+	return Strategy{
+		BuyGrowth:  0.03,
+		Cooldown:   time.Hour * 2,
+		LockGrowth: 0.03,
+		LockStep:   0.01,
+		TakeProfit: 0.08,
+		StopLoss:   0.10,
+	}
+}
+
+func (st Strategy) Validate() error {
+	for name, p := range map[string]float64{
+		"buy_growth":  st.BuyGrowth,
+		"lock_growth": st.LockGrowth,
+		"lock_step":   st.LockStep,
+		"take_profit": st.TakeProfit,
+		"stop_loss":   st.StopLoss,
+	} {
+		if p <= 0 {
+			return fmt.Errorf("strategy %s must be positive, got %f", name, p)
+		}
+	}
+	if st.StopLoss >= 1 {
+		return fmt.Errorf("strategy stop_loss must be less than 1, got %f", st.StopLoss)
+	}
+	if st.Cooldown < 0 {
+		return fmt.Errorf("strategy cooldown can not be negative, got %s", st.Cooldown)
+	}
+	return nil
+}
+
+type strategyJSON struct {
+	BuyGrowth  float64 `json:"buy_growth"`
+	Cooldown   string  `json:"cooldown"`
+	LockGrowth float64 `json:"lock_growth"`
+	LockStep   float64 `json:"lock_step"`
+	TakeProfit float64 `json:"take_profit"`
+	StopLoss   float64 `json:"stop_loss"`
+}
+
+func (st Strategy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strategyJSON{
+		BuyGrowth:  st.BuyGrowth,
+		Cooldown:   st.Cooldown.String(),
+		LockGrowth: st.LockGrowth,
+		LockStep:   st.LockStep,
+		TakeProfit: st.TakeProfit,
+		StopLoss:   st.StopLoss,
+	})
+}
+
+//UnmarshalJSON only overwrites the fields present in data, cooldown is a duration string like "2h"
+func (st *Strategy) UnmarshalJSON(data []byte) error {
+	j := strategyJSON{
+		BuyGrowth:  st.BuyGrowth,
+		Cooldown:   st.Cooldown.String(),
+		LockGrowth: st.LockGrowth,
+		LockStep:   st.LockStep,
+		TakeProfit: st.TakeProfit,
+		StopLoss:   st.StopLoss,
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	cooldown, err := time.ParseDuration(j.Cooldown)
+	if err != nil {
+		return err
+	}
+	*st = Strategy{
+		BuyGrowth:  j.BuyGrowth,
+		Cooldown:   cooldown,
+		LockGrowth: j.LockGrowth,
+		LockStep:   j.LockStep,
+		TakeProfit: j.TakeProfit,
+		StopLoss:   j.StopLoss,
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrategy_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy func(st *Strategy)
+		wantErr  bool
+	}{
+		{name: "Happy Path. Default strategy is valid.", strategy: func(st *Strategy) {}, wantErr: false},
+		{name: "Sad Path. Zero buy growth.", strategy: func(st *Strategy) { st.BuyGrowth = 0 }, wantErr: true},
+		{name: "Sad Path. Negative take profit.", strategy: func(st *Strategy) { st.TakeProfit = -0.08 }, wantErr: true},
+		{name: "Sad Path. Stop loss of 100%.", strategy: func(st *Strategy) { st.StopLoss = 1 }, wantErr: true},
+		{name: "Sad Path. Negative cooldown.", strategy: func(st *Strategy) { st.Cooldown = -time.Minute }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := DefaultStrategy()
+			tt.strategy(&st)
+			if err := st.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Strategy.Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStrategy_JSON(t *testing.T) {
+	assert := assert.New(t)
+	b, err := json.Marshal(DefaultStrategy())
+	assert.Nil(err)
+	assert.JSONEq(`{"buy_growth":0.03,"cooldown":"2h0m0s","lock_growth":0.03,"lock_step":0.01,"take_profit":0.08,"stop_loss":0.1}`, string(b))
+
+	st := DefaultStrategy()
+	assert.Nil(json.Unmarshal([]byte(`{"cooldown":"45m","lock_step":0.02}`), &st))
+	assert.Equal(time.Minute*45, st.Cooldown)
+	assert.Equal(0.02, st.LockStep)
+	assert.Equal(0.08, st.TakeProfit)
+
+	assert.NotNil(json.Unmarshal([]byte(`{"cooldown":"soon"}`), &st))
+}
+
+func TestState_BuyPaused(t *testing.T) {
+	assert := assert.New(t)
+	cbSvcMock := NewCoinbaseSvcMock()
+	cbSvcMock.TotalPurchased = d("1")
+	cbSvcMock.BuyPrice = d("103")
+	s := &State{
+		AvailableFunds: d("100"),
+		LastSaleTime:   time.Now().Add(time.Minute * -121),
+		Paused:         true,
+	}
+
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.Equal(d("100"), s.AvailableFunds)
+
+	s.Paused = false
+	assert.True(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.Equal(decimal.Zero, s.AvailableFunds)
+	assert.Len(s.Trades, 1)
+}
+
+func TestState_PauseFor(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	stSvc := NewStateSvc(nil)
+	stSvc.Clock = clock
+	s := stSvc.NewState("BTC-USD", d("100"))
+	cbSvcMock := NewCoinbaseSvcMock()
+	cbSvcMock.TotalPurchased = d("1")
+	cbSvcMock.BuyPrice = d("103")
+
+	s.PauseFor(time.Hour)
+	assert.Equal(start.Add(time.Hour), s.PausedUntil)
+	clock.Advance(time.Hour - time.Second)
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")), "still paused")
+	assert.True(s.Paused)
+
+	clock.Advance(time.Second)
+	assert.True(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")), "the error pause is over")
+	assert.False(s.Paused)
+	assert.True(s.PausedUntil.IsZero())
+
+	// a pause by hand is kept until it is resumed by hand
+	s.Pause(true)
+	s.PauseFor(time.Minute)
+	assert.True(s.PausedUntil.IsZero())
+	clock.Advance(time.Hour * 24)
+	s.AvailableFunds = d("100")
+	s.NumberOwn = decimal.Zero
+	s.SetLastSaleTime(start)
+	assert.False(s.Buy(context.Background(), cbSvcMock, d("100"), d("104")))
+	assert.True(s.Paused)
+
+	// resuming by hand ends an error pause too
+	s.Pause(false)
+	s.PauseFor(time.Minute)
+	s.Pause(false)
+	assert.False(s.Paused)
+	assert.True(s.PausedUntil.IsZero())
+}
@@ -1,23 +1,49 @@
 package svc
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type TimeSvcInterface interface {
-	GetStartAndEnd(t time.Time) (time.Time, time.Time, time.Time)
+	GetStartAndEnd(ctx context.Context, t time.Time) (time.Time, time.Time, time.Time, error)
 }
 
+//TimeSvc schedules the main loop, a tick comes every Interval.
+//With Align set the tick comes on the next Align boundary instead,
+//a 5m Align ticks at :00, :05, :10 right when a candle closes.
 type TimeSvc struct {
+	Clock    Clock
+	Interval time.Duration
+	Align    time.Duration
+	Window   time.Duration //market conditions are read from Window before the tick
 }
 
 func NewTimeSvc() TimeSvc {
-	return TimeSvc{}
+	return TimeSvc{
+		Clock:    RealClock{},
+		Interval: time.Second * 20,
+		Window:   time.Hour * 2,
+	}
 }
 
-func (svc TimeSvc) GetStartAndEnd(t time.Time) (time.Time, time.Time, time.Time) {
-	time.Sleep(time.Second * 20)
-	return time.Now(), time.Now().Add(time.Hour * -2), time.Now()
+//next is how long to wait from now for the next tick
+func (svc TimeSvc) next(now time.Time) time.Duration {
+	if svc.Align <= 0 {
+		return svc.Interval
+	}
+	return now.Truncate(svc.Align).Add(svc.Align).Sub(now)
+}
+
+//GetStartAndEnd waits for the next tick, it returns early with ctx.Err() when ctx is done
+func (svc TimeSvc) GetStartAndEnd(ctx context.Context, t time.Time) (time.Time, time.Time, time.Time, error) {
+	if err := svc.Clock.Sleep(ctx, svc.next(svc.Clock.Now())); err != nil {
+		return t, t, t, err
+	}
+	now := svc.Clock.Now()
+	return now, now.Add(-svc.Window), now, nil
 }
 
 func (svc TimeSvc) SetInitialTime() time.Time {
-	return time.Now()
+	return svc.Clock.Now()
 }
@@ -1,6 +1,9 @@
 package svc
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type TimeSvcMock struct {
 }
@@ -9,8 +12,11 @@ func NewTimeSvcMock() TimeSvcMock {
 	return TimeSvcMock{}
 }
 
-func (svc TimeSvcMock) GetStartAndEnd(t time.Time) (time.Time, time.Time, time.Time) {
-	return t.Add(time.Minute * 20), t.Add(time.Minute * 20), t.Add(time.Hour * 2)
+func (svc TimeSvcMock) GetStartAndEnd(ctx context.Context, t time.Time) (time.Time, time.Time, time.Time, error) {
+	if ctx.Err() != nil {
+		return t, t, t, ctx.Err()
+	}
+	return t.Add(time.Minute * 20), t.Add(time.Minute * 20), t.Add(time.Hour * 2), nil
 }
 
 func (svc TimeSvcMock) SetInitialTime() time.Time {
@@ -0,0 +1,73 @@
+package svc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeSvc_GetStartAndEnd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	begin := time.Now()
+	_, _, _, err := NewTimeSvc().GetStartAndEnd(ctx, begin)
+	if err != context.Canceled {
+		t.Errorf("TimeSvc.GetStartAndEnd() err = %v, want %v", err, context.Canceled)
+	}
+	if time.Since(begin) > time.Second {
+		t.Errorf("TimeSvc.GetStartAndEnd() did not return when the context was cancelled")
+	}
+}
+
+func TestTimeSvc_next(t *testing.T) {
+	base := time.Date(2021, time.August, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		align time.Duration
+		now   time.Time
+		want  time.Duration
+	}{
+		{name: "Happy Path. Interval without alignment.", now: base.Add(time.Second * 7), want: time.Second * 20},
+		{name: "Happy Path. Next 5m boundary.", align: time.Minute * 5, now: base.Add(time.Minute*2 + time.Second*30), want: time.Minute*2 + time.Second*30},
+		{name: "Happy Path. On a boundary waits a whole candle.", align: time.Minute * 5, now: base, want: time.Minute * 5},
+		{name: "Happy Path. Next hour.", align: time.Hour, now: base.Add(time.Minute * 45), want: time.Minute * 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewTimeSvc()
+			svc.Align = tt.align
+			assert.Equal(t, tt.want, svc.next(tt.now))
+		})
+	}
+}
+
+func TestTimeSvc_GetStartAndEndClock(t *testing.T) {
+	start := time.Date(2021, time.August, 1, 12, 1, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	svc := NewTimeSvc()
+	svc.Clock = clock
+	svc.Align = time.Minute * 5
+
+	type tick struct {
+		now, start, end time.Time
+		err             error
+	}
+	ticks := make(chan tick, 1)
+	go func() {
+		now, s, e, err := svc.GetStartAndEnd(context.Background(), start)
+		ticks <- tick{now, s, e, err}
+	}()
+	waitSleepers(t, clock, 1)
+	clock.Advance(time.Minute * 4)
+
+	got := <-ticks
+	boundary := time.Date(2021, time.August, 1, 12, 5, 0, 0, time.UTC)
+	assert.Nil(t, got.err)
+	assert.Equal(t, boundary, got.now)
+	assert.Equal(t, boundary.Add(time.Hour*-2), got.start)
+	assert.Equal(t, boundary, got.end)
+	assert.Equal(t, boundary, svc.SetInitialTime())
+}
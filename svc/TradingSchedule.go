@@ -0,0 +1,166 @@
+package svc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//TradingSchedule limits when Buy may open a position, sells are never limited.
+//Buying is allowed inside any Active window, or always when there are none, and never inside a Blackout.
+type TradingSchedule struct {
+	Active    []Window
+	Blackouts []Blackout
+	Location  *time.Location //windows are read in this time zone, UTC when nil
+}
+
+//Window is a cron-like weekly window, see ParseWindow
+type Window struct {
+	Spec  string
+	Days  [7]bool       //indexed by time.Weekday
+	Start time.Duration //since midnight
+	End   time.Duration //since midnight, at or before Start the window runs past midnight
+}
+
+//Blackout is a date range without buys, e.g. around a macro announcement or an exchange maintenance
+type Blackout struct {
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+//ParseWindow reads "<days> <start>-<end>".
+//Days are * or a comma list of days and day ranges, times are HH:MM and the end can be 24:00.
+//	mon-fri 09:30-16:00
+//	sat,sun 10:00-12:00
+//	* 22:00-02:00
+func ParseWindow(spec string) (Window, error) {
+	w := Window{Spec: spec}
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) != 2 {
+		return w, fmt.Errorf("window %q must look like mon-fri 09:30-16:00", spec)
+	}
+	if err := parseDays(fields[0], &w.Days); err != nil {
+		return w, fmt.Errorf("window %q %s", spec, err.Error())
+	}
+	times := strings.SplitN(fields[1], "-", 2)
+	if len(times) != 2 {
+		return w, fmt.Errorf("window %q hours must look like 09:30-16:00", spec)
+	}
+	var err error
+	if w.Start, err = parseClock(times[0]); err != nil {
+		return w, fmt.Errorf("window %q %s", spec, err.Error())
+	}
+	if w.End, err = parseClock(times[1]); err != nil {
+		return w, fmt.Errorf("window %q %s", spec, err.Error())
+	}
+	if w.Start == time.Hour*24 {
+		return w, fmt.Errorf("window %q can not start at 24:00", spec)
+	}
+	return w, nil
+}
+
+func parseDays(v string, days *[7]bool) error {
+	if v == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+	for _, part := range strings.Split(v, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		//fri-mon wraps over the weekend
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseClock(v string) (time.Duration, error) {
+	parts := strings.SplitN(v, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("time %q must look like 09:30", v)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("time %q must look like 09:30", v)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("time %q must be between 00:00 and 24:00", v)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+//Contains is true when t, in its own location, is inside the window
+func (w Window) Contains(t time.Time) bool {
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && since >= w.Start && since < w.End
+	}
+	//past midnight, the window belongs to the day it started on
+	return (w.Days[day] && since >= w.Start) || (w.Days[(day+6)%7] && since < w.End)
+}
+
+//Contains is true when t is in [Start, End)
+func (b Blackout) Contains(t time.Time) bool {
+	return !t.Before(b.Start) && t.Before(b.End)
+}
+
+//Allows is false with the reason when no buy may be opened at t
+func (ts TradingSchedule) Allows(t time.Time) (bool, string) {
+	if ts.Location != nil {
+		t = t.In(ts.Location)
+	} else {
+		t = t.UTC()
+	}
+	for _, b := range ts.Blackouts {
+		if b.Contains(t) {
+			return false, fmt.Sprintf("blackout %s until %s", b.Reason, b.End.Format(time.RFC3339))
+		}
+	}
+	if len(ts.Active) == 0 {
+		return true, ""
+	}
+	for _, w := range ts.Active {
+		if w.Contains(t) {
+			return true, ""
+		}
+	}
+	return false, "outside the active windows"
+}
+
+func (ts TradingSchedule) Validate() error {
+	for _, b := range ts.Blackouts {
+		if !b.End.After(b.Start) {
+			return fmt.Errorf("blackout %s must end after it starts", b.Reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package svc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		days    []time.Weekday
+		start   time.Duration
+		end     time.Duration
+		wantErr bool
+	}{
+		{name: "Happy Path. Weekdays.", spec: "mon-fri 09:30-16:00", days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, start: time.Hour*9 + time.Minute*30, end: time.Hour * 16},
+		{name: "Happy Path. Day list.", spec: "sat,sun 10:00-12:00", days: []time.Weekday{time.Saturday, time.Sunday}, start: time.Hour * 10, end: time.Hour * 12},
+		{name: "Happy Path. Day range over the weekend.", spec: "Fri-Mon 00:00-24:00", days: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}, end: time.Hour * 24},
+		{name: "Happy Path. Every day past midnight.", spec: "* 22:00-02:00", days: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, start: time.Hour * 22, end: time.Hour * 2},
+		{name: "Sad Path. Missing hours.", spec: "mon-fri", wantErr: true},
+		{name: "Sad Path. Unknown day.", spec: "mon-fry 09:30-16:00", wantErr: true},
+		{name: "Sad Path. Bad time.", spec: "mon 09:60-16:00", wantErr: true},
+		{name: "Sad Path. Starts at 24:00.", spec: "mon 24:00-01:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := ParseWindow(tt.spec)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			var days [7]bool
+			for _, d := range tt.days {
+				days[d] = true
+			}
+			assert.Equal(t, days, w.Days)
+			assert.Equal(t, tt.start, w.Start)
+			assert.Equal(t, tt.end, w.End)
+		})
+	}
+}
+
+func TestTradingSchedule_Allows(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database", err)
+	}
+	market, _ := ParseWindow("mon-fri 09:30-16:00")
+	night, _ := ParseWindow("fri 22:00-02:00")
+	fomc := Blackout{
+		Start:  time.Date(2021, time.September, 22, 13, 0, 0, 0, ny),
+		End:    time.Date(2021, time.September, 22, 15, 0, 0, 0, ny),
+		Reason: "fomc",
+	}
+	tests := []struct {
+		name     string
+		schedule TradingSchedule
+		t        time.Time
+		want     bool
+	}{
+		{name: "Happy Path. No schedule always allows.", t: time.Date(2021, time.September, 25, 3, 0, 0, 0, time.UTC), want: true},
+		{name: "Happy Path. Inside the window.", schedule: TradingSchedule{Active: []Window{market}, Location: ny}, t: time.Date(2021, time.September, 21, 10, 0, 0, 0, ny), want: true},
+		{name: "Happy Path. Window read in the location.", schedule: TradingSchedule{Active: []Window{market}, Location: ny}, t: time.Date(2021, time.September, 21, 14, 0, 0, 0, time.UTC), want: true},
+		{name: "Happy Path. After midnight belongs to the day before.", schedule: TradingSchedule{Active: []Window{night}}, t: time.Date(2021, time.September, 25, 1, 0, 0, 0, time.UTC), want: true},
+		{name: "Sad Path. Before the window.", schedule: TradingSchedule{Active: []Window{market}, Location: ny}, t: time.Date(2021, time.September, 21, 9, 29, 0, 0, ny)},
+		{name: "Sad Path. Window end is exclusive.", schedule: TradingSchedule{Active: []Window{market}, Location: ny}, t: time.Date(2021, time.September, 21, 16, 0, 0, 0, ny)},
+		{name: "Sad Path. Weekend.", schedule: TradingSchedule{Active: []Window{market}, Location: ny}, t: time.Date(2021, time.September, 25, 10, 0, 0, 0, ny)},
+		{name: "Sad Path. After midnight on the wrong day.", schedule: TradingSchedule{Active: []Window{night}}, t: time.Date(2021, time.September, 24, 1, 0, 0, 0, time.UTC)},
+		{name: "Sad Path. Blackout inside the window.", schedule: TradingSchedule{Active: []Window{market}, Blackouts: []Blackout{fomc}, Location: ny}, t: time.Date(2021, time.September, 22, 14, 0, 0, 0, ny)},
+		{name: "Happy Path. Blackout end is exclusive.", schedule: TradingSchedule{Active: []Window{market}, Blackouts: []Blackout{fomc}, Location: ny}, t: time.Date(2021, time.September, 22, 15, 0, 0, 0, ny), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := tt.schedule.Allows(tt.t)
+			assert.Equal(t, tt.want, ok)
+			assert.Equal(t, tt.want, reason == "", reason)
+		})
+	}
+}
+
+func TestState_BuySchedule(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2021, time.September, 22, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	stSvc := NewStateSvc(nil)
+	stSvc.Clock = clock
+	s := stSvc.NewState("BTC-USD", d("100"))
+	s.SetSchedule(TradingSchedule{Blackouts: []Blackout{{Start: start, End: start.Add(time.Hour), Reason: "maintenance"}}})
+
+	ok, reason := s.BuyAllowed()
+	assert.False(ok)
+	assert.Contains(reason, "maintenance")
+	cbSvc := NewCoinbaseSvcMock()
+	cbSvc.TotalPurchased = d("1")
+	cbSvc.BuyPrice = d("104")
+	assert.False(s.Buy(context.Background(), cbSvc, d("100"), d("104")), "no buys in a blackout")
+	assert.Equal(decimal.Zero, s.NumberOwn)
+
+	clock.Advance(time.Hour)
+	assert.True(s.Buy(context.Background(), cbSvc, d("100"), d("104")))
+
+	clock.Set(start.Add(time.Hour * 24))
+	s.SetSchedule(TradingSchedule{Blackouts: []Blackout{{Start: start.Add(time.Hour * 24), End: start.Add(time.Hour * 25), Reason: "maintenance"}}})
+	assert.True(s.Sell(context.Background(), cbSvc, d("113")), "sells are not limited by the schedule")
+}
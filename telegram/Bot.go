@@ -0,0 +1,256 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/svc"
+)
+
+//DefaultBaseURL is the Telegram Bot API
+const DefaultBaseURL = "https://api.telegram.org"
+
+const help = `/status [product] print the state
+/pnl [product] realized and unrealized P&L
+/pause [product] stop buying
+/resume [product] start buying
+/sell [product] sell the position at the last price`
+
+//Bot answers commands from the chats in Allowed, messages from any other chat are ignored.
+//The product can be left out when the bot trades a single product.
+//
+//Orders placed through the bot run on OrderCtx, like the api.
+type Bot struct {
+	BaseURL     string
+	Token       string
+	Allowed     map[int64]bool
+	CbSvc       svc.CoinbaseSvcInterface
+	States      map[string]*svc.State
+	OrderCtx    context.Context
+	Client      *http.Client
+	PollTimeout time.Duration //long poll timeout of getUpdates
+	offset      int64
+}
+
+func NewBot(token string, allowed []int64, cbSvc svc.CoinbaseSvcInterface, states ...*svc.State) *Bot {
+	b := &Bot{
+		BaseURL:     DefaultBaseURL,
+		Token:       token,
+		Allowed:     map[int64]bool{},
+		CbSvc:       cbSvc,
+		States:      map[string]*svc.State{},
+		OrderCtx:    context.Background(),
+		Client:      http.DefaultClient,
+		PollTimeout: time.Second * 30,
+	}
+	for _, id := range allowed {
+		b.Allowed[id] = true
+	}
+	for _, st := range states {
+		b.States[st.Product] = st
+	}
+	return b
+}
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+//Run polls for commands until ctx is done
+func (b *Bot) Run(ctx context.Context) error {
+	if b.Token == "" {
+		return fmt.Errorf("telegram token is required")
+	}
+	if len(b.Allowed) == 0 {
+		return fmt.Errorf("telegram allowed chats are required")
+	}
+	log.Printf("telegram bot answering %d chats", len(b.Allowed))
+	for {
+		updates, err := b.getUpdates(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("failed to get telegram updates %s", err.Error())
+			select {
+			case <-time.After(time.Second * 5):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+		for _, u := range updates {
+			b.offset = u.UpdateID + 1
+			if u.Message == nil {
+				continue
+			}
+			b.handle(ctx, u.Message)
+		}
+	}
+}
+
+func (b *Bot) handle(ctx context.Context, m *message) {
+	if !b.Allowed[m.Chat.ID] {
+		log.Printf("telegram command from chat %d ignored, it is not allowed", m.Chat.ID)
+		return
+	}
+	reply := b.command(ctx, m.Text)
+	if err := b.sendMessage(ctx, m.Chat.ID, reply); err != nil {
+		log.Printf("failed to send telegram reply %s", err.Error())
+	}
+}
+
+//command runs the text of a message and returns the reply
+func (b *Bot) command(ctx context.Context, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return help
+	}
+	// commands in groups are sent as /status@botname
+	cmd := strings.SplitN(fields[0], "@", 2)[0]
+	st, err := b.state(fields[1:])
+	if err != nil {
+		return err.Error()
+	}
+
+	switch cmd {
+	case "/status":
+		var out string
+		st.Guard(func() {
+			out = st.String()
+		})
+		return out
+	case "/pnl":
+		lastPrice, err := b.CbSvc.GetLastPrice(ctx, st.Product)
+		if err != nil {
+			return fmt.Sprintf("failed to get last price %s", err.Error())
+		}
+		var realized, unrealized decimal.Decimal
+		st.Guard(func() {
+			realized, unrealized, err = st.PnL(lastPrice)
+		})
+		if err != nil {
+			return fmt.Sprintf("failed to get pnl %s", err.Error())
+		}
+		return fmt.Sprintf("%s realized %s unrealized %s at %s", st.Product, realized, unrealized, lastPrice)
+	case "/pause", "/resume":
+		paused := cmd == "/pause"
+		st.Guard(func() {
+			st.Pause(paused)
+			st.PrintStateChange(fmt.Sprintf("telegram paused %t", paused))
+		})
+		return fmt.Sprintf("%s paused %t", st.Product, paused)
+	case "/sell":
+		if err := st.ForceSell(b.OrderCtx, b.CbSvc); err != nil {
+			return fmt.Sprintf("failed to sell %s", err.Error())
+		}
+		var out string
+		st.Guard(func() {
+			out = st.String()
+		})
+		return out
+	}
+	return help
+}
+
+//state resolves the product argument, it may be left out when there is one product
+func (b *Bot) state(args []string) (*svc.State, error) {
+	if len(args) == 0 {
+		if len(b.States) == 1 {
+			for _, st := range b.States {
+				return st, nil
+			}
+		}
+		return nil, fmt.Errorf("product is required, one of %s", strings.Join(b.products(), ", "))
+	}
+	st, ok := b.States[strings.ToUpper(args[0])]
+	if !ok {
+		return nil, fmt.Errorf("unknown product %q", args[0])
+	}
+	return st, nil
+}
+
+func (b *Bot) products() []string {
+	products := make([]string, 0, len(b.States))
+	for p := range b.States {
+		products = append(products, p)
+	}
+	sort.Strings(products)
+	return products
+}
+
+func (b *Bot) getUpdates(ctx context.Context) ([]update, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.FormatInt(b.offset, 10))
+	params.Set("timeout", strconv.Itoa(int(b.PollTimeout.Seconds())))
+	params.Set("allowed_updates", `["message"]`)
+	var updates []update
+	err := b.call(ctx, http.MethodGet, "getUpdates?"+params.Encode(), nil, &updates)
+	return updates, err
+}
+
+func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) error {
+	return b.call(ctx, http.MethodPost, "sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
+
+func (b *Bot) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/bot%s/%s", b.BaseURL, b.Token, path), &reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		// the token is part of the url, keep it out of the logs
+		return errors.New(strings.ReplaceAll(err.Error(), b.Token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s failed with status %d %s", strings.SplitN(path, "?", 2)[0], resp.StatusCode, r.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
@@ -0,0 +1,191 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JasonWBrown/decimal"
+	"github.com/JasonWBrown/svc"
+	"github.com/stretchr/testify/assert"
+)
+
+type cbSvcFake struct {
+	lastPrice decimal.Decimal
+	err       error
+}
+
+func (f *cbSvcFake) Sell(ctx context.Context, product string, numberOwn, sellPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if f.err != nil {
+		return numberOwn, decimal.Zero, f.err
+	}
+	funds, err := numberOwn.Mul(sellPrice)
+	return decimal.Zero, funds, err
+}
+
+func (f *cbSvcFake) Buy(ctx context.Context, product string, buyPrice, availablefunds decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+func (f *cbSvcFake) ResumeOrder(ctx context.Context, product, side, id string) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+func (f *cbSvcFake) GetLastPrice(ctx context.Context, product string) (decimal.Decimal, error) {
+	return f.lastPrice, f.err
+}
+
+func (f *cbSvcFake) GetMarketConditions(ctx context.Context, product string, start, end time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	return decimal.Zero, decimal.Zero, fmt.Errorf("not implemented")
+}
+
+type sent struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+//fakeTelegram serves the queued messages to getUpdates once, and records sendMessage
+type fakeTelegram struct {
+	mu      sync.Mutex
+	token   string
+	updates []update
+	sent    []sent
+	replies chan sent
+}
+
+func newFakeTelegram(token string, messages ...message) *fakeTelegram {
+	f := &fakeTelegram{token: token, replies: make(chan sent, 10)}
+	for i, m := range messages {
+		m := m
+		f.updates = append(f.updates, update{UpdateID: int64(i + 1), Message: &m})
+	}
+	return f
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.URL.Path, "/bot"+f.token+"/") {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response{OK: false, Description: "Unauthorized"})
+		return
+	}
+
+	var result interface{} = true
+	switch strings.TrimPrefix(r.URL.Path, "/bot"+f.token+"/") {
+	case "getUpdates":
+		var offset int64
+		fmt.Sscan(r.URL.Query().Get("offset"), &offset)
+		updates := []update{}
+		for _, u := range f.updates {
+			if u.UpdateID >= offset {
+				updates = append(updates, u)
+			}
+		}
+		result = updates
+	case "sendMessage":
+		var s sent
+		json.NewDecoder(r.Body).Decode(&s)
+		f.sent = append(f.sent, s)
+		f.replies <- s
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+	b, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(response{OK: true, Result: b})
+}
+
+func chat(id int64, text string) message {
+	m := message{Text: text}
+	m.Chat.ID = id
+	return m
+}
+
+func TestBot_Run(t *testing.T) {
+	assert := assert.New(t)
+	state := svc.NewStateSvc(nil).NewState("BTC-USD", decimal.NewFromInt(100))
+	fake := newFakeTelegram("secret",
+		chat(666, "/pause"),
+		chat(42, "/status"),
+		chat(42, "/pause@cryptobot"),
+	)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	bot := NewBot("secret", []int64{42}, &cbSvcFake{}, state)
+	bot.BaseURL = server.URL
+	bot.PollTimeout = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bot.Run(ctx) }()
+
+	var replies []sent
+	for len(replies) < 2 {
+		select {
+		case s := <-fake.replies:
+			replies = append(replies, s)
+		case <-time.After(time.Second * 5):
+			t.Fatal("no reply from the bot")
+		}
+	}
+	cancel()
+	assert.Nil(<-done)
+
+	// chat 666 is not allowed and gets no reply
+	assert.Equal(int64(42), replies[0].ChatID)
+	assert.Contains(replies[0].Text, "Product:BTC-USD")
+	assert.Equal("BTC-USD paused true", replies[1].Text)
+	assert.True(state.Paused)
+	assert.Len(fake.sent, 2, "updates are only handled once")
+}
+
+func TestBot_command(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		numberOwn decimal.Decimal
+		buyPrice  decimal.Decimal
+		realized  decimal.Decimal
+		cbSvc     *cbSvcFake
+		want      string
+	}{
+		{name: "Happy Path. P&L of an open position.", text: "/pnl", numberOwn: decimal.NewFromInt(2), buyPrice: decimal.NewFromInt(40), realized: decimal.RequireFromString("12.5"), cbSvc: &cbSvcFake{lastPrice: decimal.NewFromInt(50)}, want: "BTC-USD realized 12.5 unrealized 20 at 50"},
+		{name: "Happy Path. P&L under a cent is not rounded away.", text: "/pnl", numberOwn: decimal.NewFromInt(2), buyPrice: decimal.RequireFromString("0.065"), realized: decimal.RequireFromString("0.0012"), cbSvc: &cbSvcFake{lastPrice: decimal.RequireFromString("0.0655")}, want: "BTC-USD realized 0.0012 unrealized 0.001 at 0.0655"},
+		{name: "Happy Path. Product is case insensitive.", text: "/pnl btc-usd", cbSvc: &cbSvcFake{lastPrice: decimal.NewFromInt(50)}, want: "BTC-USD realized 0 unrealized 0 at 50"},
+		{name: "Happy Path. Resume buying.", text: "/resume", cbSvc: &cbSvcFake{}, want: "BTC-USD paused false"},
+		{name: "Happy Path. Sell the position.", text: "/sell", numberOwn: decimal.NewFromInt(2), buyPrice: decimal.NewFromInt(40), cbSvc: &cbSvcFake{lastPrice: decimal.NewFromInt(50)}, want: "AvailableFunds:100 "},
+		{name: "Sad Path. Nothing to sell.", text: "/sell", cbSvc: &cbSvcFake{lastPrice: decimal.NewFromInt(50)}, want: "failed to sell no position to sell for BTC-USD"},
+		{name: "Sad Path. Last price fails.", text: "/pnl", cbSvc: &cbSvcFake{err: fmt.Errorf("its broke")}, want: "failed to get last price its broke"},
+		{name: "Sad Path. Unknown product.", text: "/status ETH-USD", cbSvc: &cbSvcFake{}, want: `unknown product "ETH-USD"`},
+		{name: "Sad Path. Unknown command.", text: "/moon", cbSvc: &cbSvcFake{}, want: "/status [product] print the state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funds := decimal.NewFromInt(100)
+			if !tt.numberOwn.IsZero() {
+				funds = decimal.Zero
+			}
+			state := svc.NewStateSvc(nil).NewState("BTC-USD", funds)
+			state.NumberOwn = tt.numberOwn
+			state.BuyPrice = tt.buyPrice
+			state.RealizedPnL = tt.realized
+			bot := NewBot("secret", []int64{42}, tt.cbSvc, state)
+
+			got := bot.command(context.Background(), tt.text)
+			assert.Contains(t, got, tt.want)
+		})
+	}
+}
+
+func TestBot_Run_config(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(NewBot("", []int64{42}, &cbSvcFake{}).Run(context.Background()))
+	assert.NotNil(NewBot("secret", nil, &cbSvcFake{}).Run(context.Background()))
+}